@@ -2,10 +2,12 @@ package gitobj
 
 import (
 	"bufio"
+	"fmt"
 	"hash"
 	"io"
 	"os"
-	"path"
+	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,6 +21,9 @@ import (
 // `alternates` variable. The syntax is that of the Git environment variable
 // GIT_ALTERNATE_OBJECT_DIRECTORIES.  The hash algorithm used is specified by
 // the algo parameter.
+//
+// The object directories listed in root's "info/alternates" file, if any, are
+// also read from, after root itself. Objects are only ever written to root.
 func NewFilesystemBackend(root, tmp, alternates string, algo hash.Hash) (storage.Backend, error) {
 	fsobj := newFileStorer(root, tmp)
 	packs, err := pack.NewStorage(root, algo)
@@ -46,10 +51,10 @@ func findAllBackends(mainLoose *fileStorer, mainPacked *pack.Storage, root strin
 	storage := make([]storage.Storage, 2)
 	storage[0] = mainLoose
 	storage[1] = mainPacked
-	f, err := os.Open(path.Join(root, "info", "alternates"))
+	f, err := os.Open(filepath.Join(root, "info", "alternates"))
 	if err != nil {
 		// No alternates file, no problem.
-		if err != os.ErrNotExist {
+		if os.IsNotExist(err) {
 			return storage, nil
 		}
 		return nil, err
@@ -58,7 +63,19 @@ func findAllBackends(mainLoose *fileStorer, mainPacked *pack.Storage, root strin
 
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		storage, err = addAlternateDirectory(storage, scanner.Text(), algo)
+		// As in Git, blank lines and comments are ignored, and relative
+		// paths are taken relative to the object directory.
+		line := scanner.Text()
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		dir := splitAlternateString(line, "\n")[0]
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+
+		storage, err = addAlternateDirectory(storage, dir, algo)
 		if err != nil {
 			return nil, err
 		}
@@ -141,6 +158,36 @@ func splitAlternateString(env string, separator string) []string {
 	return dirs
 }
 
+// NewGitBackend initializes a new backend which reads objects by way of a "git
+// cat-file --batch" subprocess running against the repository whose ".git"
+// directory is given by "gitDir". This allows objects to be read from any
+// repository which Git itself can read, even one using features which gitobj
+// does not implement, while still decoding them with gitobj's own types.
+//
+// Objects are written as loose objects to the repository's object directory,
+// where the subprocess will find them. The subprocess is shut down when the
+// backend's storage is closed.
+func NewGitBackend(gitDir string) (storage.Backend, error) {
+	out, err := exec.Command("git", "--git-dir", gitDir, "rev-parse", "--git-path", "objects").Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("gitobj: could not find object directory of %s: %s",
+				gitDir, strings.TrimSpace(string(exit.Stderr)))
+		}
+		return nil, fmt.Errorf("gitobj: could not find object directory of %s: %s", gitDir, err)
+	}
+
+	git, err := newGitStorer(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitBackend{
+		git: git,
+		fs:  newFileStorer(strings.TrimSpace(string(out)), ""),
+	}, nil
+}
+
 // NewMemoryBackend initializes a new memory-based backend.
 //
 // A value of "nil" is acceptable and indicates that no entries should be added
@@ -158,6 +205,15 @@ func (b *filesystemBackend) Storage() (storage.Storage, storage.WritableStorage)
 	return storage.MultiStorage(b.backends...), b.fs
 }
 
+type gitBackend struct {
+	git *gitStorer
+	fs  *fileStorer
+}
+
+func (b *gitBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return b.git, b.fs
+}
+
 type memoryBackend struct {
 	ms *memoryStorer
 }
@@ -2,4 +2,21 @@
 
 package gitobj
 
+import "os"
+
 const alternatesSeparator = ":"
+
+// looseObjectPerm is the permissions with which loose object files are created,
+// less the process's umask. As with Git, objects are read-only.
+const looseObjectPerm = 0444
+
+// syncDir flushes the entries of the directory "dir" to stable storage.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return f.Sync()
+}
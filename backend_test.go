@@ -5,10 +5,16 @@ import (
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/git-lfs/gitobj/v2/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMemoryBackend(t *testing.T) {
@@ -86,3 +92,339 @@ func TestSplitAlternatesString(t *testing.T) {
 		}
 	}
 }
+
+func TestNewGitBackend(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	oid := runTestGit(t, dir, "Hello, world!\n", "hash-object", "-w", "--stdin")
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := hex.DecodeString(oid)
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+
+	assert.Equal(t, int64(14), blob.Size)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestNewGitBackendMissingObject(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	oid := runTestGit(t, dir, "Hello, world!\n", "hash-object", "-w", "--stdin")
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer odb.Close()
+
+	_, err = odb.Blob([]byte("aaaaaaaaaaaaaaaaaaaa"))
+	assert.True(t, errors.IsNoSuchObject(err))
+
+	// The subprocess remains usable after a missing object.
+	sha, err := hex.DecodeString(oid)
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	assert.Equal(t, int64(14), blob.Size)
+}
+
+func TestNewGitBackendInterleavedReads(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	x := runTestGit(t, dir, "x", "hash-object", "-w", "--stdin")
+	y := runTestGit(t, dir, strings.Repeat("y", 1<<20), "hash-object", "-w", "--stdin")
+
+	git, err := newGitStorer(dir)
+	require.NoError(t, err)
+	defer git.Close()
+
+	xsha, err := hex.DecodeString(x)
+	require.NoError(t, err)
+	ysha, err := hex.DecodeString(y)
+	require.NoError(t, err)
+
+	// Begin reading the second object, then open the first, and another
+	// copy of the second, before it has been read to its end.
+	yr, err := git.Open(ysha)
+	require.NoError(t, err)
+	prefix := make([]byte, 16)
+	_, err = io.ReadFull(yr, prefix)
+	require.NoError(t, err)
+
+	xr, err := git.Open(xsha)
+	require.NoError(t, err)
+	defer xr.Close()
+
+	abandoned, err := git.Open(ysha)
+	require.NoError(t, err)
+	require.NoError(t, abandoned.Close())
+
+	rest, err := ioutil.ReadAll(yr)
+	require.NoError(t, err)
+	require.NoError(t, yr.Close())
+	assert.Equal(t, "blob 1048576\x00"+strings.Repeat("y", 1<<20), string(prefix)+string(rest))
+
+	contents, err := ioutil.ReadAll(xr)
+	require.NoError(t, err)
+	assert.Equal(t, "blob 1\x00x", string(contents))
+
+	// The subprocess remains usable after an object is closed unread.
+	xr, err = git.Open(xsha)
+	require.NoError(t, err)
+	contents, err = ioutil.ReadAll(xr)
+	require.NoError(t, err)
+	assert.Equal(t, "blob 1\x00x", string(contents))
+}
+
+func TestNewGitBackendHas(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	oid := runTestGit(t, dir, "Hello, world!\n", "hash-object", "-w", "--stdin")
+
+	git, err := newGitStorer(dir)
+	require.NoError(t, err)
+	defer git.Close()
+
+	sha, err := hex.DecodeString(oid)
+	require.NoError(t, err)
+
+	ok, err := git.Has(sha)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = git.Has([]byte("aaaaaaaaaaaaaaaaaaaa"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestNewGitBackendWritesLooseObjects(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Goodbye, world!\n", runTestGit(t, dir, "", "cat-file", "blob", hex.EncodeToString(sha))+"\n")
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	assert.Equal(t, int64(16), blob.Size)
+}
+
+func TestNewGitBackendNotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	backend, err := NewGitBackend(dir)
+
+	assert.Error(t, err)
+	assert.Nil(t, backend)
+}
+
+func TestFilesystemBackendRescansPacksAfterRepack(t *testing.T) {
+	dir := newTestGitDir(t)
+	objects := filepath.Join(dir, "objects")
+
+	x := runTestGit(t, dir, "x", "hash-object", "-w", "--stdin")
+	old := runTestGit(t, dir, x+"\n", "pack-objects", "-q", filepath.Join(objects, "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+
+	odb, err := FromFilesystem(objects, "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	oid, err := hex.DecodeString(x)
+	require.NoError(t, err)
+
+	// Open an object from the original packfile, but do not read it until
+	// the packfile has been removed.
+	blob, err := odb.Blob(oid)
+	require.NoError(t, err)
+
+	// Repack both objects into a new packfile, and remove the original,
+	// as "git gc" would.
+	y := runTestGit(t, dir, "y", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, x+"\n"+y+"\n", "pack-objects", "-q", filepath.Join(objects, "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+	for _, ext := range []string{"pack", "idx"} {
+		require.NoError(t, os.Remove(filepath.Join(objects, "pack", "pack-"+old+"."+ext)))
+	}
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(contents))
+
+	for name, want := range map[string]string{x: "x", y: "y"} {
+		oid, err := hex.DecodeString(name)
+		require.NoError(t, err)
+
+		blob, err := odb.Blob(oid)
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(blob.Contents)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(contents))
+	}
+}
+
+func TestFilesystemBackendRescanMissingObject(t *testing.T) {
+	dir := newTestGitDir(t)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	oid, err := hex.DecodeString("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	require.NoError(t, err)
+
+	_, err = odb.Blob(oid)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestFilesystemBackendAlternates(t *testing.T) {
+	borrowed := newTestGitDir(t)
+	defer os.RemoveAll(borrowed)
+	packed := newTestGitDir(t)
+	defer os.RemoveAll(packed)
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	x := runTestGit(t, borrowed, "x", "hash-object", "-w", "--stdin")
+	y := runTestGit(t, packed, "y", "hash-object", "-w", "--stdin")
+	runTestGit(t, packed, y+"\n", "pack-objects", "-q", filepath.Join(packed, "objects", "pack", "pack"))
+	runTestGit(t, packed, "", "prune-packed")
+
+	// Refer to one alternate by its absolute path, and the other relative
+	// to the object directory.
+	relative, err := filepath.Rel(filepath.Join(dir, "objects"), filepath.Join(packed, "objects"))
+	require.NoError(t, err)
+
+	alternates := "# borrowed objects\n" + filepath.Join(borrowed, "objects") + "\n\n" + relative + "\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "objects", "info", "alternates"), []byte(alternates), 0644))
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	for name, want := range map[string]string{x: "x", y: "y"} {
+		oid, err := hex.DecodeString(name)
+		require.NoError(t, err)
+
+		blob, err := odb.Blob(oid)
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(blob.Contents)
+		require.NoError(t, err)
+		require.NoError(t, blob.Close())
+		assert.Equal(t, want, string(contents))
+	}
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("z")))
+	require.NoError(t, err)
+
+	z := hex.EncodeToString(sha)
+	assert.FileExists(t, filepath.Join(dir, "objects", z[:2], z[2:]))
+	for _, alternate := range []string{borrowed, packed} {
+		_, err := os.Stat(filepath.Join(alternate, "objects", z[:2], z[2:]))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestFilesystemBackendAlternatesFromOption(t *testing.T) {
+	borrowed := newTestGitDir(t)
+	defer os.RemoveAll(borrowed)
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	x := runTestGit(t, borrowed, "x", "hash-object", "-w", "--stdin")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "",
+		Alternates(`"`+filepath.Join(borrowed, "objects")+`"`))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	oid, err := hex.DecodeString(x)
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(oid)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(contents))
+}
+
+func TestFilesystemBackendUnreadableAlternates(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	// A directory in place of the alternates file cannot be read.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "objects", "info", "alternates"), 0755))
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+
+	assert.Error(t, err)
+	assert.Nil(t, odb)
+}
+
+// newTestGitDir initializes a new, bare Git repository in a temporary
+// directory and returns its path, or skips the test if Git is not installed.
+func newTestGitDir(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+
+	runTestGit(t, dir, "", "init", "--quiet", "--bare")
+	return dir
+}
+
+// runTestGit runs Git with the given arguments and standard input against the
+// repository in "dir", and returns its output without its trailing newline.
+func runTestGit(t *testing.T, dir, stdin string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"--git-dir", dir}, args...)...)
+	cmd.Stdin = strings.NewReader(stdin)
+
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	return strings.TrimSuffix(string(out), "\n")
+}
@@ -3,3 +3,14 @@
 package gitobj
 
 const alternatesSeparator = ";"
+
+// looseObjectPerm is the permissions with which loose object files are created.
+// Windows would mark a file created read-only as such, and refuse to remove it
+// if it could not be moved into place, so they are left writable.
+const looseObjectPerm = 0600
+
+// syncDir flushes the entries of the directory "dir" to stable storage. Windows
+// does not support syncing directories, so it does nothing.
+func syncDir(dir string) error {
+	return nil
+}
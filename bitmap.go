@@ -0,0 +1,44 @@
+package gitobj
+
+// ReachabilityBitmap is the set of the objects reachable from a set of tips, as
+// built by BuildBitmap.
+//
+// Despite its name, it is a plain set of object IDs: it is not stored in the
+// compressed form of Git's ".bitmap" files, and cannot be combined with other
+// bitmaps.
+//
+// A *ReachabilityBitmap is not safe for concurrent modification, but may be
+// queried concurrently once built.
+type ReachabilityBitmap struct {
+	// objects is the set of object IDs in the bitmap.
+	objects map[string]struct{}
+}
+
+// BuildBitmap walks all objects reachable from the given tips (including the
+// tips themselves) and returns a bitmap of them, which can be used to answer
+// repeated reachability and counting queries without walking the graph again.
+//
+// If any reachable object could not be read, an error is returned instead.
+func (o *ObjectDatabase) BuildBitmap(tips [][]byte) (*ReachabilityBitmap, error) {
+	bitmap := &ReachabilityBitmap{objects: make(map[string]struct{})}
+
+	err := o.walkReachable(tips, func(sha []byte, typ ObjectType) error {
+		bitmap.objects[string(sha)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bitmap, nil
+}
+
+// Contains returns whether the object named "sha" is in the bitmap.
+func (b *ReachabilityBitmap) Contains(sha []byte) bool {
+	_, ok := b.objects[string(sha)]
+	return ok
+}
+
+// Count returns the number of objects in the bitmap.
+func (b *ReachabilityBitmap) Count() int {
+	return len(b.objects)
+}
@@ -0,0 +1,138 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildBitmap(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+		{Name: "submodule", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+		{Name: "subdir", Oid: subtree, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root")
+	child := writeTestCommit(t, odb, tree, "child", root)
+	unrelated := writeTestCommit(t, odb, subtree, "unrelated")
+
+	bitmap, err := odb.BuildBitmap([][]byte{child})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, bitmap.Count())
+	for _, sha := range [][]byte{blob, subtree, tree, root, child} {
+		assert.True(t, bitmap.Contains(sha))
+	}
+	assert.False(t, bitmap.Contains(unrelated))
+	assert.False(t, bitmap.Contains([]byte("aaaaaaaaaaaaaaaaaaaa")))
+
+	other, err := odb.BuildBitmap([][]byte{unrelated})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, other.Count())
+	assert.True(t, other.Contains(unrelated))
+	assert.False(t, other.Contains(child))
+	assert.False(t, bitmap.Contains(unrelated))
+}
+
+func TestHasBitmapWrittenByGit(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	assert.False(t, odb.HasBitmap())
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	tip := writeDatedCommit(t, odb, 1, map[string][]byte{"hello.txt": blob})
+	runTestGit(t, dir, "", "update-ref", "refs/heads/main", hex.EncodeToString(tip))
+	runTestGit(t, dir, "", "repack", "-a", "-d", "-b", "-q")
+	require.True(t, odb.rescan())
+
+	assert.True(t, odb.HasBitmap())
+}
+
+func TestBuildBitmapMissingObject(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	bitmap, err := odb.BuildBitmap([][]byte{make([]byte, 20)})
+	assert.Error(t, err)
+	assert.Nil(t, bitmap)
+}
+
+func TestBuildBitmapUsesPackBitmaps(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root")
+	child := writeTestCommit(t, odb, tree, "child", root)
+
+	// "root" is bitmapped, and its bitmap names an object which is not in
+	// the database, showing that its history is not walked.
+	packed := []byte("bbbbbbbbbbbbbbbbbbbb")
+	odb.ro = &bitmappedStorage{
+		Storage: odb.ro,
+		reachable: map[string][][]byte{
+			string(root): {root, tree, packed},
+		},
+	}
+
+	bitmap, err := odb.BuildBitmap([][]byte{child})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, bitmap.Count())
+	for _, sha := range [][]byte{child, tree, root, packed} {
+		assert.True(t, bitmap.Contains(sha))
+	}
+}
+
+// bitmappedStorage is a storage.Storage which answers reachability queries
+// from a fixed set of bitmaps.
+type bitmappedStorage struct {
+	storage.Storage
+
+	// reachable maps the ID of each bitmapped commit to the objects
+	// reachable from it.
+	reachable map[string][][]byte
+}
+
+func (s *bitmappedStorage) HasBitmap() bool {
+	return len(s.reachable) > 0
+}
+
+func (s *bitmappedStorage) Reachable(oid []byte, fn pack.ReachableFn) (bool, error) {
+	objects, ok := s.reachable[string(oid)]
+	if !ok {
+		return false, nil
+	}
+
+	for _, sha := range objects {
+		if err := fn(sha, pack.TypeNone); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 	"os"
 )
 
@@ -20,13 +21,46 @@ type Blob struct {
 	// the Blob.  In particular, this will close a file, if the Blob is
 	// being read from a file on disk.
 	closeFn func() error
+	// openFn is a function that returns a new reader of the blob's
+	// contents, or nil if they may only be read once.
+	openFn func() (io.ReadCloser, error)
 }
 
+// errSingleUseBlob is returned by Blob.Reader for a blob whose contents may
+// only be read once.
+var errSingleUseBlob = fmt.Errorf("gitobj: blob contents may only be read once")
+
 // NewBlobFromBytes returns a new *Blob that yields the data given.
 func NewBlobFromBytes(contents []byte) *Blob {
 	return &Blob{
 		Contents: bytes.NewReader(contents),
 		Size:     int64(len(contents)),
+
+		openFn: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(contents)), nil
+		},
+	}
+}
+
+// NewBlobFromReader returns a new *Blob that yields the first "size" bytes of
+// "r", which is not read until the blob is encoded to the object database, so
+// its contents need not be held in memory. The size must be known in advance,
+// since it is written before the contents.
+//
+// When the blob receives a function call Close(), "r" will also be closed if it
+// implements io.Closer, and any error encountered in doing so will be returned
+// from Close(). Otherwise, closing the blob does nothing.
+func NewBlobFromReader(r io.Reader, size int64) *Blob {
+	return &Blob{
+		Contents: io.LimitReader(r, size),
+		Size:     size,
+
+		closeFn: func() error {
+			if closer, ok := r.(io.Closer); ok {
+				return closer.Close()
+			}
+			return nil
+		},
 	}
 }
 
@@ -48,6 +82,7 @@ func NewBlobFromFile(path string) (*Blob, error) {
 
 	stat, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, fmt.Errorf("gitobj: could not stat %s: %s", path,
 			err)
 	}
@@ -64,6 +99,14 @@ func NewBlobFromFile(path string) (*Blob, error) {
 			}
 			return nil
 		},
+		openFn: func() (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("gitobj: could not open: %s: %s", path,
+					err)
+			}
+			return f, nil
+		},
 	}, nil
 }
 
@@ -76,7 +119,7 @@ func (b *Blob) Type() ObjectType { return BlobObjectType }
 // stream, which is always zero.
 //
 // If any errors are encountered while reading the blob, they will be returned.
-func (b *Blob) Decode(hash hash.Hash, r io.Reader, size int64) (n int, err error) {
+func (b *Blob) Decode(hash hash.Hash, r io.Reader, size int64) (n int64, err error) {
 	b.Size = size
 	b.Contents = io.LimitReader(r, size)
 
@@ -94,10 +137,24 @@ func (b *Blob) Decode(hash hash.Hash, r io.Reader, size int64) (n int, err error
 // any error copying the blob's contents, that error will be returned.
 //
 // Otherwise, the number of bytes written will be returned.
-func (b *Blob) Encode(to io.Writer) (n int, err error) {
-	nn, err := io.Copy(to, b.Contents)
+func (b *Blob) Encode(to io.Writer) (n int64, err error) {
+	return io.Copy(to, b.Contents)
+}
 
-	return int(nn), err
+// Reader returns a new reader of the blob's contents from their beginning,
+// independent of Contents and of any other reader returned, which must be
+// closed by the caller. This allows the contents of a blob created by
+// NewBlobFromBytes or NewBlobFromFile to be read more than once, such as to
+// hash them before writing them, by re-reading the given data or re-opening the
+// file, respectively.
+//
+// The contents of any other blob, such as one read from the object database,
+// may only be read once, from Contents, and an error is returned instead.
+func (b *Blob) Reader() (io.ReadCloser, error) {
+	if b.openFn == nil {
+		return nil, errSingleUseBlob
+	}
+	return b.openFn()
 }
 
 // Closes closes any resources held by the open Blob, or returns nil if there
@@ -0,0 +1,64 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// LinesOption is an option which modifies how BlobLines splits a blob.
+type LinesOption func(*linesOptions)
+
+// linesOptions holds the set of options given to BlobLines.
+type linesOptions struct {
+	keepNewlines bool
+}
+
+// KeepNewlines is a LinesOption which preserves the trailing newline of each
+// line returned by BlobLines. By default, newlines are stripped.
+func KeepNewlines() LinesOption {
+	return func(args *linesOptions) {
+		args.keepNewlines = true
+	}
+}
+
+// BlobLines reads the blob named "sha" and splits its contents into lines.
+//
+// A final line which is not terminated by a newline is returned as-is, and an
+// empty blob yields no lines at all. BlobLines is intended for small text blobs
+// (like ".gitattributes", or ".gitmodules") and holds all of the blob's lines in
+// memory. For large blobs, read the *Blob returned by Blob() instead.
+//
+// If the blob could not be opened or read, an error is returned.
+func (o *ObjectDatabase) BlobLines(sha []byte, setters ...LinesOption) ([][]byte, error) {
+	args := new(linesOptions)
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	blob, err := o.Blob(sha)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	var lines [][]byte
+
+	r := bufio.NewReader(blob.Contents)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if !args.keepNewlines {
+				line = bytes.TrimSuffix(line, []byte("\n"))
+			}
+			lines = append(lines, line)
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,47 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobLines(t *testing.T) {
+	for desc, c := range map[string]struct {
+		Contents string
+		Options  []LinesOption
+		Expected []string
+	}{
+		"empty":                {"", nil, nil},
+		"trailing newline":     {"a\nb\n", nil, []string{"a", "b"}},
+		"no trailing newline":  {"a\nb", nil, []string{"a", "b"}},
+		"blank lines":          {"a\n\nb\n", nil, []string{"a", "", "b"}},
+		"keep newlines":        {"a\nb\n", []LinesOption{KeepNewlines()}, []string{"a\n", "b\n"}},
+		"keep missing newline": {"a\nb", []LinesOption{KeepNewlines()}, []string{"a\n", "b"}},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			odb := newTestDatabase(t, ImplicitEmptyObjects(false))
+
+			sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(c.Contents)))
+			require.NoError(t, err)
+
+			lines, err := odb.BlobLines(sha, c.Options...)
+			require.NoError(t, err)
+
+			var got []string
+			for _, line := range lines {
+				got = append(got, string(line))
+			}
+			assert.Equal(t, c.Expected, got)
+		})
+	}
+}
+
+func TestBlobLinesMissingBlob(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	lines, err := odb.BlobLines(make([]byte, 20))
+	assert.Error(t, err)
+	assert.Nil(t, lines)
+}
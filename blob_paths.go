@@ -0,0 +1,65 @@
+package gitobj
+
+import "bytes"
+
+// FindBlobPaths returns every path in the tree of the commit named "commit"
+// whose entry refers to the blob named "blob", in tree order. Since the same
+// contents may be stored at more than one path, there may be several such
+// paths, or none.
+//
+// The tree is traversed once, and a subtree which appears at more than one path
+// is only read the first time it is encountered.
+//
+// If the commit or any of its trees could not be read, an error is returned
+// instead.
+func (o *ObjectDatabase) FindBlobPaths(commit []byte, blob []byte) ([]string, error) {
+	c, err := o.Commit(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := o.findBlobPaths(c.TreeID, blob, make(map[string][]string))
+	if err != nil {
+		return nil, err
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+	return paths, nil
+}
+
+// findBlobPaths returns the paths relative to the tree named "tree" at which
+// the blob "blob" is found. The result for each subtree is recorded in "seen",
+// so that it is not traversed again.
+func (o *ObjectDatabase) findBlobPaths(tree, blob []byte, seen map[string][]string) ([]string, error) {
+	if paths, ok := seen[string(tree)]; ok {
+		return paths, nil
+	}
+
+	t, err := o.Tree(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range t.Entries {
+		switch entry.Type() {
+		case BlobObjectType:
+			if bytes.Equal(entry.Oid, blob) {
+				paths = append(paths, entry.Name)
+			}
+		case TreeObjectType:
+			sub, err := o.findBlobPaths(entry.Oid, blob, seen)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, path := range sub {
+				paths = append(paths, entry.Name+"/"+path)
+			}
+		}
+	}
+
+	seen[string(tree)] = paths
+	return paths, nil
+}
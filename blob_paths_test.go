@@ -0,0 +1,62 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBlobPaths(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	other, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "copy.txt", Oid: hello, Filemode: 0100644},
+		{Name: "other.txt", Oid: other, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a", Oid: subtree, Filemode: 040000},
+		{Name: "b", Oid: subtree, Filemode: 040000},
+		{Name: "hello.txt", Oid: hello, Filemode: 0100644},
+		{Name: "link", Oid: hello, Filemode: 0120000},
+		{Name: "submodule", Oid: hello, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	commit := writeTestCommit(t, odb, tree, "commit")
+
+	paths, err := odb.FindBlobPaths(commit, hello)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/copy.txt", "b/copy.txt", "hello.txt", "link"}, paths)
+}
+
+func TestFindBlobPathsNoMatches(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	commit := writeTestCommit(t, odb, tree, "commit")
+
+	paths, err := odb.FindBlobPaths(commit, []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, paths)
+}
+
+func TestFindBlobPathsMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	paths, err := odb.FindBlobPaths([]byte("aaaaaaaaaaaaaaaaaaaa"), []byte("bbbbbbbbbbbbbbbbbbbb"))
+
+	assert.Error(t, err)
+	assert.Nil(t, paths)
+}
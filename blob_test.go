@@ -5,11 +5,14 @@ import (
 	"crypto/sha1"
 	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBlobReturnsCorrectObjectType(t *testing.T) {
@@ -29,6 +32,80 @@ func TestBlobFromString(t *testing.T) {
 	assert.Equal(t, given, contents)
 }
 
+func TestBlobFromReader(t *testing.T) {
+	b := NewBlobFromReader(strings.NewReader("example, and more"), 7)
+
+	assert.EqualValues(t, 7, b.Size)
+
+	contents, err := ioutil.ReadAll(b.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("example"), contents)
+
+	assert.NoError(t, b.Close())
+}
+
+func TestBlobFromReaderClosesReader(t *testing.T) {
+	var calls uint32
+	expected := errors.New("some close error")
+
+	b := NewBlobFromReader(&ReadCloserFn{
+		Reader: strings.NewReader("example"),
+		closeFn: func() error {
+			atomic.AddUint32(&calls, 1)
+			return expected
+		},
+	}, 7)
+
+	assert.Equal(t, expected, b.Close())
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestBlobReaderFromBytes(t *testing.T) {
+	b := NewBlobFromBytes([]byte("example"))
+
+	for i := 0; i < 2; i++ {
+		r, err := b.Reader()
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("example"), contents)
+		assert.NoError(t, r.Close())
+	}
+}
+
+func TestBlobReaderFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example")
+	require.NoError(t, ioutil.WriteFile(path, []byte("example"), 0644))
+
+	b, err := NewBlobFromFile(path)
+	require.NoError(t, err)
+	defer b.Close()
+
+	contents, err := ioutil.ReadAll(b.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("example"), contents)
+
+	r, err := b.Reader()
+	require.NoError(t, err)
+	defer r.Close()
+
+	contents, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("example"), contents)
+}
+
+func TestBlobReaderSingleUse(t *testing.T) {
+	b := NewBlobFromReader(strings.NewReader("example"), 7)
+
+	_, err := b.Reader()
+	assert.EqualError(t, err, "gitobj: blob contents may only be read once")
+}
+
 func TestBlobEncoding(t *testing.T) {
 	const contents = "Hello, world!\n"
 
@@ -51,7 +128,7 @@ func TestBlobDecoding(t *testing.T) {
 	b := new(Blob)
 	n, err := b.Decode(sha1.New(), from, int64(len(contents)))
 
-	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(0), n)
 	assert.Nil(t, err)
 
 	assert.EqualValues(t, len(contents), b.Size)
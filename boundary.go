@@ -0,0 +1,174 @@
+package gitobj
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// paintFlags records from which side of a painting walk (see: paint) a commit
+// is reachable.
+type paintFlags uint8
+
+const (
+	// paintedFrom indicates that a commit is reachable from the commits
+	// excluded by the walk.
+	paintedFrom paintFlags = 1 << iota
+	// paintedTo indicates that a commit is reachable from the commits
+	// included by the walk.
+	paintedTo
+)
+
+// paintedCommit is a commit visited by a painting walk.
+type paintedCommit struct {
+	// parents are the parents of the commit followed by the walk (see:
+	// ObjectDatabase.parents).
+	parents [][]byte
+	// queued is the position of the commit in the walk.
+	queued *queuedCommit
+	// flags is the set of sides from which the commit is reachable.
+	flags paintFlags
+}
+
+// paint walks the history of the commits "from" and "to" newest-first by commit
+// date, and marks each commit it visits with the sides from which it is
+// reachable, as for a merge base or a range. Each side's marks are carried from
+// a commit to its parents, and a commit given a new mark after it was visited
+// is visited again to carry it further.
+//
+// The walk stops once every commit still to be visited is reachable from
+// "from", since no commit beyond them can be reachable from "to" alone. As in
+// Git, commits whose dates are skewed far enough may therefore be misjudged.
+//
+// The parents and date of each commit are given by "visit", which is
+// visitCommit unless they can be found without reading the commit. If "visit"
+// returns an error, the walk stops, and that error is returned.
+func (o *ObjectDatabase) paint(from, to [][]byte, visit visitFn) (map[string]*paintedCommit, error) {
+	painted := make(map[string]*paintedCommit)
+	queue := new(commitQueue)
+
+	mark := func(sha []byte, flags paintFlags) error {
+		p, ok := painted[string(sha)]
+		if !ok {
+			parents, when, err := visit(sha)
+			if err != nil {
+				return err
+			}
+
+			p = &paintedCommit{
+				parents: parents,
+				queued:  &queuedCommit{sha: sha, when: when, seq: queue.seq},
+			}
+			queue.seq++
+
+			painted[string(sha)] = p
+		}
+
+		if p.flags&flags == flags {
+			return nil
+		}
+		p.flags |= flags
+
+		heap.Push(queue, p.queued)
+		return nil
+	}
+
+	for _, sha := range from {
+		if err := mark(sha, paintedFrom); err != nil {
+			return nil, err
+		}
+	}
+	for _, sha := range to {
+		if err := mark(sha, paintedTo); err != nil {
+			return nil, err
+		}
+	}
+
+	// wanted returns whether any commit still to be visited is not
+	// reachable from "from".
+	wanted := func() bool {
+		for _, q := range queue.commits {
+			if painted[string(q.sha)].flags&paintedFrom == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for queue.Len() > 0 && wanted() {
+		p := painted[string(heap.Pop(queue).(*queuedCommit).sha)]
+
+		for _, parent := range p.parents {
+			if err := mark(parent, p.flags); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return painted, nil
+}
+
+// visitFn returns the parents of the commit "sha" followed by a painting walk,
+// and the time at which it was committed.
+type visitFn func(sha []byte) (parents [][]byte, when time.Time, err error)
+
+// visitCommit is a visitFn which reads the commit "sha". If the commit could
+// not be read, or its committer could not be parsed, an error is returned.
+func (o *ObjectDatabase) visitCommit(sha []byte) ([][]byte, time.Time, error) {
+	c, err := o.Commit(sha)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	committer, err := ParseSignature(c.Committer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	parents, err := o.parents(sha, c)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return parents, committer.When, nil
+}
+
+// RangeBoundary returns the boundary commits of the range "from..to", as listed
+// by "git log --boundary from..to": the commits reachable from "from" which are
+// parents of commits in the range (that is, commits reachable from "to", but
+// not from "from"). They are ordered newest-first by commit date, with each
+// commit given once. A range which is empty has no boundary.
+//
+// The range is found by painting the history of both commits, as for a merge
+// base (see: paint), so only as much history as is needed is read.
+//
+// If any commit could not be read, or the committer of any commit could not be
+// parsed, an error is returned instead.
+func (o *ObjectDatabase) RangeBoundary(from, to []byte) ([][]byte, error) {
+	painted, err := o.paint([][]byte{from}, [][]byte{to}, o.visitCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	var boundary []*queuedCommit
+	seen := make(map[string]bool)
+	for _, p := range painted {
+		if p.flags != paintedTo {
+			continue
+		}
+
+		for _, parent := range p.parents {
+			q, ok := painted[string(parent)]
+			if !ok || q.flags&paintedFrom == 0 || seen[string(parent)] {
+				continue
+			}
+			seen[string(parent)] = true
+
+			boundary = append(boundary, q.queued)
+		}
+	}
+
+	sort.Sort(&commitQueue{commits: boundary})
+
+	var commits [][]byte
+	for _, q := range boundary {
+		commits = append(commits, q.sha)
+	}
+	return commits, nil
+}
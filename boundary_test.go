@@ -0,0 +1,82 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeBoundary(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	base := writeDatedCommit(t, odb, 2, nil, root)
+	main := writeDatedCommit(t, odb, 3, nil, base)
+	topic := writeDatedCommit(t, odb, 4, nil, base)
+	tip := writeDatedCommit(t, odb, 5, nil, topic)
+
+	boundary, err := odb.RangeBoundary(main, tip)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{base}, boundary)
+}
+
+func TestRangeBoundaryWithMerge(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	//   a---b---c (from)
+	//    \   \
+	//     d---e---f (to)
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil, a)
+	c := writeDatedCommit(t, odb, 3, nil, b)
+	d := writeDatedCommit(t, odb, 4, nil, a)
+	e := writeDatedCommit(t, odb, 5, nil, d, b)
+	f := writeDatedCommit(t, odb, 6, nil, e)
+
+	boundary, err := odb.RangeBoundary(c, f)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{b, a}, boundary)
+}
+
+func TestRangeBoundaryAncestor(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil, a)
+	c := writeDatedCommit(t, odb, 3, nil, b)
+
+	boundary, err := odb.RangeBoundary(a, c)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{a}, boundary)
+
+	// The range "c..a" is empty, so has no boundary.
+	boundary, err = odb.RangeBoundary(c, a)
+	assert.NoError(t, err)
+	assert.Empty(t, boundary)
+}
+
+func TestRangeBoundaryUnrelated(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil)
+
+	boundary, err := odb.RangeBoundary(a, b)
+
+	assert.NoError(t, err)
+	assert.Empty(t, boundary)
+}
+
+func TestRangeBoundaryMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+
+	boundary, err := odb.RangeBoundary(a, []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	require.Error(t, err)
+	assert.Nil(t, boundary)
+}
@@ -0,0 +1,197 @@
+package gitobj
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckoutOptions controls the behavior of Checkout.
+type CheckoutOptions struct {
+	// Overwrite indicates whether anything already present at a path
+	// being written is replaced. If false, finding anything other than a
+	// directory at such a path is an error, and nothing already present
+	// is modified.
+	Overwrite bool
+	// SymlinksAsFiles indicates whether symbolic links are written as
+	// regular files containing the path that they point to, as Git does
+	// when "core.symlinks" is false, rather than as links.
+	SymlinksAsFiles bool
+}
+
+// Checkout writes the contents of the tree named "root" beneath the directory
+// "dir", which is created if it does not exist, as "git archive --format=dir"
+// would. Each subtree is written as a directory, and each blob as a file, which
+// is executable if its entry is. Symbolic links are written as links (or files,
+// see: CheckoutOptions), and gitlinks are skipped, since their commits are not
+// expected to be present in this database. If "opts" is nil, the default
+// options are used.
+//
+// Entries whose names could not safely be written, such as "..", ".git", or any
+// name containing a path separator, are refused, as is writing through a
+// symbolic link already present beneath "dir".
+//
+// If any tree or blob could not be read, any entry could not be written, or an
+// entry is refused, an error is returned, and the entries already written are
+// left in place.
+func (o *ObjectDatabase) Checkout(root []byte, dir string, opts *CheckoutOptions) error {
+	if opts == nil {
+		opts = new(CheckoutOptions)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return o.checkout(root, dir, "", opts)
+}
+
+// checkout writes the contents of the tree named "tree" to the directory "dir",
+// which already exists, and is at the path "prefix" relative to the directory
+// being checked out into.
+func (o *ObjectDatabase) checkout(tree []byte, dir, prefix string, opts *CheckoutOptions) error {
+	t, err := o.Tree(tree)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range t.Entries {
+		path := prefix + entry.Name
+		if !safeEntryName(entry.Name) {
+			return fmt.Errorf("gitobj: refusing to check out unsafe path %q", path)
+		}
+		target := filepath.Join(dir, entry.Name)
+
+		switch entry.Filemode & sIFMT {
+		case sIFDIR:
+			if err = checkoutDir(target, path, opts); err != nil {
+				return err
+			}
+			if err = o.checkout(entry.Oid, target, path+"/", opts); err != nil {
+				return err
+			}
+		case sIFREG:
+			mode := os.FileMode(0644)
+			if entry.Filemode&0111 != 0 {
+				mode = 0755
+			}
+			if err = o.checkoutFile(entry.Oid, target, path, mode, opts); err != nil {
+				return err
+			}
+		case sIFLNK:
+			if opts.SymlinksAsFiles {
+				err = o.checkoutFile(entry.Oid, target, path, 0644, opts)
+			} else {
+				err = o.checkoutLink(entry.Oid, target, path, opts)
+			}
+			if err != nil {
+				return err
+			}
+		case sIFGITLINK:
+			continue
+		default:
+			return fmt.Errorf("gitobj: cannot check out %q with unknown mode %o",
+				path, entry.Filemode)
+		}
+	}
+	return nil
+}
+
+// checkoutDir ensures that there is a directory at "target" (whose path
+// relative to the directory being checked out into is "path"), replacing
+// anything else there if overwriting.
+func checkoutDir(target, path string, opts *CheckoutOptions) error {
+	fi, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return nil
+	case !opts.Overwrite:
+		return fmt.Errorf("gitobj: cannot check out %q: file exists", path)
+	default:
+		if err = os.Remove(target); err != nil {
+			return err
+		}
+	}
+	return os.Mkdir(target, 0755)
+}
+
+// clearPath makes way for a file or link at "target" (whose path relative to
+// the directory being checked out into is "path"), removing anything already
+// there if overwriting.
+func clearPath(target, path string, opts *CheckoutOptions) error {
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !opts.Overwrite {
+		return fmt.Errorf("gitobj: cannot check out %q: file exists", path)
+	}
+	return os.RemoveAll(target)
+}
+
+// checkoutFile writes the contents of the blob named "blob" to a new file at
+// "target" with the given mode.
+func (o *ObjectDatabase) checkoutFile(blob []byte, target, path string, mode os.FileMode, opts *CheckoutOptions) error {
+	b, err := o.Blob(blob)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	if err = clearPath(target, path, opts); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, b.Contents); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkoutLink creates a symbolic link at "target" pointing at the path given
+// by the contents of the blob named "blob".
+func (o *ObjectDatabase) checkoutLink(blob []byte, target, path string, opts *CheckoutOptions) error {
+	b, err := o.Blob(blob)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	dest, err := ioutil.ReadAll(b.Contents)
+	if err != nil {
+		return err
+	}
+
+	if err = clearPath(target, path, opts); err != nil {
+		return err
+	}
+	return os.Symlink(string(dest), target)
+}
+
+// safeEntryName returns whether the tree entry name "name" may be written
+// beneath a directory without escaping it, or writing into a repository's
+// ".git" directory.
+func safeEntryName(name string) bool {
+	switch {
+	case name == "", name == ".", name == "..":
+		return false
+	case strings.EqualFold(name, ".git"):
+		return false
+	case strings.ContainsAny(name, "/\\\x00"):
+		return false
+	}
+	return true
+}
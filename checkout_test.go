@@ -0,0 +1,158 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links and executable bits are not supported")
+	}
+
+	odb, root := newTestCheckoutTree(t)
+	dir := newTestCheckoutDir(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, odb.Checkout(root, dir, nil))
+
+	assertFile(t, filepath.Join(dir, "README.md"), "Hello, world!\n", 0644)
+	assertFile(t, filepath.Join(dir, "bin", "run"), "#!/bin/sh\n", 0755)
+
+	link, err := os.Readlink(filepath.Join(dir, "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, "README.md", link)
+
+	_, err = os.Lstat(filepath.Join(dir, "submodule"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCheckoutSymlinksAsFiles(t *testing.T) {
+	odb, root := newTestCheckoutTree(t)
+	dir := newTestCheckoutDir(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, odb.Checkout(root, dir, &CheckoutOptions{
+		SymlinksAsFiles: true,
+	}))
+
+	fi, err := os.Lstat(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	assert.True(t, fi.Mode().IsRegular())
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, "README.md", string(contents))
+}
+
+func TestCheckoutRefusesToOverwrite(t *testing.T) {
+	odb, root := newTestCheckoutTree(t)
+	dir := newTestCheckoutDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "README.md")
+	require.NoError(t, ioutil.WriteFile(path, []byte("existing\n"), 0644))
+
+	err := odb.Checkout(root, dir, &CheckoutOptions{SymlinksAsFiles: true})
+
+	assert.EqualError(t, err, `gitobj: cannot check out "README.md": file exists`)
+
+	contents, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "existing\n", string(contents))
+}
+
+func TestCheckoutOverwrite(t *testing.T) {
+	odb, root := newTestCheckoutTree(t)
+	dir := newTestCheckoutDir(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("existing\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin"), []byte("not a directory\n"), 0644))
+
+	require.NoError(t, odb.Checkout(root, dir, &CheckoutOptions{
+		Overwrite:       true,
+		SymlinksAsFiles: true,
+	}))
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "README.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "bin", "run"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(contents))
+}
+
+func TestCheckoutRefusesUnsafePaths(t *testing.T) {
+	for _, name := range []string{"..", ".git", ".GIT", "a/b"} {
+		t.Run(name, func(t *testing.T) {
+			odb := newTestDatabase(t)
+			dir := newTestCheckoutDir(t)
+			defer os.RemoveAll(dir)
+
+			root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+				{Name: name, Oid: writeTestBlob(t, odb, "x"), Filemode: 0100644},
+			}})
+			require.NoError(t, err)
+
+			err = odb.Checkout(root, dir, nil)
+
+			assert.EqualError(t, err, "gitobj: refusing to check out unsafe path \""+name+"\"")
+		})
+	}
+}
+
+// newTestCheckoutTree returns a database holding a tree with a file, an
+// executable in a subdirectory, a symbolic link, and a gitlink, along with the
+// ID of that tree.
+func newTestCheckoutTree(t *testing.T) (*ObjectDatabase, []byte) {
+	odb := newTestDatabase(t)
+
+	bin, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "run", Oid: writeTestBlob(t, odb, "#!/bin/sh\n"), Filemode: 0100755},
+	}})
+	require.NoError(t, err)
+
+	entries := []*TreeEntry{
+		{Name: "README.md", Oid: writeTestBlob(t, odb, "Hello, world!\n"), Filemode: 0100644},
+		{Name: "bin", Oid: bin, Filemode: 040000},
+		{Name: "link", Oid: writeTestBlob(t, odb, "README.md"), Filemode: 0120000},
+		{Name: "submodule", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+	}
+	sort.Sort(SubtreeOrder(entries))
+
+	root, err := odb.WriteTree(&Tree{Entries: entries})
+	require.NoError(t, err)
+
+	return odb, root
+}
+
+// newTestCheckoutDir returns a new, empty directory, which should be removed by
+// the caller.
+func newTestCheckoutDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gitobj-checkout")
+	require.NoError(t, err)
+
+	return dir
+}
+
+// assertFile asserts that the regular file at "path" has the given contents and
+// permissions.
+func assertFile(t *testing.T, path, contents string, mode os.FileMode) {
+	fi, err := os.Lstat(path)
+	require.NoError(t, err)
+	assert.True(t, fi.Mode().IsRegular())
+	assert.Equal(t, mode, fi.Mode().Perm())
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, string(data))
+}
@@ -0,0 +1,48 @@
+package gitobj
+
+// CherryPickTree computes the tree which results from applying the changes made
+// by the commit "commit" (against its first parent, or the empty tree if it has
+// none) to the tree of the commit "onto", as "git cherry-pick" would, writes
+// it, and returns its object ID.
+//
+// Changes are merged by path (see: MergeTrees), so changes to different paths
+// always apply. Each path which both "commit" and "onto" changed differently is
+// returned as a conflict instead, in order, and keeps its entry from "onto".
+// The contents of conflicting files are not merged.
+//
+// If either commit, or any of the trees involved, could not be read, an error
+// is returned.
+func (o *ObjectDatabase) CherryPickTree(commit, onto []byte) (treeOID []byte, conflicts []string, err error) {
+	c, err := o.Commit(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent, ok, err := o.firstParent(commit, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var base []byte
+	if ok {
+		p, err := o.Commit(parent)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = p.TreeID
+	}
+
+	target, err := o.Commit(onto)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	treeOID, merged, err := o.MergeTrees(base, target.TreeID, c.TreeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, conflict := range merged {
+		conflicts = append(conflicts, conflict.Path)
+	}
+	return treeOID, conflicts, nil
+}
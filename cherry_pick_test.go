@@ -0,0 +1,83 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCherryPickTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n")
+
+	base := writeDatedCommit(t, odb, 1, map[string][]byte{"a.txt": one, "dir/b.txt": one})
+	pick := writeDatedCommit(t, odb, 2, map[string][]byte{"a.txt": two, "dir/b.txt": one}, base)
+	onto := writeDatedCommit(t, odb, 3, map[string][]byte{"a.txt": one, "dir/b.txt": two, "c.txt": one}, base)
+
+	tree, conflicts, err := odb.CherryPickTree(pick, onto)
+	require.NoError(t, err)
+
+	assert.Empty(t, conflicts)
+	assert.Equal(t, map[string][]byte{
+		"a.txt": two, "c.txt": one, "dir/b.txt": two,
+	}, testTreeFiles(t, odb, tree))
+}
+
+func TestCherryPickTreeConflicts(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two, three := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n"), writeTestBlob(t, odb, "3\n")
+
+	base := writeDatedCommit(t, odb, 1, map[string][]byte{"a.txt": one, "b.txt": one})
+	pick := writeDatedCommit(t, odb, 2, map[string][]byte{"a.txt": two, "b.txt": two}, base)
+	onto := writeDatedCommit(t, odb, 3, map[string][]byte{"a.txt": three, "b.txt": two}, base)
+
+	tree, conflicts, err := odb.CherryPickTree(pick, onto)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a.txt"}, conflicts)
+	assert.Equal(t, map[string][]byte{
+		"a.txt": three, "b.txt": two,
+	}, testTreeFiles(t, odb, tree))
+}
+
+func TestCherryPickTreeRootCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n")
+
+	pick := writeDatedCommit(t, odb, 1, map[string][]byte{"a.txt": one})
+	onto := writeDatedCommit(t, odb, 2, map[string][]byte{"b.txt": two})
+
+	tree, conflicts, err := odb.CherryPickTree(pick, onto)
+	require.NoError(t, err)
+
+	assert.Empty(t, conflicts)
+	assert.Equal(t, map[string][]byte{
+		"a.txt": one, "b.txt": two,
+	}, testTreeFiles(t, odb, tree))
+}
+
+func TestCherryPickTreeMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	onto := writeDatedCommit(t, odb, 1, nil)
+
+	_, _, err := odb.CherryPickTree(make([]byte, 20), onto)
+	assert.Error(t, err)
+}
+
+// testTreeFiles returns the object ID of every file in the tree "sha", keyed by
+// its path.
+func testTreeFiles(t *testing.T, odb *ObjectDatabase, sha []byte) map[string][]byte {
+	files, err := odb.flattenTree(sha)
+	require.NoError(t, err)
+
+	oids := make(map[string][]byte, len(files))
+	for path, entry := range files {
+		oids[path] = entry.Oid
+	}
+	return oids
+}
@@ -3,10 +3,14 @@ package gitobj
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +29,13 @@ type Signature struct {
 	Email string
 	// When is the instant in time when the signature was created.
 	When time.Time
+
+	// raw is the string from which this signature was parsed, if any.
+	raw string
+	// zone is the timezone offset from which When's location was parsed,
+	// if any, which is kept so that offsets written unusually, such as
+	// "-0000", are formatted as they were given.
+	zone string
 }
 
 const (
@@ -35,13 +46,107 @@ const (
 // expected in the Git commit internal object format. For instance:
 //
 //  Taylor Blau <ttaylorr@github.com> 1494258422 -0600
+//
+// A signature returned by ParseSignature keeps its timezone offset exactly as
+// it was given, as long as When's offset is unchanged, so that an offset of
+// "-0000", which Git distinguishes from "+0000", is formatted as such.
 func (s *Signature) String() string {
 	at := s.When.Unix()
 	zone := s.When.Format(formatTimeZoneOnly)
+	if len(s.zone) > 0 {
+		if offset, err := parseTimeZone(s.zone); err == nil && offset == s.offset() {
+			zone = s.zone
+		}
+	}
 
 	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, at, zone)
 }
 
+// OffsetMinutes returns the timezone offset of the signature in minutes east of
+// UTC, as given by the location of When, which is the offset that String()
+// emits. For instance, "+0530" is 330 minutes and "-0930" is -570 minutes.
+func (s *Signature) OffsetMinutes() int {
+	return s.offset() / 60
+}
+
+// offset returns the timezone offset of the signature in seconds east of UTC.
+func (s *Signature) offset() int {
+	_, offset := s.When.Zone()
+	return offset
+}
+
+// ParseSignature parses a signature as it appears in the "author" or
+// "committer" header of a commit (or the "tagger" header of a tag), for
+// instance:
+//
+//  Taylor Blau <ttaylorr@github.com> 1494258422 -0600
+//
+// Some tools emit more than one space (or other whitespace) between the email
+// address, timestamp, and timezone. ParseSignature accepts these, but the
+// returned signature formats using a single space (see: Canonical).
+//
+// If the signature is malformed, an error will be returned instead.
+func ParseSignature(str string) (*Signature, error) {
+	lt := strings.Index(str, "<")
+	gt := strings.LastIndex(str, ">")
+	if lt < 0 || gt < lt {
+		return nil, fmt.Errorf("gitobj: malformed signature: %q", str)
+	}
+
+	fields := strings.Fields(str[gt+1:])
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("gitobj: malformed signature: %q", str)
+	}
+
+	at, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("gitobj: malformed signature timestamp: %q", fields[0])
+	}
+
+	offset, err := parseTimeZone(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signature{
+		Name:  strings.TrimSuffix(str[:lt], " "),
+		Email: str[lt+1 : gt],
+		When:  time.Unix(at, 0).In(time.FixedZone("", offset)),
+
+		raw:  str,
+		zone: fields[1],
+	}, nil
+}
+
+// Canonical returns whether formatting the signature with String() yields
+// exactly the string it was parsed from. A non-canonical signature changes the
+// object ID of the commit or tag it belongs to if it is re-encoded from this
+// type.
+//
+// Signatures which were not created by ParseSignature are always canonical.
+func (s *Signature) Canonical() bool {
+	return len(s.raw) == 0 || s.raw == s.String()
+}
+
+// parseTimeZone parses a timezone offset given as "+hhmm" or "-hhmm" and
+// returns it as a number of seconds east of UTC.
+func parseTimeZone(zone string) (int, error) {
+	if len(zone) != 5 || (zone[0] != '+' && zone[0] != '-') {
+		return 0, fmt.Errorf("gitobj: malformed signature timezone: %q", zone)
+	}
+
+	hhmm, err := strconv.ParseUint(zone[1:], 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("gitobj: malformed signature timezone: %q", zone)
+	}
+
+	offset := int(hhmm/100)*60*60 + int(hhmm%100)*60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
 // ExtraHeader encapsulates a key-value pairing of header key to header value.
 // It is stored as a struct{string, string} in memory as opposed to a
 // map[string]string to maintain ordering in a byte-for-byte encode/decode round
@@ -88,19 +193,41 @@ func (c *Commit) Type() ObjectType { return CommitObjectType }
 
 // Decode implements Object.Decode and decodes the uncompressed commit being
 // read. It returns the number of uncompressed bytes being consumed off of the
-// stream, which should be strictly equal to the size given.
+// stream, which should be strictly equal to the size given. Nothing beyond the
+// given size is read.
 //
 // If any error was encountered along the way, that will be returned, along with
 // the number of bytes read up to that point.
-func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err error) {
+func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int64, err error) {
+	return c.decode(io.LimitReader(from, size), hash.Size())
+}
+
+// DecodeFrom is as Decode, but decodes a commit of unknown size, which extends
+// to the end of the stream, such as one read from a pipe. It returns the number
+// of bytes consumed, which is the size of the commit.
+//
+// If any error was encountered along the way, that will be returned, along with
+// the number of bytes read up to that point.
+func (c *Commit) DecodeFrom(hash hash.Hash, from io.Reader) (int, error) {
+	n, err := c.decode(from, hash.Size())
+	return int(n), err
+}
+
+// decode decodes the commit which extends to the end of "from", whose object
+// IDs are "hashlen" bytes long, and returns the number of bytes consumed.
+func (c *Commit) decode(from io.Reader, hashlen int) (n int64, err error) {
 	var finishedHeaders bool
 	var messageParts []string
 
+	// Git rejects commits with more than one tree, or with a tree after
+	// their parents.
+	var sawTree, sawParent bool
+
 	s := bufio.NewScanner(from)
 	s.Buffer(nil, 10*1024*1024)
 	for s.Scan() {
 		text := s.Text()
-		n = n + len(text+"\n")
+		n = n + int64(len(text)+1)
 
 		if len(s.Text()) == 0 && !finishedHeaders {
 			finishedHeaders = true
@@ -121,15 +248,28 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 
 			switch fields[0] {
 			case "tree":
+				if sawTree {
+					return n, fmt.Errorf("gitobj: duplicate tree header in commit")
+				} else if sawParent {
+					return n, fmt.Errorf("gitobj: tree header after parent header in commit")
+				}
+				sawTree = true
+
 				id, err := hex.DecodeString(fields[1])
 				if err != nil {
 					return n, fmt.Errorf("error parsing tree: %s", err)
+				} else if len(id) != hashlen {
+					return n, fmt.Errorf("gitobj: tree ID %q has %d hex digits, expected %d", fields[1], len(fields[1]), 2*hashlen)
 				}
 				c.TreeID = id
 			case "parent":
+				sawParent = true
+
 				id, err := hex.DecodeString(fields[1])
 				if err != nil {
 					return n, fmt.Errorf("error parsing parent: %s", err)
+				} else if len(id) != hashlen {
+					return n, fmt.Errorf("gitobj: parent ID %q has %d hex digits, expected %d", fields[1], len(fields[1]), 2*hashlen)
 				}
 				c.ParentIDs = append(c.ParentIDs, id)
 			case "author":
@@ -146,6 +286,10 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 				}
 			default:
 				if strings.HasPrefix(s.Text(), " ") {
+					if len(c.ExtraHeaders) == 0 {
+						return n, fmt.Errorf("gitobj: unexpected continuation line in commit headers: %q", text)
+					}
+
 					idx := len(c.ExtraHeaders) - 1
 					hdr := c.ExtraHeaders[idx]
 
@@ -176,23 +320,40 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 	return n, err
 }
 
+// errMissingTree is returned when encoding a commit without a tree, which Git
+// would reject. A commit with no contents refers to the empty tree instead.
+var errMissingTree = fmt.Errorf("gitobj: cannot encode commit without a tree")
+
 // Encode encodes the commit's contents to the given io.Writer, "w". If there was
 // any error copying the commit's contents, that error will be returned.
 //
+// If the commit has no TreeID, or its tree and parent IDs are not all of the
+// length of either SHA-1 or SHA-256 object IDs, nothing is written, and an error
+// is returned (see: Validate).
+//
 // Otherwise, the number of bytes written will be returned.
-func (c *Commit) Encode(to io.Writer) (n int, err error) {
-	n, err = fmt.Fprintf(to, "tree %s\n", hex.EncodeToString(c.TreeID))
+func (c *Commit) Encode(to io.Writer) (n int64, err error) {
+	if len(c.TreeID) == 0 {
+		return 0, errMissingTree
+	}
+	if problems := c.checkIDs(0); len(problems) > 0 {
+		return 0, &InvalidCommitError{Problems: problems}
+	}
+
+	n0, err := fmt.Fprintf(to, "tree %s\n", hex.EncodeToString(c.TreeID))
 	if err != nil {
-		return n, err
+		return int64(n0), err
 	}
 
+	n = int64(n0)
+
 	for _, pid := range c.ParentIDs {
 		n1, err := fmt.Fprintf(to, "parent %s\n", hex.EncodeToString(pid))
 		if err != nil {
 			return n, err
 		}
 
-		n = n + n1
+		n = n + int64(n1)
 	}
 
 	n2, err := fmt.Fprintf(to, "author %s\ncommitter %s\n", c.Author, c.Committer)
@@ -200,7 +361,7 @@ func (c *Commit) Encode(to io.Writer) (n int, err error) {
 		return n, err
 	}
 
-	n = n + n2
+	n = n + int64(n2)
 
 	for _, hdr := range c.ExtraHeaders {
 		n3, err := fmt.Fprintf(to, "%s %s\n",
@@ -209,7 +370,7 @@ func (c *Commit) Encode(to io.Writer) (n int, err error) {
 			return n, err
 		}
 
-		n = n + n3
+		n = n + int64(n3)
 	}
 
 	// c.Message is built from messageParts in the Decode() function.
@@ -221,7 +382,289 @@ func (c *Commit) Encode(to io.Writer) (n int, err error) {
 		return n, err
 	}
 
-	return n + n4, err
+	return n + int64(n4), err
+}
+
+// EncodeSorted encodes the commit as Encode does, except that its extra
+// headers are written in order of their keys rather than in the order in which
+// they appear in ExtraHeaders, with any signature headers ("gpgsig" and
+// "gpgsig-sha256") written last. Headers with the same key keep their relative
+// order. ExtraHeaders itself is left unchanged.
+//
+// Git requires only that the tree, parents, author, and committer come first,
+// in that order, so any ordering of the extra headers after them produces a
+// valid commit. Since the signature of a commit is made over its contents
+// without its signature headers, sorting the other headers of a signed commit
+// invalidates its signature unless they were already in order. Either way, a
+// commit whose headers are reordered has a different object ID.
+func (c *Commit) EncodeSorted(to io.Writer) (n int64, err error) {
+	dup := *c
+	dup.ExtraHeaders = make([]*ExtraHeader, len(c.ExtraHeaders))
+	copy(dup.ExtraHeaders, c.ExtraHeaders)
+
+	sort.SliceStable(dup.ExtraHeaders, func(i, j int) bool {
+		_, iSig := signatureHeaders[dup.ExtraHeaders[i].K]
+		_, jSig := signatureHeaders[dup.ExtraHeaders[j].K]
+		if iSig != jSig {
+			return jSig
+		}
+		return dup.ExtraHeaders[i].K < dup.ExtraHeaders[j].K
+	})
+	return dup.Encode(to)
+}
+
+// Size returns the number of bytes which Encode would write for the commit,
+// without encoding it. Since Encode writes nothing for a commit without a
+// TreeID, or with IDs of an invalid length, the size of such a commit is zero.
+func (c *Commit) Size() int64 {
+	if len(c.TreeID) == 0 || len(c.checkIDs(0)) > 0 {
+		return 0
+	}
+
+	n := int64(len("tree \n") + hex.EncodedLen(len(c.TreeID)))
+	for _, pid := range c.ParentIDs {
+		n += int64(len("parent \n") + hex.EncodedLen(len(pid)))
+	}
+	n += int64(len("author \ncommitter \n") + len(c.Author) + len(c.Committer))
+
+	for _, hdr := range c.ExtraHeaders {
+		// Each line after the first is continued with a leading space.
+		n += int64(len(hdr.K) + len(" ") + len(hdr.V) + strings.Count(hdr.V, "\n") + len("\n"))
+	}
+	return n + int64(len("\n\n")+len(c.Message))
+}
+
+// Validate checks that the commit is well-formed enough to be written: that it
+// has a tree, that its tree and parents are named by object IDs of the same
+// length, which is that of either SHA-1 or SHA-256, that its author and
+// committer are valid signatures (see: ParseSignature), and that the keys of
+// its extra headers are non-empty and contain no spaces or newlines.
+//
+// This catches mistakes in constructing a commit, such as giving a hex-encoded
+// tree ID, which would otherwise produce an object rejected by Git. Every
+// problem found is reported by a single *InvalidCommitError, and nil is
+// returned if there are none.
+func (c *Commit) Validate() error {
+	return c.validate(0)
+}
+
+// validate is as Validate, but if "hashLen" is non-zero, object IDs must be of
+// exactly that length.
+func (c *Commit) validate(hashLen int) error {
+	var problems []string
+
+	if len(c.TreeID) == 0 {
+		problems = append(problems, "missing tree")
+	}
+	problems = append(problems, c.checkIDs(hashLen)...)
+
+	for _, sig := range []struct{ name, value string }{
+		{"author", c.Author},
+		{"committer", c.Committer},
+	} {
+		if len(sig.value) == 0 {
+			problems = append(problems, "missing "+sig.name)
+		} else if _, err := ParseSignature(sig.value); err != nil {
+			problems = append(problems, fmt.Sprintf("malformed %s: %q", sig.name, sig.value))
+		}
+	}
+
+	for _, hdr := range c.ExtraHeaders {
+		if len(hdr.K) == 0 || strings.ContainsAny(hdr.K, " \n") {
+			problems = append(problems, fmt.Sprintf("malformed extra header key: %q", hdr.K))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &InvalidCommitError{Problems: problems}
+	}
+	return nil
+}
+
+// AuthorSig parses the commit's author as a *Signature (see: ParseSignature),
+// including the time and timezone at which it was authored. If the author is
+// malformed, an error is returned instead.
+func (c *Commit) AuthorSig() (*Signature, error) {
+	return ParseSignature(c.Author)
+}
+
+// CommitterSig parses the commit's committer as a *Signature (see:
+// ParseSignature), including the time and timezone at which it was committed.
+// If the committer is malformed, an error is returned instead.
+func (c *Commit) CommitterSig() (*Signature, error) {
+	return ParseSignature(c.Committer)
+}
+
+// checkIDs returns a description of each problem with the lengths of the
+// commit's tree and parent IDs, which must be "hashLen" bytes long, or if
+// "hashLen" is zero, must all be the length of either SHA-1 or SHA-256 IDs. A
+// missing tree is not a problem here.
+func (c *Commit) checkIDs(hashLen int) []string {
+	var problems []string
+
+	if hashLen == 0 && (len(c.TreeID) == sha1.Size || len(c.TreeID) == sha256.Size) {
+		hashLen = len(c.TreeID)
+	}
+	checkID := func(what string, id []byte) {
+		switch {
+		case hashLen > 0 && len(id) == hashLen:
+		case hashLen == 0 && (len(id) == sha1.Size || len(id) == sha256.Size):
+		case len(id) == 2*sha1.Size || len(id) == 2*sha256.Size:
+			problems = append(problems, fmt.Sprintf(
+				"%s has invalid length %d (is it hex-encoded?)", what, len(id)))
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"%s has invalid length %d", what, len(id)))
+		}
+	}
+
+	if len(c.TreeID) > 0 {
+		checkID("tree ID", c.TreeID)
+	}
+	for i, parent := range c.ParentIDs {
+		checkID(fmt.Sprintf("parent %d", i), parent)
+	}
+	return problems
+}
+
+// IsMerge returns whether the commit is a merge commit, or in other words,
+// whether it has more than one parent.
+func (c *Commit) IsMerge() bool {
+	return len(c.ParentIDs) > 1
+}
+
+// IsRoot returns whether the commit is a root commit, or in other words,
+// whether it has no parents.
+func (c *Commit) IsRoot() bool {
+	return len(c.ParentIDs) == 0
+}
+
+// FirstParent returns the object ID of the commit's first parent and a value
+// of true, or nil and a value of false if the commit has no parents.
+func (c *Commit) FirstParent() ([]byte, bool) {
+	if len(c.ParentIDs) == 0 {
+		return nil, false
+	}
+	return c.ParentIDs[0], true
+}
+
+// Signature returns the PGP signature held by the commit's "gpgsig" header,
+// ending in a newline as Git extracts it, along with the payload which it
+// signs: the commit encoded without that header (or its "gpgsig-sha256"
+// header), as given to gpg by "git verify-commit". If the commit has no
+// "gpgsig" header, or could not be encoded, ok is false.
+func (c *Commit) Signature() (sig string, signed []byte, ok bool) {
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K == "gpgsig" {
+			sig, ok = hdr.V+"\n", true
+			break
+		}
+	}
+	if !ok {
+		return "", nil, false
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.withoutSignatures().Encode(&buf); err != nil {
+		return "", nil, false
+	}
+	return sig, buf.Bytes(), true
+}
+
+// AddSignature sets the commit's "gpgsig" header to the PGP signature "sig",
+// replacing any signature already given by that header. The header is placed
+// first among the commit's extra headers, directly after its committer, and a
+// single trailing newline in "sig" (as returned by Signature) is dropped, since
+// Encode writes each line of the header's value on its own continuation line.
+func (c *Commit) AddSignature(sig string) {
+	headers := make([]*ExtraHeader, 0, len(c.ExtraHeaders)+1)
+	headers = append(headers, &ExtraHeader{
+		K: "gpgsig",
+		V: strings.TrimSuffix(sig, "\n"),
+	})
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K != "gpgsig" {
+			headers = append(headers, hdr)
+		}
+	}
+	c.ExtraHeaders = headers
+}
+
+// MergeTags decodes the tags recorded by the commit's "mergetag" headers, which
+// "git merge" adds for each signed tag that it merges, and returns them in the
+// order in which the headers appear. A commit without any such headers has no
+// merge tags.
+//
+// The object IDs of the tags are expected to be of the same length as that of
+// the commit's tree, that is, to use the same hash algorithm.
+//
+// If any header does not hold a well-formed tag, which must at least name the
+// object that it tags, an error is returned instead.
+func (c *Commit) MergeTags() ([]*Tag, error) {
+	hash := sha1.New()
+	if len(c.TreeID) == sha256.Size {
+		hash = sha256.New()
+	}
+
+	var tags []*Tag
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K != "mergetag" {
+			continue
+		}
+
+		tag := new(Tag)
+		if _, err := tag.Decode(hash, strings.NewReader(hdr.V), int64(len(hdr.V))); err != nil {
+			return nil, fmt.Errorf("gitobj: malformed mergetag %d: %s", len(tags)+1, err)
+		}
+		if len(tag.Object) == 0 {
+			return nil, fmt.Errorf("gitobj: malformed mergetag %d: missing object", len(tags)+1)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// withParents returns a shallow copy of the commit with its parents replaced by
+// the given set.
+func (c *Commit) withParents(parents [][]byte) *Commit {
+	dup := *c
+	dup.ParentIDs = parents
+	return &dup
+}
+
+// signatureHeaders are the names of the extra headers which hold the
+// signatures of a commit.
+var signatureHeaders = map[string]struct{}{
+	"gpgsig":        {},
+	"gpgsig-sha256": {},
+}
+
+// withoutSignatures returns a shallow copy of the commit with any of its
+// signature headers removed.
+func (c *Commit) withoutSignatures() *Commit {
+	dup := *c
+	dup.ExtraHeaders = make([]*ExtraHeader, 0, len(c.ExtraHeaders))
+	for _, hdr := range c.ExtraHeaders {
+		if _, ok := signatureHeaders[hdr.K]; !ok {
+			dup.ExtraHeaders = append(dup.ExtraHeaders, hdr)
+		}
+	}
+	return &dup
+}
+
+// clone returns a copy of the commit which shares no mutable state with it.
+func (c *Commit) clone() *Commit {
+	dup := *c
+	dup.ParentIDs = make([][]byte, len(c.ParentIDs))
+	for i, parent := range c.ParentIDs {
+		dup.ParentIDs[i] = append([]byte(nil), parent...)
+	}
+	dup.TreeID = append([]byte(nil), c.TreeID...)
+	dup.ExtraHeaders = make([]*ExtraHeader, len(c.ExtraHeaders))
+	for i, hdr := range c.ExtraHeaders {
+		dup.ExtraHeaders[i] = &ExtraHeader{K: hdr.K, V: hdr.V}
+	}
+	return &dup
 }
 
 // Equal returns whether the receiving and given commits are equal, or in other
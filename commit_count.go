@@ -0,0 +1,51 @@
+package gitobj
+
+import "time"
+
+// CommitCount returns the number of commits reachable from "tip", including
+// "tip" itself, which are not reachable from any of "excludes", as "git rev-list
+// --count tip ^exclude..." does. Each commit is counted once, however many ways
+// it can be reached.
+//
+// The history of "tip" and "excludes" is painted together, as for a range (see:
+// paint), so only as much of the history of "excludes" is visited as is needed.
+// As in Git, commits whose dates are skewed far enough may therefore be
+// miscounted.
+//
+// The parents and date of each commit are taken from the repository's
+// commit-graph where it has them, so that they need not be read, and the
+// commits themselves are read otherwise. As in Git, a commit-graph which could
+// not be read is ignored, as is any commit-graph in a shallow repository. Only
+// the IDs of the commits visited are kept, and not the commits themselves.
+//
+// If any commit could not be read, an error is returned instead.
+func (o *ObjectDatabase) CommitCount(tip []byte, excludes [][]byte) (int, error) {
+	graph, err := o.commitGraph()
+	if err != nil {
+		graph = nil
+	}
+
+	visit := func(sha []byte) ([][]byte, time.Time, error) {
+		if graph != nil {
+			if ps, ok, err := graph.parents(sha); err == nil && ok {
+				if when, ok := graph.when(sha); ok {
+					return ps, when, nil
+				}
+			}
+		}
+		return o.visitCommit(sha)
+	}
+
+	painted, err := o.paint(excludes, [][]byte{tip}, visit)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, p := range painted {
+		if p.flags == paintedTo {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,176 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitCount(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	a := writeDatedCommit(t, odb, 2, nil, root)
+	b := writeDatedCommit(t, odb, 3, nil, root)
+	merge := writeDatedCommit(t, odb, 4, nil, a, b)
+	tip := writeDatedCommit(t, odb, 5, nil, merge)
+
+	for _, test := range []struct {
+		excludes [][]byte
+		count    int
+	}{
+		{nil, 5},
+		{[][]byte{a}, 3},
+		{[][]byte{a, b}, 2},
+		{[][]byte{tip}, 0},
+	} {
+		count, err := odb.CommitCount(tip, test.excludes)
+		require.NoError(t, err)
+
+		assert.Equal(t, test.count, count)
+	}
+}
+
+func TestCommitCountMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, err := odb.CommitCount(make([]byte, 20), nil)
+	assert.Error(t, err)
+}
+
+func TestCommitCountWithCommitGraph(t *testing.T) {
+	for _, split := range []bool{false, true} {
+		dir := newTestGitDir(t)
+		defer os.RemoveAll(dir)
+
+		odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+		require.NoError(t, err)
+		defer odb.Close()
+
+		when := int64(0)
+		commit := func(parents ...[]byte) []byte {
+			when++
+			return writeDatedCommit(t, odb, when, nil, parents...)
+		}
+
+		root := commit()
+		var heads [][]byte
+		for i := 0; i < 3; i++ {
+			heads = append(heads, commit(root))
+		}
+		octopus := commit(heads...)
+		runTestGit(t, dir, "", "update-ref", "refs/heads/main", hex.EncodeToString(octopus))
+
+		if split {
+			runTestGit(t, dir, "", "commit-graph", "write", "--split", "--reachable")
+		} else {
+			runTestGit(t, dir, "", "commit-graph", "write", "--reachable")
+		}
+
+		// Commits after the commit-graph was written are read instead.
+		tip := commit(commit(octopus))
+		if split {
+			runTestGit(t, dir, "", "update-ref", "refs/heads/main", hex.EncodeToString(tip))
+			runTestGit(t, dir, "", "commit-graph", "write", "--split=no-merge", "--reachable")
+			tip = commit(tip)
+		}
+
+		graph, err := odb.commitGraph()
+		require.NoError(t, err)
+		require.NotNil(t, graph)
+		if split {
+			assert.Len(t, graph.layers, 2)
+		}
+
+		for _, excludes := range [][][]byte{nil, {heads[0]}, {octopus}} {
+			args := []string{"rev-list", "--count", hex.EncodeToString(tip)}
+			for _, exclude := range excludes {
+				args = append(args, "^"+hex.EncodeToString(exclude))
+			}
+
+			count, err := odb.CommitCount(tip, excludes)
+			require.NoError(t, err)
+
+			assert.Equal(t, runTestGit(t, dir, "", args...), strconv.Itoa(count))
+		}
+	}
+}
+
+func TestCommitGraphParents(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	var heads [][]byte
+	for i := 0; i < 4; i++ {
+		heads = append(heads, writeDatedCommit(t, odb, int64(2+i), nil, root))
+	}
+	octopus := writeDatedCommit(t, odb, 6, nil, heads...)
+	runTestGit(t, dir, "", "update-ref", "refs/heads/main", hex.EncodeToString(octopus))
+	runTestGit(t, dir, "", "commit-graph", "write", "--reachable")
+
+	graph, err := odb.commitGraph()
+	require.NoError(t, err)
+	require.NotNil(t, graph)
+
+	parents, ok, err := graph.parents(octopus)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, heads, parents)
+
+	parents, ok, err = graph.parents(root)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, parents)
+
+	_, ok, err = graph.parents(make([]byte, 20))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	when, ok := graph.when(octopus)
+	require.True(t, ok)
+	assert.Equal(t, int64(6), when.Unix())
+
+	_, ok = graph.when(make([]byte, 20))
+	assert.False(t, ok)
+}
+
+func TestCommitGraphIsKeptUntilRescan(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	runTestGit(t, dir, "", "update-ref", "refs/heads/main", hex.EncodeToString(root))
+	runTestGit(t, dir, "", "commit-graph", "write", "--reachable")
+
+	graph, err := odb.commitGraph()
+	require.NoError(t, err)
+	require.NotNil(t, graph)
+
+	again, err := odb.commitGraph()
+	require.NoError(t, err)
+	assert.True(t, graph == again)
+
+	// Repacking the objects rescans the packfiles, after which the
+	// commit-graph is read again.
+	runTestGit(t, dir, "", "repack", "-a", "-d", "-q")
+	require.True(t, odb.rescan())
+
+	again, err = odb.commitGraph()
+	require.NoError(t, err)
+	require.NotNil(t, again)
+	assert.False(t, graph == again)
+}
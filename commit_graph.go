@@ -0,0 +1,299 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// graphSignature is the magic number at the beginning of every
+	// commit-graph file.
+	graphSignature = "CGPH"
+
+	// graphParentNone is the position given for a missing parent.
+	graphParentNone = 0x70000000
+	// graphExtraEdges is set in the position of the second parent of a
+	// commit with more than two parents, whose remaining bits give the
+	// index of its parents (after the first) in the list of extra edges.
+	// It is also set in the last position of each commit in that list.
+	graphExtraEdges = 0x80000000
+)
+
+// commitGraph is a read-only view of the commit-graph of an object directory,
+// made up of one or more layers, which records the parents of the commits in
+// it without their having to be read.
+type commitGraph struct {
+	// layers are the layers of the graph, beginning with the base layer.
+	layers []*graphLayer
+	// hashlen is the length of the object IDs in the graph.
+	hashlen int
+}
+
+// graphLayer is a single commit-graph file.
+type graphLayer struct {
+	// fanout is the fanout table of the layer's commits, giving the
+	// number of commits whose first byte is at most each value.
+	fanout []byte
+	// oids is the sorted list of the layer's commits.
+	oids []byte
+	// data is the tree, parents, and generation of each commit.
+	data []byte
+	// edges is the list of extra parents of octopus merges, if any.
+	edges []byte
+	// base is the total number of commits in the layers below this one,
+	// which is the position of its first commit.
+	base uint32
+}
+
+// commitGraph returns the commit-graph of the database's object directory, or
+// nil if there is none (see: readCommitGraph). It is read once, and kept until
+// the packfiles are found to have changed (see: rescan), since a repack may
+// also rewrite it.
+func (o *ObjectDatabase) commitGraph() (*commitGraph, error) {
+	o.graphMu.Lock()
+	defer o.graphMu.Unlock()
+
+	if !o.graphRead {
+		o.graph, o.graphErr = o.readCommitGraph()
+		o.graphRead = true
+	}
+	return o.graph, o.graphErr
+}
+
+// forgetCommitGraph discards the commit-graph kept by commitGraph, so that it is
+// read again when it is next needed.
+func (o *ObjectDatabase) forgetCommitGraph() {
+	o.graphMu.Lock()
+	defer o.graphMu.Unlock()
+
+	o.graph, o.graphErr, o.graphRead = nil, nil, false
+}
+
+// readCommitGraph reads the commit-graph of the database's object directory,
+// and returns it, or nil if there is none. A commit-graph is ignored in a
+// shallow repository, as by Git, since the parents that it records may be
+// shallower than those that it gives.
+//
+// If the commit-graph exists but could not be read, or is malformed, an error
+// is returned.
+func (o *ObjectDatabase) readCommitGraph() (*commitGraph, error) {
+	root, ok := o.Root()
+	if !ok {
+		return nil, nil
+	}
+	if shallow, err := o.ShallowBoundary(); err != nil || len(shallow) > 0 {
+		return nil, err
+	}
+
+	var paths []string
+	info := filepath.Join(root, "info")
+
+	chain, err := os.Open(filepath.Join(info, "commit-graphs", "commit-graph-chain"))
+	if err == nil {
+		scanner := bufio.NewScanner(chain)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+				paths = append(paths, filepath.Join(info, "commit-graphs", "graph-"+line+".graph"))
+			}
+		}
+		err = scanner.Err()
+		chain.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	} else if os.IsNotExist(err) {
+		if _, err = os.Stat(filepath.Join(info, "commit-graph")); os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.Join(info, "commit-graph"))
+	} else {
+		return nil, err
+	}
+
+	graph := &commitGraph{hashlen: o.HashLen()}
+	for _, path := range paths {
+		layer, err := readGraphLayer(path, graph.hashlen)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(graph.layers); n > 0 {
+			layer.base = graph.layers[n-1].base + graph.layers[n-1].count()
+		}
+		graph.layers = append(graph.layers, layer)
+	}
+	return graph, nil
+}
+
+// readGraphLayer reads the commit-graph file at "path", whose object IDs are
+// "hashlen" bytes long.
+func readGraphLayer(path string, hashlen int) (*graphLayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 8 || string(data[:4]) != graphSignature {
+		return nil, fmt.Errorf("gitobj: %s is not a commit-graph", path)
+	}
+	if version := data[4]; version != 1 {
+		return nil, fmt.Errorf("gitobj: unsupported commit-graph version %d", version)
+	}
+	if algo := data[5]; (algo == 1 && hashlen != 20) || (algo == 2 && hashlen != 32) || algo < 1 || algo > 2 {
+		return nil, fmt.Errorf("gitobj: commit-graph %s has unexpected hash version %d", path, algo)
+	}
+
+	chunks := int(data[6])
+	if len(data) < 8+12*(chunks+1) {
+		return nil, fmt.Errorf("gitobj: commit-graph %s is truncated", path)
+	}
+
+	layer := new(graphLayer)
+	for i := 0; i < chunks; i++ {
+		entry := data[8+12*i:]
+		id := string(entry[:4])
+		start := binary.BigEndian.Uint64(entry[4:])
+		end := binary.BigEndian.Uint64(entry[16:])
+		if start > end || end > uint64(len(data)) {
+			return nil, fmt.Errorf("gitobj: commit-graph %s has malformed chunk %q", path, id)
+		}
+
+		chunk := data[start:end]
+		switch id {
+		case "OIDF":
+			layer.fanout = chunk
+		case "OIDL":
+			layer.oids = chunk
+		case "CDAT":
+			layer.data = chunk
+		case "EDGE":
+			layer.edges = chunk
+		}
+	}
+
+	if len(layer.fanout) != 256*4 || layer.oids == nil || layer.data == nil {
+		return nil, fmt.Errorf("gitobj: commit-graph %s is missing required chunks", path)
+	}
+	if n := int(layer.count()); len(layer.oids) != n*hashlen || len(layer.data) != n*(hashlen+16) {
+		return nil, fmt.Errorf("gitobj: commit-graph %s has malformed chunks", path)
+	}
+	return layer, nil
+}
+
+// count returns the number of commits in the layer.
+func (l *graphLayer) count() uint32 {
+	return binary.BigEndian.Uint32(l.fanout[255*4:])
+}
+
+// parents returns the parents of the commit "sha" as recorded by the graph, and
+// true, or false if the commit is not in the graph. If the graph records them
+// incorrectly, an error is returned.
+func (g *commitGraph) parents(sha []byte) ([][]byte, bool, error) {
+	for _, layer := range g.layers {
+		i, ok := layer.find(sha, g.hashlen)
+		if !ok {
+			continue
+		}
+
+		data := layer.data[i*(g.hashlen+16)+g.hashlen:]
+		first := binary.BigEndian.Uint32(data)
+		second := binary.BigEndian.Uint32(data[4:])
+
+		var positions []uint32
+		if first != graphParentNone {
+			positions = append(positions, first)
+		}
+		switch {
+		case second == graphParentNone:
+		case second&graphExtraEdges == 0:
+			positions = append(positions, second)
+		default:
+			for edge := int(second &^ graphExtraEdges); ; edge++ {
+				if 4*edge+4 > len(layer.edges) {
+					return nil, false, fmt.Errorf("gitobj: commit-graph has malformed extra edges for %x", sha)
+				}
+
+				pos := binary.BigEndian.Uint32(layer.edges[4*edge:])
+				positions = append(positions, pos&^graphExtraEdges)
+				if pos&graphExtraEdges != 0 {
+					break
+				}
+			}
+		}
+
+		parents := make([][]byte, 0, len(positions))
+		for _, pos := range positions {
+			parent, ok := g.oid(pos)
+			if !ok {
+				return nil, false, fmt.Errorf("gitobj: commit-graph has malformed parent %d for %x", pos, sha)
+			}
+			parents = append(parents, parent)
+		}
+		return parents, true, nil
+	}
+	return nil, false, nil
+}
+
+// when returns the time at which the commit "sha" was committed, as recorded by
+// the graph, and true, or false if the commit is not in the graph.
+func (g *commitGraph) when(sha []byte) (time.Time, bool) {
+	for _, layer := range g.layers {
+		i, ok := layer.find(sha, g.hashlen)
+		if !ok {
+			continue
+		}
+
+		// The generation of the commit is stored in the upper 30 bits,
+		// and its commit time in the lower 34.
+		data := layer.data[i*(g.hashlen+16)+g.hashlen+8:]
+		return time.Unix(int64(binary.BigEndian.Uint64(data)&(1<<34-1)), 0), true
+	}
+	return time.Time{}, false
+}
+
+// oid returns the ID of the commit at the position "pos" in the graph, or false
+// if there is no such position.
+func (g *commitGraph) oid(pos uint32) ([]byte, bool) {
+	for _, layer := range g.layers {
+		if pos >= layer.base && pos < layer.base+layer.count() {
+			i := int(pos-layer.base) * g.hashlen
+			return layer.oids[i : i+g.hashlen], true
+		}
+	}
+	return nil, false
+}
+
+// find returns the index of the commit "sha" in the layer, or false if it is
+// not there.
+func (l *graphLayer) find(sha []byte, hashlen int) (int, bool) {
+	if len(sha) != hashlen {
+		return 0, false
+	}
+
+	var lo int
+	if sha[0] > 0 {
+		lo = int(binary.BigEndian.Uint32(l.fanout[4*(int(sha[0])-1):]))
+	}
+	hi := int(binary.BigEndian.Uint32(l.fanout[4*int(sha[0]):]))
+	if lo > hi || hi > int(l.count()) {
+		return 0, false
+	}
+
+	i := lo + sort.Search(hi-lo, func(k int) bool {
+		return bytes.Compare(l.oids[(lo+k)*hashlen:(lo+k+1)*hashlen], sha) >= 0
+	})
+	if i < hi && bytes.Equal(l.oids[i*hashlen:(i+1)*hashlen], sha) {
+		return i, true
+	}
+	return 0, false
+}
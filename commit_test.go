@@ -3,6 +3,7 @@ package gitobj
 import (
 	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -58,6 +59,41 @@ func TestCommitEncoding(t *testing.T) {
 	assert.Equal(t, 0, buf.Len())
 }
 
+func TestCommitEncodeSorted(t *testing.T) {
+	c := &Commit{
+		Author:    "John Doe <john@example.com> 1136239445 -0700",
+		Committer: "Jane Doe <jane@example.com> 1136239445 -0700",
+		TreeID:    []byte("cccccccccccccccccccc"),
+		ExtraHeaders: []*ExtraHeader{
+			{"gpgsig", "<signature>"},
+			{"mergetag", "second"},
+			{"encoding", "ISO-8859-1"},
+			{"mergetag", "first"},
+		},
+		Message: "initial commit",
+	}
+
+	buf := new(bytes.Buffer)
+
+	n, err := c.EncodeSorted(buf)
+	require.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	assertLine(t, buf, "tree 6363636363636363636363636363636363636363")
+	assertLine(t, buf, "author %s", c.Author)
+	assertLine(t, buf, "committer %s", c.Committer)
+	assertLine(t, buf, "encoding ISO-8859-1")
+	assertLine(t, buf, "mergetag second")
+	assertLine(t, buf, "mergetag first")
+	assertLine(t, buf, "gpgsig <signature>")
+	assertLine(t, buf, "")
+	assertLine(t, buf, "initial commit")
+	assert.Equal(t, 0, buf.Len())
+
+	assert.Equal(t, "gpgsig", c.ExtraHeaders[0].K)
+	assert.Equal(t, "second", c.ExtraHeaders[1].V)
+}
+
 func TestCommitDecoding(t *testing.T) {
 	author := &Signature{Name: "John Doe", Email: "john@example.com", When: time.Now()}
 	committer := &Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
@@ -69,10 +105,10 @@ func TestCommitDecoding(t *testing.T) {
 	from := new(bytes.Buffer)
 	fmt.Fprintf(from, "author %s\n", author)
 	fmt.Fprintf(from, "committer %s\n", committer)
+	fmt.Fprintf(from, "tree %s\n", hex.EncodeToString(treeId))
 	fmt.Fprintf(from, "parent %s\n", hex.EncodeToString(p1))
 	fmt.Fprintf(from, "parent %s\n", hex.EncodeToString(p2))
 	fmt.Fprintf(from, "foo bar\n")
-	fmt.Fprintf(from, "tree %s\n", hex.EncodeToString(treeId))
 	fmt.Fprintf(from, "\ninitial commit\n")
 
 	flen := from.Len()
@@ -81,7 +117,7 @@ func TestCommitDecoding(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, author.String(), commit.Author)
 	assert.Equal(t, committer.String(), commit.Committer)
@@ -111,7 +147,7 @@ func TestCommitDecodingWithEmptyName(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, author.String(), commit.Author)
 	assert.Equal(t, committer.String(), commit.Committer)
@@ -142,7 +178,7 @@ func TestCommitDecodingWithLargeCommitMessage(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, author.String(), commit.Author)
 	assert.Equal(t, committer.String(), commit.Committer)
@@ -168,7 +204,7 @@ func TestCommitDecodingWithMessageKeywordPrefix(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	assert.NoError(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, author.String(), commit.Author)
 	assert.Equal(t, committer.String(), commit.Committer)
@@ -195,7 +231,7 @@ func TestCommitDecodingWithWhitespace(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	assert.NoError(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, author.String(), commit.Author)
 	assert.Equal(t, committer.String(), commit.Committer)
@@ -225,7 +261,7 @@ func TestCommitDecodingMultilineHeader(t *testing.T) {
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	require.Nil(t, err)
-	require.Equal(t, flen, n)
+	require.Equal(t, int64(flen), n)
 	require.Len(t, commit.ExtraHeaders, 1)
 
 	hdr := commit.ExtraHeaders[0]
@@ -238,6 +274,61 @@ func TestCommitDecodingMultilineHeader(t *testing.T) {
 		strings.Split(hdr.V, "\n"))
 }
 
+func TestCommitDecodingMercurialExtraHeaders(t *testing.T) {
+	// Commits imported from Mercurial (for instance, by hg-git) carry
+	// extra headers of their own, whose values may contain runs of spaces,
+	// escaped characters, and continuation lines.
+	const raw = "tree e8ad84c41c2acde27c77fa212b8865cd3acfe6fb\n" +
+		"parent b343c8beec664ef6f0e9964d3001c7c7966331ae\n" +
+		"author Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"committer Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"HG:rename-source hg\n" +
+		"HG:extra branch:stable\n" +
+		"HG:extra convert_revision:svn:0b87c9a3/trunk@123\n" +
+		"HG:extra  source:a\\x00b  with  spaces  \n" +
+		"HG:extra transplant_source:first\n" +
+		" second\n" +
+		"HG:rename old.txt:new.txt\n" +
+		"\n" +
+		"Imported from Mercurial\n"
+
+	commit := new(Commit)
+	n, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+
+	require.NoError(t, err)
+	require.Equal(t, int64(len(raw)), n)
+	assert.Equal(t, "Pat Doe <pdoe@example.org> 1337892984 -0700", commit.Author)
+	assert.Equal(t, "Pat Doe <pdoe@example.org> 1337892984 -0700", commit.Committer)
+	assert.Equal(t, []*ExtraHeader{
+		{K: "HG:rename-source", V: "hg"},
+		{K: "HG:extra", V: "branch:stable"},
+		{K: "HG:extra", V: "convert_revision:svn:0b87c9a3/trunk@123"},
+		{K: "HG:extra", V: " source:a\\x00b  with  spaces  "},
+		{K: "HG:extra", V: "transplant_source:first\nsecond"},
+		{K: "HG:rename", V: "old.txt:new.txt"},
+	}, commit.ExtraHeaders)
+	assert.Equal(t, "Imported from Mercurial", commit.Message)
+
+	buf := new(bytes.Buffer)
+	_, err = commit.Encode(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, raw, buf.String())
+}
+
+func TestCommitDecodingContinuationWithoutExtraHeader(t *testing.T) {
+	const raw = "tree e8ad84c41c2acde27c77fa212b8865cd3acfe6fb\n" +
+		"committer Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		" continued\n" +
+		"\n" +
+		"message\n"
+
+	commit := new(Commit)
+	_, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+
+	assert.EqualError(t, err, "gitobj: unexpected continuation line in commit headers: \" continued\"")
+}
+
 func TestCommitDecodingMessageWithLineStartingWithTree(t *testing.T) {
 	from := new(bytes.Buffer)
 
@@ -274,7 +365,7 @@ Merge tag 'random' of git://git.example.ca/git/
 	n, err := commit.Decode(sha1.New(), from, int64(flen))
 
 	require.Nil(t, err)
-	require.Equal(t, flen, n)
+	require.Equal(t, int64(flen), n)
 	require.Equal(t, commit.ExtraHeaders, []*ExtraHeader{
 		{
 			K: "mergetag",
@@ -455,3 +546,546 @@ func TestCommitEqualReturnsTrueWhenBothCommitsAreNil(t *testing.T) {
 
 	assert.True(t, c1.Equal(c2))
 }
+
+func TestParseSignature(t *testing.T) {
+	sig, err := ParseSignature("Jane Doe <jane@example.com> 1494258422 -0600")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Jane Doe", sig.Name)
+	assert.Equal(t, "jane@example.com", sig.Email)
+	assert.Equal(t, int64(1494258422), sig.When.Unix())
+	assert.Equal(t, "-0600", sig.When.Format(formatTimeZoneOnly))
+	assert.Equal(t, "Jane Doe <jane@example.com> 1494258422 -0600", sig.String())
+	assert.True(t, sig.Canonical())
+}
+
+func TestParseSignatureWithEmptyName(t *testing.T) {
+	sig, err := ParseSignature(" <jane@example.com> 1494258422 +0000")
+	require.NoError(t, err)
+
+	assert.Equal(t, "", sig.Name)
+	assert.Equal(t, "jane@example.com", sig.Email)
+	assert.True(t, sig.Canonical())
+}
+
+func TestParseSignatureWithExtraWhitespace(t *testing.T) {
+	for desc, str := range map[string]string{
+		"double space": "Jane Doe <jane@example.com>  1494258422 +0530",
+		"tab":          "Jane Doe <jane@example.com>\t1494258422\t+0530",
+	} {
+		t.Run(desc, func(t *testing.T) {
+			sig, err := ParseSignature(str)
+			require.NoError(t, err)
+
+			assert.Equal(t, "Jane Doe", sig.Name)
+			assert.Equal(t, "jane@example.com", sig.Email)
+			assert.Equal(t, int64(1494258422), sig.When.Unix())
+			assert.Equal(t, "Jane Doe <jane@example.com> 1494258422 +0530", sig.String())
+			assert.False(t, sig.Canonical())
+		})
+	}
+}
+
+func TestParseSignatureRejectsMalformedSignatures(t *testing.T) {
+	for desc, str := range map[string]string{
+		"missing email":     "Jane Doe 1494258422 -0600",
+		"missing timestamp": "Jane Doe <jane@example.com>",
+		"bad timestamp":     "Jane Doe <jane@example.com> abc -0600",
+		"bad timezone":      "Jane Doe <jane@example.com> 1494258422 0600",
+	} {
+		t.Run(desc, func(t *testing.T) {
+			sig, err := ParseSignature(str)
+
+			assert.Error(t, err)
+			assert.Nil(t, sig)
+		})
+	}
+}
+
+func TestSignatureWithoutRawIsCanonical(t *testing.T) {
+	sig := &Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
+
+	assert.True(t, sig.Canonical())
+}
+
+func TestSignatureOffsetMinutes(t *testing.T) {
+	for zone, minutes := range map[string]int{
+		"+0000": 0,
+		"+0530": 330,
+		"-0930": -570,
+		"+1400": 840,
+	} {
+		t.Run(zone, func(t *testing.T) {
+			sig, err := ParseSignature("Jane Doe <jane@example.com> 1494258422 " + zone)
+			require.NoError(t, err)
+
+			assert.Equal(t, minutes, sig.OffsetMinutes())
+			assert.True(t, strings.HasSuffix(sig.String(), " "+zone))
+		})
+	}
+}
+
+func TestSignatureOffsetMinutesFromLocation(t *testing.T) {
+	sig := &Signature{When: time.Unix(1494258422, 0).In(time.FixedZone("", -(9*60+30)*60))}
+
+	assert.Equal(t, -570, sig.OffsetMinutes())
+}
+
+func TestCommitParentPredicates(t *testing.T) {
+	p1 := []byte("aaaaaaaaaaaaaaaaaaaa")
+	p2 := []byte("bbbbbbbbbbbbbbbbbbbb")
+
+	for desc, c := range map[string]struct {
+		Parents [][]byte
+		IsMerge bool
+		IsRoot  bool
+	}{
+		"root":    {nil, false, true},
+		"regular": {[][]byte{p1}, false, false},
+		"merge":   {[][]byte{p1, p2}, true, false},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			commit := &Commit{ParentIDs: c.Parents}
+
+			assert.Equal(t, c.IsMerge, commit.IsMerge())
+			assert.Equal(t, c.IsRoot, commit.IsRoot())
+		})
+	}
+}
+
+func TestCommitFirstParent(t *testing.T) {
+	p1 := []byte("aaaaaaaaaaaaaaaaaaaa")
+	p2 := []byte("bbbbbbbbbbbbbbbbbbbb")
+
+	parent, ok := (&Commit{ParentIDs: [][]byte{p1, p2}}).FirstParent()
+	assert.True(t, ok)
+	assert.Equal(t, p1, parent)
+
+	parent, ok = new(Commit).FirstParent()
+	assert.False(t, ok)
+	assert.Nil(t, parent)
+}
+
+func TestCommitValidate(t *testing.T) {
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		ParentIDs: [][]byte{[]byte("aaaaaaaaaaaaaaaaaaaa")},
+		TreeID:    []byte("bbbbbbbbbbbbbbbbbbbb"),
+		ExtraHeaders: []*ExtraHeader{
+			{K: "encoding", V: "ISO-8859-1"},
+		},
+		Message: "Initial commit",
+	}
+
+	assert.NoError(t, commit.Validate())
+}
+
+func TestCommitValidateReportsEveryProblem(t *testing.T) {
+	commit := &Commit{
+		Author:    "Jane Doe",
+		ParentIDs: [][]byte{[]byte("aaaa"), []byte("aaaaaaaaaaaaaaaaaaaa")},
+		TreeID:    []byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		ExtraHeaders: []*ExtraHeader{
+			{K: "bad key", V: "value"},
+		},
+	}
+
+	err := commit.Validate()
+
+	require.IsType(t, &InvalidCommitError{}, err)
+	assert.Equal(t, []string{
+		"tree ID has invalid length 40 (is it hex-encoded?)",
+		"parent 0 has invalid length 4",
+		`malformed author: "Jane Doe"`,
+		"missing committer",
+		`malformed extra header key: "bad key"`,
+	}, err.(*InvalidCommitError).Problems)
+	assert.EqualError(t, err, "gitobj: invalid commit: "+
+		"tree ID has invalid length 40 (is it hex-encoded?); "+
+		"parent 0 has invalid length 4; "+
+		`malformed author: "Jane Doe"; `+
+		"missing committer; "+
+		`malformed extra header key: "bad key"`)
+}
+
+func TestCommitValidateRequiresConsistentIDLengths(t *testing.T) {
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		ParentIDs: [][]byte{make([]byte, 32)},
+		TreeID:    make([]byte, 20),
+	}
+
+	assert.EqualError(t, commit.Validate(),
+		"gitobj: invalid commit: parent 0 has invalid length 32")
+}
+
+func TestCommitValidateMissingTree(t *testing.T) {
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+	}
+
+	assert.EqualError(t, commit.Validate(), "gitobj: invalid commit: missing tree")
+}
+
+func TestCommitSignature(t *testing.T) {
+	unsigned := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author A U Thor <author@example.com> 1234567890 -0700\n" +
+		"committer C O Mitter <committer@example.com> 1234567890 -0700\n"
+	sig := "-----BEGIN PGP SIGNATURE-----\n" +
+		"\n" +
+		"iQEzBAABCAAdFiEE\n" +
+		"-----END PGP SIGNATURE-----\n"
+	message := "\nSigned commit\n"
+
+	raw := unsigned +
+		"gpgsig " + strings.Replace(strings.TrimSuffix(sig, "\n"), "\n", "\n ", -1) + "\n" +
+		"mergetag object 1234\n" +
+		message
+
+	commit := new(Commit)
+	_, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+	require.NoError(t, err)
+
+	got, signed, ok := commit.Signature()
+
+	assert.True(t, ok)
+	assert.Equal(t, sig, got)
+	assert.Equal(t, unsigned+"mergetag object 1234\n"+message, string(signed))
+}
+
+func TestCommitSignatureUnsigned(t *testing.T) {
+	commit := &Commit{
+		Author:    "A U Thor <author@example.com> 1234567890 -0700",
+		Committer: "C O Mitter <committer@example.com> 1234567890 -0700",
+		TreeID:    make([]byte, sha1.Size),
+		Message:   "Unsigned commit",
+	}
+
+	sig, signed, ok := commit.Signature()
+
+	assert.False(t, ok)
+	assert.Empty(t, sig)
+	assert.Nil(t, signed)
+}
+
+func TestCommitAddSignature(t *testing.T) {
+	sig := "-----BEGIN PGP SIGNATURE-----\n" +
+		"\n" +
+		"iQEzBAABCAAdFiEE\n" +
+		"-----END PGP SIGNATURE-----\n"
+
+	commit := &Commit{
+		Author:    "A U Thor <author@example.com> 1234567890 -0700",
+		Committer: "C O Mitter <committer@example.com> 1234567890 -0700",
+		TreeID:    make([]byte, sha1.Size),
+		ExtraHeaders: []*ExtraHeader{
+			{K: "encoding", V: "ISO-8859-1"},
+			{K: "gpgsig", V: "stale"},
+		},
+		Message: "Signed commit",
+	}
+	commit.AddSignature(sig)
+
+	var buf bytes.Buffer
+	_, err := commit.Encode(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "tree 0000000000000000000000000000000000000000\n"+
+		"author A U Thor <author@example.com> 1234567890 -0700\n"+
+		"committer C O Mitter <committer@example.com> 1234567890 -0700\n"+
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n"+
+		" \n"+
+		" iQEzBAABCAAdFiEE\n"+
+		" -----END PGP SIGNATURE-----\n"+
+		"encoding ISO-8859-1\n"+
+		"\n"+
+		"Signed commit\n", buf.String())
+
+	got, _, ok := commit.Signature()
+	assert.True(t, ok)
+	assert.Equal(t, sig, got)
+}
+
+func TestCommitMergeTags(t *testing.T) {
+	tagger := "J. Roe <jroe@example.ca> 1337889148 -0600"
+	commit := &Commit{
+		ExtraHeaders: []*ExtraHeader{
+			{K: "mergetag", V: "object 1e8a52e18cfb381bc9cc1f0b720540364d2a6edd\n" +
+				"type commit\ntag one\ntagger " + tagger + "\n\nFirst tag"},
+			{K: "encoding", V: "UTF-8"},
+			{K: "mergetag", V: "object b343c8beec664ef6f0e9964d3001c7c7966331ae\n" +
+				"type commit\ntag two\ntagger " + tagger + "\n\nSecond tag"},
+		},
+	}
+
+	tags, err := commit.MergeTags()
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	assert.Equal(t, "1e8a52e18cfb381bc9cc1f0b720540364d2a6edd", hex.EncodeToString(tags[0].Object))
+	assert.Equal(t, CommitObjectType, tags[0].ObjectType)
+	assert.Equal(t, "one", tags[0].Name)
+	assert.Equal(t, tagger, tags[0].Tagger)
+	assert.Equal(t, "First tag", tags[0].Message)
+
+	assert.Equal(t, "b343c8beec664ef6f0e9964d3001c7c7966331ae", hex.EncodeToString(tags[1].Object))
+	assert.Equal(t, "two", tags[1].Name)
+}
+
+func TestCommitMergeTagsSHA256(t *testing.T) {
+	object := strings.Repeat("ab", sha256.Size)
+	commit := &Commit{
+		TreeID: make([]byte, sha256.Size),
+		ExtraHeaders: []*ExtraHeader{
+			{K: "mergetag", V: "object " + object + "\n" +
+				"type commit\ntag one\ntagger J. Roe <jroe@example.ca> 1337889148 -0600\n\nFirst tag"},
+		},
+	}
+
+	tags, err := commit.MergeTags()
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+
+	assert.Equal(t, object, hex.EncodeToString(tags[0].Object))
+}
+
+func TestCommitMergeTagsWithoutMergeTags(t *testing.T) {
+	tags, err := new(Commit).MergeTags()
+
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestCommitMergeTagsMalformed(t *testing.T) {
+	for _, v := range []string{
+		"object xyz\ntype commit",
+		"nonsense",
+		"type commit\ntag one",
+	} {
+		commit := &Commit{ExtraHeaders: []*ExtraHeader{{K: "mergetag", V: v}}}
+
+		_, err := commit.MergeTags()
+		if assert.Error(t, err, v) {
+			assert.Contains(t, err.Error(), "gitobj: malformed mergetag 1: ")
+		}
+	}
+}
+
+func TestCommitAuthorSigAndCommitterSig(t *testing.T) {
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1503956287 -0400",
+		Committer: " <john@example.com> 1503956300 +0530",
+	}
+
+	author, err := commit.AuthorSig()
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", author.Name)
+	assert.Equal(t, "jane@example.com", author.Email)
+	assert.Equal(t, int64(1503956287), author.When.Unix())
+	_, offset := author.When.Zone()
+	assert.Equal(t, -4*60*60, offset)
+
+	committer, err := commit.CommitterSig()
+	require.NoError(t, err)
+	assert.Equal(t, "", committer.Name)
+	assert.Equal(t, "john@example.com", committer.Email)
+	assert.Equal(t, int64(1503956300), committer.When.Unix())
+	_, offset = committer.When.Zone()
+	assert.Equal(t, 5*60*60+30*60, offset)
+}
+
+func TestCommitAuthorSigMalformed(t *testing.T) {
+	commit := &Commit{Author: "Jane Doe jane@example.com", Committer: ""}
+
+	_, err := commit.AuthorSig()
+	assert.EqualError(t, err, `gitobj: malformed signature: "Jane Doe jane@example.com"`)
+
+	_, err = commit.CommitterSig()
+	assert.Error(t, err)
+}
+
+func TestSignaturePreservesTimeZone(t *testing.T) {
+	for _, str := range []string{
+		"Jane Doe <jane@example.com> 1494258422 -0000",
+		"Jane Doe <jane@example.com> 1494258422 +0530",
+		"Jane Doe <jane@example.com> 1494258422 +0060",
+	} {
+		sig, err := ParseSignature(str)
+		require.NoError(t, err)
+
+		assert.Equal(t, str, sig.String())
+		assert.True(t, sig.Canonical(), str)
+	}
+}
+
+func TestSignatureTimeZoneFollowsWhen(t *testing.T) {
+	sig, err := ParseSignature("Jane Doe <jane@example.com> 1494258422 -0000")
+	require.NoError(t, err)
+
+	sig.When = sig.When.In(time.FixedZone("", 60*60))
+
+	assert.Equal(t, "Jane Doe <jane@example.com> 1494258422 +0100", sig.String())
+}
+
+func TestCommitRoundTripsNegativeZeroTimeZone(t *testing.T) {
+	raw := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author A U Thor <author@example.com> 1234567890 -0000\n" +
+		"committer C O Mitter <committer@example.com> 1234567890 -0000\n" +
+		"\n" +
+		"Commit from nowhere\n"
+
+	commit := new(Commit)
+	_, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = commit.Encode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, raw, buf.String())
+
+	author, err := commit.AuthorSig()
+	require.NoError(t, err)
+	assert.Equal(t, commit.Author, author.String())
+}
+
+func TestCommitSize(t *testing.T) {
+	author := &Signature{Name: "John Doe", Email: "john@example.com", When: time.Now()}
+	committer := &Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
+
+	for _, commit := range []*Commit{
+		{
+			Author:    author.String(),
+			Committer: committer.String(),
+			TreeID:    []byte("cccccccccccccccccccc"),
+		},
+		{
+			Author:    author.String(),
+			Committer: committer.String(),
+			ParentIDs: [][]byte{
+				[]byte("aaaaaaaaaaaaaaaaaaaa"), []byte("bbbbbbbbbbbbbbbbbbbb"),
+			},
+			TreeID: []byte("cccccccccccccccccccc"),
+			ExtraHeaders: []*ExtraHeader{
+				{"foo", "bar"},
+				{"gpgsig", "-----BEGIN PGP SIGNATURE-----\n\nsig\n-----END PGP SIGNATURE-----"},
+			},
+			Message: "initial commit\n\nWith a body.",
+		},
+		{
+			Author:    "",
+			Committer: "",
+			TreeID:    make([]byte, 32),
+			Message:   "SHA-256 commit",
+		},
+	} {
+		var buf bytes.Buffer
+		n, err := commit.Encode(&buf)
+		require.NoError(t, err)
+
+		assert.Equal(t, n, commit.Size())
+		assert.Equal(t, int64(buf.Len()), commit.Size())
+	}
+}
+
+func TestCommitSizeWithoutTree(t *testing.T) {
+	assert.Equal(t, int64(0), new(Commit).Size())
+}
+
+func TestCommitEncodeRejectsInvalidIDLengths(t *testing.T) {
+	for _, commit := range []*Commit{
+		{TreeID: make([]byte, 19)},
+		{TreeID: make([]byte, 20), ParentIDs: [][]byte{make([]byte, 32)}},
+		{TreeID: make([]byte, 32), ParentIDs: [][]byte{make([]byte, 64)}},
+	} {
+		var buf bytes.Buffer
+		n, err := commit.Encode(&buf)
+
+		assert.IsType(t, &InvalidCommitError{}, err)
+		assert.Equal(t, int64(0), n)
+		assert.Equal(t, 0, buf.Len())
+		assert.Equal(t, int64(0), commit.Size())
+	}
+}
+
+func TestCommitDecodeFrom(t *testing.T) {
+	raw := "tree e8ad84c41c2acde27c77fa212b8865cd3acfe6fb\n" +
+		"parent b343c8beec664ef6f0e9964d3001c7c7966331ae\n" +
+		"author Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"committer Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"encoding UTF-8\n" +
+		"\n" +
+		"Subject\n" +
+		"\n" +
+		"Body after a blank line.\n"
+
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, raw)
+		pw.Close()
+	}()
+
+	commit := new(Commit)
+	n, err := commit.DecodeFrom(sha1.New(), pr)
+	require.NoError(t, err)
+
+	assert.Equal(t, len(raw), n)
+
+	expected := new(Commit)
+	_, err = expected.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+	require.NoError(t, err)
+
+	assert.True(t, expected.Equal(commit))
+	assert.Equal(t, "Subject\n\nBody after a blank line.", commit.Message)
+}
+
+func TestCommitDecodeFromWithLargeCommitMessage(t *testing.T) {
+	message := "This message text is, with newline, exactly 64 characters long. "
+	longMessage := strings.Repeat(message, (10*1024*1024/64)-1)
+	longMessage += strings.TrimSpace(message)
+
+	from := new(bytes.Buffer)
+	fmt.Fprintf(from, "tree %s\n", hex.EncodeToString([]byte("cccccccccccccccccccc")))
+	fmt.Fprintf(from, "author %s\n", "Jane Doe <jane@example.com> 1257894000 +0000")
+	fmt.Fprintf(from, "committer %s\n", "Jane Doe <jane@example.com> 1257894000 +0000")
+	fmt.Fprintf(from, "\n%s\n", longMessage)
+
+	flen := from.Len()
+
+	commit := new(Commit)
+	n, err := commit.DecodeFrom(sha1.New(), from)
+
+	assert.NoError(t, err)
+	assert.Equal(t, flen, n)
+	assert.Equal(t, longMessage, commit.Message)
+}
+
+func TestCommitDecodingRejectsDuplicateTree(t *testing.T) {
+	raw := "tree e8ad84c41c2acde27c77fa212b8865cd3acfe6fb\n" +
+		"tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"committer Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"\n" +
+		"Two trees\n"
+
+	commit := new(Commit)
+	_, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+
+	assert.EqualError(t, err, "gitobj: duplicate tree header in commit")
+}
+
+func TestCommitDecodingRejectsTreeAfterParent(t *testing.T) {
+	raw := "parent b343c8beec664ef6f0e9964d3001c7c7966331ae\n" +
+		"tree e8ad84c41c2acde27c77fa212b8865cd3acfe6fb\n" +
+		"author Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"committer Pat Doe <pdoe@example.org> 1337892984 -0700\n" +
+		"\n" +
+		"Tree after parent\n"
+
+	commit := new(Commit)
+	_, err := commit.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+
+	assert.EqualError(t, err, "gitobj: tree header after parent header in commit")
+}
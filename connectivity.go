@@ -0,0 +1,127 @@
+package gitobj
+
+import (
+	"fmt"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// ConnectivityError describes a single broken reference found by
+// ConnectivityCheck: an object which refers to another that is either missing,
+// or is not of the type that the reference declares.
+type ConnectivityError struct {
+	// Oid is the ID of the object containing the reference.
+	Oid []byte
+	// Ref is the ID of the referenced object.
+	Ref []byte
+	// Wanted is the type of the referenced object, as declared by the
+	// reference.
+	Wanted ObjectType
+	// Got is the actual type of the referenced object, or
+	// UnknownObjectType if it is missing.
+	Got ObjectType
+}
+
+// Missing returns whether the referenced object is missing.
+func (e *ConnectivityError) Missing() bool {
+	return e.Got == UnknownObjectType
+}
+
+// Error implements the error.Error() function.
+func (e *ConnectivityError) Error() string {
+	if e.Missing() {
+		return fmt.Sprintf("gitobj: %x references missing %s %x",
+			e.Oid, e.Wanted, e.Ref)
+	}
+	return fmt.Sprintf("gitobj: %x references %s %x, which is a %s",
+		e.Oid, e.Wanted, e.Ref, e.Got)
+}
+
+// ConnectivityCheck checks that every object referenced by each of the given
+// objects exists, and is of the type that the reference declares: that the
+// entries of trees are of the type given by their mode, that the tree and
+// parents of commits are a tree and commits, and that the targets of tags are
+// of their declared ObjectType. Gitlinks are not checked, since their commits
+// are not expected to be present in this database.
+//
+// Only the direct references of the given objects are checked, not those of
+// the objects they refer to, which makes it considerably cheaper than a check
+// of the whole database.
+//
+// Each broken reference is returned as a ConnectivityError. If any of the
+// given objects could not be read, or a referenced object could not be read
+// for a reason other than being missing, an error is returned instead.
+func (o *ObjectDatabase) ConnectivityCheck(oids [][]byte) ([]ConnectivityError, error) {
+	var broken []ConnectivityError
+
+	for _, oid := range oids {
+		obj, err := o.Object(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		type ref struct {
+			oid []byte
+			typ ObjectType
+		}
+
+		var refs []ref
+		switch obj := obj.(type) {
+		case *Blob:
+			if err = obj.Close(); err != nil {
+				return nil, err
+			}
+		case *Tree:
+			for _, entry := range obj.Entries {
+				if typ := entry.Type(); typ != CommitObjectType {
+					refs = append(refs, ref{oid: entry.Oid, typ: typ})
+				}
+			}
+		case *Commit:
+			refs = append(refs, ref{oid: obj.TreeID, typ: TreeObjectType})
+
+			parents, err := o.parents(oid, obj)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range parents {
+				refs = append(refs, ref{oid: parent, typ: CommitObjectType})
+			}
+		case *Tag:
+			refs = append(refs, ref{oid: obj.Object, typ: obj.ObjectType})
+		}
+
+		for _, r := range refs {
+			got, err := o.objectType(r.oid)
+			if err != nil {
+				return nil, err
+			}
+
+			if got != r.typ {
+				broken = append(broken, ConnectivityError{
+					Oid:    oid,
+					Ref:    r.oid,
+					Wanted: r.typ,
+					Got:    got,
+				})
+			}
+		}
+	}
+	return broken, nil
+}
+
+// objectType returns the type of the object named "sha" by reading only its
+// header, or UnknownObjectType if it does not exist.
+func (o *ObjectDatabase) objectType(sha []byte) (ObjectType, error) {
+	r, err := o.open(sha)
+	if err != nil {
+		if errors.IsNoSuchObject(err) {
+			return UnknownObjectType, nil
+		}
+		return UnknownObjectType, err
+	}
+	defer r.Close()
+
+	typ, _, err := r.Header()
+	return typ, err
+}
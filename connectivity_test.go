@@ -0,0 +1,112 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectivityCheck(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+		{Name: "submodule", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root")
+	child := writeTestCommit(t, odb, tree, "child", root)
+
+	tag, err := odb.WriteTag(&Tag{
+		Object:     child,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "v1.0.0\n",
+	})
+	require.NoError(t, err)
+
+	broken, err := odb.ConnectivityCheck([][]byte{blob, tree, root, child, tag})
+
+	assert.NoError(t, err)
+	assert.Empty(t, broken)
+}
+
+func TestConnectivityCheckMissingObjects(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	missing := []byte("bbbbbbbbbbbbbbbbbbbb")
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "missing.txt", Oid: missing, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	commit := writeTestCommit(t, odb, tree, "commit", missing)
+
+	broken, err := odb.ConnectivityCheck([][]byte{tree, commit})
+
+	require.NoError(t, err)
+	require.Len(t, broken, 2)
+
+	assert.Equal(t, tree, broken[0].Oid)
+	assert.Equal(t, missing, broken[0].Ref)
+	assert.Equal(t, BlobObjectType, broken[0].Wanted)
+	assert.True(t, broken[0].Missing())
+
+	assert.Equal(t, commit, broken[1].Oid)
+	assert.Equal(t, missing, broken[1].Ref)
+	assert.Equal(t, CommitObjectType, broken[1].Wanted)
+	assert.True(t, broken[1].Missing())
+	assert.EqualError(t, &broken[1], "gitobj: "+
+		"4a66e03d7c3a3ebf3a562d7bb3589e45b09c03e7 references missing "+
+		"commit 6262626262626262626262626262626262626262")
+}
+
+func TestConnectivityCheckMismatchedTypes(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "dir", Oid: blob, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	commit := writeTestCommit(t, odb, blob, "commit", tree)
+
+	tag, err := odb.WriteTag(&Tag{
+		Object:     tree,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "v1.0.0\n",
+	})
+	require.NoError(t, err)
+
+	broken, err := odb.ConnectivityCheck([][]byte{tree, commit, tag})
+
+	require.NoError(t, err)
+	assert.Equal(t, []ConnectivityError{
+		{Oid: tree, Ref: blob, Wanted: TreeObjectType, Got: BlobObjectType},
+		{Oid: commit, Ref: blob, Wanted: TreeObjectType, Got: BlobObjectType},
+		{Oid: commit, Ref: tree, Wanted: CommitObjectType, Got: TreeObjectType},
+		{Oid: tag, Ref: tree, Wanted: CommitObjectType, Got: TreeObjectType},
+	}, broken)
+	assert.False(t, broken[0].Missing())
+}
+
+func TestConnectivityCheckMissingObject(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	broken, err := odb.ConnectivityCheck([][]byte{[]byte("bbbbbbbbbbbbbbbbbbbb")})
+
+	assert.Error(t, err)
+	assert.Nil(t, broken)
+}
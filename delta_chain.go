@@ -0,0 +1,140 @@
+package gitobj
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// deltaChainer is implemented by storage which stores objects as chains of
+// deltas, and can describe those chains.
+type deltaChainer interface {
+	// DeltaChain returns the delta-base chain of the object "oid", from
+	// the object itself down to its base, or errors.NoSuchObject if the
+	// storage does not hold it.
+	DeltaChain(oid []byte) ([]*pack.ChainLink, error)
+}
+
+// storedSizer is implemented by storage which stores each object whole, and can
+// report the number of bytes that it occupies.
+type storedSizer interface {
+	// StoredSize returns the number of bytes that the object "oid"
+	// occupies in the storage, or errors.NoSuchObject if the storage does
+	// not hold it.
+	StoredSize(oid []byte) (int64, error)
+}
+
+// ChainLink describes a single link in the chain of deltas by which an object
+// is stored, as returned by DeltaChain.
+type ChainLink struct {
+	// Oid is the ID of the object that the link resolves to, or nil if it
+	// could not be determined.
+	Oid []byte
+	// Type is the type of the link: pack.TypeObjectOffsetDelta or
+	// pack.TypeObjectReferenceDelta for a delta against the next link, or
+	// the type of the object itself for the last link, which is stored
+	// whole.
+	Type pack.PackedObjectType
+	// StoredSize is the number of (compressed) bytes that the link
+	// occupies in storage, excluding the links which follow it.
+	StoredSize int64
+}
+
+// DeltaChain returns the chain of links by which the object named "sha" is
+// stored, beginning with the object itself, and ending with the base which
+// every delta in the chain is ultimately applied to. An object which is not
+// stored as a delta, such as a loose object, has a chain of a single link.
+//
+// The chain is taken from the first storage holding the object, in the order
+// in which they are searched when reading it. Only the headers of each link
+// are read, so no delta is resolved.
+//
+// If the object does not exist, an errors.NoSuchObject error is returned. If the
+// first storage holding it cannot describe how it is stored, or the chain could
+// not be read, an error is returned instead.
+func (o *ObjectDatabase) DeltaChain(sha []byte) ([]*ChainLink, error) {
+	for _, s := range o.storages() {
+		var chain []*ChainLink
+		var err error
+
+		if c, ok := s.(deltaChainer); ok {
+			var links []*pack.ChainLink
+			if links, err = c.DeltaChain(sha); err == nil {
+				for _, link := range links {
+					chain = append(chain, &ChainLink{
+						Oid:        link.Name,
+						Type:       link.Type,
+						StoredSize: link.StoredSize,
+					})
+				}
+			}
+		} else if sz, ok := s.(storedSizer); ok {
+			chain, err = o.wholeChain(s, sz, sha)
+		} else {
+			var f io.ReadCloser
+			if f, err = s.Open(sha); err == nil {
+				f.Close()
+				err = fmt.Errorf("gitobj: cannot read delta chains from storage of type %T", s)
+			}
+		}
+
+		if err != nil {
+			if errors.IsNoSuchObject(err) {
+				continue
+			}
+			return nil, err
+		}
+		return chain, nil
+	}
+	return nil, errors.NoSuchObject(sha)
+}
+
+// wholeChain returns the chain of a single link describing the object "sha",
+// which is stored whole in "s", whose sizes are given by "sz".
+func (o *ObjectDatabase) wholeChain(s storage.Storage, sz storedSizer, sha []byte) ([]*ChainLink, error) {
+	size, err := sz.StoredSize(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.Open(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	var r *ObjectReader
+	if s.IsCompressed() {
+		r, err = NewObjectReadCloser(f)
+	} else {
+		r, err = NewUncompressedObjectReadCloser(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	defer r.Close()
+
+	typ, _, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	var packed pack.PackedObjectType
+	switch typ {
+	case CommitObjectType:
+		packed = pack.TypeCommit
+	case TreeObjectType:
+		packed = pack.TypeTree
+	case BlobObjectType:
+		packed = pack.TypeBlob
+	case TagObjectType:
+		packed = pack.TypeTag
+	default:
+		return nil, fmt.Errorf("gitobj: unknown type of object %x", sha)
+	}
+
+	return []*ChainLink{{Oid: sha, Type: packed, StoredSize: size}}, nil
+}
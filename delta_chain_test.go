@@ -0,0 +1,84 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaChainLoose(t *testing.T) {
+	odb := newTestDatabase(t)
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+
+	chain, err := odb.DeltaChain(blob)
+
+	require.NoError(t, err)
+	require.Len(t, chain, 1)
+	assert.Equal(t, blob, chain[0].Oid)
+	assert.Equal(t, pack.TypeBlob, chain[0].Type)
+	assert.True(t, chain[0].StoredSize > 0)
+}
+
+func TestDeltaChainPacked(t *testing.T) {
+	dir := newTestGitDir(t)
+	objects := filepath.Join(dir, "objects")
+
+	contents := strings.Repeat("Hello, world!\n", 100)
+	base := runTestGit(t, dir, contents+"Goodbye!\n", "hash-object", "-w", "--stdin")
+	delta := runTestGit(t, dir, contents, "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, base+"\n"+delta+"\n", "pack-objects", "-q", filepath.Join(objects, "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+
+	odb, err := FromFilesystem(objects, "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	oid, err := hex.DecodeString(delta)
+	require.NoError(t, err)
+
+	chain, err := odb.DeltaChain(oid)
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+
+	assert.Equal(t, delta, hex.EncodeToString(chain[0].Oid))
+	assert.Contains(t, []pack.PackedObjectType{
+		pack.TypeObjectOffsetDelta, pack.TypeObjectReferenceDelta,
+	}, chain[0].Type)
+
+	assert.Equal(t, base, hex.EncodeToString(chain[1].Oid))
+	assert.Equal(t, pack.TypeBlob, chain[1].Type)
+
+	for _, link := range chain {
+		disk := runTestGit(t, dir, hex.EncodeToString(link.Oid)+"\n",
+			"cat-file", "--batch-check=%(objectsize:disk)")
+		want, err := strconv.ParseInt(disk, 10, 64)
+		require.NoError(t, err)
+
+		assert.Equal(t, want, link.StoredSize)
+	}
+
+	// The base is not itself a delta.
+	oid, err = hex.DecodeString(base)
+	require.NoError(t, err)
+
+	chain, err = odb.DeltaChain(oid)
+	require.NoError(t, err)
+	require.Len(t, chain, 1)
+	assert.Equal(t, pack.TypeBlob, chain[0].Type)
+}
+
+func TestDeltaChainMissing(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	chain, err := odb.DeltaChain([]byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, chain)
+}
@@ -0,0 +1,96 @@
+package gitobj
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Describe finds the annotated tag nearest to the commit named "commit", as
+// "git describe" does, and returns its name along with the number of commits by
+// which "commit" is ahead of it, which is zero if the commit is itself tagged.
+//
+// The tags considered are those of "tagRefs", which maps the name of each tag
+// to the object ID of its reference. Lightweight tags, which refer directly to
+// a commit, are ignored, as are tags which do not ultimately refer to a commit.
+//
+// History is walked breadth-first from the commit, and the tag chosen is one
+// which tags a commit at the fewest steps from it along any path of parents. If
+// several tags are that near, the most recently tagged is chosen, and if they
+// were tagged at the same time, the first by name.
+//
+// If no tag is reachable from the commit, or any commit or tag could not be
+// read, an error is returned.
+func (o *ObjectDatabase) Describe(commit []byte, tagRefs map[string][]byte) (tag string, ahead int, err error) {
+	type candidate struct {
+		name string
+		when int64
+	}
+
+	tagged := make(map[string][]*candidate)
+	for name, sha := range tagRefs {
+		t, err := o.Tag(sha)
+		if err != nil {
+			if _, ok := err.(*UnexpectedObjectType); ok {
+				continue
+			}
+			return "", 0, err
+		}
+
+		c := &candidate{name: name}
+		if tagger, err := ParseSignature(t.Tagger); err == nil {
+			c.when = tagger.When.Unix()
+		}
+
+		typ, target, err := o.Peel(t.Object)
+		if err != nil {
+			return "", 0, err
+		}
+		if typ != CommitObjectType {
+			continue
+		}
+		tagged[string(target)] = append(tagged[string(target)], c)
+	}
+
+	seen := map[string]struct{}{string(commit): {}}
+	generation := [][]byte{commit}
+	for depth := 0; len(generation) > 0; depth++ {
+		var found []*candidate
+		for _, sha := range generation {
+			found = append(found, tagged[string(sha)]...)
+		}
+
+		if len(found) > 0 {
+			sort.Slice(found, func(i, j int) bool {
+				if found[i].when != found[j].when {
+					return found[i].when > found[j].when
+				}
+				return found[i].name < found[j].name
+			})
+			return found[0].name, depth, nil
+		}
+
+		var next [][]byte
+		for _, sha := range generation {
+			c, err := o.Commit(sha)
+			if err != nil {
+				return "", 0, err
+			}
+
+			parents, err := o.parents(sha, c)
+			if err != nil {
+				return "", 0, err
+			}
+
+			for _, parent := range parents {
+				if _, ok := seen[string(parent)]; ok {
+					continue
+				}
+				seen[string(parent)] = struct{}{}
+
+				next = append(next, parent)
+			}
+		}
+		generation = next
+	}
+	return "", 0, fmt.Errorf("gitobj: no annotated tags can describe %x", commit)
+}
@@ -0,0 +1,115 @@
+package gitobj
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDatedTag writes an annotated tag of the given object, tagged the given
+// number of seconds after the epoch, and returns its object ID.
+func writeDatedTag(t *testing.T, odb *ObjectDatabase, when int64, name string, object []byte, typ ObjectType) []byte {
+	sha, err := odb.WriteTag(&Tag{
+		Object:     object,
+		ObjectType: typ,
+		Name:       name,
+		Tagger:     fmt.Sprintf("Jane Doe <jane@example.com> %d +0000", when),
+		Message:    "tag " + name,
+	})
+	require.NoError(t, err)
+
+	return sha
+}
+
+func TestDescribe(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	a := writeDatedCommit(t, odb, 2, nil, root)
+	b := writeDatedCommit(t, odb, 3, nil, a)
+	c := writeDatedCommit(t, odb, 4, nil, b)
+
+	tags := map[string][]byte{
+		"v1":    writeDatedTag(t, odb, 1, "v1", root, CommitObjectType),
+		"v2":    writeDatedTag(t, odb, 2, "v2", a, CommitObjectType),
+		"light": b,
+	}
+
+	for _, test := range []struct {
+		commit []byte
+		tag    string
+		ahead  int
+	}{
+		{c, "v2", 2},
+		{a, "v2", 0},
+		{root, "v1", 0},
+	} {
+		tag, ahead, err := odb.Describe(test.commit, tags)
+		require.NoError(t, err)
+
+		assert.Equal(t, test.tag, tag)
+		assert.Equal(t, test.ahead, ahead)
+	}
+}
+
+func TestDescribePrefersNearestThenNewestTag(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	left := writeDatedCommit(t, odb, 2, nil, root)
+	right := writeDatedCommit(t, odb, 3, nil, root)
+	right2 := writeDatedCommit(t, odb, 4, nil, right)
+	merge := writeDatedCommit(t, odb, 5, nil, right2, left)
+
+	tags := map[string][]byte{
+		"root":  writeDatedTag(t, odb, 9, "root", root, CommitObjectType),
+		"left":  writeDatedTag(t, odb, 6, "left", left, CommitObjectType),
+		"right": writeDatedTag(t, odb, 7, "right", right, CommitObjectType),
+	}
+
+	tag, ahead, err := odb.Describe(merge, tags)
+	require.NoError(t, err)
+	assert.Equal(t, "left", tag)
+	assert.Equal(t, 1, ahead)
+
+	// Tags at the same distance are chosen by date.
+	tags["right2"] = writeDatedTag(t, odb, 8, "right2", right2, CommitObjectType)
+
+	tag, ahead, err = odb.Describe(merge, tags)
+	require.NoError(t, err)
+	assert.Equal(t, "right2", tag)
+	assert.Equal(t, 1, ahead)
+}
+
+func TestDescribePeelsNestedTags(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	tip := writeDatedCommit(t, odb, 2, nil, root)
+
+	inner := writeDatedTag(t, odb, 1, "inner", root, CommitObjectType)
+	outer := writeDatedTag(t, odb, 2, "outer", inner, TagObjectType)
+
+	tag, ahead, err := odb.Describe(tip, map[string][]byte{"outer": outer})
+	require.NoError(t, err)
+
+	assert.Equal(t, "outer", tag)
+	assert.Equal(t, 1, ahead)
+}
+
+func TestDescribeWithoutTags(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root := writeDatedCommit(t, odb, 1, nil)
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	_, _, err = odb.Describe(root, map[string][]byte{
+		"light": root,
+		"tree":  writeDatedTag(t, odb, 1, "tree", tree, TreeObjectType),
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: no annotated tags can describe %x", root))
+}
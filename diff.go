@@ -0,0 +1,340 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffStatus is the kind of change made to a path between two trees.
+type DiffStatus uint8
+
+const (
+	// DiffAdded indicates that the path is present only in the new tree.
+	DiffAdded DiffStatus = iota
+	// DiffDeleted indicates that the path is present only in the old tree.
+	DiffDeleted
+	// DiffModified indicates that the path is present in both trees, but
+	// with a different object ID or filemode.
+	DiffModified
+	// DiffRenamed indicates that the entry at a path present only in the
+	// old tree was moved to a path present only in the new tree, with the
+	// same or similar contents. It is only reported when renames are
+	// detected (see: DiffOptions).
+	DiffRenamed
+)
+
+// String implements fmt.Stringer and returns a human-readable name for the
+// status.
+func (s DiffStatus) String() string {
+	switch s {
+	case DiffAdded:
+		return "added"
+	case DiffDeleted:
+		return "deleted"
+	case DiffModified:
+		return "modified"
+	case DiffRenamed:
+		return "renamed"
+	}
+	return fmt.Sprintf("<unknown status %d>", uint8(s))
+}
+
+// TreeDiff is a single change to a non-tree path between two trees.
+type TreeDiff struct {
+	// Path is the slash-separated path of the changed entry, relative to
+	// the root of the trees being compared. For renames, it is the path in
+	// the new tree.
+	Path string
+	// OldPath is the path of a renamed entry in the old tree, and is empty
+	// for all other changes.
+	OldPath string
+	// Status is the kind of change made to the entry.
+	Status DiffStatus
+	// Similarity is the percentage of the contents of a renamed entry
+	// which is unchanged, where 100 indicates an exact rename. It is zero
+	// for all other changes.
+	Similarity int
+	// Old is the entry in the old tree, or nil if it was added.
+	Old *TreeEntry
+	// New is the entry in the new tree, or nil if it was deleted.
+	New *TreeEntry
+}
+
+// DiffOptions controls the behavior of DiffTreesWithOptions.
+type DiffOptions struct {
+	// DetectRenames indicates whether an entry deleted from one path and
+	// added at another with the same contents is reported as a single
+	// rename, rather than a deletion and an addition.
+	DetectRenames bool
+	// RenameSimilarity is the minimum similarity, as a percentage, of the
+	// contents of a deleted and an added entry for them to be reported as
+	// a rename when their contents differ. Similarity is estimated from
+	// the sizes of the contents, and the lengths of their common prefix
+	// and suffix. A value of zero or less detects only exact renames,
+	// which is considerably cheaper, since no contents need be read.
+	RenameSimilarity int
+}
+
+// DiffTrees recursively compares the trees named "a" and "b", and returns the
+// changes needed to turn "a" into "b", ordered by path. Either may be nil to
+// compare against the empty tree.
+//
+// Subtrees are descended into rather than reported, so every change refers to
+// a blob, symbolic link, or gitlink. An entry which changes between a subtree
+// and any other type is reported as the deletion of one and the addition of
+// the other.
+//
+// If either tree or any of their subtrees could not be read, an error is
+// returned instead.
+func (o *ObjectDatabase) DiffTrees(a, b []byte) ([]*TreeDiff, error) {
+	return o.DiffTreesWithOptions(a, b, nil)
+}
+
+// DiffTreesWithOptions is as DiffTrees, but with the given options. If "opts"
+// is nil, the default options are used, which are the same as those of
+// DiffTrees.
+func (o *ObjectDatabase) DiffTreesWithOptions(a, b []byte, opts *DiffOptions) ([]*TreeDiff, error) {
+	if opts == nil {
+		opts = new(DiffOptions)
+	}
+
+	var diffs []*TreeDiff
+	if err := o.diffTrees(&diffs, "", a, b, nil); err != nil {
+		return nil, err
+	}
+
+	if opts.DetectRenames {
+		return o.detectRenames(diffs, opts.RenameSimilarity)
+	}
+	return diffs, nil
+}
+
+// DiffTreeToFiles compares the tree named "tree" against the proposed set of
+// entries "files", keyed by their slash-separated path, and returns the changes
+// which replacing the contents of the tree with those entries would make,
+// ordered by path as in DiffTrees. The tree may be nil to compare against the
+// empty tree.
+//
+// Paths which are not in "files", or whose entry is nil, are treated as
+// deleted. No objects are written, and the objects named by "files" need not
+// exist, which makes this suitable for previewing the effect of a commit before
+// writing it.
+//
+// If any entry of "files" is a subtree, or the tree or any of its subtrees
+// could not be read, an error is returned instead.
+func (o *ObjectDatabase) DiffTreeToFiles(tree []byte, files map[string]*TreeEntry) ([]*TreeDiff, error) {
+	paths := make([]string, 0, len(files))
+	for path, entry := range files {
+		if entry == nil {
+			continue
+		}
+		if entry.Type() == TreeObjectType {
+			return nil, fmt.Errorf("gitobj: cannot diff subtree entry at %q", path)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// The flattened contents of the tree are its changes from the empty
+	// tree, in the same order as the sorted paths.
+	var olds []*TreeDiff
+	if err := o.diffTrees(&olds, "", tree, nil, nil); err != nil {
+		return nil, err
+	}
+
+	var diffs []*TreeDiff
+	for len(olds) > 0 || len(paths) > 0 {
+		var diff *TreeDiff
+
+		switch {
+		case len(paths) == 0 || (len(olds) > 0 && olds[0].Path < paths[0]):
+			diff, olds = olds[0], olds[1:]
+		case len(olds) == 0 || paths[0] < olds[0].Path:
+			diff = &TreeDiff{Path: paths[0], Status: DiffAdded, New: files[paths[0]]}
+			paths = paths[1:]
+		default:
+			path, old, new := paths[0], olds[0].Old, files[paths[0]]
+			olds, paths = olds[1:], paths[1:]
+
+			if bytes.Equal(old.Oid, new.Oid) && old.Filemode == new.Filemode {
+				continue
+			}
+			diff = &TreeDiff{Path: path, Status: DiffModified, Old: old, New: new}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// Diff compares the entries of the tree against those of the tree "other", and
+// returns the changes needed to turn the one into the other, in subtree order,
+// as TreeDiffs whose paths are the names of the entries. Either tree may be nil
+// to compare against the empty tree.
+//
+// Unlike DiffTrees, only the entries of the two trees themselves are compared,
+// so a subtree whose object ID has changed is reported as modified, and is not
+// descended into. An entry which changes between a subtree and any other type
+// is reported as the deletion of one and the addition of the other, and an
+// entry whose filemode alone has changed is reported as modified.
+//
+// The entries of both trees are compared in a single pass, which requires them
+// to be in subtree order (see: Tree.Sort), as they are in every tree read from
+// the object database. If either tree's entries are out of order, or it has
+// more than one entry with the same name, an error is returned.
+func (t *Tree) Diff(other *Tree) ([]*TreeDiff, error) {
+	var olds, news []*TreeEntry
+	if t != nil {
+		olds = t.Entries
+	}
+	if other != nil {
+		news = other.Entries
+	}
+
+	for _, entries := range [][]*TreeEntry{olds, news} {
+		for i := 1; i < len(entries); i++ {
+			if compareEntries(entries[i-1], entries[i]) >= 0 {
+				return nil, fmt.Errorf("gitobj: cannot diff tree with entry %q out of order", entries[i].Name)
+			}
+		}
+	}
+
+	var diffs []*TreeDiff
+	for len(olds) > 0 || len(news) > 0 {
+		var old, new *TreeEntry
+
+		switch {
+		case len(news) == 0:
+			old, olds = olds[0], olds[1:]
+		case len(olds) == 0:
+			new, news = news[0], news[1:]
+		default:
+			switch cmp := compareEntries(olds[0], news[0]); {
+			case cmp < 0:
+				old, olds = olds[0], olds[1:]
+			case cmp > 0:
+				new, news = news[0], news[1:]
+			default:
+				old, olds = olds[0], olds[1:]
+				new, news = news[0], news[1:]
+			}
+		}
+
+		switch {
+		case old == nil:
+			diffs = append(diffs, &TreeDiff{Path: new.Name, Status: DiffAdded, New: new})
+		case new == nil:
+			diffs = append(diffs, &TreeDiff{Path: old.Name, Status: DiffDeleted, Old: old})
+		case !bytes.Equal(old.Oid, new.Oid) || old.Filemode != new.Filemode:
+			diffs = append(diffs, &TreeDiff{Path: new.Name, Status: DiffModified, Old: old, New: new})
+		}
+	}
+	return diffs, nil
+}
+
+// diffTrees appends the changes between the trees "a" and "b", whose entries
+// are beneath the path "prefix", to "diffs".
+//
+// If "include" is non-nil, only the entries at paths for which it returns true
+// are compared (or descended into, in the case of subtrees).
+func (o *ObjectDatabase) diffTrees(diffs *[]*TreeDiff, prefix string, a, b []byte, include func(path string) bool) error {
+	if a != nil && b != nil && bytes.Equal(a, b) {
+		return nil
+	}
+
+	olds, err := o.sortedEntries(a)
+	if err != nil {
+		return err
+	}
+	news, err := o.sortedEntries(b)
+	if err != nil {
+		return err
+	}
+
+	for len(olds) > 0 || len(news) > 0 {
+		var old, new *TreeEntry
+
+		switch {
+		case len(news) == 0:
+			old, olds = olds[0], olds[1:]
+		case len(olds) == 0:
+			new, news = news[0], news[1:]
+		default:
+			switch cmp := compareEntries(olds[0], news[0]); {
+			case cmp < 0:
+				old, olds = olds[0], olds[1:]
+			case cmp > 0:
+				new, news = news[0], news[1:]
+			default:
+				old, olds = olds[0], olds[1:]
+				new, news = news[0], news[1:]
+			}
+		}
+
+		var entry *TreeEntry
+		if entry = old; entry == nil {
+			entry = new
+		}
+		path := prefix + entry.Name
+		if include != nil && !include(path) {
+			continue
+		}
+
+		if entry.Type() == TreeObjectType {
+			var from, to []byte
+			if old != nil {
+				from = old.Oid
+			}
+			if new != nil {
+				to = new.Oid
+			}
+
+			if err := o.diffTrees(diffs, path+"/", from, to, include); err != nil {
+				return err
+			}
+			continue
+		}
+
+		diff := &TreeDiff{Path: path, Old: old, New: new}
+		switch {
+		case old == nil:
+			diff.Status = DiffAdded
+		case new == nil:
+			diff.Status = DiffDeleted
+		case !bytes.Equal(old.Oid, new.Oid) || old.Filemode != new.Filemode:
+			diff.Status = DiffModified
+		default:
+			continue
+		}
+		*diffs = append(*diffs, diff)
+	}
+	return nil
+}
+
+// sortedEntries returns the entries of the tree named "sha" in subtree order,
+// or no entries if "sha" is nil.
+func (o *ObjectDatabase) sortedEntries(sha []byte) ([]*TreeEntry, error) {
+	if sha == nil {
+		return nil, nil
+	}
+
+	tree, err := o.Tree(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*TreeEntry, len(tree.Entries))
+	copy(entries, tree.Entries)
+	sort.Stable(SubtreeOrder(entries))
+
+	return entries, nil
+}
+
+// compareEntries compares two tree entries in subtree order (see:
+// SubtreeOrder). Entries which compare equal have the same name and are either
+// both subtrees, or both not.
+func compareEntries(a, b *TreeEntry) int {
+	order := SubtreeOrder{a, b}
+	return strings.Compare(order.Name(0), order.Name(1))
+}
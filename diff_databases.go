@@ -0,0 +1,38 @@
+package gitobj
+
+import "bytes"
+
+// DiffDatabases compares the objects held by the databases "a" and "b", and
+// returns the IDs of those held only by "a", and of those held only by "b",
+// each in ascending order. This is suitable for checking that a mirror or
+// migration copied every object.
+//
+// Both databases are listed concurrently, in order, and merged as they are
+// read, so neither set of objects is held in memory in its entirety. The
+// implicit empty objects are only considered if they are stored.
+//
+// If either database could not be listed, an error is returned instead.
+func DiffDatabases(a, b *ObjectDatabase) (onlyA, onlyB [][]byte, err error) {
+	sa, sb := newObjectStream(a.eachObject), newObjectStream(b.eachObject)
+	sa.next()
+	sb.next()
+
+	for sa.head != nil || sb.head != nil {
+		switch {
+		case sb.head == nil || (sa.head != nil && bytes.Compare(sa.head, sb.head) < 0):
+			onlyA = append(onlyA, sa.head)
+			sa.next()
+		case sa.head == nil || bytes.Compare(sb.head, sa.head) < 0:
+			onlyB = append(onlyB, sb.head)
+			sb.next()
+		default:
+			sa.next()
+			sb.next()
+		}
+	}
+
+	if err = closeStreams([]*objectStream{sa, sb}); err != nil {
+		return nil, nil, err
+	}
+	return onlyA, onlyB, nil
+}
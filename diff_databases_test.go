@@ -0,0 +1,90 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDatabases(t *testing.T) {
+	a, b := newTestDatabase(t), newTestDatabase(t)
+
+	shared := writeTestBlob(t, a, "shared\n")
+	assert.Equal(t, shared, writeTestBlob(t, b, "shared\n"))
+
+	onlyA := writeTestBlob(t, a, "only in a\n")
+	onlyB := writeTestBlob(t, b, "only in b\n")
+
+	gotA, gotB, err := DiffDatabases(a, b)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{onlyA}, gotA)
+	assert.Equal(t, [][]byte{onlyB}, gotB)
+}
+
+func TestDiffDatabasesIdentical(t *testing.T) {
+	a, b := newTestDatabase(t), newTestDatabase(t)
+	for _, contents := range []string{"one\n", "two\n", "three\n"} {
+		writeTestBlob(t, a, contents)
+		writeTestBlob(t, b, contents)
+	}
+
+	onlyA, onlyB, err := DiffDatabases(a, b)
+
+	assert.NoError(t, err)
+	assert.Empty(t, onlyA)
+	assert.Empty(t, onlyB)
+}
+
+func TestDiffDatabasesPackedAndLoose(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	packed := runTestGit(t, dir, "packed\n", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, "", "repack", "-a", "-d", "-q")
+	runTestGit(t, dir, "loose\n", "hash-object", "-w", "--stdin")
+
+	fs, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer fs.Close()
+
+	mem := newTestDatabase(t)
+	writeTestBlob(t, mem, "loose\n")
+	memory := writeTestBlob(t, mem, "memory\n")
+
+	onlyA, onlyB, err := DiffDatabases(fs, mem)
+
+	require.NoError(t, err)
+	require.Len(t, onlyA, 1)
+	assert.Equal(t, packed, hex.EncodeToString(onlyA[0]))
+	assert.Equal(t, [][]byte{memory}, onlyB)
+}
+
+func TestDiffDatabasesGitBackend(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	runTestGit(t, dir, "packed\n", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, "", "repack", "-a", "-d", "-q")
+	runTestGit(t, dir, "loose\n", "hash-object", "-w", "--stdin")
+
+	fs, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer fs.Close()
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+	git, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer git.Close()
+
+	onlyA, onlyB, err := DiffDatabases(fs, git)
+
+	assert.NoError(t, err)
+	assert.Empty(t, onlyA)
+	assert.Empty(t, onlyB)
+}
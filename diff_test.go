@@ -0,0 +1,290 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffTrees(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	goodbye, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "nested.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "deleted.txt", Oid: hello, Filemode: 0100644},
+		{Name: "dir", Oid: subtree, Filemode: 040000},
+		{Name: "modified.txt", Oid: hello, Filemode: 0100644},
+		{Name: "same.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "added.txt", Oid: goodbye, Filemode: 0100644},
+		{Name: "dir", Oid: goodbye, Filemode: 0100755},
+		{Name: "modified.txt", Oid: goodbye, Filemode: 0100644},
+		{Name: "same.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTrees(a, b)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*TreeDiff{
+		{
+			Path:   "added.txt",
+			Status: DiffAdded,
+			New:    &TreeEntry{Name: "added.txt", Oid: goodbye, Filemode: 0100644},
+		},
+		{
+			Path:   "deleted.txt",
+			Status: DiffDeleted,
+			Old:    &TreeEntry{Name: "deleted.txt", Oid: hello, Filemode: 0100644},
+		},
+		{
+			Path:   "dir",
+			Status: DiffAdded,
+			New:    &TreeEntry{Name: "dir", Oid: goodbye, Filemode: 0100755},
+		},
+		{
+			Path:   "dir/nested.txt",
+			Status: DiffDeleted,
+			Old:    &TreeEntry{Name: "nested.txt", Oid: hello, Filemode: 0100644},
+		},
+		{
+			Path:   "modified.txt",
+			Status: DiffModified,
+			Old:    &TreeEntry{Name: "modified.txt", Oid: hello, Filemode: 0100644},
+			New:    &TreeEntry{Name: "modified.txt", Oid: goodbye, Filemode: 0100644},
+		},
+	}, diffs)
+}
+
+func TestDiffTreesAgainstEmptyTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	added, err := odb.DiffTrees(nil, tree)
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	assert.Equal(t, DiffAdded, added[0].Status)
+	assert.Equal(t, "hello.txt", added[0].Path)
+
+	deleted, err := odb.DiffTrees(tree, nil)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, DiffDeleted, deleted[0].Status)
+
+	same, err := odb.DiffTrees(tree, tree)
+	require.NoError(t, err)
+	assert.Empty(t, same)
+}
+
+func TestDiffTreesReportsFilemodeChanges(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("#!/bin/sh\n")))
+	require.NoError(t, err)
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "script.sh", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "script.sh", Oid: blob, Filemode: 0100755},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTrees(a, b)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+
+	assert.Equal(t, DiffModified, diffs[0].Status)
+	assert.EqualValues(t, 0100644, diffs[0].Old.Filemode)
+	assert.EqualValues(t, 0100755, diffs[0].New.Filemode)
+}
+
+func TestDiffTreesMissingTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	diffs, err := odb.DiffTrees(nil, []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	assert.Error(t, err)
+	assert.Nil(t, diffs)
+}
+
+func TestDiffStatusString(t *testing.T) {
+	assert.Equal(t, "added", DiffAdded.String())
+	assert.Equal(t, "deleted", DiffDeleted.String())
+	assert.Equal(t, "modified", DiffModified.String())
+	assert.Equal(t, "renamed", DiffRenamed.String())
+	assert.Equal(t, "<unknown status 255>", DiffStatus(255).String())
+}
+
+func TestDiffTreeToFiles(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	goodbye := []byte("aaaaaaaaaaaaaaaaaaaa")
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "nested.txt", Oid: hello, Filemode: 0100644},
+		{Name: "same.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "deleted.txt", Oid: hello, Filemode: 0100644},
+		{Name: "dir", Oid: subtree, Filemode: 040000},
+		{Name: "modified.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTreeToFiles(tree, map[string]*TreeEntry{
+		"added.txt":      {Name: "added.txt", Oid: goodbye, Filemode: 0100644},
+		"dir/nested.txt": {Name: "nested.txt", Oid: hello, Filemode: 0100755},
+		"dir/same.txt":   {Name: "same.txt", Oid: hello, Filemode: 0100644},
+		"modified.txt":   {Name: "modified.txt", Oid: goodbye, Filemode: 0100644},
+		"removed.txt":    nil,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*TreeDiff{
+		{
+			Path:   "added.txt",
+			Status: DiffAdded,
+			New:    &TreeEntry{Name: "added.txt", Oid: goodbye, Filemode: 0100644},
+		},
+		{
+			Path:   "deleted.txt",
+			Status: DiffDeleted,
+			Old:    &TreeEntry{Name: "deleted.txt", Oid: hello, Filemode: 0100644},
+		},
+		{
+			Path:   "dir/nested.txt",
+			Status: DiffModified,
+			Old:    &TreeEntry{Name: "nested.txt", Oid: hello, Filemode: 0100644},
+			New:    &TreeEntry{Name: "nested.txt", Oid: hello, Filemode: 0100755},
+		},
+		{
+			Path:   "modified.txt",
+			Status: DiffModified,
+			Old:    &TreeEntry{Name: "modified.txt", Oid: hello, Filemode: 0100644},
+			New:    &TreeEntry{Name: "modified.txt", Oid: goodbye, Filemode: 0100644},
+		},
+	}, diffs)
+}
+
+func TestDiffTreeToFilesAgainstEmptyTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := []byte("aaaaaaaaaaaaaaaaaaaa")
+
+	diffs, err := odb.DiffTreeToFiles(nil, map[string]*TreeEntry{
+		"a/b.txt": {Name: "b.txt", Oid: blob, Filemode: 0100644},
+		"a.txt":   {Name: "a.txt", Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "a.txt", diffs[0].Path)
+	assert.Equal(t, "a/b.txt", diffs[1].Path)
+	assert.Equal(t, DiffAdded, diffs[0].Status)
+	assert.Equal(t, DiffAdded, diffs[1].Status)
+}
+
+func TestDiffTreeToFilesRejectsSubtrees(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	diffs, err := odb.DiffTreeToFiles(nil, map[string]*TreeEntry{
+		"dir": {Name: "dir", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 040000},
+	})
+
+	assert.EqualError(t, err, "gitobj: cannot diff subtree entry at \"dir\"")
+	assert.Nil(t, diffs)
+}
+
+func TestTreeDiff(t *testing.T) {
+	a, b, c := []byte("aaaaaaaaaaaaaaaaaaaa"), []byte("bbbbbbbbbbbbbbbbbbbb"), []byte("cccccccccccccccccccc")
+
+	old := &Tree{Entries: []*TreeEntry{
+		{Name: "deleted.txt", Oid: a, Filemode: 0100644},
+		{Name: "dir", Oid: a, Filemode: 040000},
+		{Name: "mode.sh", Oid: b, Filemode: 0100644},
+		{Name: "modified.txt", Oid: a, Filemode: 0100644},
+		{Name: "same.txt", Oid: c, Filemode: 0100644},
+		{Name: "type", Oid: a, Filemode: 040000},
+	}}
+	new := &Tree{Entries: []*TreeEntry{
+		{Name: "added.txt", Oid: a, Filemode: 0100644},
+		{Name: "dir", Oid: b, Filemode: 040000},
+		{Name: "mode.sh", Oid: b, Filemode: 0100755},
+		{Name: "modified.txt", Oid: b, Filemode: 0100644},
+		{Name: "same.txt", Oid: c, Filemode: 0100644},
+		{Name: "type", Oid: a, Filemode: 0100644},
+	}}
+
+	diffs, err := old.Diff(new)
+	require.NoError(t, err)
+
+	type change struct {
+		Path   string
+		Status DiffStatus
+	}
+	var changes []change
+	for _, diff := range diffs {
+		changes = append(changes, change{diff.Path, diff.Status})
+	}
+
+	assert.Equal(t, []change{
+		{"added.txt", DiffAdded},
+		{"deleted.txt", DiffDeleted},
+		{"dir", DiffModified},
+		{"mode.sh", DiffModified},
+		{"modified.txt", DiffModified},
+		{"type", DiffAdded},
+		{"type", DiffDeleted},
+	}, changes)
+
+	assert.Equal(t, int32(0100644), diffs[3].Old.Filemode)
+	assert.Equal(t, int32(0100755), diffs[3].New.Filemode)
+}
+
+func TestTreeDiffAgainstNil(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0100644},
+	}}
+
+	diffs, err := tree.Diff(nil)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, DiffDeleted, diffs[0].Status)
+	assert.Nil(t, diffs[0].New)
+}
+
+func TestTreeDiffRejectsUnsortedEntries(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "b.txt", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0100644},
+		{Name: "a.txt", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0100644},
+	}}
+
+	_, err := new(Tree).Diff(tree)
+	assert.EqualError(t, err, `gitobj: cannot diff tree with entry "a.txt" out of order`)
+}
@@ -0,0 +1,64 @@
+package gitobj
+
+// DirSizes returns the total inflated size of the blobs (including symbolic
+// links, but not gitlinks) beneath each directory of the tree named "root",
+// keyed by the directory's slash-separated path relative to the root. The
+// total of each directory includes those of its subdirectories, and the root
+// itself is keyed by the empty path.
+//
+// The tree is traversed once, and the size of each blob is read from its header
+// alone. A subtree or blob which appears at more than one path is only read and
+// summed the first time it is encountered.
+//
+// If the tree, any of its subtrees, or the header of any blob could not be
+// read, an error is returned instead.
+func (o *ObjectDatabase) DirSizes(root []byte) (map[string]int64, error) {
+	return o.dirSizes(root, make(map[string]map[string]int64), make(map[string]int64))
+}
+
+// dirSizes returns the sizes of the directories beneath the tree named "tree",
+// with paths relative to it. The result for each subtree is recorded in "seen",
+// and the size of each blob in "sizes", so that neither is read again. The
+// result must not be modified, since it may be shared.
+func (o *ObjectDatabase) dirSizes(tree []byte, seen map[string]map[string]int64, sizes map[string]int64) (map[string]int64, error) {
+	if dirs, ok := seen[string(tree)]; ok {
+		return dirs, nil
+	}
+
+	t, err := o.Tree(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]int64{"": 0}
+	for _, entry := range t.Entries {
+		switch entry.Type() {
+		case BlobObjectType:
+			size, ok := sizes[string(entry.Oid)]
+			if !ok {
+				if size, err = o.blobSize(entry.Oid); err != nil {
+					return nil, err
+				}
+				sizes[string(entry.Oid)] = size
+			}
+			dirs[""] += size
+		case TreeObjectType:
+			sub, err := o.dirSizes(entry.Oid, seen, sizes)
+			if err != nil {
+				return nil, err
+			}
+
+			for path, size := range sub {
+				if len(path) == 0 {
+					dirs[entry.Name] = size
+					dirs[""] += size
+				} else {
+					dirs[entry.Name+"/"+path] = size
+				}
+			}
+		}
+	}
+
+	seen[string(tree)] = dirs
+	return dirs, nil
+}
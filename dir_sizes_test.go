@@ -0,0 +1,67 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSizes(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	shared, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: writeTestBlob(t, odb, "aaaa"), Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	nested, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "b.txt", Oid: writeTestBlob(t, odb, "bb"), Filemode: 0100644},
+		{Name: "shared", Oid: shared, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "link", Oid: writeTestBlob(t, odb, "c"), Filemode: 0120000},
+		{Name: "nested", Oid: nested, Filemode: 040000},
+		{Name: "shared", Oid: shared, Filemode: 040000},
+		{Name: "submodule", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	sizes, err := odb.DirSizes(root)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		"":              11,
+		"nested":        6,
+		"nested/shared": 4,
+		"shared":        4,
+	}, sizes)
+}
+
+func TestDirSizesEmptyTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	sizes, err := odb.DirSizes(root)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"": 0}, sizes)
+}
+
+func TestDirSizesMissingBlob(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	sizes, err := odb.DirSizes(root)
+
+	assert.Error(t, err)
+	assert.Nil(t, sizes)
+}
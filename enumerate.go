@@ -0,0 +1,316 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// objectEnumerator is implemented by storage which can list the objects it
+// holds.
+type objectEnumerator interface {
+	// ForEach calls "fn" with the ID of each object in the storage, in
+	// ascending order, stopping at the first error. Each ID given must not
+	// be modified afterwards.
+	ForEach(fn func(oid []byte) error) error
+}
+
+// objectSeeker is implemented by storage which can list the objects it holds
+// beginning at a given ID, without listing those before it.
+type objectSeeker interface {
+	// ForEachAfter is as ForEach, but only gives the IDs greater than
+	// "after".
+	ForEachAfter(after []byte, fn func(oid []byte) error) error
+}
+
+// errStreamClosed is returned to an enumeration by an *objectStream whose
+// consumer has stopped reading from it.
+var errStreamClosed = fmt.Errorf("gitobj: object stream closed")
+
+// objectStream yields the object IDs given by an enumeration running in its own
+// goroutine, so that several enumerations may be merged without holding any of
+// them in memory.
+type objectStream struct {
+	// ids yields each ID given by the enumeration, and is closed once it
+	// has finished.
+	ids chan []byte
+	// done is closed when the consumer stops reading from "ids".
+	done chan struct{}
+	// err is the error returned by the enumeration, which may be read
+	// once "ids" is closed.
+	err error
+
+	// head is the ID most recently read from "ids", or nil if the stream
+	// is exhausted.
+	head []byte
+}
+
+// newObjectStream starts the enumeration "each", and returns an *objectStream
+// yielding the IDs that it gives.
+func newObjectStream(each func(fn func(oid []byte) error) error) *objectStream {
+	s := &objectStream{
+		ids:  make(chan []byte, 64),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.ids)
+
+		err := each(func(oid []byte) error {
+			select {
+			case s.ids <- oid:
+				return nil
+			case <-s.done:
+				return errStreamClosed
+			}
+		})
+		if err != errStreamClosed {
+			s.err = err
+		}
+	}()
+	return s
+}
+
+// next advances the stream, and returns whether there is another ID at its
+// head.
+func (s *objectStream) next() bool {
+	s.head = <-s.ids
+	return s.head != nil
+}
+
+// close stops the enumeration, waits for it to finish, and returns the error
+// it encountered, if any.
+func (s *objectStream) close() error {
+	close(s.done)
+	for range s.ids {
+	}
+	return s.err
+}
+
+// closeStreams closes each of the given streams, and returns the first error
+// that any of them encountered.
+func closeStreams(streams []*objectStream) error {
+	var first error
+	for _, s := range streams {
+		if err := s.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// eachObject calls "fn" with the ID of each object in the database, in
+// ascending order, stopping at the first error. Objects held by more than one
+// storage are only given once, and the implicit empty objects are not given
+// unless they are stored.
+//
+// If any storage in the database cannot list its objects, an error is returned
+// without calling "fn".
+func (o *ObjectDatabase) eachObject(fn func(oid []byte) error) error {
+	return o.eachObjectAfter(nil, fn)
+}
+
+// eachObjectAfter is as eachObject, but only gives the IDs greater than "after",
+// unless it is nil. Storage which can seek to "after" (see: objectSeeker) does
+// not list the objects before it.
+func (o *ObjectDatabase) eachObjectAfter(after []byte, fn func(oid []byte) error) error {
+	return o.mergeObjects(after, func(oid []byte, from storage.Storage) error {
+		return fn(oid)
+	})
+}
+
+// mergeObjects is as eachObjectAfter, but also gives "fn" the first storage (in
+// the order in which they are searched) which holds each object. The objects
+// listed by each storage are merged as they are read, so none of them are held
+// in memory in their entirety.
+func (o *ObjectDatabase) mergeObjects(after []byte, fn func(oid []byte, from storage.Storage) error) error {
+	all := o.storages()
+
+	enumerators := make([]objectEnumerator, 0, len(all))
+	for _, s := range all {
+		e, ok := s.(objectEnumerator)
+		if !ok {
+			return fmt.Errorf("gitobj: cannot list objects in storage of type %T", s)
+		}
+		enumerators = append(enumerators, e)
+	}
+
+	streams := make([]*objectStream, 0, len(enumerators))
+	for _, e := range enumerators {
+		s := newObjectStream(seek(e, after))
+		s.next()
+
+		streams = append(streams, s)
+	}
+
+	for {
+		var next []byte
+		var from storage.Storage
+		for i, s := range streams {
+			if s.head != nil && (next == nil || bytes.Compare(s.head, next) < 0) {
+				next, from = s.head, all[i]
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		if err := fn(next, from); err != nil {
+			closeStreams(streams)
+			return err
+		}
+
+		for _, s := range streams {
+			if s.head != nil && bytes.Equal(s.head, next) {
+				s.next()
+			}
+		}
+	}
+	return closeStreams(streams)
+}
+
+// seek returns an enumeration of the objects listed by "e" whose IDs are greater
+// than "after", or all of them if "after" is nil.
+func seek(e objectEnumerator, after []byte) func(fn func(oid []byte) error) error {
+	if after == nil {
+		return e.ForEach
+	}
+	if s, ok := e.(objectSeeker); ok {
+		return func(fn func(oid []byte) error) error {
+			return s.ForEachAfter(after, fn)
+		}
+	}
+	return func(fn func(oid []byte) error) error {
+		return e.ForEach(func(oid []byte) error {
+			if bytes.Compare(oid, after) <= 0 {
+				return nil
+			}
+			return fn(oid)
+		})
+	}
+}
+
+// errPageFull is returned to an enumeration by EachObjectPage once it has
+// found every object it needs.
+var errPageFull = fmt.Errorf("gitobj: object page full")
+
+// EachObjectPage returns a page of at most "limit" object IDs from the
+// database, in ascending order, beginning after the position given by the
+// cursor "after", along with a cursor for the next page. The first page is
+// returned for an empty cursor, and the returned cursor is empty once there are
+// no more pages.
+//
+// Cursors are opaque, but remain valid across calls (and across databases
+// opened on the same storage), so pages may be requested at any time. Since
+// objects are given in order of ID, which is stable, an object added or removed
+// between requests only affects pages beyond the one in which it falls.
+//
+// Objects held by more than one storage are only given once, and the implicit
+// empty objects are not given unless they are stored. Only one page of IDs is
+// held in memory at once, and the objects before the cursor are skipped using
+// the fanout of each packfile index and loose object directory, rather than
+// listed.
+//
+// If "limit" is not positive, the cursor is malformed, or any storage in the
+// database cannot list its objects, an error is returned instead.
+func (o *ObjectDatabase) EachObjectPage(after string, limit int) (oids [][]byte, next string, err error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("gitobj: invalid object page limit: %d", limit)
+	}
+
+	var start []byte
+	if len(after) > 0 {
+		start, err = hex.DecodeString(after)
+		if err != nil || len(start) != o.HashLen() {
+			return nil, "", fmt.Errorf("gitobj: invalid object page cursor: %q", after)
+		}
+	}
+
+	var more bool
+	err = o.eachObjectAfter(start, func(oid []byte) error {
+		if len(oids) == limit {
+			more = true
+			return errPageFull
+		}
+
+		oids = append(oids, oid)
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return nil, "", err
+	}
+
+	if more {
+		next = hex.EncodeToString(oids[len(oids)-1])
+	}
+	return oids, next, nil
+}
+
+// objectTyper is implemented by storage which can give the type of an object
+// that it holds without reading the object's contents.
+type objectTyper interface {
+	// ObjectType returns the type of the object "oid", as named in its
+	// header ("blob", "tree", "commit", or "tag").
+	ObjectType(oid []byte) (string, error)
+}
+
+// ForEachObject calls "fn" with the ID and type of each object in the database,
+// loose or packed, in ascending order of ID, stopping at the first error, which
+// is returned. An object held more than once, such as both loose and packed, is
+// only given once. The implicit empty objects are not given unless they are
+// stored.
+//
+// The objects listed by each storage are merged as they are read, as by
+// EachObjectPage, so none of their IDs are held in memory in their entirety.
+// Each type is given by the storage holding the object (see: objectTyper), or
+// is otherwise read from the object's header, so no object's contents are
+// read.
+//
+// If any storage in the database cannot list its objects, an error is returned
+// without calling "fn".
+func (o *ObjectDatabase) ForEachObject(fn func(oid []byte, typ ObjectType) error) error {
+	return o.mergeObjects(nil, func(oid []byte, from storage.Storage) error {
+		typ, err := storedObjectType(from, oid)
+		if err != nil {
+			return err
+		}
+		return fn(oid, typ)
+	})
+}
+
+// storedObjectType returns the type of the object "oid" held by the storage
+// "s", reading only the object's header if the storage cannot give its type
+// otherwise.
+func storedObjectType(s storage.Storage, oid []byte) (ObjectType, error) {
+	if t, ok := s.(objectTyper); ok {
+		typ, err := t.ObjectType(oid)
+		if err != nil {
+			return UnknownObjectType, err
+		}
+		return ObjectTypeFromString(typ), nil
+	}
+
+	f, err := s.Open(oid)
+	if err != nil {
+		return UnknownObjectType, err
+	}
+
+	var r *ObjectReader
+	if s.IsCompressed() {
+		r, err = NewObjectReadCloser(f)
+	} else {
+		r, err = NewUncompressedObjectReadCloser(f)
+	}
+	if err != nil {
+		f.Close()
+		return UnknownObjectType, err
+	}
+
+	typ, _, err := r.Header()
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return typ, err
+}
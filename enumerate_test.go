@@ -0,0 +1,205 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEachObjectPage(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	var want [][]byte
+	for i := 0; i < 5; i++ {
+		want = append(want, writeTestBlob(t, odb, fmt.Sprintf("blob %d", i)))
+	}
+	sort.Slice(want, func(i, j int) bool {
+		return bytes.Compare(want[i], want[j]) < 0
+	})
+
+	var got [][]byte
+	var pages int
+	for cursor := ""; ; pages++ {
+		oids, next, err := odb.EachObjectPage(cursor, 2)
+		require.NoError(t, err)
+		assert.True(t, len(oids) <= 2)
+
+		got = append(got, oids...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, 2, pages)
+}
+
+func TestEachObjectPagePackedAndLoose(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	var want []string
+	for i := 0; i < 6; i++ {
+		want = append(want, runTestGit(t, dir, fmt.Sprintf("packed %d\n", i), "hash-object", "-w", "--stdin"))
+	}
+	runTestGit(t, dir, strings.Join(want, "\n")+"\n", "pack-objects", "-q", filepath.Join(dir, "objects", "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+	for i := 0; i < 6; i++ {
+		want = append(want, runTestGit(t, dir, fmt.Sprintf("loose %d\n", i), "hash-object", "-w", "--stdin"))
+	}
+	sort.Strings(want)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	var got []string
+	for cursor := ""; ; {
+		oids, next, err := odb.EachObjectPage(cursor, 5)
+		require.NoError(t, err)
+
+		for _, oid := range oids {
+			got = append(got, hex.EncodeToString(oid))
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestEachObjectPageExactLimit(t *testing.T) {
+	odb := newTestDatabase(t)
+	writeTestBlob(t, odb, "a")
+	writeTestBlob(t, odb, "b")
+
+	oids, next, err := odb.EachObjectPage("", 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, oids, 2)
+	assert.Empty(t, next)
+}
+
+func TestEachObjectPageIsStable(t *testing.T) {
+	odb := newTestDatabase(t)
+	for i := 0; i < 4; i++ {
+		writeTestBlob(t, odb, fmt.Sprintf("blob %d", i))
+	}
+
+	first, cursor, err := odb.EachObjectPage("", 2)
+	require.NoError(t, err)
+
+	again, _, err := odb.EachObjectPage(cursor, 2)
+	require.NoError(t, err)
+	second, _, err := odb.EachObjectPage(cursor, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, again, second)
+	for _, oid := range second {
+		assert.True(t, bytes.Compare(first[len(first)-1], oid) < 0)
+	}
+}
+
+func TestEachObjectPageInvalidArguments(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, _, err := odb.EachObjectPage("", 0)
+	assert.EqualError(t, err, "gitobj: invalid object page limit: 0")
+
+	_, _, err = odb.EachObjectPage("not a cursor", 1)
+	assert.EqualError(t, err, `gitobj: invalid object page cursor: "not a cursor"`)
+}
+
+func TestForEachObject(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	blob := runTestGit(t, dir, "packed\n", "hash-object", "-w", "--stdin")
+	tree := runTestGit(t, dir, fmt.Sprintf("100644 blob %s\tpacked.txt\n", blob), "mktree")
+
+	// Without "-d", the packed objects are also left loose.
+	runTestGit(t, dir, "", "repack", "-a", "-q")
+	loose := runTestGit(t, dir, "loose\n", "hash-object", "-w", "--stdin")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	got := make(map[string]ObjectType)
+	err = odb.ForEachObject(func(oid []byte, typ ObjectType) error {
+		_, ok := got[hex.EncodeToString(oid)]
+		assert.False(t, ok, "object %x given more than once", oid)
+
+		got[hex.EncodeToString(oid)] = typ
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]ObjectType{
+		blob:  BlobObjectType,
+		tree:  TreeObjectType,
+		loose: BlobObjectType,
+	}, got)
+}
+
+func TestForEachObjectInOrder(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	blob := runTestGit(t, dir, "blob\n", "hash-object", "-w", "--stdin")
+	tree := runTestGit(t, dir, fmt.Sprintf("100644 blob %s\tblob.txt\n", blob), "mktree")
+	runTestGit(t, dir, blob+"\n"+tree+"\n", "pack-objects", "-q", filepath.Join(dir, "objects", "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+	loose := runTestGit(t, dir, "loose\n", "hash-object", "-w", "--stdin")
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer odb.Close()
+
+	var got []string
+	types := make(map[string]ObjectType)
+	err = odb.ForEachObject(func(oid []byte, typ ObjectType) error {
+		got = append(got, hex.EncodeToString(oid))
+		types[hex.EncodeToString(oid)] = typ
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.True(t, sort.StringsAreSorted(got))
+	assert.Equal(t, map[string]ObjectType{
+		blob:  BlobObjectType,
+		tree:  TreeObjectType,
+		loose: BlobObjectType,
+	}, types)
+}
+
+func TestForEachObjectStopsOnError(t *testing.T) {
+	odb := newTestDatabase(t)
+	writeTestBlob(t, odb, "a")
+	writeTestBlob(t, odb, "b")
+
+	stop := fmt.Errorf("stop")
+
+	var calls int
+	err := odb.ForEachObject(func(oid []byte, typ ObjectType) error {
+		calls++
+		return stop
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
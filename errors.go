@@ -1,6 +1,9 @@
 package gitobj
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // UnexpectedObjectType is an error type that represents a scenario where an
 // object was requested of a given type "Wanted", and received as a different
@@ -16,3 +19,58 @@ type UnexpectedObjectType struct {
 func (e *UnexpectedObjectType) Error() string {
 	return fmt.Sprintf("gitobj: unexpected object type, got: %q, wanted: %q", e.Got, e.Wanted)
 }
+
+// InvalidCommitError is an error type that describes the problems found with a
+// commit by Commit.Validate.
+type InvalidCommitError struct {
+	// Problems describes each problem found, in the order they were found.
+	Problems []string
+}
+
+// Error implements the error.Error() function.
+func (e *InvalidCommitError) Error() string {
+	return fmt.Sprintf("gitobj: invalid commit: %s", strings.Join(e.Problems, "; "))
+}
+
+// DetachedHeadError is an error type returned by HeadRef when HEAD points
+// directly at an object, rather than at a reference.
+type DetachedHeadError struct {
+	// Oid is the ID of the object that HEAD points at.
+	Oid []byte
+}
+
+// Error implements the error.Error() function.
+func (e *DetachedHeadError) Error() string {
+	return fmt.Sprintf("gitobj: HEAD is detached at %x", e.Oid)
+}
+
+// NoSuchPathError is an error type returned when a path could not be found
+// beneath a tree, or did not name an entry of the kind required.
+type NoSuchPathError struct {
+	// Path is the slash-separated path which could not be found.
+	Path string
+}
+
+// Error implements the error.Error() function.
+func (e *NoSuchPathError) Error() string {
+	return fmt.Sprintf("gitobj: no such path: %q", e.Path)
+}
+
+// BlobSizeError is an error type returned when writing a blob whose contents
+// are not of the size that it declares.
+type BlobSizeError struct {
+	// Size is the size which the blob declared.
+	Size int64
+	// Read is the number of bytes read from the blob's contents, which is
+	// at most one more than its size, since no more are read once the
+	// contents are known to be too long.
+	Read int64
+}
+
+// Error implements the error.Error() function.
+func (e *BlobSizeError) Error() string {
+	if e.Read > e.Size {
+		return fmt.Sprintf("gitobj: blob has more than its declared size of %d bytes", e.Size)
+	}
+	return fmt.Sprintf("gitobj: blob ended after %d of its declared size of %d bytes", e.Read, e.Size)
+}
@@ -13,3 +13,9 @@ func TestUnexpectedObjectTypeErrFormatting(t *testing.T) {
 
 	assert.Equal(t, "gitobj: unexpected object type, got: \"tree\", wanted: \"blob\"", err.Error())
 }
+
+func TestNoSuchPathErrorFormatting(t *testing.T) {
+	err := &NoSuchPathError{Path: "a/b"}
+
+	assert.Equal(t, "gitobj: no such path: \"a/b\"", err.Error())
+}
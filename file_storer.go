@@ -1,12 +1,16 @@
 package gitobj
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/git-lfs/gitobj/v2/errors"
 )
@@ -19,6 +23,28 @@ type fileStorer struct {
 
 	// temp directory, defaults to os.TempDir
 	tmp string
+
+	// fileMode and dirMode are the modes given to the object files and
+	// fan-out directories created by this storer, respectively, if shared
+	// is set. Otherwise, object files are created read-only, and
+	// directories with the default permissions, both subject to the
+	// process's umask.
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	shared   bool
+
+	// fsync indicates whether each object stored, and the directory entry
+	// naming it, are flushed to stable storage.
+	fsync bool
+
+	// mu guards batches and dirty, below.
+	mu *sync.Mutex
+	// batches is the number of batches begun, but not yet ended. While
+	// it is non-zero, directories are not synced as objects are stored.
+	batches int
+	// dirty is the set of directories whose sync has been deferred until
+	// the end of the outermost batch.
+	dirty map[string]struct{}
 }
 
 // NewFileStorer returns a new fileStorer instance with the given root.
@@ -26,6 +52,7 @@ func newFileStorer(root, tmp string) *fileStorer {
 	return &fileStorer{
 		root: root,
 		tmp:  tmp,
+		mu:   new(sync.Mutex),
 	}
 }
 
@@ -43,12 +70,34 @@ func (fs *fileStorer) Open(sha []byte) (f io.ReadCloser, err error) {
 	return f, err
 }
 
+// Has returns whether a loose object file exists for the object "sha", without
+// opening it.
+func (fs *fileStorer) Has(sha []byte) (bool, error) {
+	_, err := os.Stat(fs.path(sha))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // Store implements the storer.Store function and returns the number of bytes
 // written, along with any error encountered in copying the given io.Reader, "r"
 // into the object database on disk at a path given by "sha".
 //
 // If the file could not be created, or opened, an error will be returned.
 func (fs *fileStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
+	n, _, err = fs.StoreCreated(sha, r)
+	return n, err
+}
+
+// StoreCreated is as Store, but additionally returns whether a new object was
+// created.
+//
+// The object is moved into place by hard-linking it, which fails if the object
+// already exists, so that the result is accurate even if another process
+// stores the same object at the same time. Where hard links are not supported,
+// it is renamed into place instead.
+func (fs *fileStorer) StoreCreated(sha []byte, r io.Reader) (n int64, created bool, err error) {
 	path := fs.path(sha)
 	dir := filepath.Dir(path)
 
@@ -56,39 +105,177 @@ func (fs *fileStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
 		// If the file already exists, there is no work left for us to
 		// do, since the object already exists (or there is a SHA1
 		// collision).
-		_, err = io.Copy(ioutil.Discard, r)
-		if err != nil {
-			return 0, fmt.Errorf("discard pre-existing object data: %s", err)
-		}
-
-		return 0, nil
+		return 0, false, discard(r)
 	}
 
-	tmp, err := ioutil.TempFile(fs.tmp, "")
+	tmp, err := createTemp(fs.tmp, looseObjectPerm)
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
+	defer os.Remove(tmp.Name())
 
 	n, err = io.Copy(tmp, r)
-	if err = tmp.Close(); err != nil {
-		return n, err
+	if err == nil && fs.shared {
+		err = tmp.Chmod(fs.fileMode)
+	}
+	if err == nil && fs.fsync {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
 	}
 	if err != nil {
-		return n, err
+		return n, false, err
 	}
 
 	// Since .git/objects partitions objects based on the first two
 	// characters of their ASCII-encoded SHA1 object ID, ensure that
 	// the directory exists before copying a file into it.
-	if err = os.MkdirAll(dir, 0755); err != nil {
-		return n, err
+	made, err := fs.mkdir(dir)
+	if err != nil {
+		return n, false, err
+	}
+
+	// The new object must be synced along with its directory, as must the
+	// root if the directory is new.
+	dirs := []string{dir}
+	if made {
+		dirs = append(dirs, fs.root)
 	}
 
+	if err = os.Link(tmp.Name(), path); err == nil {
+		return n, true, fs.syncDirs(dirs...)
+	} else if os.IsExist(err) {
+		return 0, false, nil
+	}
+
+	if _, err = os.Lstat(path); err == nil {
+		return 0, false, nil
+	}
 	if err = os.Rename(tmp.Name(), path); err != nil {
-		return n, err
+		return n, false, err
+	}
+	return n, true, fs.syncDirs(dirs...)
+}
+
+// createTemp creates a new file in the directory "dir" (or os.TempDir, if it is
+// empty) with a unique name, and opens it for reading and writing. Unlike
+// ioutil.TempFile, the file is created with the permissions "perm", less the
+// process's umask.
+func createTemp(dir string, perm os.FileMode) (*os.File, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	var suffix [8]byte
+	for tries := 0; ; tries++ {
+		if _, err := rand.Read(suffix[:]); err != nil {
+			return nil, err
+		}
+
+		name := filepath.Join(dir, "tmp_obj_"+hex.EncodeToString(suffix[:]))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && tries < 100 {
+			continue
+		}
+		return f, err
 	}
+}
+
+// discard reads and discards the remainder of "r", which holds the data of an
+// object which already exists.
+func discard(r io.Reader) error {
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return fmt.Errorf("discard pre-existing object data: %s", err)
+	}
+	return nil
+}
+
+// SetPermissions sets the modes given to the object files and fan-out
+// directories created by this storer, regardless of the process's umask.
+func (fs *fileStorer) SetPermissions(file, dir os.FileMode) {
+	fs.fileMode = file
+	fs.dirMode = dir
+	fs.shared = true
+}
+
+// SetFsync sets whether each object stored, and the directory entry naming it,
+// are flushed to stable storage.
+func (fs *fileStorer) SetFsync(enabled bool) {
+	fs.fsync = enabled
+}
+
+// BeginBatch begins a batch of stores, during which the syncing of directories
+// is deferred until the batch ends. Batches may be nested, in which case
+// directories are synced at the end of the outermost.
+func (fs *fileStorer) BeginBatch() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.batches++
+}
+
+// EndBatch ends a batch of stores begun by BeginBatch. If it is the outermost
+// batch, each directory to which an object was stored during it is synced
+// once, and the first error encountered in doing so is returned.
+func (fs *fileStorer) EndBatch() error {
+	fs.mu.Lock()
+	if fs.batches == 0 {
+		fs.mu.Unlock()
+		return fmt.Errorf("gitobj: EndBatch called without BeginBatch")
+	}
+
+	fs.batches--
+	if fs.batches > 0 {
+		fs.mu.Unlock()
+		return nil
+	}
+
+	dirs := make([]string, 0, len(fs.dirty))
+	for dir := range fs.dirty {
+		dirs = append(dirs, dir)
+	}
+	fs.dirty = nil
+	fs.mu.Unlock()
+
+	// Sync the fan-out directories before the root, which names them.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+
+	var first error
+	for _, dir := range dirs {
+		if err := syncDir(dir); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
 
-	return n, nil
+// syncDirs syncs each of the given directories if objects are being synced, or
+// defers doing so until the end of the current batch, if there is one.
+func (fs *fileStorer) syncDirs(dirs ...string) error {
+	if !fs.fsync {
+		return nil
+	}
+
+	fs.mu.Lock()
+	if fs.batches > 0 {
+		if fs.dirty == nil {
+			fs.dirty = make(map[string]struct{})
+		}
+		for _, dir := range dirs {
+			fs.dirty[dir] = struct{}{}
+		}
+		fs.mu.Unlock()
+		return nil
+	}
+	fs.mu.Unlock()
+
+	for _, dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Root gives the absolute (fully-qualified) path to the file storer on disk.
@@ -96,6 +283,130 @@ func (fs *fileStorer) Root() string {
 	return fs.root
 }
 
+// LooseObjectAges returns the modification time of each object stored beneath
+// the root, keyed by its hex-encoded object ID. Files in the object
+// directories which are not named as objects, such as temporary files, are
+// ignored.
+func (fs *fileStorer) LooseObjectAges() (map[string]time.Time, error) {
+	ages := make(map[string]time.Time)
+
+	err := fs.eachObjectFile("", func(name string, info os.FileInfo) error {
+		ages[name] = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ages, nil
+}
+
+// ForEach calls "fn" with the ID of each object stored beneath the root, in
+// ascending order. If "fn" returns an error, iteration stops and that error is
+// returned.
+func (fs *fileStorer) ForEach(fn func(oid []byte) error) error {
+	return fs.ForEachAfter(nil, fn)
+}
+
+// ForEachAfter is as ForEach, but only gives the IDs greater than "after". The
+// object directories before that of "after" are not listed.
+func (fs *fileStorer) ForEachAfter(after []byte, fn func(oid []byte) error) error {
+	return fs.eachObjectFile(hex.EncodeToString(after), func(name string, info os.FileInfo) error {
+		oid, err := hex.DecodeString(name)
+		if err != nil {
+			// An odd number of hex digits cannot name an object.
+			return nil
+		}
+		return fn(oid)
+	})
+}
+
+// StoredSize returns the size of the (compressed) file holding the object
+// "sha", or errors.NoSuchObject if there is none.
+func (fs *fileStorer) StoredSize(sha []byte) (int64, error) {
+	fi, err := os.Stat(fs.path(sha))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, errors.NoSuchObject(sha)
+		}
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// EachSize calls "fn" with the ID, type, and inflated size of each object
+// stored beneath the root, along with the size of its (compressed) file, in
+// ascending order of ID. Only the header of each object is read. If "fn"
+// returns an error, iteration stops and that error is returned.
+func (fs *fileStorer) EachSize(fn func(oid []byte, typ string, size, stored int64) error) error {
+	return fs.eachObjectFile("", func(name string, info os.FileInfo) error {
+		oid, err := hex.DecodeString(name)
+		if err != nil {
+			return nil
+		}
+
+		f, err := os.Open(filepath.Join(fs.root, name[:2], name[2:]))
+		if err != nil {
+			return err
+		}
+
+		r, err := NewObjectReadCloser(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		typ, size, err := r.Header()
+		if cerr := r.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return fn(oid, typ.String(), size, info.Size())
+	})
+}
+
+// eachObjectFile calls "fn" with the hex-encoded object ID and file info of
+// each object file beneath the root, in ascending order of ID, stopping at
+// the first error. Files which are not named as objects are skipped, as is each
+// object whose hex-encoded ID is not greater than "after", and a missing root
+// is treated as empty.
+func (fs *fileStorer) eachObjectFile(after string, fn func(name string, info os.FileInfo) error) error {
+	dirs, err := ioutil.ReadDir(fs.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || !isHex(dir.Name(), 2) {
+			continue
+		}
+		if len(after) >= 2 && dir.Name() < after[:2] {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(fs.root, dir.Name()))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if !file.Mode().IsRegular() || !isHex(file.Name(), -1) {
+				continue
+			}
+			if len(after) >= 2 && dir.Name() == after[:2] && file.Name() <= after[2:] {
+				continue
+			}
+			if err = fn(dir.Name()+file.Name(), file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Close closes the file storer.
 func (fs *fileStorer) Close() error {
 	return nil
@@ -106,11 +417,44 @@ func (fs *fileStorer) IsCompressed() bool {
 	return true
 }
 
+// mkdir creates the directory "dir" and any of its parents which do not exist,
+// and returns whether it did. If "dir" itself does not exist and permissions
+// have been set (see: SetPermissions), it is given the storer's directory mode
+// exactly, regardless of the process's umask.
+func (fs *fileStorer) mkdir(dir string) (bool, error) {
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if !fs.shared {
+		return true, os.MkdirAll(dir, 0755)
+	}
+
+	if err := os.MkdirAll(dir, fs.dirMode.Perm()); err != nil {
+		return false, err
+	}
+	return true, os.Chmod(dir, fs.dirMode)
+}
+
 // open opens a given file.
 func (fs *fileStorer) open(path string, flag int) (*os.File, error) {
 	return os.OpenFile(path, flag, 0)
 }
 
+// isHex returns whether "s" consists only of lowercase hexadecimal digits, and,
+// if "n" is not negative, is "n" characters long.
+func isHex(s string, n int) bool {
+	if len(s) == 0 || (n >= 0 && len(s) != n) {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 // path returns an absolute path on disk to the object given by the OID "sha".
 func (fs *fileStorer) path(sha []byte) string {
 	encoded := hex.EncodeToString(sha)
@@ -0,0 +1,128 @@
+package gitobj
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// FramedReader decodes a stream of concatenated objects, each preceded by a
+// header line giving its object ID, type, and size, as in the output of "git
+// cat-file --batch":
+//
+//  <oid> SP <type> SP <size> LF
+//  <contents> LF
+//
+// Objects which are reported as missing ("<oid> SP missing LF") are returned
+// as an error satisfying errors.IsNoSuchObject, and the stream may continue to
+// be read afterwards.
+type FramedReader struct {
+	// r is the buffered stream of framed objects.
+	r *bufio.Reader
+	// hash is the hash instance given to each object's Decode method.
+	hash hash.Hash
+
+	// cur is the contents of the most recently returned object, or nil if
+	// no object has been returned yet. Any of its unread contents are
+	// discarded before the next object is read.
+	cur *io.LimitedReader
+}
+
+// NewFramedReader returns a new *FramedReader that reads framed objects from
+// "r". "hash" is a hash instance from the ObjectDatabase's Hasher method.
+func NewFramedReader(r io.Reader, hash hash.Hash) *FramedReader {
+	return &FramedReader{
+		r:    bufio.NewReader(r),
+		hash: hash,
+	}
+}
+
+// Next reads and decodes the next object on the stream, returning its object
+// ID along with the decoded object itself.
+//
+// The contents of a returned *Blob are read from the underlying stream, and
+// are therefore only valid until the next call to Next.
+//
+// When no objects remain on the stream, Next returns io.EOF. If an object's
+// header is malformed, or the number of bytes consumed in decoding it does not
+// match the size given in its header, an error is returned.
+func (f *FramedReader) Next() ([]byte, Object, error) {
+	if err := f.finish(); err != nil {
+		return nil, nil, err
+	}
+
+	line, err := f.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && len(line) == 0 {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, fmt.Errorf("gitobj: could not read object header: %s", err)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("gitobj: empty object header")
+	}
+
+	oid, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("gitobj: malformed object header: %q", line)
+	}
+
+	if len(fields) == 2 && fields[1] == "missing" {
+		return oid, nil, errors.NoSuchObject(oid)
+	} else if len(fields) != 3 {
+		return nil, nil, fmt.Errorf("gitobj: malformed object header: %q", line)
+	}
+
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gitobj: malformed object size: %q", fields[2])
+	}
+
+	obj, err := newObject(ObjectTypeFromString(fields[1]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f.cur = &io.LimitedReader{R: f.r, N: size}
+
+	n, err := obj.Decode(f.hash, f.cur, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if obj.Type() != BlobObjectType && n != size {
+		return nil, nil, fmt.Errorf(
+			"gitobj: object %x decoded %d bytes, expected %d", oid, n, size)
+	}
+	return oid, obj, nil
+}
+
+// finish discards the unread contents of the most recently returned object, as
+// well as the newline which follows it.
+func (f *FramedReader) finish() error {
+	if f.cur == nil {
+		return nil
+	}
+
+	if _, err := io.Copy(ioutil.Discard, f.cur); err != nil {
+		return err
+	}
+	f.cur = nil
+
+	b, err := f.r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("gitobj: could not read object trailer: %s", err)
+	} else if b != '\n' {
+		return fmt.Errorf("gitobj: malformed object trailer: %q", b)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramedReaderReadsObjects(t *testing.T) {
+	const commit = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author Jane Doe <jane@example.com> 1257894000 +0000\n" +
+		"committer Jane Doe <jane@example.com> 1257894000 +0000\n" +
+		"\n" +
+		"initial commit\n"
+
+	stream := fmt.Sprintf("%s blob 14\nHello, world!\n\n", strings.Repeat("a", 40)) +
+		fmt.Sprintf("%s missing\n", strings.Repeat("b", 40)) +
+		fmt.Sprintf("%s commit %d\n%s\n", strings.Repeat("c", 40), len(commit), commit)
+
+	r := NewFramedReader(strings.NewReader(stream), sha1.New())
+
+	oid, obj, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 40), hex.EncodeToString(oid))
+	require.IsType(t, new(Blob), obj)
+	assert.EqualValues(t, 14, obj.(*Blob).Size)
+
+	oid, obj, err = r.Next()
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Equal(t, strings.Repeat("b", 40), hex.EncodeToString(oid))
+	assert.Nil(t, obj)
+
+	oid, obj, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("c", 40), hex.EncodeToString(oid))
+	require.IsType(t, new(Commit), obj)
+	assert.Equal(t, "initial commit", obj.(*Commit).Message)
+
+	_, _, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFramedReaderReadsBlobContents(t *testing.T) {
+	stream := fmt.Sprintf("%s blob 5\nHello\n", strings.Repeat("a", 40)) +
+		fmt.Sprintf("%s blob 5\nworld\n", strings.Repeat("b", 40))
+
+	r := NewFramedReader(strings.NewReader(stream), sha1.New())
+
+	_, obj, err := r.Next()
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadAll(obj.(*Blob).Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(contents))
+
+	_, obj, err = r.Next()
+	require.NoError(t, err)
+
+	contents, err = ioutil.ReadAll(obj.(*Blob).Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(contents))
+}
+
+func TestFramedReaderRejectsMalformedHeaders(t *testing.T) {
+	for desc, stream := range map[string]string{
+		"bad object id": "zz blob 5\nHello\n",
+		"bad size":      strings.Repeat("a", 40) + " blob five\nHello\n",
+		"missing size":  strings.Repeat("a", 40) + " blob\nHello\n",
+		"unknown type":  strings.Repeat("a", 40) + " bogus 5\nHello\n",
+	} {
+		t.Run(desc, func(t *testing.T) {
+			r := NewFramedReader(strings.NewReader(stream), sha1.New())
+
+			_, obj, err := r.Next()
+			assert.Error(t, err)
+			assert.Nil(t, obj)
+		})
+	}
+}
+
+func TestFramedReaderRejectsMismatchedFraming(t *testing.T) {
+	const commit = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nmessage\n"
+
+	stream := fmt.Sprintf("%s commit %d\n%s\n", strings.Repeat("a", 40),
+		len(commit)-1, commit)
+
+	r := NewFramedReader(strings.NewReader(stream), sha1.New())
+
+	_, _, err := r.Next()
+	assert.Error(t, err)
+}
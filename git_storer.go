@@ -0,0 +1,461 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// gitStorer implements the storage.Storage interface by reading objects from a
+// "git cat-file --batch" subprocess, and looking for them using a "git cat-file
+// --batch-check" subprocess.
+type gitStorer struct {
+	// mu guards the subprocesses and pending below, since only one request
+	// may be made of each at a time.
+	mu *sync.Mutex
+
+	// gitDir is the ".git" directory of the repository being read.
+	gitDir string
+
+	// batch reads the contents of objects, and check, which is started
+	// when it is first needed, looks for them.
+	batch *catFile
+	check *catFile
+
+	// pending is the object most recently opened from batch, if it has not
+	// yet been read to its end.
+	pending *gitObjectReader
+
+	// closed indicates whether the subprocesses have been shut down.
+	closed bool
+}
+
+// newGitStorer starts "git cat-file --batch" against the repository whose
+// ".git" directory is "gitDir", and returns a *gitStorer reading from it.
+func newGitStorer(gitDir string) (*gitStorer, error) {
+	batch, err := startCatFile(gitDir, "--batch")
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitStorer{
+		mu:     new(sync.Mutex),
+		gitDir: gitDir,
+		batch:  batch,
+	}, nil
+}
+
+// Open implements the storage.Storage.Open function, and returns an
+// io.ReadCloser of the uncompressed object named by "sha", with its loose
+// object header. If the object does not exist, an error satisfying
+// errors.IsNoSuchObject is returned.
+//
+// The contents of the object are streamed from the subprocess as they are
+// read. If another object is opened before they have all been read, the rest
+// are first read into memory, so that both may be read in any order.
+func (g *gitStorer) Open(sha []byte) (io.ReadCloser, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, fmt.Errorf("gitobj: cannot use closed git cat-file")
+	}
+
+	if g.pending != nil {
+		if err := g.pending.buffer(); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := g.batch.request(sha)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 2 && fields[1] == "missing" {
+		return nil, errors.NoSuchObject(sha)
+	} else if len(fields) != 3 || fields[0] != hex.EncodeToString(sha) {
+		return nil, fmt.Errorf("gitobj: malformed git cat-file header: %q",
+			strings.Join(fields, " "))
+	}
+
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("gitobj: malformed object size: %q", fields[2])
+	}
+
+	r := &gitObjectReader{
+		g:         g,
+		header:    strings.NewReader(objectHeader(ObjectTypeFromString(fields[1]), size)),
+		remaining: size,
+	}
+	g.pending = r
+	if size == 0 {
+		if err = r.finish(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Has returns whether the repository holds the object "sha", as given by the
+// "git cat-file --batch-check" subprocess, without reading its contents.
+func (g *gitStorer) Has(sha []byte) (bool, error) {
+	_, err := g.checkObject(sha)
+	if errors.IsNoSuchObject(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ObjectType returns the type of the object "sha", as given by the "git
+// cat-file --batch-check" subprocess, without reading its contents.
+func (g *gitStorer) ObjectType(sha []byte) (string, error) {
+	return g.checkObject(sha)
+}
+
+// checkObject asks the "git cat-file --batch-check" subprocess, starting it if
+// it has not yet been, for the object "sha", and returns its type. If the
+// object does not exist, an error satisfying errors.IsNoSuchObject is returned.
+func (g *gitStorer) checkObject(sha []byte) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return "", fmt.Errorf("gitobj: cannot use closed git cat-file")
+	}
+
+	if g.check == nil {
+		check, err := startCatFile(g.gitDir, "--batch-check")
+		if err != nil {
+			return "", err
+		}
+		g.check = check
+	}
+
+	fields, err := g.check.request(sha)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 2 && fields[1] == "missing" {
+		return "", errors.NoSuchObject(sha)
+	} else if len(fields) != 3 || fields[0] != hex.EncodeToString(sha) {
+		return "", fmt.Errorf("gitobj: malformed git cat-file header: %q",
+			strings.Join(fields, " "))
+	}
+	return fields[1], nil
+}
+
+// ForEach calls "fn" with the ID of each object in the repository, in ascending
+// order, as listed by a separate "git cat-file --batch-all-objects"
+// subprocess. If "fn" returns an error, iteration stops and that error is
+// returned.
+func (g *gitStorer) ForEach(fn func(oid []byte) error) error {
+	return g.eachObject("%(objectname)", func(fields []string) error {
+		if len(fields) != 1 {
+			return fmt.Errorf("gitobj: malformed git cat-file output: %q",
+				strings.Join(fields, " "))
+		}
+
+		oid, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return fmt.Errorf("gitobj: malformed object name: %q", fields[0])
+		}
+		return fn(oid)
+	})
+}
+
+// EachSize calls "fn" with the ID, type, and inflated size of each object in
+// the repository, along with the number of bytes it occupies on disk, as
+// listed by a separate "git cat-file --batch-all-objects" subprocess. If "fn"
+// returns an error, iteration stops and that error is returned.
+func (g *gitStorer) EachSize(fn func(oid []byte, typ string, size, stored int64) error) error {
+	format := "%(objectname) %(objecttype) %(objectsize) %(objectsize:disk)"
+
+	return g.eachObject(format, func(fields []string) error {
+		if len(fields) != 4 {
+			return fmt.Errorf("gitobj: malformed git cat-file output: %q",
+				strings.Join(fields, " "))
+		}
+
+		oid, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return fmt.Errorf("gitobj: malformed object name: %q", fields[0])
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("gitobj: malformed object size: %q", fields[2])
+		}
+		stored, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return fmt.Errorf("gitobj: malformed object size: %q", fields[3])
+		}
+		return fn(oid, fields[1], size, stored)
+	})
+}
+
+// eachObject runs "git cat-file --batch-all-objects" with the given
+// "--batch-check" format, and calls "fn" with the space-separated fields of
+// each line that it writes. If "fn" returns an error, the subprocess is killed
+// and that error is returned.
+func (g *gitStorer) eachObject(format string, fn func(fields []string) error) error {
+	cmd := exec.Command("git", "--git-dir", g.gitDir, "cat-file",
+		"--batch-all-objects", "--batch-check="+format)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("gitobj: could not start git cat-file: %s", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if err = fn(strings.Fields(scanner.Text())); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("gitobj: git cat-file failed: %s: %s", err,
+			strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Close shuts down the subprocesses, and returns any error they encountered.
+// Any object which has not been read to its end is first read into memory, so
+// that it may still be read. Close is safe to call more than once.
+func (g *gitStorer) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+
+	var err error
+	if g.pending != nil {
+		err = g.pending.buffer()
+	}
+	if cerr := g.batch.close(); err == nil {
+		err = cerr
+	}
+	if g.check != nil {
+		if cerr := g.check.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// IsCompressed returns false, because the objects read from the subprocess are
+// not compressed.
+func (g *gitStorer) IsCompressed() bool {
+	return false
+}
+
+// gitObjectReader is an io.ReadCloser of an object opened from a *gitStorer,
+// which streams its contents from the "git cat-file --batch" subprocess until
+// another object is opened.
+type gitObjectReader struct {
+	g *gitStorer
+
+	// header is the loose object header of the object, which is read
+	// before its contents.
+	header *strings.Reader
+	// remaining is the number of bytes of its contents which have not yet
+	// been read from the subprocess, and body holds those which have been
+	// read into memory by buffer, but not yet read from the reader.
+	remaining int64
+	body      *bytes.Buffer
+}
+
+// Read implements io.Reader.
+func (r *gitObjectReader) Read(p []byte) (int, error) {
+	if r.header.Len() > 0 {
+		return r.header.Read(p)
+	}
+
+	r.g.mu.Lock()
+	defer r.g.mu.Unlock()
+
+	if r.body != nil {
+		return r.body.Read(p)
+	}
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.g.batch.stdout.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, r.g.batch.failed(err)
+	}
+	if r.remaining == 0 {
+		err = r.finish()
+	}
+	return n, err
+}
+
+// Close implements io.Closer. If the object has not been read to its end, the
+// rest of its contents are read from the subprocess and discarded.
+func (r *gitObjectReader) Close() error {
+	r.g.mu.Lock()
+	defer r.g.mu.Unlock()
+
+	r.body = nil
+	if r.g.pending != r {
+		return nil
+	}
+	if _, err := io.CopyN(ioutil.Discard, r.g.batch.stdout, r.remaining); err != nil {
+		return r.g.batch.failed(err)
+	}
+	r.remaining = 0
+	return r.finish()
+}
+
+// buffer reads the rest of the contents of the object from the subprocess into
+// memory, so that another object may be read from it. It must be called with
+// the storer's lock held.
+func (r *gitObjectReader) buffer() error {
+	r.body = new(bytes.Buffer)
+	if _, err := io.CopyN(r.body, r.g.batch.stdout, r.remaining); err != nil {
+		return r.g.batch.failed(err)
+	}
+	r.remaining = 0
+	return r.finish()
+}
+
+// finish reads the newline which follows the contents of the object, once they
+// have all been read, so that another object may be read from the subprocess.
+// It must be called with the storer's lock held.
+func (r *gitObjectReader) finish() error {
+	r.g.pending = nil
+
+	if b, err := r.g.batch.stdout.ReadByte(); err != nil {
+		return r.g.batch.failed(err)
+	} else if b != '\n' {
+		return fmt.Errorf("gitobj: malformed git cat-file trailer: %q", b)
+	}
+	return nil
+}
+
+// catFile is a "git cat-file" subprocess reading requests from its standard
+// input.
+type catFile struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *syncBuffer
+}
+
+// startCatFile starts "git cat-file" with the arguments "args" against the
+// repository whose ".git" directory is "gitDir".
+func startCatFile(gitDir string, args ...string) (*catFile, error) {
+	cmd := exec.Command("git", append([]string{"--git-dir", gitDir, "cat-file"}, args...)...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := new(syncBuffer)
+	cmd.Stderr = stderr
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("gitobj: could not start git cat-file: %s", err)
+	}
+
+	return &catFile{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: stderr,
+	}, nil
+}
+
+// request asks the subprocess for the object "sha", and returns the
+// space-separated fields of the header line that it writes in response.
+func (c *catFile) request(sha []byte) ([]string, error) {
+	if _, err := fmt.Fprintf(c.stdin, "%x\n", sha); err != nil {
+		return nil, c.failed(err)
+	}
+
+	line, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return nil, c.failed(err)
+	}
+	return strings.Fields(line), nil
+}
+
+// close shuts down the subprocess, and returns any error it encountered.
+func (c *catFile) close() error {
+	if err := c.stdin.Close(); err != nil {
+		return err
+	}
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("gitobj: git cat-file failed: %s: %s", err,
+			strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
+
+// failed returns an error describing a failure to communicate with the
+// subprocess, including anything it has written to its standard error.
+func (c *catFile) failed(err error) error {
+	if stderr := strings.TrimSpace(c.stderr.String()); len(stderr) > 0 {
+		return fmt.Errorf("gitobj: git cat-file failed: %s: %s", err, stderr)
+	}
+	return fmt.Errorf("gitobj: git cat-file failed: %s", err)
+}
+
+// syncBuffer is a bytes.Buffer which may be written to by a subprocess while
+// it is read from.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
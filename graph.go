@@ -0,0 +1,144 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// abbrevLength is the number of hexadecimal characters used when abbreviating
+// object IDs for display.
+const abbrevLength = 7
+
+// GraphOptions controls the output of GraphDOT.
+type GraphOptions struct {
+	// MaxCommits is the maximum number of commits written to the graph. A
+	// value of zero or less indicates that no limit should be applied.
+	MaxCommits int
+	// IncludeTrees indicates whether each commit's root tree should be
+	// written as a node of its own, along with an edge from the commit to
+	// its tree.
+	IncludeTrees bool
+}
+
+// GraphDOT walks the commit graph beginning at the given tips and writes a
+// Graphviz DOT representation of it to "w". Each commit is written as a node
+// labeled with its abbreviated object ID and subject line, and with an edge to
+// each of its parents.
+//
+// Commits are visited in breadth-first order from the tips, and each commit
+// (and tree) is written at most once. Edges are only written to the parents
+// which are themselves written, so none lead to commits beyond MaxCommits. If
+// "opts" is nil, the default options are used.
+//
+// If any commit could not be read, or writing to "w" failed, an error will be
+// returned.
+func (o *ObjectDatabase) GraphDOT(w io.Writer, tips [][]byte, opts *GraphOptions) error {
+	if opts == nil {
+		opts = new(GraphOptions)
+	}
+
+	if _, err := io.WriteString(w, "digraph commits {\n"); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{})
+	queue := make([][]byte, 0, len(tips))
+	for _, tip := range tips {
+		if _, ok := seen[string(tip)]; ok {
+			continue
+		}
+		seen[string(tip)] = struct{}{}
+		queue = append(queue, tip)
+	}
+
+	// Edges are written after the walk, once it is known which of the
+	// parents were written themselves.
+	type edge struct {
+		from, to []byte
+	}
+	var edges []edge
+
+	written := make(map[string]struct{})
+	trees := make(map[string]struct{})
+	for len(queue) > 0 {
+		if opts.MaxCommits > 0 && len(written) >= opts.MaxCommits {
+			break
+		}
+
+		sha := queue[0]
+		queue = queue[1:]
+
+		commit, err := o.Commit(sha)
+		if err != nil {
+			return err
+		}
+
+		id := hex.EncodeToString(sha)
+		label := fmt.Sprintf("%s %s", abbrev(sha), commitSubject(commit.Message))
+		if _, err = fmt.Fprintf(w, "\t%q [label=%s];\n", id, strconv.Quote(label)); err != nil {
+			return err
+		}
+
+		if opts.IncludeTrees {
+			tree := hex.EncodeToString(commit.TreeID)
+			if _, ok := trees[tree]; !ok {
+				trees[tree] = struct{}{}
+				if _, err = fmt.Fprintf(w, "\t%q [label=%q, shape=box];\n", tree, abbrev(commit.TreeID)); err != nil {
+					return err
+				}
+			}
+			if _, err = fmt.Fprintf(w, "\t%q -> %q [style=dashed];\n", id, tree); err != nil {
+				return err
+			}
+		}
+
+		parents, err := o.parents(sha, commit)
+		if err != nil {
+			return err
+		}
+
+		for _, parent := range parents {
+			edges = append(edges, edge{from: sha, to: parent})
+
+			if _, ok := seen[string(parent)]; ok {
+				continue
+			}
+			seen[string(parent)] = struct{}{}
+			queue = append(queue, parent)
+		}
+
+		written[string(sha)] = struct{}{}
+	}
+
+	for _, e := range edges {
+		if _, ok := written[string(e.to)]; !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\t%q -> %q;\n", hex.EncodeToString(e.from), hex.EncodeToString(e.to)); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// abbrev returns the abbreviated, hex-encoded form of the given object ID.
+func abbrev(sha []byte) string {
+	id := hex.EncodeToString(sha)
+	if len(id) > abbrevLength {
+		return id[:abbrevLength]
+	}
+	return id
+}
+
+// commitSubject returns the first line of the given commit message.
+func commitSubject(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i]
+	}
+	return message
+}
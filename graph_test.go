@@ -0,0 +1,143 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphDOT(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root\n\nbody")
+	left := writeTestCommit(t, odb, tree, "left", root)
+	right := writeTestCommit(t, odb, tree, "right", root)
+	merge := writeTestCommit(t, odb, tree, "merge", left, right)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, odb.GraphDOT(buf, [][]byte{merge}, nil))
+
+	out := buf.String()
+
+	assert.Contains(t, out, "digraph commits {\n")
+	assert.Contains(t, out, fmt.Sprintf("%q [label=\"%s merge\"];", hex.EncodeToString(merge), hex.EncodeToString(merge)[:7]))
+	assert.Contains(t, out, fmt.Sprintf("%q [label=\"%s root\"];", hex.EncodeToString(root), hex.EncodeToString(root)[:7]))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q;", hex.EncodeToString(merge), hex.EncodeToString(left)))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q;", hex.EncodeToString(merge), hex.EncodeToString(right)))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q;", hex.EncodeToString(left), hex.EncodeToString(root)))
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte(hex.EncodeToString(root)+"\" [label")))
+	assert.NotContains(t, out, hex.EncodeToString(tree))
+}
+
+func TestGraphDOTWithOptions(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root")
+	child := writeTestCommit(t, odb, tree, "child", root)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, odb.GraphDOT(buf, [][]byte{child}, &GraphOptions{
+		MaxCommits:   1,
+		IncludeTrees: true,
+	}))
+
+	out := buf.String()
+
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q [style=dashed];", hex.EncodeToString(child), hex.EncodeToString(tree)))
+	assert.NotContains(t, out, hex.EncodeToString(root))
+}
+
+func TestGraphDOTWritesSharedTreesOnce(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, odb, tree, "root")
+	child := writeTestCommit(t, odb, tree, "child", root)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, odb.GraphDOT(buf, [][]byte{child}, &GraphOptions{IncludeTrees: true}))
+
+	out := buf.String()
+
+	assert.Equal(t, 1, strings.Count(out, fmt.Sprintf("%q [label", hex.EncodeToString(tree))))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q [style=dashed];", hex.EncodeToString(child), hex.EncodeToString(tree)))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q [style=dashed];", hex.EncodeToString(root), hex.EncodeToString(tree)))
+	assert.Contains(t, out, fmt.Sprintf("%q -> %q;", hex.EncodeToString(child), hex.EncodeToString(root)))
+}
+
+func TestGraphDOTMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	err := odb.GraphDOT(new(bytes.Buffer), [][]byte{make([]byte, 20)}, nil)
+	assert.Error(t, err)
+}
+
+// newTestDatabase returns a new *ObjectDatabase backed by memory, whose
+// objects may be read more than once.
+func newTestDatabase(t *testing.T, options ...Option) *ObjectDatabase {
+	odb, err := FromBackend(&rereadableBackend{newMemoryStorer(nil)}, options...)
+	require.NoError(t, err)
+
+	return odb
+}
+
+// rereadableBackend is a storage.Backend backed by a *memoryStorer, which
+// opens a new reader over the contents of an object each time it is opened,
+// rather than the buffer the object was stored in.
+type rereadableBackend struct {
+	*memoryStorer
+}
+
+func (b *rereadableBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return b, b
+}
+
+func (b *rereadableBackend) Open(sha []byte) (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.fs[fmt.Sprintf("%x", sha)]
+	if !ok {
+		return nil, errors.NoSuchObject(sha)
+	}
+	return ioutil.NopCloser(bytes.NewReader(entry.ReadWriter.(*bytes.Buffer).Bytes())), nil
+}
+
+// writeTestCommit writes a commit with the given tree, message, and parents to
+// the given database, and returns its object ID.
+func writeTestCommit(t *testing.T, odb *ObjectDatabase, tree []byte, message string, parents ...[]byte) []byte {
+	sig := &Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Unix(1257894000, 0).UTC(),
+	}
+
+	sha, err := odb.WriteCommit(&Commit{
+		Author:    sig.String(),
+		Committer: sig.String(),
+		ParentIDs: parents,
+		TreeID:    tree,
+		Message:   message,
+	})
+	require.NoError(t, err)
+
+	return sha
+}
@@ -0,0 +1,212 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// GrepOptions controls the behavior of Grep.
+type GrepOptions struct {
+	// MaxMatches is the maximum number of matching lines returned. A value
+	// of zero or less indicates that no limit should be applied.
+	MaxMatches int
+	// Binary indicates whether blobs which appear to be binary are
+	// searched as if they were text, rather than skipped.
+	Binary bool
+	// Concurrency is the number of blobs searched at once. A value of zero
+	// or less searches as many blobs at once as there are CPUs available
+	// (see: runtime.GOMAXPROCS).
+	Concurrency int
+}
+
+// GrepMatch is a single line matched by Grep.
+type GrepMatch struct {
+	// Path is the slash-separated path of the blob containing the line,
+	// relative to the root of the tree being searched.
+	Path string
+	// Line is the line number of the line, beginning with 1.
+	Line int
+	// Text is the contents of the line, without its trailing newline.
+	Text string
+}
+
+// Grep searches the blobs (and symbolic links) of the tree named "root" for
+// lines matching "pattern", in the same way as "git grep <pattern> <tree>". If
+// "root" names a commit, its tree is searched instead. If "opts" is nil, the
+// default options are used.
+//
+// Matches are ordered by path, and then by line number. Blobs which appear to
+// be binary are skipped unless the Binary option is given, and gitlinks are
+// never searched.
+//
+// If the tree or any of its subtrees or blobs could not be read, an error is
+// returned instead.
+func (o *ObjectDatabase) Grep(root []byte, pattern *regexp.Regexp, opts *GrepOptions) ([]*GrepMatch, error) {
+	if opts == nil {
+		opts = new(GrepOptions)
+	}
+
+	typ, err := o.objectType(root)
+	if err != nil {
+		return nil, err
+	}
+	if typ == CommitObjectType {
+		commit, err := o.Commit(root)
+		if err != nil {
+			return nil, err
+		}
+		root = commit.TreeID
+	}
+
+	var blobs []*grepBlob
+	if err = o.grepBlobs(&blobs, "", root); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Blobs are handed out to the workers in order, and once enough
+	// matches have been found, no more are handed out. Since every blob
+	// still to be handed out comes after all of those already searched,
+	// the matches which are returned are the same as if the blobs were
+	// searched one at a time.
+	var (
+		found int64
+		wg    sync.WaitGroup
+		once  sync.Once
+		ferr  error
+	)
+
+	next := make(chan *grepBlob)
+	done := make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for blob := range next {
+				if err := o.searchBlob(blob, pattern, opts); err != nil {
+					once.Do(func() {
+						ferr = err
+						close(done)
+					})
+					return
+				}
+				atomic.AddInt64(&found, int64(len(blob.matches)))
+			}
+		}()
+	}
+
+dispatch:
+	for _, blob := range blobs {
+		if opts.MaxMatches > 0 && atomic.LoadInt64(&found) >= int64(opts.MaxMatches) {
+			break
+		}
+
+		select {
+		case next <- blob:
+		case <-done:
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	var matches []*GrepMatch
+	for _, blob := range blobs {
+		matches = append(matches, blob.matches...)
+	}
+	if opts.MaxMatches > 0 && len(matches) > opts.MaxMatches {
+		matches = matches[:opts.MaxMatches]
+	}
+	return matches, nil
+}
+
+// grepBlob is a single blob to be searched by Grep, along with the matches
+// found within it.
+type grepBlob struct {
+	path    string
+	oid     []byte
+	matches []*GrepMatch
+}
+
+// grepBlobs appends the blobs and symbolic links beneath the tree named "sha",
+// whose entries are beneath the path "prefix", to "blobs" in path order.
+func (o *ObjectDatabase) grepBlobs(blobs *[]*grepBlob, prefix string, sha []byte) error {
+	entries, err := o.sortedEntries(sha)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		switch entry.Type() {
+		case BlobObjectType:
+			*blobs = append(*blobs, &grepBlob{path: prefix + entry.Name, oid: entry.Oid})
+		case TreeObjectType:
+			if err = o.grepBlobs(blobs, prefix+entry.Name+"/", entry.Oid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// searchBlob streams the contents of the given blob, and records each of its
+// lines which match "pattern". At most opts.MaxMatches lines are recorded.
+func (o *ObjectDatabase) searchBlob(blob *grepBlob, pattern *regexp.Regexp, opts *GrepOptions) error {
+	b, err := o.Blob(blob.oid)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	r := bufio.NewReader(b.Contents)
+	if !opts.Binary {
+		// Peek returns as much as is available if the blob is shorter
+		// than the sniffed length, along with an error which can be
+		// ignored, since the same error is returned by the reads
+		// below.
+		head, _ := r.Peek(binarySniffLength)
+		if isBinary(head) {
+			return nil
+		}
+	}
+
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(line, []byte("\n"))
+
+			if pattern.Match(line) {
+				blob.matches = append(blob.matches, &GrepMatch{
+					Path: blob.path,
+					Line: n,
+					Text: string(line),
+				})
+
+				if opts.MaxMatches > 0 && len(blob.matches) >= opts.MaxMatches {
+					return nil
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package gitobj
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrep(t *testing.T) {
+	odb, tree := grepFixture(t)
+
+	matches, err := odb.Grep(tree, regexp.MustCompile("world"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*GrepMatch{
+		{Path: "a.txt", Line: 1, Text: "Hello, world!"},
+		{Path: "a.txt", Line: 3, Text: "Goodbye, world!"},
+		{Path: "dir/b.txt", Line: 2, Text: "world without end"},
+		{Path: "link", Line: 1, Text: "world"},
+	}, matches)
+}
+
+func TestGrepCommit(t *testing.T) {
+	odb, tree := grepFixture(t)
+	commit := writeTestCommit(t, odb, tree, "commit")
+
+	matches, err := odb.Grep(commit, regexp.MustCompile("^Goodbye"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*GrepMatch{
+		{Path: "a.txt", Line: 3, Text: "Goodbye, world!"},
+	}, matches)
+}
+
+func TestGrepBinary(t *testing.T) {
+	odb, tree := grepFixture(t)
+
+	matches, err := odb.Grep(tree, regexp.MustCompile("binary"), &GrepOptions{Binary: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*GrepMatch{
+		{Path: "binary.bin", Line: 1, Text: "binary\x00world"},
+	}, matches)
+}
+
+func TestGrepMaxMatches(t *testing.T) {
+	odb, tree := grepFixture(t)
+
+	for _, concurrency := range []int{1, 4} {
+		matches, err := odb.Grep(tree, regexp.MustCompile("world"), &GrepOptions{
+			MaxMatches:  3,
+			Concurrency: concurrency,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, matches, 3)
+		assert.Equal(t, "dir/b.txt", matches[2].Path)
+	}
+}
+
+func TestGrepConcurrency(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	var entries []*TreeEntry
+	for i := 0; i < 100; i++ {
+		blob := writeTestBlob(t, odb, strings.Repeat("line\n", i)+"match\n")
+		entries = append(entries, &TreeEntry{
+			Name:     string([]byte{'a' + byte(i/10), 'a' + byte(i%10)}),
+			Oid:      blob,
+			Filemode: 0100644,
+		})
+	}
+	tree, err := odb.WriteTree(&Tree{Entries: entries})
+	require.NoError(t, err)
+
+	matches, err := odb.Grep(tree, regexp.MustCompile("match"), &GrepOptions{Concurrency: 8})
+	require.NoError(t, err)
+
+	require.Len(t, matches, 100)
+	for i, match := range matches {
+		assert.Equal(t, entries[i].Name, match.Path)
+		assert.Equal(t, i+1, match.Line)
+	}
+}
+
+func TestGrepMissingTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	matches, err := odb.Grep([]byte("aaaaaaaaaaaaaaaaaaaa"), regexp.MustCompile("."), nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, matches)
+}
+
+// grepFixture writes a tree containing text and binary blobs, a symbolic link,
+// a gitlink, and a subtree to a new database, and returns the database along
+// with the tree's object ID.
+func grepFixture(t *testing.T) (*ObjectDatabase, []byte) {
+	odb := newTestDatabase(t)
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "b.txt", Oid: writeTestBlob(t, odb, "nothing here\nworld without end"), Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: writeTestBlob(t, odb, "Hello, world!\nnope\nGoodbye, world!\n"), Filemode: 0100644},
+		{Name: "binary.bin", Oid: writeTestBlob(t, odb, "binary\x00world\n"), Filemode: 0100644},
+		{Name: "dir", Oid: subtree, Filemode: 040000},
+		{Name: "link", Oid: writeTestBlob(t, odb, "world"), Filemode: 0120000},
+		{Name: "submodule", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	return odb, tree
+}
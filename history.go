@@ -0,0 +1,164 @@
+package gitobj
+
+import (
+	"container/heap"
+	"strings"
+	"time"
+)
+
+// PathHistory walks the history of the commit named "tip", and returns, for
+// each of the given paths, the commits which changed it or (if it is a
+// directory) anything beneath it. The commits of each path are ordered
+// newest-first by commit date, and paths which were never changed have no
+// commits.
+//
+// Every path is considered in the same walk, in which each commit is compared
+// once against its first parent (or the empty tree, if it has none), and only
+// the subtrees leading to the given paths are read. This is considerably
+// cheaper than walking the history of each path separately.
+//
+// If any commit or tree could not be read, or the committer of any commit could
+// not be parsed, an error is returned instead.
+func (o *ObjectDatabase) PathHistory(tip []byte, paths []string) (map[string][][]byte, error) {
+	history := make(map[string][][]byte, len(paths))
+
+	// keys holds each distinct path given, and dirs the same path without
+	// any leading or trailing slashes.
+	var keys, dirs []string
+	for _, path := range paths {
+		if _, ok := history[path]; ok {
+			continue
+		}
+		history[path] = nil
+
+		keys = append(keys, path)
+		dirs = append(dirs, strings.Trim(path, "/"))
+	}
+
+	// include returns whether the path "p" is, or leads to, one of the
+	// given paths, and so must be compared.
+	include := func(p string) bool {
+		for _, dir := range dirs {
+			if covers(dir, p) || covers(p, dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	commits := make(map[string]*Commit)
+	parents := make(map[string][][]byte)
+	queue := new(commitQueue)
+
+	push := func(sha []byte) error {
+		if _, ok := commits[string(sha)]; ok {
+			return nil
+		}
+
+		c, err := o.Commit(sha)
+		if err != nil {
+			return err
+		}
+		committer, err := ParseSignature(c.Committer)
+		if err != nil {
+			return err
+		}
+
+		ps, err := o.parents(sha, c)
+		if err != nil {
+			return err
+		}
+
+		commits[string(sha)] = c
+		parents[string(sha)] = ps
+		heap.Push(queue, &queuedCommit{sha: sha, when: committer.When, seq: queue.seq})
+		queue.seq++
+		return nil
+	}
+
+	if err := push(tip); err != nil {
+		return nil, err
+	}
+
+	for queue.Len() > 0 {
+		sha := heap.Pop(queue).(*queuedCommit).sha
+		c := commits[string(sha)]
+
+		ps := parents[string(sha)]
+		for _, parent := range ps {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+
+		var base []byte
+		if len(ps) > 0 {
+			base = commits[string(ps[0])].TreeID
+		}
+
+		var diffs []*TreeDiff
+		if err := o.diffTrees(&diffs, "", base, c.TreeID, include); err != nil {
+			return nil, err
+		}
+
+		for i, dir := range dirs {
+			for _, diff := range diffs {
+				if covers(dir, diff.Path) {
+					history[keys[i]] = append(history[keys[i]], sha)
+					break
+				}
+			}
+		}
+	}
+	return history, nil
+}
+
+// covers returns whether "path" is either "dir" itself, or beneath it. The
+// empty path covers every other.
+func covers(dir, path string) bool {
+	return len(dir) == 0 || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// queuedCommit is a commit waiting to be visited by a walk of history, such as
+// that of PathHistory or RevList.
+type queuedCommit struct {
+	// sha is the object ID of the commit.
+	sha []byte
+	// when is the time at which the commit was committed.
+	when time.Time
+	// seq is the order in which the commit was queued, which breaks ties
+	// between commits with the same date.
+	seq int
+	// depth is the number of commits on the shortest known path from a
+	// tip of the walk to this commit, counting both. It is only used by
+	// walks which limit their depth.
+	depth int
+}
+
+// commitQueue is a priority queue of commits, implementing heap.Interface, from
+// which the newest commit is removed first.
+type commitQueue struct {
+	commits []*queuedCommit
+	// seq is the sequence number given to the next queued commit.
+	seq int
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.commits[i], q.commits[j]
+	if !a.when.Equal(b.when) {
+		return a.when.After(b.when)
+	}
+	return a.seq < b.seq
+}
+
+func (q *commitQueue) Swap(i, j int) { q.commits[i], q.commits[j] = q.commits[j], q.commits[i] }
+
+func (q *commitQueue) Push(x interface{}) { q.commits = append(q.commits, x.(*queuedCommit)) }
+
+func (q *commitQueue) Pop() interface{} {
+	last := q.commits[len(q.commits)-1]
+	q.commits = q.commits[:len(q.commits)-1]
+	return last
+}
@@ -0,0 +1,116 @@
+package gitobj
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathHistory(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	v1 := writeTestBlob(t, odb, "v1\n")
+	v2 := writeTestBlob(t, odb, "v2\n")
+	v3 := writeTestBlob(t, odb, "v3\n")
+
+	c1 := writeDatedCommit(t, odb, 1, map[string][]byte{"a.txt": v1, "dir/b.txt": v1})
+	c2 := writeDatedCommit(t, odb, 2, map[string][]byte{"a.txt": v2, "dir/b.txt": v1}, c1)
+	c3 := writeDatedCommit(t, odb, 3, map[string][]byte{"a.txt": v2, "dir/b.txt": v2}, c2)
+	c4 := writeDatedCommit(t, odb, 4, map[string][]byte{"a.txt": v3, "dir/b.txt": v2, "dir/c.txt": v1}, c3)
+
+	history, err := odb.PathHistory(c4, []string{"a.txt", "dir/", "dir/b.txt", "missing"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][][]byte{
+		"a.txt":     {c4, c2, c1},
+		"dir/":      {c4, c3, c1},
+		"dir/b.txt": {c3, c1},
+		"missing":   nil,
+	}, history)
+}
+
+func TestPathHistoryComparesMergesAgainstFirstParent(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	v1 := writeTestBlob(t, odb, "v1\n")
+	v2 := writeTestBlob(t, odb, "v2\n")
+
+	root := writeDatedCommit(t, odb, 1, map[string][]byte{"x": v1, "y": v1})
+	right := writeDatedCommit(t, odb, 2, map[string][]byte{"x": v1, "y": v2}, root)
+	left := writeDatedCommit(t, odb, 3, map[string][]byte{"x": v2, "y": v1}, root)
+	merge := writeDatedCommit(t, odb, 4, map[string][]byte{"x": v2, "y": v2}, left, right)
+
+	history, err := odb.PathHistory(merge, []string{"x", "y"})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]byte{left, root}, history["x"])
+	assert.Equal(t, [][]byte{merge, right, root}, history["y"])
+}
+
+func TestPathHistoryMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	history, err := odb.PathHistory([]byte("aaaaaaaaaaaaaaaaaaaa"), []string{"a.txt"})
+
+	assert.Error(t, err)
+	assert.Nil(t, history)
+}
+
+// writeTestBlob writes a blob with the given contents to the given database,
+// and returns its object ID.
+func writeTestBlob(t *testing.T, odb *ObjectDatabase, contents string) []byte {
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(contents)))
+	require.NoError(t, err)
+
+	return sha
+}
+
+// writeDatedCommit writes a commit containing the given files, committed the
+// given number of seconds after the epoch, and returns its object ID. Each
+// file's path may contain at most one directory.
+func writeDatedCommit(t *testing.T, odb *ObjectDatabase, when int64, files map[string][]byte, parents ...[]byte) []byte {
+	var entries []*TreeEntry
+	subtrees := make(map[string][]*TreeEntry)
+	for path, blob := range files {
+		if i := strings.Index(path, "/"); i >= 0 {
+			subtrees[path[:i]] = append(subtrees[path[:i]], &TreeEntry{
+				Name: path[i+1:], Oid: blob, Filemode: 0100644,
+			})
+			continue
+		}
+		entries = append(entries, &TreeEntry{Name: path, Oid: blob, Filemode: 0100644})
+	}
+	for dir, subentries := range subtrees {
+		sort.Sort(SubtreeOrder(subentries))
+
+		sha, err := odb.WriteTree(&Tree{Entries: subentries})
+		require.NoError(t, err)
+
+		entries = append(entries, &TreeEntry{Name: dir, Oid: sha, Filemode: 040000})
+	}
+	sort.Sort(SubtreeOrder(entries))
+
+	tree, err := odb.WriteTree(&Tree{Entries: entries})
+	require.NoError(t, err)
+
+	sig := &Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Unix(when, 0).UTC(),
+	}
+
+	sha, err := odb.WriteCommit(&Commit{
+		Author:    sig.String(),
+		Committer: sig.String(),
+		ParentIDs: parents,
+		TreeID:    tree,
+		Message:   "commit",
+	})
+	require.NoError(t, err)
+
+	return sha
+}
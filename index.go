@@ -0,0 +1,308 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Index is a Git index file (also known as the staging area), which lists the
+// entries to be committed as the next tree, along with what was known about
+// each of their files in the working tree when they were last updated.
+type Index struct {
+	// Version is the version of the index file format, which is one of 2,
+	// 3, or 4.
+	Version uint32
+	// Entries are the entries in the index, in the order in which they
+	// were stored, which is by path, then stage.
+	Entries []*IndexEntry
+}
+
+// IndexEntry is a single entry in an index.
+type IndexEntry struct {
+	// Path is the slash-separated path of the entry, relative to the root
+	// of the working tree.
+	Path string
+	// Filemode is the mode of the entry, as it would be given in a tree.
+	Filemode int32
+	// Oid is the ID of the object which the entry refers to.
+	Oid []byte
+	// Stage is the merge stage of the entry: 0 for an entry which is not
+	// conflicted, or 1, 2, or 3 for the base, our, or their version of a
+	// conflicted path, respectively.
+	Stage int
+
+	// Ctime and Mtime are the times at which the entry's file was last
+	// changed, and its contents last modified, respectively.
+	Ctime, Mtime time.Time
+	// Dev, Ino, UID, and GID are the device and inode numbers of the
+	// entry's file, and the IDs of its owning user and group, as given by
+	// stat(2), truncated to 32 bits.
+	Dev, Ino, UID, GID uint32
+	// Size is the size of the entry's file, truncated to 32 bits.
+	Size uint32
+
+	// AssumeValid indicates that the entry's file is assumed to be
+	// unchanged (see: "git update-index --assume-unchanged").
+	AssumeValid bool
+	// SkipWorktree indicates that the entry's file is not present in the
+	// working tree, for instance because it is outside of a sparse
+	// checkout.
+	SkipWorktree bool
+	// IntentToAdd indicates that the entry records that its path will be
+	// added, but not yet its contents (see: "git add --intent-to-add").
+	IntentToAdd bool
+}
+
+const (
+	// indexSignature is the magic number at the beginning of every index
+	// file.
+	indexSignature = "DIRC"
+
+	// indexAssumeValid, indexExtended, and indexStageMask are the bits of
+	// the flags of an index entry which mark it as assumed valid, give it
+	// extended flags, and hold its stage, respectively. indexNameMask holds
+	// the length of its path.
+	indexAssumeValid = 0x8000
+	indexExtended    = 0x4000
+	indexStageMask   = 0x3000
+	indexNameMask    = 0x0fff
+
+	// indexSkipWorktree and indexIntentToAdd are the bits of the extended
+	// flags of an index entry which mark it as skipped in the working tree,
+	// and intended to be added, respectively.
+	indexSkipWorktree = 0x4000
+	indexIntentToAdd  = 0x2000
+)
+
+// ReadIndex reads the index file at "path", as found in a repository's ".git"
+// directory, whose object IDs are of the kind used by this database. Versions 2
+// through 4 of the format are supported.
+//
+// Extensions which Git permits readers to ignore, such as the cached trees
+// ("TREE") and resolved conflicts ("REUC"), are skipped. Sparse directory
+// entries ("sdir") are read as ordinary entries with a filemode of 040000. A
+// split index ("link"), whose entries are shared with another file, is not
+// supported.
+//
+// If the index could not be read, is malformed, uses an unsupported version or
+// extension, or its trailing checksum does not match its contents, an error is
+// returned. A trailing checksum of all zeroes, as written by Git when
+// "index.skipHash" is set, is not checked.
+func (o *ObjectDatabase) ReadIndex(path string) (*Index, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := o.Hasher()
+	hashlen := h.Size()
+
+	if len(data) < 12+hashlen {
+		return nil, fmt.Errorf("gitobj: index %s is truncated", path)
+	}
+	sum := data[len(data)-hashlen:]
+	data = data[:len(data)-hashlen]
+
+	h.Write(data)
+	if got := h.Sum(nil); !bytes.Equal(got, sum) && !bytes.Equal(sum, make([]byte, hashlen)) {
+		return nil, fmt.Errorf("gitobj: index %s checksum mismatch: contents hash to %x, expected %x",
+			path, got, sum)
+	}
+
+	if string(data[:4]) != indexSignature {
+		return nil, fmt.Errorf("gitobj: %s is not an index", path)
+	}
+
+	idx := &Index{Version: binary.BigEndian.Uint32(data[4:])}
+	if idx.Version < 2 || idx.Version > 4 {
+		return nil, fmt.Errorf("gitobj: unsupported index version %d", idx.Version)
+	}
+
+	count := binary.BigEndian.Uint32(data[8:])
+	r := &indexReader{data: data, off: 12, hashlen: hashlen, version: idx.Version}
+	for i := uint32(0); i < count; i++ {
+		entry, err := r.entry()
+		if err != nil {
+			return nil, fmt.Errorf("gitobj: malformed entry %d in index %s: %s", i, path, err)
+		}
+		idx.Entries = append(idx.Entries, entry)
+	}
+
+	if err = r.extensions(); err != nil {
+		return nil, fmt.Errorf("gitobj: malformed index %s: %s", path, err)
+	}
+	return idx, nil
+}
+
+// WriteTreeFromIndex writes the trees described by the entries of the index
+// "idx", and returns the object ID of the root tree, as "git write-tree" would.
+// Entries which are only intended to be added are left out, and sparse
+// directory entries are written as the subtrees that they name. The objects
+// named by the entries are not required to exist.
+//
+// If the index has any unmerged entries, or any tree could not be written, an
+// error is returned instead.
+func (o *ObjectDatabase) WriteTreeFromIndex(idx *Index) ([]byte, error) {
+	files := make(map[string]*TreeEntry, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		if entry.Stage != 0 {
+			return nil, fmt.Errorf("gitobj: cannot write tree from index with unmerged path %q", entry.Path)
+		}
+		if entry.IntentToAdd {
+			continue
+		}
+
+		files[strings.TrimSuffix(entry.Path, "/")] = &TreeEntry{
+			Oid:      entry.Oid,
+			Filemode: entry.Filemode,
+		}
+	}
+	return o.writeFiles(files)
+}
+
+// indexReader reads the entries and extensions of an index file, whose
+// contents (without their checksum) are "data".
+type indexReader struct {
+	data []byte
+	// off is the offset in "data" of the next entry or extension.
+	off int
+	// hashlen is the length of the object IDs of the entries.
+	hashlen int
+	// version is the version of the index file format.
+	version uint32
+	// last is the path of the last entry read, against which paths are
+	// compressed in version 4.
+	last string
+}
+
+// entry reads the next entry.
+func (r *indexReader) entry() (*IndexEntry, error) {
+	start := r.off
+	fixed := 40 + r.hashlen + 2
+	if len(r.data)-r.off < fixed {
+		return nil, fmt.Errorf("unexpected end of entries")
+	}
+
+	b := r.data[r.off:]
+	word := func(i int) uint32 { return binary.BigEndian.Uint32(b[4*i:]) }
+
+	entry := &IndexEntry{
+		Ctime:    time.Unix(int64(word(0)), int64(word(1))),
+		Mtime:    time.Unix(int64(word(2)), int64(word(3))),
+		Dev:      word(4),
+		Ino:      word(5),
+		Filemode: int32(word(6)),
+		UID:      word(7),
+		GID:      word(8),
+		Size:     word(9),
+		Oid:      append([]byte(nil), b[40:40+r.hashlen]...),
+	}
+
+	flags := binary.BigEndian.Uint16(b[40+r.hashlen:])
+	entry.AssumeValid = flags&indexAssumeValid != 0
+	entry.Stage = int(flags&indexStageMask) >> 12
+	r.off += fixed
+
+	if flags&indexExtended != 0 {
+		if r.version < 3 {
+			return nil, fmt.Errorf("extended flags in version %d", r.version)
+		}
+		if len(r.data)-r.off < 2 {
+			return nil, fmt.Errorf("unexpected end of entries")
+		}
+
+		extended := binary.BigEndian.Uint16(r.data[r.off:])
+		entry.SkipWorktree = extended&indexSkipWorktree != 0
+		entry.IntentToAdd = extended&indexIntentToAdd != 0
+		r.off += 2
+	}
+
+	var prefix string
+	if r.version >= 4 {
+		strip, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if strip > uint64(len(r.last)) {
+			return nil, fmt.Errorf("cannot remove %d bytes from path %q", strip, r.last)
+		}
+		prefix = r.last[:len(r.last)-int(strip)]
+	}
+
+	nul := bytes.IndexByte(r.data[r.off:], 0)
+	if nul < 0 {
+		return nil, fmt.Errorf("unterminated path")
+	}
+	if n := int(flags & indexNameMask); r.version < 4 && n < indexNameMask && n != nul {
+		return nil, fmt.Errorf("path has length %d, expected %d", nul, n)
+	}
+	entry.Path = prefix + string(r.data[r.off:r.off+nul])
+	r.off += nul + 1
+
+	if r.version < 4 {
+		// Entries are padded with between one and eight NULs
+		// (including that which terminates the path), to a multiple
+		// of eight bytes.
+		r.off = start + (r.off-1-start+8)&^7
+		if r.off > len(r.data) {
+			return nil, fmt.Errorf("unexpected end of entries")
+		}
+	}
+
+	r.last = entry.Path
+	return entry, nil
+}
+
+// varint reads a variable-length integer as encoded in version 4 of the index
+// format, in which each byte but the last has its high bit set, and each
+// continuation adds one to the value so far before shifting it.
+func (r *indexReader) varint() (uint64, error) {
+	var val uint64
+	for i := 0; ; i++ {
+		if r.off >= len(r.data) || i > 9 {
+			return 0, fmt.Errorf("malformed path compression")
+		}
+
+		c := r.data[r.off]
+		r.off++
+
+		if i > 0 {
+			val = (val + 1) << 7
+		}
+		val |= uint64(c & 0x7f)
+
+		if c&0x80 == 0 {
+			return val, nil
+		}
+	}
+}
+
+// extensions reads each of the extensions following the entries, which are
+// skipped unless they are required and unsupported.
+func (r *indexReader) extensions() error {
+	for r.off < len(r.data) {
+		if len(r.data)-r.off < 8 {
+			return fmt.Errorf("truncated extension header")
+		}
+
+		sig := string(r.data[r.off : r.off+4])
+		size := binary.BigEndian.Uint32(r.data[r.off+4:])
+		r.off += 8
+
+		if uint64(size) > uint64(len(r.data)-r.off) {
+			return fmt.Errorf("extension %q is truncated", sig)
+		}
+		r.off += int(size)
+
+		// Extensions beginning with an uppercase letter are optional,
+		// and may be ignored by readers which do not understand them.
+		if (sig[0] < 'A' || sig[0] > 'Z') && sig != "sdir" {
+			return fmt.Errorf("unsupported extension %q", sig)
+		}
+	}
+	return nil
+}
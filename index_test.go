@@ -0,0 +1,158 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIndex returns a repository whose index holds a blob at each of the
+// given paths, which is written by "git update-index" using the given index
+// format version, along with a database of the repository's objects.
+func newTestIndex(t *testing.T, version string, paths ...string) (string, *ObjectDatabase) {
+	dir := newTestGitDir(t)
+
+	blob := runTestGit(t, dir, "Hello, world!\n", "hash-object", "-w", "--stdin")
+	for _, path := range paths {
+		runTestGit(t, dir, "", "update-index", "--add", "--cacheinfo", "100644,"+blob+","+path)
+	}
+	runTestGit(t, dir, "", "update-index", "--index-version", version)
+	// Write the cached trees ("TREE") extension.
+	runTestGit(t, dir, "", "write-tree")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+
+	return dir, odb
+}
+
+func TestReadIndex(t *testing.T) {
+	for version, expected := range map[string]uint32{
+		"2": 2,
+		// Git only writes version 3 when an entry needs it.
+		"3": 2,
+		"4": 4,
+	} {
+		dir, odb := newTestIndex(t, version, "a.txt", "dir/b.txt", "dir/sub/c.txt", "z.txt")
+		defer os.RemoveAll(dir)
+		defer odb.Close()
+
+		idx, err := odb.ReadIndex(filepath.Join(dir, "index"))
+		require.NoError(t, err, version)
+
+		assert.Equal(t, expected, idx.Version)
+
+		var stages []string
+		for _, entry := range idx.Entries {
+			assert.Equal(t, int32(0100644), entry.Filemode)
+			stages = append(stages, "100644 "+hex.EncodeToString(entry.Oid)+" 0\t"+entry.Path)
+		}
+		assert.Equal(t, runTestGit(t, dir, "", "ls-files", "--stage"), strings.Join(stages, "\n"))
+	}
+}
+
+func TestReadIndexExtendedFlags(t *testing.T) {
+	dir, odb := newTestIndex(t, "2", "a.txt")
+	defer os.RemoveAll(dir)
+	defer odb.Close()
+
+	worktree, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(worktree)
+
+	runTestGit(t, dir, "", "--work-tree", worktree, "update-index", "--skip-worktree", "a.txt")
+
+	idx, err := odb.ReadIndex(filepath.Join(dir, "index"))
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 3, idx.Version)
+	require.Len(t, idx.Entries, 1)
+	assert.True(t, idx.Entries[0].SkipWorktree)
+	assert.False(t, idx.Entries[0].IntentToAdd)
+}
+
+func TestReadIndexChecksumMismatch(t *testing.T) {
+	dir, odb := newTestIndex(t, "2", "a.txt")
+	defer os.RemoveAll(dir)
+	defer odb.Close()
+
+	path := filepath.Join(dir, "index")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	_, err = odb.ReadIndex(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	}
+}
+
+func TestReadIndexSkipHash(t *testing.T) {
+	dir, odb := newTestIndex(t, "2", "a.txt")
+	defer os.RemoveAll(dir)
+	defer odb.Close()
+
+	path := filepath.Join(dir, "index")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	// Git writes an all-zero checksum when "index.skipHash" is set.
+	copy(data[len(data)-20:], make([]byte, 20))
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	idx, err := odb.ReadIndex(path)
+	require.NoError(t, err)
+	require.Len(t, idx.Entries, 1)
+	assert.Equal(t, "a.txt", idx.Entries[0].Path)
+}
+
+func TestReadIndexNotAnIndex(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	f, err := ioutil.TempFile("", "gitobj")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(strings.Repeat("x", 40))
+	f.Close()
+
+	_, err = odb.ReadIndex(f.Name())
+	assert.Error(t, err)
+}
+
+func TestWriteTreeFromIndex(t *testing.T) {
+	dir, odb := newTestIndex(t, "4", "a.txt", "dir/b.txt", "dir/sub/c.txt", "dir.txt", "z.txt")
+	defer os.RemoveAll(dir)
+	defer odb.Close()
+
+	runTestGit(t, dir, "", "update-index", "--add", "--cacheinfo",
+		"160000,"+strings.Repeat("1", 40)+",module")
+	blob := runTestGit(t, dir, "Hello, world!\n", "hash-object", "--stdin")
+	runTestGit(t, dir, "", "update-index", "--add", "--cacheinfo", "100755,"+blob+",dir/run.sh")
+
+	idx, err := odb.ReadIndex(filepath.Join(dir, "index"))
+	require.NoError(t, err)
+
+	sha, err := odb.WriteTreeFromIndex(idx)
+	require.NoError(t, err)
+
+	assert.Equal(t, runTestGit(t, dir, "", "write-tree"), hex.EncodeToString(sha))
+}
+
+func TestWriteTreeFromIndexUnmerged(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, err := odb.WriteTreeFromIndex(&Index{Version: 2, Entries: []*IndexEntry{
+		{Path: "a.txt", Filemode: 0100644, Oid: make([]byte, 20), Stage: 2},
+	}})
+
+	assert.EqualError(t, err, `gitobj: cannot write tree from index with unmerged path "a.txt"`)
+}
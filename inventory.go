@@ -0,0 +1,102 @@
+package gitobj
+
+// FileInfo describes a single blob found beneath a tree by Inventory.
+type FileInfo struct {
+	// Path is the slash-separated path of the blob, relative to the root
+	// of the tree.
+	Path string
+	// Oid is the ID of the blob.
+	Oid []byte
+	// Filemode is the filemode of the blob's entry.
+	Filemode int32
+	// Size is the inflated size of the blob's contents, in bytes.
+	Size int64
+}
+
+// Inventory returns a *FileInfo for every blob (including symbolic links, but
+// not gitlinks) beneath the tree named "root", in tree order.
+//
+// The size of each blob is read from its header alone, so its contents are not
+// inflated. A subtree or blob which appears at more than one path is only read
+// the first time it is encountered.
+//
+// If the tree, any of its subtrees, or the header of any blob could not be
+// read, an error is returned instead.
+func (o *ObjectDatabase) Inventory(root []byte) ([]*FileInfo, error) {
+	files, err := o.inventory(root, make(map[string][]*FileInfo), make(map[string]int64))
+	if err != nil {
+		return nil, err
+	}
+	if files == nil {
+		files = []*FileInfo{}
+	}
+	return files, nil
+}
+
+// inventory returns the blobs beneath the tree named "tree", with paths
+// relative to it. The result for each subtree is recorded in "seen", and the
+// size of each blob in "sizes", so that neither is read again.
+func (o *ObjectDatabase) inventory(tree []byte, seen map[string][]*FileInfo, sizes map[string]int64) ([]*FileInfo, error) {
+	if files, ok := seen[string(tree)]; ok {
+		return files, nil
+	}
+
+	t, err := o.Tree(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*FileInfo
+	for _, entry := range t.Entries {
+		switch entry.Type() {
+		case BlobObjectType:
+			size, ok := sizes[string(entry.Oid)]
+			if !ok {
+				if size, err = o.blobSize(entry.Oid); err != nil {
+					return nil, err
+				}
+				sizes[string(entry.Oid)] = size
+			}
+
+			files = append(files, &FileInfo{
+				Path:     entry.Name,
+				Oid:      entry.Oid,
+				Filemode: entry.Filemode,
+				Size:     size,
+			})
+		case TreeObjectType:
+			sub, err := o.inventory(entry.Oid, seen, sizes)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, file := range sub {
+				copied := *file
+				copied.Path = entry.Name + "/" + file.Path
+
+				files = append(files, &copied)
+			}
+		}
+	}
+
+	seen[string(tree)] = files
+	return files, nil
+}
+
+// blobSize returns the size of the blob named "sha" by reading only its
+// header.
+func (o *ObjectDatabase) blobSize(sha []byte) (int64, error) {
+	r, err := o.open(sha)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return 0, err
+	} else if typ != BlobObjectType {
+		return 0, &UnexpectedObjectType{Got: typ, Wanted: BlobObjectType}
+	}
+	return size, nil
+}
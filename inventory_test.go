@@ -0,0 +1,67 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	other, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "other.txt", Oid: other, Filemode: 0100755},
+	}})
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a", Oid: subtree, Filemode: 040000},
+		{Name: "b", Oid: subtree, Filemode: 040000},
+		{Name: "hello.txt", Oid: hello, Filemode: 0100644},
+		{Name: "link", Oid: hello, Filemode: 0120000},
+		{Name: "submodule", Oid: hello, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	files, err := odb.Inventory(tree)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*FileInfo{
+		{Path: "a/other.txt", Oid: other, Filemode: 0100755, Size: 16},
+		{Path: "b/other.txt", Oid: other, Filemode: 0100755, Size: 16},
+		{Path: "hello.txt", Oid: hello, Filemode: 0100644, Size: 14},
+		{Path: "link", Oid: hello, Filemode: 0120000, Size: 14},
+	}, files)
+}
+
+func TestInventoryEmptyTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	files, err := odb.Inventory(tree)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*FileInfo{}, files)
+}
+
+func TestInventoryMissingBlob(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "missing.txt", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	files, err := odb.Inventory(tree)
+
+	assert.Error(t, err)
+	assert.Nil(t, files)
+}
@@ -0,0 +1,132 @@
+package gitobj
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// objectSizer is implemented by storage which can report the sizes of the
+// objects it holds without reading their contents.
+type objectSizer interface {
+	// EachSize calls "fn" with the ID, type, and inflated size of each
+	// object in the storage, along with the number of bytes that it
+	// occupies in the storage, stopping at the first error. Each ID given
+	// must not be modified afterwards.
+	EachSize(fn func(oid []byte, typ string, size, stored int64) error) error
+}
+
+// ObjectSize gives the sizes of a single object, as returned by LargestObjects.
+type ObjectSize struct {
+	// Oid is the ID of the object.
+	Oid []byte
+	// Type is the type of the object.
+	Type ObjectType
+	// Size is the size of the object's contents, once inflated (and, if
+	// it is stored as a delta, resolved).
+	Size int64
+	// StoredSize is the number of bytes that the object occupies in the
+	// storage holding it: the size of its compressed file if it is loose,
+	// or of its entry (excluding those of any delta bases) if it is
+	// packed.
+	StoredSize int64
+}
+
+// LargestObjects returns the "n" largest objects in the database by inflated
+// size, largest first, with objects of the same size ordered by ID. An object
+// stored more than once is only given once, with the stored size of the first
+// copy found.
+//
+// Sizes are read from the headers of loose objects and packfile entries, so no
+// object's contents are read, and only "n" objects are held in memory at once.
+//
+// If any storage in the database cannot report the sizes of its objects, or
+// any object's header could not be read, an error is returned instead.
+func (o *ObjectDatabase) LargestObjects(n int) ([]*ObjectSize, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	all := o.storages()
+
+	sizers := make([]objectSizer, 0, len(all))
+	for _, s := range all {
+		sz, ok := s.(objectSizer)
+		if !ok {
+			return nil, fmt.Errorf("gitobj: cannot list object sizes in storage of type %T", s)
+		}
+		sizers = append(sizers, sz)
+	}
+
+	largest := &sizeHeap{held: make(map[string]bool, n)}
+	for _, sz := range sizers {
+		err := sz.EachSize(func(oid []byte, typ string, size, stored int64) error {
+			if largest.held[string(oid)] {
+				return nil
+			}
+
+			obj := &ObjectSize{
+				Oid:        oid,
+				Type:       ObjectTypeFromString(typ),
+				Size:       size,
+				StoredSize: stored,
+			}
+
+			if largest.Len() < n {
+				heap.Push(largest, obj)
+			} else if smaller(largest.objs[0], obj) {
+				delete(largest.held, string(largest.objs[0].Oid))
+				largest.objs[0] = obj
+				largest.held[string(oid)] = true
+				heap.Fix(largest, 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objs := largest.objs
+	sort.Slice(objs, func(i, j int) bool {
+		return smaller(objs[j], objs[i])
+	})
+	return objs, nil
+}
+
+// smaller returns whether "a" ranks below "b" among the largest objects: that
+// is, whether it is smaller, or the same size with a greater ID.
+func smaller(a, b *ObjectSize) bool {
+	if a.Size != b.Size {
+		return a.Size < b.Size
+	}
+	return bytes.Compare(a.Oid, b.Oid) > 0
+}
+
+// sizeHeap is a heap.Interface holding the largest objects found so far, whose
+// root is the object ranking lowest among them (see: smaller).
+type sizeHeap struct {
+	objs []*ObjectSize
+	// held is the set of IDs of the objects in the heap.
+	held map[string]bool
+}
+
+func (h *sizeHeap) Len() int           { return len(h.objs) }
+func (h *sizeHeap) Less(i, j int) bool { return smaller(h.objs[i], h.objs[j]) }
+func (h *sizeHeap) Swap(i, j int)      { h.objs[i], h.objs[j] = h.objs[j], h.objs[i] }
+
+func (h *sizeHeap) Push(x interface{}) {
+	obj := x.(*ObjectSize)
+
+	h.objs = append(h.objs, obj)
+	h.held[string(obj.Oid)] = true
+}
+
+func (h *sizeHeap) Pop() interface{} {
+	obj := h.objs[len(h.objs)-1]
+	h.objs = h.objs[:len(h.objs)-1]
+
+	delete(h.held, string(obj.Oid))
+	return obj
+}
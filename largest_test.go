@@ -0,0 +1,146 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargestObjects(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	small := writeTestBlob(t, odb, "a")
+	medium := writeTestBlob(t, odb, strings.Repeat("b", 100))
+	large := writeTestBlob(t, odb, strings.Repeat("c", 1000))
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a", Oid: small, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	largest, err := odb.LargestObjects(2)
+	require.NoError(t, err)
+	require.Len(t, largest, 2)
+
+	assert.Equal(t, large, largest[0].Oid)
+	assert.Equal(t, BlobObjectType, largest[0].Type)
+	assert.EqualValues(t, 1000, largest[0].Size)
+	assert.True(t, largest[0].StoredSize > 0)
+	assert.True(t, largest[0].StoredSize < largest[0].Size)
+
+	assert.Equal(t, medium, largest[1].Oid)
+	assert.EqualValues(t, 100, largest[1].Size)
+
+	all, err := odb.LargestObjects(10)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+
+	assert.Equal(t, tree, all[2].Oid)
+	assert.Equal(t, TreeObjectType, all[2].Type)
+	assert.Equal(t, small, all[3].Oid)
+}
+
+func TestLargestObjectsOrdersTiesByOid(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeTestBlob(t, odb, "a")
+	b := writeTestBlob(t, odb, "b")
+	c := writeTestBlob(t, odb, "c")
+
+	largest, err := odb.LargestObjects(2)
+	require.NoError(t, err)
+
+	oids := [][]byte{a, b, c}
+	sort.Slice(oids, func(i, j int) bool {
+		return bytes.Compare(oids[i], oids[j]) < 0
+	})
+
+	require.Len(t, largest, 2)
+	assert.Equal(t, oids[0], largest[0].Oid)
+	assert.Equal(t, oids[1], largest[1].Oid)
+}
+
+func TestLargestObjectsNone(t *testing.T) {
+	odb := newTestDatabase(t)
+	writeTestBlob(t, odb, "a")
+
+	largest, err := odb.LargestObjects(0)
+	assert.NoError(t, err)
+	assert.Empty(t, largest)
+}
+
+func TestLargestObjectsPacked(t *testing.T) {
+	dir := newTestGitDir(t)
+
+	contents := strings.Repeat("Hello, world!\n", 100)
+	blob := runTestGit(t, dir, contents, "hash-object", "-w", "--stdin")
+	delta := runTestGit(t, dir, contents+"Goodbye!\n", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, blob+"\n"+delta+"\n",
+		"pack-objects", "-q", filepath.Join(dir, "objects", "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+
+	// Store one of the objects loose as well, so that it is held twice.
+	runTestGit(t, dir, contents, "hash-object", "-w", "--stdin")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	largest, err := odb.LargestObjects(10)
+	require.NoError(t, err)
+	require.Len(t, largest, 2)
+
+	assert.Equal(t, delta, hex.EncodeToString(largest[0].Oid))
+	assert.Equal(t, BlobObjectType, largest[0].Type)
+	assert.EqualValues(t, len(contents)+9, largest[0].Size)
+
+	assert.Equal(t, blob, hex.EncodeToString(largest[1].Oid))
+	assert.EqualValues(t, len(contents), largest[1].Size)
+
+	for _, obj := range largest {
+		if hex.EncodeToString(obj.Oid) == blob {
+			// The loose copy may be found first.
+			continue
+		}
+
+		disk := runTestGit(t, dir, hex.EncodeToString(obj.Oid)+"\n",
+			"cat-file", "--batch-check=%(objectsize:disk)")
+		want, err := strconv.ParseInt(disk, 10, 64)
+		require.NoError(t, err)
+
+		assert.Equal(t, want, obj.StoredSize)
+	}
+}
+
+func TestLargestObjectsFromGitBackend(t *testing.T) {
+	dir := newTestGitDir(t)
+
+	small := runTestGit(t, dir, "a", "hash-object", "-w", "--stdin")
+	large := runTestGit(t, dir, strings.Repeat("b", 100), "hash-object", "-w", "--stdin")
+
+	backend, err := NewGitBackend(dir)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer odb.Close()
+
+	largest, err := odb.LargestObjects(10)
+	require.NoError(t, err)
+	require.Len(t, largest, 2)
+
+	assert.Equal(t, large, hex.EncodeToString(largest[0].Oid))
+	assert.Equal(t, BlobObjectType, largest[0].Type)
+	assert.EqualValues(t, 100, largest[0].Size)
+	assert.True(t, largest[0].StoredSize > 0)
+
+	assert.Equal(t, small, hex.EncodeToString(largest[1].Oid))
+	assert.EqualValues(t, 1, largest[1].Size)
+}
@@ -0,0 +1,150 @@
+package gitobj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lfsPointerMaxSize is the largest blob which is considered as a possible Git
+// LFS pointer. Git LFS itself ignores anything larger.
+const lfsPointerMaxSize = 1024
+
+var (
+	// lfsPointerVersions are the values of the "version" key which
+	// identify a Git LFS pointer, including that used by pre-release
+	// versions of Git LFS.
+	lfsPointerVersions = map[string]bool{
+		"https://git-lfs.github.com/spec/v1": true,
+		"https://hawser.github.com/spec/v1":  true,
+	}
+
+	// lfsOidPattern matches the value of an "oid" key, and of an
+	// extension.
+	lfsOidPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+	// lfsExtensionPattern matches the key of an extension, capturing its
+	// priority and name.
+	lfsExtensionPattern = regexp.MustCompile(`^ext-([0-9])-([a-z0-9]+)$`)
+)
+
+// LFSPointer is a parsed Git LFS pointer: the small blob which Git LFS stores
+// in place of the contents of a file, which are kept elsewhere.
+type LFSPointer struct {
+	// Version is the URL identifying the version of the pointer format.
+	Version string
+	// Oid is the hex-encoded SHA-256 of the file's contents.
+	Oid string
+	// Size is the size of the file's contents, in bytes.
+	Size int64
+	// Extensions are the extensions which were applied to the file's
+	// contents when it was stored, in order of priority.
+	Extensions []*LFSPointerExtension
+}
+
+// LFSPointerExtension is a single extension recorded in a Git LFS pointer.
+type LFSPointerExtension struct {
+	// Name is the name of the extension.
+	Name string
+	// Priority is the order in which the extension was applied.
+	Priority int
+	// Oid is the hex-encoded SHA-256 of the contents given to the
+	// extension.
+	Oid string
+}
+
+// IsLFSPointer returns whether the blob named "sha" is a Git LFS pointer, and
+// if so, the pointer that it contains.
+//
+// Only blobs small enough to be pointers are read, so this is cheap even for
+// large blobs. A blob which is not a pointer is reported as such without error;
+// an error is returned only if the blob could not be read.
+func (o *ObjectDatabase) IsLFSPointer(sha []byte) (bool, *LFSPointer, error) {
+	blob, err := o.Blob(sha)
+	if err != nil {
+		return false, nil, err
+	}
+	defer blob.Close()
+
+	if blob.Size > lfsPointerMaxSize {
+		return false, nil, nil
+	}
+
+	data, err := ioutil.ReadAll(blob.Contents)
+	if err != nil {
+		return false, nil, err
+	}
+
+	p, ok := parseLFSPointer(data)
+	return ok, p, nil
+}
+
+// parseLFSPointer parses "data" as a Git LFS pointer, and returns whether it
+// is one.
+//
+// A pointer consists of "<key> <value>" lines, each ending in a newline. The
+// first key is "version", and the remainder are in sorted order, and include
+// "oid" and "size". Unknown keys are permitted, for forward compatibility.
+func parseLFSPointer(data []byte) (*LFSPointer, bool) {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		return nil, false
+	}
+
+	p := new(LFSPointer)
+
+	var hasOid, hasSize bool
+	var prev string
+	for i, line := range strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, false
+		}
+		key, value := parts[0], parts[1]
+
+		if i == 0 {
+			if key != "version" || !lfsPointerVersions[value] {
+				return nil, false
+			}
+			p.Version = value
+			continue
+		}
+
+		if key <= prev || key == "version" {
+			return nil, false
+		}
+		prev = key
+
+		switch {
+		case key == "oid":
+			if !lfsOidPattern.MatchString(value) {
+				return nil, false
+			}
+			p.Oid, hasOid = strings.TrimPrefix(value, "sha256:"), true
+		case key == "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, false
+			}
+			p.Size, hasSize = size, true
+		case strings.HasPrefix(key, "ext-"):
+			m := lfsExtensionPattern.FindStringSubmatch(key)
+			if m == nil || !lfsOidPattern.MatchString(value) {
+				return nil, false
+			}
+			priority, _ := strconv.Atoi(m[1])
+
+			p.Extensions = append(p.Extensions, &LFSPointerExtension{
+				Name:     m[2],
+				Priority: priority,
+				Oid:      strings.TrimPrefix(value, "sha256:"),
+			})
+		}
+	}
+
+	if !hasOid || !hasSize {
+		return nil, false
+	}
+	return p, true
+}
@@ -0,0 +1,90 @@
+package gitobj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lfsTestOid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestIsLFSPointer(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(
+		"version https://git-lfs.github.com/spec/v1\n" +
+			"oid sha256:" + lfsTestOid + "\n" +
+			"size 12345\n")))
+	require.NoError(t, err)
+
+	ok, p, err := odb.IsLFSPointer(sha)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &LFSPointer{
+		Version: "https://git-lfs.github.com/spec/v1",
+		Oid:     lfsTestOid,
+		Size:    12345,
+	}, p)
+}
+
+func TestIsLFSPointerWithExtensions(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(
+		"version https://git-lfs.github.com/spec/v1\n" +
+			"ext-0-foo sha256:" + strings.Repeat("a", 64) + "\n" +
+			"ext-1-bar sha256:" + strings.Repeat("b", 64) + "\n" +
+			"oid sha256:" + lfsTestOid + "\n" +
+			"size 0\n")))
+	require.NoError(t, err)
+
+	ok, p, err := odb.IsLFSPointer(sha)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []*LFSPointerExtension{
+		{Name: "foo", Priority: 0, Oid: strings.Repeat("a", 64)},
+		{Name: "bar", Priority: 1, Oid: strings.Repeat("b", 64)},
+	}, p.Extensions)
+}
+
+func TestIsLFSPointerNotAPointer(t *testing.T) {
+	for desc, contents := range map[string]string{
+		"text":           "Hello, world!\n",
+		"empty":          "",
+		"no newline":     "version https://git-lfs.github.com/spec/v1\noid sha256:" + lfsTestOid + "\nsize 1",
+		"no size":        "version https://git-lfs.github.com/spec/v1\noid sha256:" + lfsTestOid + "\n",
+		"unsorted":       "version https://git-lfs.github.com/spec/v1\nsize 1\noid sha256:" + lfsTestOid + "\n",
+		"bad oid":        "version https://git-lfs.github.com/spec/v1\noid sha256:1234\nsize 1\n",
+		"bad size":       "version https://git-lfs.github.com/spec/v1\noid sha256:" + lfsTestOid + "\nsize -1\n",
+		"bad version":    "version https://example.com/spec/v1\noid sha256:" + lfsTestOid + "\nsize 1\n",
+		"version second": "oid sha256:" + lfsTestOid + "\nversion https://git-lfs.github.com/spec/v1\nsize 1\n",
+		"large":          strings.Repeat("x", lfsPointerMaxSize+1),
+	} {
+		t.Run(desc, func(t *testing.T) {
+			odb := newTestDatabase(t)
+
+			sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(contents)))
+			require.NoError(t, err)
+
+			ok, p, err := odb.IsLFSPointer(sha)
+
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Nil(t, p)
+		})
+	}
+}
+
+func TestIsLFSPointerMissingBlob(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	ok, p, err := odb.IsLFSPointer([]byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, p)
+}
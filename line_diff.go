@@ -0,0 +1,405 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// lineOp is the kind of a single step in a line-by-line edit script.
+type lineOp uint8
+
+const (
+	// lineEqual keeps a line common to both sides.
+	lineEqual lineOp = iota
+	// lineDelete removes a line from the old side.
+	lineDelete
+	// lineInsert adds a line from the new side.
+	lineInsert
+)
+
+// lineEdit is a single step in a line-by-line edit script.
+type lineEdit struct {
+	// op is the kind of step.
+	op lineOp
+	// a and b are the number of lines of the old and new sides,
+	// respectively, which precede this step. For lineEqual and
+	// lineDelete, "a" is also the index of the line concerned on the old
+	// side, and likewise for lineEqual, lineInsert and "b".
+	a, b int
+}
+
+// diffLines returns a shortest edit script turning the lines "a" into the lines
+// "b", using the linear-space variant of the algorithm described in Eugene W.
+// Myers' "An O(ND) Difference Algorithm and Its Variations". Rather than
+// keeping the furthest points reached in each round, which takes O((N+M)D)
+// memory, each step finds the middle snake of an optimal path by searching
+// from both ends at once, and divides the problem there.
+func diffLines(a, b [][]byte) []lineEdit {
+	d := &lineDiffer{a: a, b: b, edits: make([]lineEdit, 0, len(a)+len(b))}
+	d.compare(0, len(a), 0, len(b))
+
+	return slideEdits(a, b, d.edits)
+}
+
+// lineDiffer accumulates the edit script turning the lines "a" into the lines
+// "b" (see: diffLines).
+type lineDiffer struct {
+	a, b  [][]byte
+	edits []lineEdit
+}
+
+// compare appends the edits turning the lines a[a0:a1] into b[b0:b1].
+func (d *lineDiffer) compare(a0, a1, b0, b1 int) {
+	// Lines common to the start and end of both sides are kept without
+	// searching for them.
+	start, end := a0, a1
+	for a0 < a1 && b0 < b1 && bytes.Equal(d.a[a0], d.b[b0]) {
+		a0, b0 = a0+1, b0+1
+	}
+	for a0 < a1 && b0 < b1 && bytes.Equal(d.a[a1-1], d.b[b1-1]) {
+		a1, b1 = a1-1, b1-1
+	}
+	d.equal(start, a0, b0-(a0-start))
+
+	switch {
+	case a0 == a1:
+		for y := b0; y < b1; y++ {
+			d.edits = append(d.edits, lineEdit{op: lineInsert, a: a0, b: y})
+		}
+	case b0 == b1:
+		for x := a0; x < a1; x++ {
+			d.edits = append(d.edits, lineEdit{op: lineDelete, a: x, b: b0})
+		}
+	default:
+		x, y := d.middle(a0, a1, b0, b1)
+		d.compare(a0, x, b0, y)
+		d.compare(x, a1, y, b1)
+	}
+
+	d.equal(a1, end, b1)
+}
+
+// equal appends the edits keeping the lines a[a0:a1], which are the same as
+// those of "b" beginning at "b0".
+func (d *lineDiffer) equal(a0, a1, b0 int) {
+	for x := a0; x < a1; x++ {
+		d.edits = append(d.edits, lineEdit{op: lineEqual, a: x, b: b0 + x - a0})
+	}
+}
+
+// middle returns a point on an optimal path from (a0, b0) to (a1, b1) which
+// divides it into two shorter ones, found where the furthest paths searched
+// forwards from the start and backwards from the end first overlap. Neither
+// side may be empty, and they must differ in their first and last lines.
+func (d *lineDiffer) middle(a0, a1, b0, b1 int) (int, int) {
+	n, m := a1-a0, b1-b0
+	max := (n + m + 1) / 2
+	delta := n - m
+	// odd indicates whether the paths overlap while searching forwards,
+	// rather than backwards.
+	odd := delta%2 != 0
+
+	// fwd and bwd hold, for each diagonal k (offset by max), the furthest
+	// point along the old side reached by a path on that diagonal from
+	// the start and the end, respectively, or -1 if none has been.
+	fwd := make([]int, 2*max+2)
+	bwd := make([]int, 2*max+2)
+	for i := range fwd {
+		fwd[i], bwd[i] = -1, -1
+	}
+	fwd[max+1], bwd[max+1] = 0, 0
+
+	// Diagonals whose paths have run off either side are trimmed from the
+	// search.
+	var fstart, fend, bstart, bend int
+
+	for r := 0; r < max; r++ {
+		for k := -r + fstart; k <= r-fend; k += 2 {
+			var x int
+			if k == -r || (k != r && fwd[max+k-1] < fwd[max+k+1]) {
+				x = fwd[max+k+1]
+			} else {
+				x = fwd[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(d.a[a0+x], d.b[b0+y]) {
+				x, y = x+1, y+1
+			}
+			fwd[max+k] = x
+
+			if x > n {
+				fend += 2
+			} else if y > m {
+				fstart += 2
+			} else if odd {
+				if at := max + delta - k; at >= 0 && at < len(bwd) && bwd[at] != -1 {
+					if x >= n-bwd[at] {
+						return a0 + x, b0 + y
+					}
+				}
+			}
+		}
+
+		for k := -r + bstart; k <= r-bend; k += 2 {
+			var x int
+			if k == -r || (k != r && bwd[max+k-1] < bwd[max+k+1]) {
+				x = bwd[max+k+1]
+			} else {
+				x = bwd[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(d.a[a1-x-1], d.b[b1-y-1]) {
+				x, y = x+1, y+1
+			}
+			bwd[max+k] = x
+
+			if x > n {
+				bend += 2
+			} else if y > m {
+				bstart += 2
+			} else if !odd {
+				if at := max + delta - k; at >= 0 && at < len(fwd) && fwd[at] != -1 {
+					fx := fwd[at]
+					if fy := max + fx - at; fx >= n-x {
+						return a0 + fx, b0 + fy
+					}
+				}
+			}
+		}
+	}
+
+	// The sides have no lines in common, so every line of the old side is
+	// deleted, and every line of the new side inserted.
+	return a1, b0
+}
+
+// slideEdits moves each run of insertions (or deletions) in "edits" to where
+// "git diff" places ambiguous changes, without changing the result: next to a
+// change on the other side if possible, and otherwise as far down as it will
+// go (for instance, onto the second of two identical blocks rather than the
+// first).
+func slideEdits(a, b [][]byte, edits []lineEdit) []lineEdit {
+	for start := 0; start < len(edits); {
+		op := edits[start].op
+		if op == lineEqual {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(edits) && edits[end].op == op {
+			end++
+		}
+
+		for slideUp(a, b, edits, start, end) {
+			start, end = start-1, end-1
+		}
+
+		aligned := -1
+		for {
+			if (start > 0 && edits[start-1].op != lineEqual) ||
+				(end < len(edits) && edits[end].op != lineEqual) {
+				aligned = start
+			}
+
+			if !slideDown(a, b, edits, start, end) {
+				break
+			}
+			start, end = start+1, end+1
+		}
+
+		for aligned >= 0 && start > aligned {
+			slideUp(a, b, edits, start, end)
+			start, end = start-1, end-1
+		}
+		start = end
+	}
+	return edits
+}
+
+// runLine returns the line concerned by the edit "e" on the side changed by
+// edits of type "op".
+func runLine(a, b [][]byte, op lineOp, e lineEdit) []byte {
+	if op == lineInsert {
+		return b[e.b]
+	}
+	return a[e.a]
+}
+
+// slideUp moves the run of edits[start:end] up by one line, if the line before
+// it is the same as its last line, and returns whether it did.
+func slideUp(a, b [][]byte, edits []lineEdit, start, end int) bool {
+	if start == 0 || edits[start-1].op != lineEqual {
+		return false
+	}
+
+	op := edits[start].op
+	if !bytes.Equal(runLine(a, b, op, edits[start-1]), runLine(a, b, op, edits[end-1])) {
+		return false
+	}
+
+	// The line before the run is instead kept after it, where it is
+	// preceded by one fewer line on the side which the run leaves
+	// unchanged.
+	equal := edits[end-1]
+	equal.op = lineEqual
+	if op == lineInsert {
+		equal.a--
+	} else {
+		equal.b--
+	}
+
+	copy(edits[start-1:end-1], edits[start:end])
+	for i := start - 1; i < end-1; i++ {
+		edits[i].a--
+		edits[i].b--
+	}
+	edits[end-1] = equal
+	return true
+}
+
+// slideDown moves the run of edits[start:end] down by one line, if the line
+// after it is the same as its first line, and returns whether it did.
+func slideDown(a, b [][]byte, edits []lineEdit, start, end int) bool {
+	if end == len(edits) || edits[end].op != lineEqual {
+		return false
+	}
+
+	op := edits[start].op
+	if !bytes.Equal(runLine(a, b, op, edits[start]), runLine(a, b, op, edits[end])) {
+		return false
+	}
+
+	// The line after the run is instead kept before it, at the position
+	// of the run's first line.
+	equal := lineEdit{op: lineEqual, a: edits[start].a, b: edits[start].b}
+
+	copy(edits[start+1:end+1], edits[start:end])
+	for i := start + 1; i <= end; i++ {
+		edits[i].a++
+		edits[i].b++
+	}
+	edits[start] = equal
+	return true
+}
+
+// writeUnifiedDiff writes the hunks of a unified diff turning the lines "a"
+// into the lines "b" to "w", with "context" lines of unchanged context around
+// each change. Each line is expected to include its trailing newline, if any.
+func writeUnifiedDiff(w io.Writer, a, b [][]byte, context int) error {
+	edits := diffLines(a, b)
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == lineEqual {
+			i++
+			continue
+		}
+
+		// Extend the hunk to include every change which is separated
+		// from the previous one by no more than twice the context.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(edits) && j-end <= 2*context+1; j++ {
+			if edits[j].op != lineEqual {
+				end = j
+			}
+		}
+		i = end + 1
+		if end += context + 1; end > len(edits) {
+			end = len(edits)
+		}
+
+		if err := writeHunk(w, a, b, edits[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHunk writes a single hunk consisting of the given edits.
+func writeHunk(w io.Writer, a, b [][]byte, edits []lineEdit) error {
+	var oldLines, newLines int
+	for _, e := range edits {
+		if e.op != lineInsert {
+			oldLines++
+		}
+		if e.op != lineDelete {
+			newLines++
+		}
+	}
+
+	header := fmt.Sprintf("@@ -%s +%s @@",
+		hunkRange(edits[0].a, oldLines),
+		hunkRange(edits[0].b, newLines))
+	if heading := hunkHeading(a, edits[0].a); len(heading) > 0 {
+		header += " " + heading
+	}
+
+	if _, err := fmt.Fprintf(w, "%s\n", header); err != nil {
+		return err
+	}
+
+	for _, e := range edits {
+		var prefix byte
+		var line []byte
+
+		switch e.op {
+		case lineEqual:
+			prefix, line = ' ', a[e.a]
+		case lineDelete:
+			prefix, line = '-', a[e.a]
+		case lineInsert:
+			prefix, line = '+', b[e.b]
+		}
+
+		if _, err := fmt.Fprintf(w, "%c%s", prefix, line); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			if _, err := io.WriteString(w, "\n\\ No newline at end of file\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hunkRange formats the range of a hunk which is preceded by "before" lines and
+// spans "lines" lines, as in a unified diff hunk header.
+func hunkRange(before, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, lines)
+}
+
+// hunkHeadingLength is the maximum length of the heading of a hunk.
+const hunkHeadingLength = 80
+
+// hunkHeading returns the heading of a hunk which is preceded by the first
+// "before" lines of "a": the nearest of those lines which begins with a letter,
+// an underscore, or a dollar sign (which, by default, is how "git diff" finds
+// the enclosing function), or the empty string if there is none.
+func hunkHeading(a [][]byte, before int) string {
+	for i := before - 1; i >= 0; i-- {
+		line := a[i]
+		if len(line) == 0 {
+			continue
+		}
+
+		if c := line[0]; c == '_' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			if len(line) > hunkHeadingLength {
+				line = line[:hunkHeadingLength]
+			}
+			return string(bytes.TrimRight(line, " \t\r\n"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package gitobj
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	a := splitLines([]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"))
+	b := splitLines([]byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n15\nsixteen\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, a, b, 3))
+
+	assert.Equal(t, "@@ -1,6 +1,6 @@\n"+
+		" 1\n 2\n-3\n+three\n 4\n 5\n 6\n"+
+		"@@ -11,5 +11,5 @@\n"+
+		" 11\n 12\n 13\n-14\n 15\n+sixteen\n", buf.String())
+}
+
+func TestWriteUnifiedDiffMergesNearbyChanges(t *testing.T) {
+	a := splitLines([]byte("1\n2\n3\n4\n5\n6\n7\n8\n"))
+	b := splitLines([]byte("one\n2\n3\n4\n5\n6\n7\neight\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, a, b, 3))
+
+	assert.Equal(t, "@@ -1,8 +1,8 @@\n"+
+		"-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n", buf.String())
+}
+
+func TestWriteUnifiedDiffMissingNewline(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf,
+		splitLines([]byte("a\nb")), splitLines([]byte("a\nb\n")), 3))
+
+	assert.Equal(t, "@@ -1,2 +1,2 @@\n"+
+		" a\n-b\n\\ No newline at end of file\n+b\n", buf.String())
+}
+
+func TestWriteUnifiedDiffEmptySides(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, nil, splitLines([]byte("a\n")), 3))
+	assert.Equal(t, "@@ -0,0 +1 @@\n+a\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeUnifiedDiff(&buf, splitLines([]byte("a\nb\n")), nil, 3))
+	assert.Equal(t, "@@ -1,2 +0,0 @@\n-a\n-b\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeUnifiedDiff(&buf, nil, nil, 3))
+	assert.Empty(t, buf.String())
+}
+
+func TestWriteUnifiedDiffSlidesAmbiguousChanges(t *testing.T) {
+	a := splitLines([]byte("}\n\nfunc a() {\n"))
+	b := splitLines([]byte("}\n\nfunc b() {\n}\n\nfunc a() {\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, a, b, 3))
+
+	assert.Equal(t, "@@ -1,3 +1,6 @@\n"+
+		" }\n \n+func b() {\n+}\n+\n func a() {\n", buf.String())
+}
+
+func TestWriteUnifiedDiffAlignsChanges(t *testing.T) {
+	a := splitLines([]byte("x\nold\n}\n\ny\n"))
+	b := splitLines([]byte("x\nnew\n}\n\nextra\n}\n\ny\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, a, b, 3))
+
+	assert.Equal(t, "@@ -1,5 +1,8 @@\n"+
+		" x\n-old\n+new\n+}\n+\n+extra\n }\n \n y\n", buf.String())
+}
+
+func TestWriteUnifiedDiffHeading(t *testing.T) {
+	a := splitLines([]byte("func f() {\n\t1\n\t2\n\t3\n\t4\n\t5\n}\n"))
+	b := splitLines([]byte("func f() {\n\t1\n\t2\n\t3\n\t4\n\tfive\n}\n"))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUnifiedDiff(&buf, a, b, 3))
+
+	assert.Equal(t, "@@ -3,5 +3,5 @@ func f() {\n"+
+		" \t2\n \t3\n \t4\n-\t5\n+\tfive\n }\n", buf.String())
+}
+
+func TestDiffLinesLargeSides(t *testing.T) {
+	var a, b [][]byte
+	for i := 0; i < 5000; i++ {
+		line := []byte(strconv.Itoa(i) + "\n")
+		a = append(a, line)
+		if i%1000 != 500 {
+			b = append(b, line)
+		} else {
+			b = append(b, []byte("changed\n"))
+		}
+	}
+
+	var deleted, inserted int
+	for _, e := range diffLines(a, b) {
+		switch e.op {
+		case lineDelete:
+			assert.EqualValues(t, 500, e.a%1000)
+			deleted++
+		case lineInsert:
+			assert.Equal(t, "changed\n", string(b[e.b]))
+			inserted++
+		}
+	}
+	assert.Equal(t, 5, deleted)
+	assert.Equal(t, 5, inserted)
+}
@@ -2,8 +2,10 @@ package gitobj
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/git-lfs/gitobj/v2/errors"
@@ -38,15 +40,26 @@ func newMemoryStorer(m map[string]io.ReadWriter) *memoryStorer {
 
 // Store implements the storer.Store function and copies the data given in "r"
 // into an object entry in the memory. If an object given by that SHA "sha" is
-// already indexed in the database, Store will panic().
+// already indexed in the database, it is left unchanged.
 func (ms *memoryStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
+	n, _, err = ms.StoreCreated(sha, r)
+	return n, err
+}
+
+// StoreCreated is as Store, but additionally returns whether a new object was
+// created. If the object already exists, it is left unchanged.
+func (ms *memoryStorer) StoreCreated(sha []byte, r io.Reader) (n int64, created bool, err error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	key := fmt.Sprintf("%x", sha)
+	if _, ok := ms.fs[key]; ok {
+		return 0, false, discard(r)
+	}
 
 	ms.fs[key] = &bufCloser{new(bytes.Buffer)}
-	return io.Copy(ms.fs[key], r)
+	n, err = io.Copy(ms.fs[key], r)
+	return n, true, err
 }
 
 // Open implements the storer.Open function, and returns a io.ReadWriteCloser
@@ -63,6 +76,90 @@ func (ms *memoryStorer) Open(sha []byte) (f io.ReadCloser, err error) {
 	return ms.fs[key], nil
 }
 
+// ForEach calls "fn" with the ID of each object held in memory, in ascending
+// order. If "fn" returns an error, iteration stops and that error is returned.
+// Objects stored during iteration may or may not be given.
+func (ms *memoryStorer) ForEach(fn func(oid []byte) error) error {
+	return ms.ForEachAfter(nil, fn)
+}
+
+// ForEachAfter is as ForEach, but only gives the IDs greater than "after".
+func (ms *memoryStorer) ForEachAfter(after []byte, fn func(oid []byte) error) error {
+	ms.mu.Lock()
+	oids := make([][]byte, 0, len(ms.fs))
+	for key := range ms.fs {
+		if oid, err := hex.DecodeString(key); err == nil && bytes.Compare(oid, after) > 0 {
+			oids = append(oids, oid)
+		}
+	}
+	ms.mu.Unlock()
+
+	sort.Slice(oids, func(i, j int) bool {
+		return bytes.Compare(oids[i], oids[j]) < 0
+	})
+
+	for _, oid := range oids {
+		if err := fn(oid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has returns whether the object "sha" is held in memory.
+func (ms *memoryStorer) Has(sha []byte) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, ok := ms.fs[fmt.Sprintf("%x", sha)]
+	return ok, nil
+}
+
+// StoredSize returns the size of the (compressed) contents of the object
+// "sha", or errors.NoSuchObject if it is not held in memory.
+func (ms *memoryStorer) StoredSize(sha []byte) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry, ok := ms.fs[fmt.Sprintf("%x", sha)]
+	if !ok {
+		return 0, errors.NoSuchObject(sha)
+	}
+
+	buf, ok := entry.ReadWriter.(*bytes.Buffer)
+	if !ok {
+		return 0, fmt.Errorf("gitobj: cannot read size of object %x", sha)
+	}
+	return int64(buf.Len()), nil
+}
+
+// EachSize calls "fn" with the ID, type, and inflated size of each object held
+// in memory, along with the size of its (compressed) contents, in ascending
+// order of ID. If "fn" returns an error, iteration stops and that error is
+// returned.
+func (ms *memoryStorer) EachSize(fn func(oid []byte, typ string, size, stored int64) error) error {
+	return ms.ForEach(func(oid []byte) error {
+		ms.mu.Lock()
+		entry := ms.fs[fmt.Sprintf("%x", oid)]
+		ms.mu.Unlock()
+
+		buf, ok := entry.ReadWriter.(*bytes.Buffer)
+		if !ok {
+			return fmt.Errorf("gitobj: cannot read size of object %x", oid)
+		}
+
+		r, err := NewObjectReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return err
+		}
+		typ, size, err := r.Header()
+		if err != nil {
+			return err
+		}
+		return fn(oid, typ.String(), size, int64(buf.Len()))
+	})
+}
+
 // Close closes the memory storer.
 func (ms *memoryStorer) Close() error {
 	return nil
@@ -0,0 +1,326 @@
+package gitobj
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// MergeConflict is a path which could not be merged by MergeTrees.
+type MergeConflict struct {
+	// Path is the slash-separated path of the conflict, relative to the
+	// root of the trees being merged.
+	Path string
+	// Base, Ours, and Theirs are the entries at the path in the base tree,
+	// our tree, and their tree, respectively. Each is nil if that tree has
+	// no file at the path, such as when it has been deleted, or when it is
+	// a directory.
+	Base, Ours, Theirs *TreeEntry
+}
+
+// MergeTrees performs a three-way merge of the trees "ours" and "theirs",
+// whose common ancestor is the tree "base", any of which may be nil to refer to
+// the empty tree. Each path changed on only one side since "base" is taken from
+// that side, and each path changed in the same way on both is taken once. The
+// merged tree is written, and its object ID returned.
+//
+// Each path changed differently on both sides is a conflict, and keeps its
+// entry from "ours". So does each entry which would otherwise leave a file and
+// a directory at the same path, such as one side replacing a directory with a
+// file while the other adds a file beneath it. Conflicts are returned in order
+// of their paths, and the contents of files are never merged.
+//
+// Subtrees which are the same on both sides, or unchanged since "base" on
+// either, are taken whole without being read.
+//
+// If any of the trees could not be read, or the merged tree could not be
+// written, an error is returned instead.
+func (o *ObjectDatabase) MergeTrees(base, ours, theirs []byte) (merged []byte, conflicts []*MergeConflict, err error) {
+	if tree := mergedTree(base, ours, theirs); tree != nil {
+		return tree, nil, nil
+	}
+
+	m := &treeMerge{
+		files:      make(map[string]*TreeEntry),
+		sides:      make(map[string][3]*TreeEntry),
+		conflicted: make(map[string]struct{}),
+	}
+	if err = o.mergeDirs(m, "", base, ours, theirs); err != nil {
+		return nil, nil, err
+	}
+
+	// A path may not hold both a file and a directory. Keep whichever of
+	// the two is in "ours", along with everything in it.
+	dirs := make(map[string]struct{})
+	for path := range m.files {
+		for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		if _, ok := m.files[dir]; !ok {
+			continue
+		}
+		m.conflicted[dir] = struct{}{}
+
+		if m.sides[dir][1] == nil {
+			delete(m.files, dir)
+			continue
+		}
+		for path := range m.files {
+			if strings.HasPrefix(path, dir+"/") {
+				delete(m.files, path)
+			}
+		}
+	}
+
+	if merged, err = o.writeFiles(m.files); err != nil {
+		return nil, nil, err
+	}
+
+	for path := range m.conflicted {
+		sides := m.sides[path]
+		conflicts = append(conflicts, &MergeConflict{
+			Path:   path,
+			Base:   sides[0],
+			Ours:   sides[1],
+			Theirs: sides[2],
+		})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+
+	return merged, conflicts, nil
+}
+
+// treeMerge holds the state of a merge performed by MergeTrees.
+type treeMerge struct {
+	// files maps the slash-separated path of each entry of the merged tree
+	// to that entry, which is itself a tree if it was taken whole from
+	// one side.
+	files map[string]*TreeEntry
+	// sides maps each path merged file by file to the files at it in the
+	// base tree, our tree, and their tree, each of which is nil if that
+	// tree has no file at the path.
+	sides map[string][3]*TreeEntry
+	// conflicted is the set of paths which could not be merged.
+	conflicted map[string]struct{}
+}
+
+// mergeDirs merges the entries of the trees "base", "ours", and "theirs", any of
+// which may be nil, into "m", where they are found at the slash-separated path
+// "prefix" (which is empty, or ends in a slash).
+//
+// Entries which are the same on both sides, or unchanged on either, are taken
+// as they are, including subtrees. Entries which are subtrees (or missing) on
+// every side are merged recursively, and what remains is merged file by file.
+func (o *ObjectDatabase) mergeDirs(m *treeMerge, prefix string, base, ours, theirs []byte) error {
+	names := make(map[string]struct{})
+	var sides [3]map[string]*TreeEntry
+	for i, sha := range [][]byte{base, ours, theirs} {
+		entries, err := o.sortedEntries(sha)
+		if err != nil {
+			return err
+		}
+
+		sides[i] = make(map[string]*TreeEntry, len(entries))
+		for _, entry := range entries {
+			sides[i][entry.Name] = entry
+			names[entry.Name] = struct{}{}
+		}
+	}
+
+	for name := range names {
+		path := prefix + name
+		b, our, their := sides[0][name], sides[1][name], sides[2][name]
+
+		if entry, ok := mergedEntry(b, our, their); ok {
+			if entry != nil {
+				m.files[path] = entry
+				if entry.Type() != TreeObjectType {
+					m.sides[path] = [3]*TreeEntry{fileEntry(b), fileEntry(our), fileEntry(their)}
+				}
+			}
+			continue
+		}
+
+		if fileEntry(b) == nil && fileEntry(our) == nil && fileEntry(their) == nil {
+			if err := o.mergeDirs(m, path+"/", entryOid(b), entryOid(our), entryOid(their)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := o.mergeFiles(m, path, b, our, their); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFiles merges the entries "base", "ours", and "theirs" at the
+// slash-separated path "path" into "m", file by file.
+func (o *ObjectDatabase) mergeFiles(m *treeMerge, path string, base, ours, theirs *TreeEntry) error {
+	var sides [3]map[string]*TreeEntry
+	for i, entry := range []*TreeEntry{base, ours, theirs} {
+		files, err := o.flattenEntry(path, entry)
+		if err != nil {
+			return err
+		}
+		sides[i] = files
+	}
+
+	files := make(map[string]struct{})
+	for _, side := range sides {
+		for file := range side {
+			files[file] = struct{}{}
+		}
+	}
+
+	for file := range files {
+		b, our, their := sides[0][file], sides[1][file], sides[2][file]
+		m.sides[file] = [3]*TreeEntry{b, our, their}
+
+		entry, ok := mergedEntry(b, our, their)
+		if !ok {
+			entry = our
+			m.conflicted[file] = struct{}{}
+		}
+		if entry != nil {
+			m.files[file] = entry
+		}
+	}
+	return nil
+}
+
+// mergedTree returns the object ID of the tree merged from "base", "ours", and
+// "theirs" if one side is the same as the other, or as "base", or nil if they
+// must be merged entry by entry.
+func mergedTree(base, ours, theirs []byte) []byte {
+	switch {
+	case bytes.Equal(ours, theirs), bytes.Equal(base, theirs):
+		return ours
+	case bytes.Equal(base, ours):
+		return theirs
+	}
+	return nil
+}
+
+// mergedEntry returns the entry merged from "base", "ours", and "theirs",
+// any of which may be nil, and true, if one side is the same as the other, or
+// as "base". Otherwise, it returns false.
+func mergedEntry(base, ours, theirs *TreeEntry) (*TreeEntry, bool) {
+	switch {
+	case sameEntry(ours, theirs), sameEntry(base, theirs):
+		return ours, true
+	case sameEntry(base, ours):
+		return theirs, true
+	}
+	return nil, false
+}
+
+// flattenEntry returns the entry "entry", found at the slash-separated path
+// "path", keyed by that path, or every entry beneath it which is not itself a
+// tree if it is one. If "entry" is nil, no entries are returned.
+func (o *ObjectDatabase) flattenEntry(path string, entry *TreeEntry) (map[string]*TreeEntry, error) {
+	if entry == nil {
+		return nil, nil
+	}
+	if entry.Type() != TreeObjectType {
+		return map[string]*TreeEntry{path: entry}, nil
+	}
+	return o.flattenDir(path+"/", entry.Oid)
+}
+
+// fileEntry returns "entry" if it is not a tree, or nil otherwise.
+func fileEntry(entry *TreeEntry) *TreeEntry {
+	if entry == nil || entry.Type() == TreeObjectType {
+		return nil
+	}
+	return entry
+}
+
+// entryOid returns the object ID of "entry", or nil if "entry" is nil.
+func entryOid(entry *TreeEntry) []byte {
+	if entry == nil {
+		return nil
+	}
+	return entry.Oid
+}
+
+// flattenTree returns every entry of the tree named "sha" which is not itself a
+// tree, keyed by its slash-separated path. If "sha" is nil, no entries are
+// returned.
+func (o *ObjectDatabase) flattenTree(sha []byte) (map[string]*TreeEntry, error) {
+	return o.flattenDir("", sha)
+}
+
+// flattenDir is as flattenTree, but prefixes each path with "prefix" (which is
+// empty, or ends in a slash).
+func (o *ObjectDatabase) flattenDir(prefix string, sha []byte) (map[string]*TreeEntry, error) {
+	var diffs []*TreeDiff
+	if err := o.diffTrees(&diffs, prefix, sha, nil, nil); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*TreeEntry, len(diffs))
+	for _, diff := range diffs {
+		files[diff.Path] = diff.Old
+	}
+	return files, nil
+}
+
+// writeFiles writes the trees needed to hold the entries "files", keyed by
+// their slash-separated path, and returns the object ID of the root tree. A
+// path's entry is given its last path component as its name. Any entry which is
+// itself a tree is written as is, so no other entry may be beneath it.
+func (o *ObjectDatabase) writeFiles(files map[string]*TreeEntry) ([]byte, error) {
+	var entries []*TreeEntry
+	subtrees := make(map[string]map[string]*TreeEntry)
+	for path, entry := range files {
+		if i := strings.Index(path, "/"); i >= 0 {
+			dir := path[:i]
+			if subtrees[dir] == nil {
+				subtrees[dir] = make(map[string]*TreeEntry)
+			}
+			subtrees[dir][path[i+1:]] = entry
+			continue
+		}
+
+		entries = append(entries, &TreeEntry{
+			Name:     path,
+			Oid:      entry.Oid,
+			Filemode: entry.Filemode,
+		})
+	}
+
+	for dir, subfiles := range subtrees {
+		sha, err := o.writeFiles(subfiles)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &TreeEntry{Name: dir, Oid: sha, Filemode: FilemodeDir})
+	}
+	sort.Sort(SubtreeOrder(entries))
+
+	return o.WriteTree(&Tree{Entries: entries})
+}
+
+// sameEntry returns whether the entries "a" and "b", either of which may be
+// nil, have the same object ID and filemode.
+func sameEntry(a, b *TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.Oid, b.Oid) && a.Filemode == b.Filemode
+}
+
+// parentDir returns the slash-separated path of the directory containing
+// "path", or the empty string if it is at the root.
+func parentDir(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeTrees(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two, three := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n"), writeTestBlob(t, odb, "3\n")
+
+	base, err := odb.writeFiles(map[string]*TreeEntry{
+		"ours.txt":     {Oid: one, Filemode: 0100644},
+		"theirs.txt":   {Oid: one, Filemode: 0100644},
+		"both.txt":     {Oid: one, Filemode: 0100644},
+		"dir/conf.txt": {Oid: one, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	ours, err := odb.writeFiles(map[string]*TreeEntry{
+		"ours.txt":     {Oid: two, Filemode: 0100644},
+		"theirs.txt":   {Oid: one, Filemode: 0100644},
+		"both.txt":     {Oid: two, Filemode: 0100644},
+		"dir/conf.txt": {Oid: two, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	theirs, err := odb.writeFiles(map[string]*TreeEntry{
+		"ours.txt":     {Oid: one, Filemode: 0100644},
+		"theirs.txt":   {Oid: two, Filemode: 0100644},
+		"both.txt":     {Oid: two, Filemode: 0100644},
+		"dir/conf.txt": {Oid: three, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	merged, conflicts, err := odb.MergeTrees(base, ours, theirs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*MergeConflict{
+		{
+			Path:   "dir/conf.txt",
+			Base:   &TreeEntry{Name: "conf.txt", Oid: one, Filemode: 0100644},
+			Ours:   &TreeEntry{Name: "conf.txt", Oid: two, Filemode: 0100644},
+			Theirs: &TreeEntry{Name: "conf.txt", Oid: three, Filemode: 0100644},
+		},
+	}, conflicts)
+	assert.Equal(t, map[string][]byte{
+		"ours.txt": two, "theirs.txt": two, "both.txt": two, "dir/conf.txt": two,
+	}, testTreeFiles(t, odb, merged))
+}
+
+func TestMergeTreesFileDirectoryConflict(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n")
+
+	base, err := odb.writeFiles(map[string]*TreeEntry{
+		"a/b.txt": {Oid: one, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	ours, err := odb.writeFiles(map[string]*TreeEntry{
+		"a": {Oid: two, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	theirs, err := odb.writeFiles(map[string]*TreeEntry{
+		"a/b.txt": {Oid: one, Filemode: 0100644},
+		"a/c.txt": {Oid: two, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	merged, conflicts, err := odb.MergeTrees(base, ours, theirs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*MergeConflict{
+		{Path: "a", Ours: &TreeEntry{Name: "a", Oid: two, Filemode: 0100644}},
+	}, conflicts)
+	assert.Equal(t, map[string][]byte{"a": two}, testTreeFiles(t, odb, merged))
+}
+
+func TestMergeTreesDeletions(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n")
+
+	base, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: one, Filemode: 0100644},
+		"b.txt": {Oid: one, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	ours, err := odb.writeFiles(map[string]*TreeEntry{
+		"b.txt": {Oid: one, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+	theirs, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: one, Filemode: 0100644},
+		"b.txt": {Oid: two, Filemode: 0100755},
+	})
+	require.NoError(t, err)
+
+	merged, conflicts, err := odb.MergeTrees(base, ours, theirs)
+	require.NoError(t, err)
+
+	assert.Empty(t, conflicts)
+
+	files, err := odb.flattenTree(merged)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, two, files["b.txt"].Oid)
+	assert.Equal(t, int32(0100755), files["b.txt"].Filemode)
+}
+
+func TestMergeTreesDoesNotReadUnchangedSubtrees(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	one, two := writeTestBlob(t, odb, "1\n"), writeTestBlob(t, odb, "2\n")
+
+	// Neither subtree is stored, so reading either would fail.
+	same := &TreeEntry{Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: FilemodeDir}
+	changed := &TreeEntry{Oid: []byte("bbbbbbbbbbbbbbbbbbbb"), Filemode: FilemodeDir}
+
+	base, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: one, Filemode: 0100644},
+		"same":  same,
+		"ours":  same,
+	})
+	require.NoError(t, err)
+	ours, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: two, Filemode: 0100644},
+		"same":  same,
+		"ours":  changed,
+	})
+	require.NoError(t, err)
+	theirs, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: one, Filemode: 0100644},
+		"b.txt": {Oid: one, Filemode: 0100644},
+		"same":  same,
+		"ours":  same,
+	})
+	require.NoError(t, err)
+
+	merged, conflicts, err := odb.MergeTrees(base, ours, theirs)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	tree, err := odb.Tree(merged)
+	require.NoError(t, err)
+	assert.Equal(t, []*TreeEntry{
+		{Name: "a.txt", Oid: two, Filemode: 0100644},
+		{Name: "b.txt", Oid: one, Filemode: 0100644},
+		{Name: "ours", Oid: changed.Oid, Filemode: FilemodeDir},
+		{Name: "same", Oid: same.Oid, Filemode: FilemodeDir},
+	}, tree.Entries)
+}
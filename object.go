@@ -19,7 +19,7 @@ type Object interface {
 	// meaning that a particular invocation of Encode() cannot progress, and
 	// an accurate number "n" of bytes written up that point should be
 	// returned.
-	Encode(to io.Writer) (n int, err error)
+	Encode(to io.Writer) (n int64, err error)
 
 	// Decode takes an io.Reader, "from" as well as a size "size" (the
 	// number of uncompressed bytes on the stream that represent the object
@@ -32,7 +32,7 @@ type Object interface {
 	//
 	// If an(y) error was encountered, it should be returned immediately,
 	// along with the number of bytes read up to that point.
-	Decode(hash hash.Hash, from io.Reader, size int64) (n int, err error)
+	Decode(hash hash.Hash, from io.Reader, size int64) (n int64, err error)
 
 	// Type returns the ObjectType constant that represents an instance of
 	// the implementing type.
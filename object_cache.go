@@ -0,0 +1,84 @@
+package gitobj
+
+import (
+	"container/list"
+	"sync"
+)
+
+// objectCache is a least-recently-used cache of decoded objects, keyed by their
+// object IDs. A nil *objectCache caches nothing.
+type objectCache struct {
+	// mu guards the fields below.
+	mu *sync.Mutex
+	// max is the largest number of objects held at once.
+	max int
+	// order holds an *objectCacheEntry for each cached object, from most
+	// to least recently used.
+	order *list.List
+	// elems maps the object ID of each cached object to its element of
+	// "order".
+	elems map[string]*list.Element
+}
+
+// objectCacheEntry is a single object held by an objectCache.
+type objectCacheEntry struct {
+	key    string
+	object Object
+}
+
+// newObjectCache returns a new cache holding at most "max" objects, or nil if
+// "max" is not positive.
+func newObjectCache(max int) *objectCache {
+	if max <= 0 {
+		return nil
+	}
+	return &objectCache{
+		mu:    new(sync.Mutex),
+		max:   max,
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+// get returns the object cached for "sha", marking it as the most recently
+// used, or nil if there is none.
+func (c *objectCache) get(sha []byte) Object {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.elems[string(sha)]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*objectCacheEntry).object
+}
+
+// add caches "object" as the object named "sha", evicting the least recently
+// used object if the cache is full. Blobs, whose contents may only be read
+// once, are not cached.
+func (c *objectCache) add(sha []byte, object Object) {
+	if c == nil || object.Type() == BlobObjectType {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := string(sha)
+	if e, ok := c.elems[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.elems[key] = c.order.PushFront(&objectCacheEntry{key: key, object: object})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elems, oldest.Value.(*objectCacheEntry).key)
+	}
+}
@@ -0,0 +1,116 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheObjectsReadsTreeOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	odb, err := FromFilesystem(dir, "", CacheObjects(16))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	sha, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: FilemodeRegular},
+	}})
+	require.NoError(t, err)
+
+	first, err := odb.Tree(sha)
+	require.NoError(t, err)
+
+	// Remove the tree from storage, so that it can only be read from the
+	// cache.
+	name := hex.EncodeToString(sha)
+	require.NoError(t, os.Remove(filepath.Join(dir, name[:2], name[2:])))
+
+	second, err := odb.Tree(sha)
+	require.NoError(t, err)
+	assert.True(t, first == second)
+
+	object, err := odb.Object(sha)
+	require.NoError(t, err)
+	assert.True(t, first == object)
+
+	_, err = odb.Commit(sha)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestCacheObjectsEvictsLeastRecentlyUsed(t *testing.T) {
+	odb := newTestDatabase(t, CacheObjects(2))
+	defer odb.Close()
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	a := writeTestCommit(t, odb, tree, "a")
+	b := writeTestCommit(t, odb, tree, "b")
+	c := writeTestCommit(t, odb, tree, "c")
+
+	first := make(map[string]*Commit)
+	for _, sha := range [][]byte{a, b, a, c} {
+		commit, err := odb.Commit(sha)
+		require.NoError(t, err)
+
+		if _, ok := first[string(sha)]; !ok {
+			first[string(sha)] = commit
+		}
+	}
+
+	// "b" was used least recently when "c" was read, so only it should
+	// have been evicted.
+	for _, sha := range [][]byte{a, c} {
+		commit, err := odb.Commit(sha)
+		require.NoError(t, err)
+		assert.True(t, commit == first[string(sha)])
+	}
+
+	commit, err := odb.Commit(b)
+	require.NoError(t, err)
+	assert.False(t, commit == first[string(b)])
+	assert.Equal(t, first[string(b)], commit)
+}
+
+func TestCacheObjectsSkipsBlobs(t *testing.T) {
+	odb := newTestDatabase(t, CacheObjects(16))
+	defer odb.Close()
+
+	sha := writeTestBlob(t, odb, "Hello, world!\n")
+
+	for i := 0; i < 2; i++ {
+		blob, err := odb.Blob(sha)
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(blob.Contents)
+		require.NoError(t, err)
+		require.NoError(t, blob.Close())
+		assert.Equal(t, "Hello, world!\n", string(contents))
+	}
+}
+
+func TestObjectsAreNotCachedByDefault(t *testing.T) {
+	odb := newTestDatabase(t)
+	defer odb.Close()
+
+	sha, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	first, err := odb.Tree(sha)
+	require.NoError(t, err)
+	second, err := odb.Tree(sha)
+	require.NoError(t, err)
+
+	assert.False(t, first == second)
+	assert.Equal(t, first, second)
+}
@@ -9,8 +9,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/git-lfs/gitobj/v2/errors"
 	"github.com/git-lfs/gitobj/v2/storage"
 )
 
@@ -35,11 +39,45 @@ type ObjectDatabase struct {
 
 	// objectFormat is the object format (hash algorithm)
 	objectFormat ObjectFormatAlgorithm
+
+	// implicitEmpty indicates whether the empty tree and blob are treated
+	// as present, even if they are not stored.
+	implicitEmpty bool
+
+	// skipVerify indicates whether objects are read without checking that
+	// their contents hash to the ID by which they were requested.
+	skipVerify bool
+
+	// shallow is the set of commits at the boundary of a shallow clone,
+	// which are treated as having no parents. It is read lazily, guarded
+	// by shallowOnce, and shallowErr holds any error encountered while
+	// doing so.
+	shallow     map[string]struct{}
+	shallowErr  error
+	shallowOnce sync.Once
+
+	// graph is the commit-graph of the object directory, if it has been
+	// read, as indicated by graphRead, and graphErr is any error
+	// encountered while doing so. They are guarded by graphMu.
+	graph     *commitGraph
+	graphErr  error
+	graphRead bool
+	graphMu   sync.Mutex
+
+	// cache holds recently decoded trees, commits, and tags, or is nil if
+	// they are not cached.
+	cache *objectCache
 }
 
 type options struct {
-	alternates   string
-	objectFormat ObjectFormatAlgorithm
+	alternates    string
+	objectFormat  ObjectFormatAlgorithm
+	implicitEmpty bool
+	maxDeltaDepth int
+	shared        SharedMode
+	skipVerify    bool
+	fsync         bool
+	cacheSize     int
 }
 
 type Option func(*options)
@@ -61,30 +99,121 @@ func Alternates(alternates string) Option {
 }
 
 // ObjectFormat is an Option to specify the hash algorithm (object format) in
-// use in Git.  If not specified, it defaults to ObjectFormatSHA1.
+// use in Git.  If not specified, it defaults to ObjectFormatSHA1, except in
+// FromFilesystem, which uses the format given by the repository's
+// configuration, if any.
 func ObjectFormat(algo ObjectFormatAlgorithm) Option {
 	return func(args *options) {
 		args.objectFormat = algo
 	}
 }
 
+// ImplicitEmptyObjects is an Option to specify whether the well-known empty
+// tree and empty blob are treated as present in the database even when they are
+// not stored, as Git does. If not specified, it defaults to true.
+func ImplicitEmptyObjects(enabled bool) Option {
+	return func(args *options) {
+		args.implicitEmpty = enabled
+	}
+}
+
+// MaxDeltaDepth is an Option to specify the maximum number of deltas followed
+// when reading a packed object, beyond which the object is treated as corrupt.
+// This protects against packfiles crafted with pathologically deep delta
+// chains. If not specified, it defaults to pack.DefaultMaxDeltaDepth, the
+// greatest depth Git writes; a depth of 50, Git's default, is stricter, but
+// rejects packfiles written with a greater depth.
+func MaxDeltaDepth(depth int) Option {
+	return func(args *options) {
+		args.maxDeltaDepth = depth
+	}
+}
+
+// SharedRepository is an Option to specify the permissions given to the
+// objects and directories written to the database, so that they match those
+// which Git would create given the same "core.sharedRepository" setting (see:
+// ParseSharedRepository). If not specified, it defaults to SharedUmask, and
+// objects are created read-only and directories with the default permissions,
+// both subject to the process's umask, as Git creates them.
+func SharedRepository(mode SharedMode) Option {
+	return func(args *options) {
+		args.shared = mode
+	}
+}
+
+// FsyncObjects is an Option to specify whether each object written to the
+// database, and the directory entry naming it, are flushed to stable storage
+// before the write returns, so that they survive a crash. Directory syncs may
+// be deferred to the end of a batch of writes (see: BeginBatch). If not
+// specified, it defaults to false, as with Git's loose objects.
+func FsyncObjects(enabled bool) Option {
+	return func(args *options) {
+		args.fsync = enabled
+	}
+}
+
+// SkipHashVerification is an Option to specify whether objects are read
+// without checking that their contents hash to the ID by which they were
+// requested. If not specified, it defaults to true, and the storage is trusted.
+//
+// Otherwise, the contents of every object read are hashed as they are decoded,
+// and a mismatch is reported as an error (in the case of blobs, once their
+// contents have been read in full). This costs the hashing of everything read,
+// so is best left to untrusted storage; objects can instead be checked
+// individually with Verify.
+func SkipHashVerification(skip bool) Option {
+	return func(args *options) {
+		args.skipVerify = skip
+	}
+}
+
+// CacheObjects is an Option to specify the number of recently decoded trees,
+// commits, and tags which are kept in memory, so that reading any of them again
+// returns the object already decoded, rather than reading it from storage. This
+// speeds up walks which visit the same objects repeatedly, such as those of
+// history which touch the same trees. Blobs are never cached.
+//
+// Since a cached object is shared by every caller which reads it, callers must
+// treat the objects returned by the database as read-only. If not specified,
+// or not positive, no objects are cached.
+func CacheObjects(entries int) Option {
+	return func(args *options) {
+		args.cacheSize = entries
+	}
+}
+
 // FromFilesystem constructs an *ObjectDatabase instance that is backed by a
 // directory on the filesystem. Specifically, this should point to:
 //
 //  /absolute/repo/path/.git/objects
+//
+// Unless given by the ObjectFormat option, the object format is that set by
+// "extensions.objectFormat" in the repository's configuration (the "config"
+// file alongside the object directory), or SHA-1 if it sets none.
 func FromFilesystem(root, tmp string, setters ...Option) (*ObjectDatabase, error) {
-	args := &options{objectFormat: ObjectFormatSHA1}
+	args := &options{implicitEmpty: true, skipVerify: true}
 
 	for _, setter := range setters {
 		setter(args)
 	}
 
+	if len(args.objectFormat) == 0 {
+		format, err := detectObjectFormat(root)
+		if err != nil {
+			return nil, err
+		}
+		args.objectFormat = format
+	}
+	if hasher(args.objectFormat) == nil {
+		return nil, fmt.Errorf("gitobj: unsupported object format %q", args.objectFormat)
+	}
+
 	b, err := NewFilesystemBackend(root, tmp, args.alternates, hasher(args.objectFormat))
 	if err != nil {
 		return nil, err
 	}
 
-	odb, err := FromBackend(b, setters...)
+	odb, err := FromBackend(b, append(append([]Option(nil), setters...), ObjectFormat(args.objectFormat))...)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +222,7 @@ func FromFilesystem(root, tmp string, setters ...Option) (*ObjectDatabase, error
 }
 
 func FromBackend(b storage.Backend, setters ...Option) (*ObjectDatabase, error) {
-	args := &options{objectFormat: ObjectFormatSHA1}
+	args := &options{objectFormat: ObjectFormatSHA1, implicitEmpty: true, skipVerify: true}
 
 	for _, setter := range setters {
 		setter(args)
@@ -101,9 +230,27 @@ func FromBackend(b storage.Backend, setters ...Option) (*ObjectDatabase, error)
 
 	ro, rw := b.Storage()
 	odb := &ObjectDatabase{
-		ro:           ro,
-		rw:           rw,
-		objectFormat: args.objectFormat,
+		ro:            ro,
+		rw:            rw,
+		objectFormat:  args.objectFormat,
+		implicitEmpty: args.implicitEmpty,
+		skipVerify:    args.skipVerify,
+		cache:         newObjectCache(args.cacheSize),
+	}
+
+	if args.maxDeltaDepth != 0 {
+		for _, s := range odb.storages() {
+			if l, ok := s.(deltaDepthLimiter); ok {
+				l.SetMaxDeltaDepth(args.maxDeltaDepth)
+			}
+		}
+	}
+
+	if p, ok := rw.(permissionSetter); ok && args.shared != SharedUmask {
+		p.SetPermissions(args.shared.FileMode(), args.shared.DirMode())
+	}
+	if f, ok := rw.(fsyncer); ok {
+		f.SetFsync(args.fsync)
 	}
 	return odb, nil
 }
@@ -130,9 +277,18 @@ func (o *ObjectDatabase) Close() error {
 // Object returns an Object (of unknown implementation) satisfying the type
 // associated with the object named "sha".
 //
+// The type is read from the object's header, and the object is decoded as a
+// *Blob, *Tree, *Commit, or *Tag accordingly, so a type switch on the result
+// may be used to handle each kind. As with Blob, a *Blob must be closed once
+// its contents have been read.
+//
 // If the object could not be opened, is of unknown type, or could not be
 // decoded, than an appropriate error is returned instead.
 func (o *ObjectDatabase) Object(sha []byte) (Object, error) {
+	if cached := o.cache.get(sha); cached != nil {
+		return cached, nil
+	}
+
 	r, err := o.open(sha)
 	if err != nil {
 		return nil, err
@@ -143,20 +299,15 @@ func (o *ObjectDatabase) Object(sha []byte) (Object, error) {
 		return nil, err
 	}
 
-	var into Object
-	switch typ {
-	case BlobObjectType:
-		into = new(Blob)
-	case TreeObjectType:
-		into = new(Tree)
-	case CommitObjectType:
-		into = new(Commit)
-	case TagObjectType:
-		into = new(Tag)
-	default:
-		return nil, fmt.Errorf("gitobj: unknown object type: %s", typ)
+	into, err := newObject(typ)
+	if err != nil {
+		return nil, err
 	}
-	return into, o.decode(r, into)
+	if err = o.decode(sha, r, into); err != nil {
+		return into, err
+	}
+	o.cache.add(sha, into)
+	return into, nil
 }
 
 // Blob returns a *Blob as identified by the SHA given, or an error if one was
@@ -173,84 +324,270 @@ func (o *ObjectDatabase) Blob(sha []byte) (*Blob, error) {
 // Tree returns a *Tree as identified by the SHA given, or an error if one was
 // encountered.
 func (o *ObjectDatabase) Tree(sha []byte) (*Tree, error) {
+	if t, ok := o.cache.get(sha).(*Tree); ok {
+		return t, nil
+	}
+
 	var t Tree
 	if err := o.openDecode(sha, &t); err != nil {
 		return nil, err
 	}
+	o.cache.add(sha, &t)
 	return &t, nil
 }
 
 // Commit returns a *Commit as identified by the SHA given, or an error if one
 // was encountered.
 func (o *ObjectDatabase) Commit(sha []byte) (*Commit, error) {
+	if c, ok := o.cache.get(sha).(*Commit); ok {
+		return c, nil
+	}
+
 	var c Commit
 
 	if err := o.openDecode(sha, &c); err != nil {
 		return nil, err
 	}
+	o.cache.add(sha, &c)
 	return &c, nil
 }
 
 // Tag returns a *Tag as identified by the SHA given, or an error if one was
 // encountered.
 func (o *ObjectDatabase) Tag(sha []byte) (*Tag, error) {
+	if t, ok := o.cache.get(sha).(*Tag); ok {
+		return t, nil
+	}
+
 	var t Tag
 
 	if err := o.openDecode(sha, &t); err != nil {
 		return nil, err
 	}
+	o.cache.add(sha, &t)
 	return &t, nil
 }
 
+// Serialize returns the canonical, uncompressed serialization of the object
+// named "sha", including its "<type> <size>\x00" header. The object is decoded
+// and encoded again through the same path used when writing, so these are the
+// bytes that WriteBlob, WriteTree, WriteCommit, or WriteTag would hash to
+// produce its ID.
+//
+// If the object could not be opened, decoded, or encoded, an error is
+// returned instead.
+func (o *ObjectDatabase) Serialize(sha []byte) ([]byte, error) {
+	obj, err := o.Object(sha)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := obj.(*Blob); ok {
+		defer b.Close()
+		obj = &sizedBlob{b}
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := obj.Encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(objectHeader(obj.Type(), n)), buf.Bytes()...), nil
+}
+
 // WriteBlob stores a *Blob on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
+//
+// Exactly b.Size bytes must be read from the blob's contents. If they end
+// early, or go on for longer, a *BlobSizeError is returned, and nothing is
+// written.
 func (o *ObjectDatabase) WriteBlob(b *Blob) ([]byte, error) {
+	sha, _, err := o.WriteBlobEx(b)
+	return sha, err
+}
+
+// WriteBlobEx is as WriteBlob, but additionally returns whether the blob was
+// newly created, rather than already present.
+func (o *ObjectDatabase) WriteBlobEx(b *Blob) ([]byte, bool, error) {
 	buf, err := ioutil.TempFile(o.tmp, "")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer o.cleanup(buf)
 
-	sha, _, err := o.encodeBuffer(b, buf)
+	sha, created, err := o.encodeBuffer(&sizedBlob{b}, buf)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	if err = b.Close(); err != nil {
+		return nil, false, err
+	}
+
+	return sha, created, nil
+}
+
+// WriteBlobFromPath stores the contents of the file at location "path" on disk
+// as a blob, and returns the SHA it is uniquely identified by. The file is read
+// as it is written, and is closed once the blob has been written, whether or
+// not that succeeded.
+//
+// If the file could not be opened, read, or closed, or the blob could not be
+// written, an error is returned instead.
+func (o *ObjectDatabase) WriteBlobFromPath(path string) ([]byte, error) {
+	b, err := NewBlobFromFile(path)
+	if err != nil {
 		return nil, err
 	}
 
+	sha, err := o.WriteBlob(b)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
 	return sha, nil
 }
 
+// HashBlob returns the SHA by which a blob of the given size, whose contents are
+// read from "r", would be identified if it were written to the database, as
+// "git hash-object" would, without writing it. Its contents are read as they
+// are hashed, so they need not be held in memory.
+//
+// As with WriteBlob, exactly "size" bytes must be read from "r", or a
+// *BlobSizeError is returned. If "r" could not be read, its error is returned.
+func (o *ObjectDatabase) HashBlob(r io.Reader, size int64) ([]byte, error) {
+	h := o.Hasher()
+	io.WriteString(h, objectHeader(BlobObjectType, size))
+
+	if _, err := (&sizedBlob{NewBlobFromReader(r, size)}).Encode(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// sizedBlob is a *Blob which checks that its contents are of its declared size
+// as they are encoded, so that a blob whose contents end early, or go on too
+// long, is never written.
+type sizedBlob struct {
+	*Blob
+}
+
+// Encode implements Object.Encode, and returns a *BlobSizeError if the contents
+// of the blob are not exactly of its size.
+func (b *sizedBlob) Encode(to io.Writer) (int64, error) {
+	n, err := io.Copy(to, io.LimitReader(b.Contents, b.Size+1))
+	if err != nil {
+		return n, err
+	}
+	if n != b.Size {
+		return n, &BlobSizeError{Size: b.Size, Read: n}
+	}
+	return n, nil
+}
+
 // WriteTree stores a *Tree on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
 func (o *ObjectDatabase) WriteTree(t *Tree) ([]byte, error) {
-	sha, _, err := o.encode(t)
-	if err != nil {
-		return nil, err
-	}
-	return sha, nil
+	sha, _, err := o.WriteTreeEx(t)
+	return sha, err
+}
+
+// WriteTreeEx is as WriteTree, but additionally returns whether the tree was
+// newly created, rather than already present.
+func (o *ObjectDatabase) WriteTreeEx(t *Tree) ([]byte, bool, error) {
+	return o.encode(t)
 }
 
 // WriteCommit stores a *Commit on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
-func (o *ObjectDatabase) WriteCommit(c *Commit) ([]byte, error) {
-	sha, _, err := o.encode(c)
-	if err != nil {
-		return nil, err
+//
+// The commit is written exactly as given unless otherwise specified by any of
+// the given WriteOptions. Its tree and parent IDs must be of the length used by
+// the database, or an *InvalidCommitError is returned, and nothing is written.
+func (o *ObjectDatabase) WriteCommit(c *Commit, setters ...WriteOption) ([]byte, error) {
+	sha, _, err := o.WriteCommitEx(c, setters...)
+	return sha, err
+}
+
+// WriteCommitEx is as WriteCommit, but additionally returns whether the commit
+// was newly created, rather than already present.
+func (o *ObjectDatabase) WriteCommitEx(c *Commit, setters ...WriteOption) ([]byte, bool, error) {
+	args := newWriteOptions(setters...)
+
+	if args.dedupParents != DedupNone {
+		deduped := c.withParents(dedupParents(c.ParentIDs, args.dedupParents))
+		if len(deduped.ParentIDs) != len(c.ParentIDs) && !args.keepSignatures {
+			deduped = deduped.withoutSignatures()
+		}
+		c = deduped
 	}
-	return sha, nil
+
+	if args.validate {
+		if err := c.validate(o.HashLen()); err != nil {
+			return nil, false, err
+		}
+	} else if problems := c.checkIDs(o.HashLen()); len(problems) > 0 {
+		return nil, false, &InvalidCommitError{Problems: problems}
+	}
+
+	return o.encode(c)
 }
 
 // WriteTag stores a *Tag on disk and returns the SHA it is uniquely identified
 // by, or an error if one was encountered.
-func (o *ObjectDatabase) WriteTag(t *Tag) ([]byte, error) {
-	sha, _, err := o.encode(t)
-	if err != nil {
-		return nil, err
+//
+// The tag is written exactly as given. If the ValidateTagTarget option is given,
+// the object which it names must exist, and be of the tag's ObjectType.
+func (o *ObjectDatabase) WriteTag(t *Tag, setters ...WriteOption) ([]byte, error) {
+	sha, _, err := o.WriteTagEx(t, setters...)
+	return sha, err
+}
+
+// WriteTagEx is as WriteTag, but additionally returns whether the tag was newly
+// created, rather than already present.
+func (o *ObjectDatabase) WriteTagEx(t *Tag, setters ...WriteOption) ([]byte, bool, error) {
+	args := newWriteOptions(setters...)
+
+	if args.validateTag {
+		typ, err := o.objectType(t.Object)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if typ == UnknownObjectType {
+			return nil, false, fmt.Errorf("gitobj: tag %q names missing object %x", t.Name, t.Object)
+		} else if typ != t.ObjectType {
+			return nil, false, fmt.Errorf("gitobj: tag %q has object type %s, but names %s %x",
+				t.Name, t.ObjectType, typ, t.Object)
+		}
+	}
+
+	return o.encode(t)
+}
+
+// BeginBatch begins a batch of writes, such as an import of many objects.
+// Until the batch is ended by EndBatch, the directories to which objects are
+// written are not synced as each object is written (see: FsyncObjects), but
+// only once each, at the end of the batch. Batches may be nested, in which case
+// the directories are synced at the end of the outermost.
+//
+// Objects written during a batch are readable as soon as they are written, but
+// are not durable until it ends. If objects are not being synced, or the
+// database's storage has no directories to sync, batches have no effect.
+func (o *ObjectDatabase) BeginBatch() {
+	if b, ok := o.rw.(batcher); ok {
+		b.BeginBatch()
 	}
-	return sha, nil
+}
+
+// EndBatch ends a batch of writes begun by BeginBatch, syncing every directory
+// written to during the outermost batch, and returns the first error
+// encountered in doing so. Unless batches have no effect, it returns an error if
+// no batch has begun.
+func (o *ObjectDatabase) EndBatch() error {
+	if b, ok := o.rw.(batcher); ok {
+		return b.EndBatch()
+	}
+	return nil
 }
 
 // Root returns the filesystem root that this *ObjectDatabase works within, if
@@ -269,62 +606,252 @@ func (o *ObjectDatabase) Root() (string, bool) {
 	return "", false
 }
 
+// LooseObjectAges returns the modification time of each loose object to which
+// this *ObjectDatabase writes, keyed by its hex-encoded object ID, as needed to
+// respect a grace period before pruning unreachable objects.
+//
+// Only the metadata of the object directories is read, and not the objects
+// themselves, so this is cheap even when there are many loose objects. If the
+// *ObjectDatabase does not write loose objects to disk (for instance, if it was
+// constructed with NewMemoryBackend), an empty map is returned.
+func (o *ObjectDatabase) LooseObjectAges() (map[string]time.Time, error) {
+	type ager interface {
+		LooseObjectAges() (map[string]time.Time, error)
+	}
+
+	if ager, ok := o.rw.(ager); ok {
+		return ager.LooseObjectAges()
+	}
+	return make(map[string]time.Time), nil
+}
+
+// HasBitmap returns whether any of the packfiles from which this
+// *ObjectDatabase reads has a reachability bitmap (a "pack-*.bitmap" file).
+//
+// When bitmaps are present, BuildBitmap uses them to find the objects reachable
+// from any bitmapped commit without walking the object graph beneath that
+// commit. RevList does not, since it lists commits in order of their dates,
+// and so reads every commit regardless; nor does ConnectivityCheck, which only
+// reads the objects it is given.
+func (o *ObjectDatabase) HasBitmap() bool {
+	for _, s := range o.storages() {
+		if b, ok := s.(bitmapStorage); ok && b.HasBitmap() {
+			return true
+		}
+	}
+	return false
+}
+
+// Exists returns whether the object named "sha" is in the database, without
+// reading it. Storage backends which can do so are asked whether they hold it
+// directly: loose objects are looked for by their path alone, and packed
+// objects in the packfile indexes alone. Other backends are checked by opening
+// the object, but not reading its contents.
+//
+// Unlike the readers of the database, Exists does not rescan the packfiles when
+// an object is not found, since it is often asked about many objects which do
+// not exist, such as when deduplicating objects being imported. An object
+// which could not be looked for, such as because of an I/O error, is reported
+// as not existing. If the database was created with ImplicitEmptyObjects, the
+// empty tree and empty blob always exist.
+func (o *ObjectDatabase) Exists(sha []byte) bool {
+	if o.exists(sha) {
+		return true
+	}
+	return o.implicitEmpty && o.emptyObjectType(sha) != UnknownObjectType
+}
+
+// exists returns whether any storage backend holds the object "sha".
+func (o *ObjectDatabase) exists(sha []byte) bool {
+	for _, s := range o.storages() {
+		if h, ok := s.(haser); ok {
+			if has, err := h.Has(sha); err == nil && has {
+				return true
+			}
+			continue
+		}
+
+		if f, err := s.Open(sha); err == nil {
+			f.Close()
+			return true
+		}
+	}
+	return false
+}
+
 // Hasher returns a new hash instance suitable for this object database.
 func (o *ObjectDatabase) Hasher() hash.Hash {
 	return hasher(o.objectFormat)
 }
 
+// HashAlgo returns the name of the hash algorithm by which this database names
+// its objects, which is either "sha1" or "sha256" (see: ObjectFormat).
+func (o *ObjectDatabase) HashAlgo() string {
+	return string(o.objectFormat)
+}
+
+// HashLen returns the length in bytes of the object IDs in this database, which
+// is 20 for SHA-1 and 32 for SHA-256, or zero if its hash algorithm is unknown.
+func (o *ObjectDatabase) HashLen() int {
+	if h := o.Hasher(); h != nil {
+		return h.Size()
+	}
+	return 0
+}
+
+// deltaDepthLimiter is implemented by storage backends which resolve delta
+// chains, and can limit their depth.
+type deltaDepthLimiter interface {
+	// SetMaxDeltaDepth sets the maximum number of deltas followed when
+	// resolving an object.
+	SetMaxDeltaDepth(depth int)
+}
+
+// creatingStorer is implemented by writable storage backends which can report
+// whether storing an object created it, or found it already present.
+type creatingStorer interface {
+	// StoreCreated is as Store, but additionally returns whether a new
+	// object was created.
+	StoreCreated(sha []byte, r io.Reader) (n int64, created bool, err error)
+}
+
+// permissionSetter is implemented by writable storage backends which create
+// files on disk, and can control the permissions given to them.
+type permissionSetter interface {
+	// SetPermissions sets the modes given to the object files and
+	// directories created by the backend.
+	SetPermissions(file, dir os.FileMode)
+}
+
+// fsyncer is implemented by writable storage backends which can flush the
+// objects they store to stable storage.
+type fsyncer interface {
+	// SetFsync sets whether each object stored is flushed to stable
+	// storage.
+	SetFsync(enabled bool)
+}
+
+// batcher is implemented by writable storage backends which can defer the
+// work of making stored objects durable until a batch of stores has ended.
+type batcher interface {
+	// BeginBatch begins a batch of stores.
+	BeginBatch()
+	// EndBatch ends a batch of stores, and makes the objects stored during
+	// it durable.
+	EndBatch() error
+}
+
+// rescanner is implemented by storage backends which can notice objects moved
+// into or out of them by other processes, such as when a repository is
+// repacked.
+type rescanner interface {
+	// Rescan looks for such changes, and returns whether there were any.
+	Rescan() (bool, error)
+}
+
+// haser is implemented by storage backends which can report whether they hold
+// an object without opening it.
+type haser interface {
+	// Has returns whether the backend holds the object "sha".
+	Has(sha []byte) (bool, error)
+}
+
+// storages returns the flattened set of storage backends from which this
+// *ObjectDatabase reads, in the order in which they are searched.
+func (o *ObjectDatabase) storages() []storage.Storage {
+	type multi interface {
+		Storages() []storage.Storage
+	}
+
+	var all []storage.Storage
+
+	queue := []storage.Storage{o.ro}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		if m, ok := s.(multi); ok {
+			queue = append(m.Storages(), queue...)
+			continue
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
 // encode encodes and saves an object to the storage backend and uses an
 // in-memory buffer to calculate the object's encoded body.
-func (d *ObjectDatabase) encode(object Object) (sha []byte, n int64, err error) {
+func (d *ObjectDatabase) encode(object Object) (sha []byte, created bool, err error) {
 	return d.encodeBuffer(object, bytes.NewBuffer(nil))
 }
 
 // encodeBuffer encodes and saves an object to the storage backend by using the
 // given buffer to calculate and store the object's encoded body.
-func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []byte, n int64, err error) {
+func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []byte, created bool, err error) {
 	cn, err := object.Encode(buf)
 	if err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 
 	tmp, err := ioutil.TempFile(d.tmp, "")
 	if err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 	defer d.cleanup(tmp)
 
 	to := NewObjectWriter(tmp, d.Hasher())
-	if _, err = to.WriteHeader(object.Type(), int64(cn)); err != nil {
-		return nil, 0, err
+	if _, err = to.WriteHeader(object.Type(), cn); err != nil {
+		return nil, false, err
 	}
 
 	if seek, ok := buf.(io.Seeker); ok {
 		if _, err = seek.Seek(0, io.SeekStart); err != nil {
-			return nil, 0, err
+			return nil, false, err
 		}
 	}
 
 	if _, err = io.Copy(to, buf); err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 
 	if err = to.Close(); err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 
 	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
-		return nil, 0, err
+		return nil, false, err
 	}
 	return d.save(to.Sha(), tmp)
 }
 
 // save writes the given buffer to the location given by the storer "o.s" as
-// identified by the sha []byte.
-func (o *ObjectDatabase) save(sha []byte, buf io.Reader) ([]byte, int64, error) {
-	n, err := o.rw.Store(sha, buf)
+// identified by the sha []byte, and returns whether a new object was created.
+//
+// An object which is already held by any storage backend, such as in a
+// packfile, is not stored again, and is reported as not created. Otherwise, if
+// the storer cannot report whether it created the object itself, the object is
+// only stored if the storer does not already hold it.
+func (o *ObjectDatabase) save(sha []byte, buf io.Reader) ([]byte, bool, error) {
+	if o.exists(sha) {
+		return sha, false, nil
+	}
+
+	if c, ok := o.rw.(creatingStorer); ok {
+		_, created, err := c.StoreCreated(sha, buf)
+		return sha, created, err
+	}
+
+	f, err := o.rw.Open(sha)
+	if err == nil {
+		return sha, false, f.Close()
+	} else if !errors.IsNoSuchObject(err) {
+		return nil, false, err
+	}
 
-	return sha, n, err
+	if _, err = o.rw.Store(sha, buf); err != nil {
+		return nil, false, err
+	}
+	return sha, true, nil
 }
 
 // open gives an `*ObjectReader` for the given loose object keyed by the given
@@ -335,6 +862,19 @@ func (o *ObjectDatabase) open(sha []byte) (*ObjectReader, error) {
 	}
 
 	f, err := o.ro.Open(sha)
+	if err != nil && errors.IsNoSuchObject(err) && o.implicitEmpty {
+		// The implicit empty objects need not be looked for again.
+		if typ := o.emptyObjectType(sha); typ != UnknownObjectType {
+			return NewUncompressedObjectReader(
+				strings.NewReader(objectHeader(typ, 0)))
+		}
+	}
+	if err != nil && o.rescan() {
+		// The object may have been moved to a packfile which did not
+		// exist when it was looked for, or out of one which has since
+		// been removed, so look again.
+		f, err = o.ro.Open(sha)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -344,6 +884,39 @@ func (o *ObjectDatabase) open(sha []byte) (*ObjectReader, error) {
 	return NewUncompressedObjectReadCloser(f)
 }
 
+// rescan rescans each storage backend which can notice objects moved by other
+// processes (see: rescanner), and returns whether any found that objects had
+// been moved, in which case the commit-graph is read again when it is next
+// needed. Backends which could not be rescanned are left unchanged.
+func (o *ObjectDatabase) rescan() bool {
+	var changed bool
+	for _, s := range o.storages() {
+		if r, ok := s.(rescanner); ok {
+			if ok, err := r.Rescan(); err == nil && ok {
+				changed = true
+			}
+		}
+	}
+	if changed {
+		o.forgetCommitGraph()
+	}
+	return changed
+}
+
+// emptyObjectType returns TreeObjectType or BlobObjectType if "sha" names the
+// empty tree or empty blob, respectively, and UnknownObjectType otherwise.
+func (o *ObjectDatabase) emptyObjectType(sha []byte) ObjectType {
+	for _, typ := range []ObjectType{TreeObjectType, BlobObjectType} {
+		h := o.Hasher()
+		io.WriteString(h, objectHeader(typ, 0))
+
+		if bytes.Equal(sha, h.Sum(nil)) {
+			return typ
+		}
+	}
+	return UnknownObjectType
+}
+
 // openDecode calls decode (see: below) on the object named "sha" after openin
 // it.
 func (o *ObjectDatabase) openDecode(sha []byte, into Object) error {
@@ -351,18 +924,23 @@ func (o *ObjectDatabase) openDecode(sha []byte, into Object) error {
 	if err != nil {
 		return err
 	}
-	return o.decode(r, into)
+	return o.decode(sha, r, into)
 }
 
 // decode decodes an object given by the sha "sha []byte" into the given object
 // "into", or returns an error if one was encountered.
 //
+// Unless verification is skipped, the contents are hashed as they are decoded,
+// and an error is returned if they do not hash to "sha". Since the contents of
+// blobs are read lazily, this error is instead returned by the blob's reader
+// once its contents have been read in full.
+//
 // Ordinarily, it closes the object's underlying io.ReadCloser (if it implements
 // the `io.Closer` interface), but skips this if the "into" Object is of type
 // BlobObjectType. Blob's don't exhaust the buffer completely (they instead
 // maintain a handle on the blob's contents via an io.LimitedReader) and
 // therefore cannot be closed until signaled explicitly by gitobj.Blob.Close().
-func (o *ObjectDatabase) decode(r *ObjectReader, into Object) error {
+func (o *ObjectDatabase) decode(sha []byte, r *ObjectReader, into Object) error {
 	typ, size, err := r.Header()
 	if err != nil {
 		return err
@@ -370,16 +948,182 @@ func (o *ObjectDatabase) decode(r *ObjectReader, into Object) error {
 		return &UnexpectedObjectType{Got: typ, Wanted: into.Type()}
 	}
 
-	if _, err = into.Decode(o.Hasher(), r, size); err != nil {
+	if o.skipVerify {
+		if into.Type() == BlobObjectType {
+			_, err = into.Decode(o.Hasher(), r, size)
+			return err
+		}
+
+		contents := io.LimitReader(r, size)
+		if _, err = into.Decode(o.Hasher(), contents, size); err != nil {
+			return err
+		}
+		if _, err = io.Copy(ioutil.Discard, contents); err != nil {
+			r.Close()
+			return err
+		}
+		if err = checkTrailing(sha, r); err != nil {
+			r.Close()
+			return err
+		}
+		return r.Close()
+	}
+
+	v := o.newVerifyingReader(sha, r, typ, size)
+	if _, err = into.Decode(o.Hasher(), v, size); err != nil {
 		return err
 	}
 
 	if into.Type() == BlobObjectType {
+		if size == 0 {
+			// There are no contents to be read, so check the
+			// hash now.
+			if err = v.verify(); err != io.EOF {
+				r.Close()
+				return err
+			}
+		}
 		return nil
 	}
+
+	// Consume anything not read while decoding, so that the whole object
+	// has been hashed.
+	if _, err = io.Copy(ioutil.Discard, v); err != nil {
+		r.Close()
+		return err
+	}
+	if err = checkTrailing(sha, r); err != nil {
+		r.Close()
+		return err
+	}
 	return r.Close()
 }
 
+// checkTrailing reads whatever remains of "r" after the contents of the object
+// named "sha", and returns an error if anything but whitespace was found there,
+// rather than silently ignoring it.
+func checkTrailing(sha []byte, r io.Reader) error {
+	var buf [4096]byte
+	for {
+		n, err := r.Read(buf[:])
+		if len(bytes.TrimSpace(buf[:n])) > 0 {
+			return fmt.Errorf("gitobj: trailing garbage after object %x", sha)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Verify checks that the contents of the object named "sha" hash to "sha",
+// regardless of whether verification is otherwise skipped (see:
+// SkipHashVerification). It returns an error if they do not, or if the object
+// could not be read.
+func (o *ObjectDatabase) Verify(sha []byte) error {
+	r, err := o.open(sha)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(ioutil.Discard, o.newVerifyingReader(sha, r, typ, size))
+	return err
+}
+
+// verifyingReader reads the contents of an object from an *ObjectReader,
+// hashing them as they are read, and returns an error in place of io.EOF if
+// they do not hash to the object's ID.
+type verifyingReader struct {
+	// r is the reader positioned at the start of the object's contents.
+	r *ObjectReader
+	// h is the hash of the object's header and the contents read so far.
+	h hash.Hash
+	// sha is the ID by which the object was requested.
+	sha []byte
+	// remaining is the number of bytes of contents not yet read.
+	remaining int64
+	// err is the result of checking the hash once all of the contents
+	// have been read, or nil if it has not yet been checked.
+	err error
+}
+
+// newVerifyingReader returns a *verifyingReader for the contents of the object
+// named "sha", of the given type and size, which are read from "r".
+func (o *ObjectDatabase) newVerifyingReader(sha []byte, r *ObjectReader, typ ObjectType, size int64) *verifyingReader {
+	h := o.Hasher()
+	io.WriteString(h, objectHeader(typ, size))
+
+	return &verifyingReader{r: r, h: h, sha: sha, remaining: size}
+}
+
+// Read implements io.Reader.
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.remaining <= 0 {
+		return 0, v.verify()
+	}
+
+	if int64(len(p)) > v.remaining {
+		p = p[:v.remaining]
+	}
+
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	v.remaining -= int64(n)
+
+	if err == io.EOF {
+		// The contents ended early, which the hash will reflect.
+		return n, v.verify()
+	} else if v.remaining == 0 {
+		// Check the hash along with the last of the contents, since
+		// readers such as the blob's io.LimitedReader won't ask for
+		// more.
+		if verr := v.verify(); verr != io.EOF {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer by closing the underlying *ObjectReader.
+func (v *verifyingReader) Close() error {
+	return v.r.Close()
+}
+
+// verify returns io.EOF if the contents read hash to the object's ID, and an
+// error describing the mismatch otherwise.
+func (v *verifyingReader) verify() error {
+	if v.err == nil {
+		v.err = io.EOF
+		if got := v.h.Sum(nil); !bytes.Equal(got, v.sha) {
+			v.err = fmt.Errorf("gitobj: hash mismatch for object %x: contents hash to %x", v.sha, got)
+		}
+	}
+	return v.err
+}
+
+// newObject returns a new, empty Object of the concrete type given by "typ", or
+// an error if the type is unknown.
+func newObject(typ ObjectType) (Object, error) {
+	switch typ {
+	case BlobObjectType:
+		return new(Blob), nil
+	case TreeObjectType:
+		return new(Tree), nil
+	case CommitObjectType:
+		return new(Commit), nil
+	case TagObjectType:
+		return new(Tag), nil
+	}
+	return nil, fmt.Errorf("gitobj: unknown object type: %s", typ)
+}
+
 func (o *ObjectDatabase) cleanup(f *os.File) {
 	f.Close()
 	os.Remove(f.Name())
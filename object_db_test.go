@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -97,6 +101,48 @@ func TestDecodeObject(t *testing.T) {
 	}
 }
 
+func TestDecodeObjectOfEachType(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	commit := writeTestCommit(t, odb, tree, "initial commit")
+	tag, err := odb.WriteTag(&Tag{
+		Object:     commit,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "Release v1.0.0",
+	})
+	require.NoError(t, err)
+
+	for _, sha := range [][]byte{blob, tree, commit, tag} {
+		obj, err := odb.Object(sha)
+		require.NoError(t, err)
+
+		switch obj := obj.(type) {
+		case *Blob:
+			assert.Equal(t, blob, sha)
+			assert.EqualValues(t, 14, obj.Size)
+			obj.Close()
+		case *Tree:
+			assert.Equal(t, tree, sha)
+			assert.Len(t, obj.Entries, 1)
+		case *Commit:
+			assert.Equal(t, commit, sha)
+			assert.Equal(t, tree, obj.TreeID)
+		case *Tag:
+			assert.Equal(t, tag, sha)
+			assert.Equal(t, commit, obj.Object)
+		default:
+			t.Fatalf("unexpected object of type %T", obj)
+		}
+	}
+}
+
 func TestDecodeBlob(t *testing.T) {
 	testCases := []struct {
 		options []Option
@@ -282,6 +328,140 @@ func TestWriteBlob(t *testing.T) {
 	}
 }
 
+func TestWriteBlobRejectsShortContents(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	sha, err := odb.WriteBlob(NewBlobFromReader(strings.NewReader("Hello"), 14))
+
+	assert.Nil(t, sha)
+	assert.Equal(t, &BlobSizeError{Size: 14, Read: 5}, err)
+	assert.EqualError(t, err, "gitobj: blob ended after 5 of its declared size of 14 bytes")
+
+	_, s := b.Storage()
+	assert.Empty(t, s.(*memoryStorer).fs)
+}
+
+func TestWriteBlobRejectsLongContents(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, err := odb.WriteBlob(&Blob{
+		Size:     5,
+		Contents: strings.NewReader("Hello, world!\n"),
+	})
+
+	assert.Equal(t, &BlobSizeError{Size: 5, Read: 6}, err)
+}
+
+func TestWriteBlobFromPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hello.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("Hello, world!\n"), 0644))
+
+	odb := newTestDatabase(t)
+
+	sha, err := odb.WriteBlobFromPath(path)
+	require.NoError(t, err)
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(sha))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestWriteBlobFromPathMissingFile(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	sha, err := odb.WriteBlobFromPath(filepath.Join("does", "not", "exist"))
+	assert.Nil(t, sha)
+	assert.Error(t, err)
+}
+
+func TestHashBlobMatchesWriteBlob(t *testing.T) {
+	for _, format := range []ObjectFormatAlgorithm{ObjectFormatSHA1, ObjectFormatSHA256} {
+		b, err := NewMemoryBackend(nil)
+		require.NoError(t, err)
+
+		odb, err := FromBackend(b, ObjectFormat(format))
+		require.NoError(t, err)
+
+		hashed, err := odb.HashBlob(strings.NewReader("Hello, world!\n"), 14)
+		require.NoError(t, err)
+
+		_, s := b.Storage()
+		assert.Empty(t, s.(*memoryStorer).fs)
+
+		written, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+		require.NoError(t, err)
+		assert.Equal(t, written, hashed)
+	}
+}
+
+func TestHashBlobRejectsShortContents(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, err := odb.HashBlob(strings.NewReader("Hello"), 14)
+	assert.Equal(t, &BlobSizeError{Size: 14, Read: 5}, err)
+}
+
+func TestWriteBlobLargerThan2GiB(t *testing.T) {
+	if len(os.Getenv("GITOBJ_TEST_LARGE_OBJECTS")) == 0 {
+		t.Skip("set GITOBJ_TEST_LARGE_OBJECTS to test objects larger than 2 GiB")
+	}
+
+	const size = 1<<31 + 1
+
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(&Blob{
+		Size:     size,
+		Contents: io.LimitReader(zeroReader{}, size),
+	})
+	require.NoError(t, err)
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", int64(size))
+	_, err = io.Copy(h, io.LimitReader(zeroReader{}, size))
+	require.NoError(t, err)
+	assert.Equal(t, h.Sum(nil), sha)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	n, err := io.Copy(ioutil.Discard, blob.Contents)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), blob.Size)
+	assert.Equal(t, int64(size), n)
+}
+
+// zeroReader is an io.Reader which yields an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
 func TestWriteTree(t *testing.T) {
 	testCases := []struct {
 		options []Option
@@ -373,6 +553,60 @@ func TestWriteCommit(t *testing.T) {
 	}
 }
 
+func TestWriteCommitWithEmptyTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	sha, err := odb.WriteCommit(&Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    tree,
+		Message:   "Empty commit",
+	})
+	require.NoError(t, err)
+
+	data, err := odb.Serialize(sha)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), "\x00tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n")
+}
+
+func TestWriteCommitWithoutTree(t *testing.T) {
+	for desc, tree := range map[string][]byte{
+		"nil":   nil,
+		"empty": []byte{},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			odb := newTestDatabase(t)
+
+			sha, err := odb.WriteCommit(&Commit{
+				Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+				Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+				TreeID:    tree,
+				Message:   "No tree",
+			})
+
+			assert.EqualError(t, err, "gitobj: cannot encode commit without a tree")
+			assert.Nil(t, sha)
+		})
+	}
+}
+
+func TestWriteCommitRejectsIDsOfOtherLength(t *testing.T) {
+	odb := newTestDatabase(t, ObjectFormat(ObjectFormatSHA256))
+
+	_, err := odb.WriteCommit(&Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    make([]byte, sha1.Size),
+		Message:   "SHA-1 tree in a SHA-256 database",
+	})
+
+	assert.EqualError(t, err, "gitobj: invalid commit: tree ID has invalid length 20")
+}
+
 func TestWriteCommitWithGPGSignature(t *testing.T) {
 	b, err := NewMemoryBackend(nil)
 	require.NoError(t, err)
@@ -515,3 +749,729 @@ func TestObjectDatabaseRootWithoutRoot(t *testing.T) {
 	assert.Equal(t, "", root)
 	assert.False(t, ok)
 }
+
+func TestSerialize(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	data, err := odb.Serialize(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "blob 14\x00Hello, world!\n", string(data))
+
+	hash := odb.Hasher()
+	hash.Write(data)
+	assert.Equal(t, sha, hash.Sum(nil))
+}
+
+func TestSerializeRoundTripsCommits(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	commit := new(Commit)
+	_, err = commit.Decode(sha1.New(),
+		strings.NewReader(roundTripCommit), int64(len(roundTripCommit)))
+	require.NoError(t, err)
+
+	sha, err := odb.WriteCommit(commit)
+	require.NoError(t, err)
+
+	data, err := odb.Serialize(sha)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("commit %d\x00%s",
+		len(roundTripCommit), roundTripCommit), string(data))
+}
+
+func TestSerializeTrees(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	sha, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	data, err := odb.Serialize(sha)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "tree 33\x00"))
+
+	hash := odb.Hasher()
+	hash.Write(data)
+	assert.Equal(t, sha, hash.Sum(nil))
+}
+
+func TestSerializeMissingObject(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	data, err := odb.Serialize(make([]byte, 20))
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, data)
+}
+
+func TestImplicitEmptyObjects(t *testing.T) {
+	testCases := []struct {
+		options []Option
+		treeSha string
+		blobSha string
+	}{
+		{
+			[]Option{},
+			"4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+			"e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		},
+		{
+			[]Option{ObjectFormat(ObjectFormatSHA256)},
+			"6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321",
+			"473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813",
+		},
+	}
+
+	for _, test := range testCases {
+		odb := newTestDatabase(t, test.options...)
+
+		treeSha, _ := hex.DecodeString(test.treeSha)
+		tree, err := odb.Tree(treeSha)
+		require.NoError(t, err)
+		assert.Empty(t, tree.Entries)
+
+		blobSha, _ := hex.DecodeString(test.blobSha)
+		blob, err := odb.Blob(blobSha)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, blob.Size)
+
+		contents, err := ioutil.ReadAll(blob.Contents)
+		require.NoError(t, err)
+		assert.Empty(t, contents)
+		assert.NoError(t, blob.Close())
+	}
+}
+
+func TestImplicitEmptyObjectsDisabled(t *testing.T) {
+	odb := newTestDatabase(t, ImplicitEmptyObjects(false))
+
+	treeSha, _ := hex.DecodeString("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	tree, err := odb.Tree(treeSha)
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, tree)
+
+	blobSha, _ := hex.DecodeString("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	blob, err := odb.Blob(blobSha)
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, blob)
+}
+
+func TestImplicitEmptyObjectsDoesNotMatchOtherTypes(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	treeSha, _ := hex.DecodeString("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	blob, err := odb.Blob(treeSha)
+	assert.IsType(t, &UnexpectedObjectType{}, err)
+	assert.Nil(t, blob)
+}
+
+func TestMaxDeltaDepth(t *testing.T) {
+	b := &deltaDepthBackend{ms: newMemoryStorer(nil)}
+
+	_, err := FromBackend(b, MaxDeltaDepth(100))
+	require.NoError(t, err)
+
+	assert.Equal(t, 100, b.depth)
+}
+
+func TestMaxDeltaDepthDefault(t *testing.T) {
+	b := &deltaDepthBackend{ms: newMemoryStorer(nil)}
+
+	_, err := FromBackend(b)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, b.depth)
+}
+
+// deltaDepthBackend is a storage.Backend backed by a *memoryStorer, which
+// records the maximum delta depth it is given.
+type deltaDepthBackend struct {
+	ms    *memoryStorer
+	depth int
+}
+
+func (b *deltaDepthBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return storage.MultiStorage(b, b.ms), b.ms
+}
+
+func (b *deltaDepthBackend) Open(sha []byte) (io.ReadCloser, error) { return b.ms.Open(sha) }
+func (b *deltaDepthBackend) Close() error                           { return nil }
+func (b *deltaDepthBackend) IsCompressed() bool                     { return true }
+func (b *deltaDepthBackend) SetMaxDeltaDepth(depth int)             { b.depth = depth }
+
+func TestLooseObjectAges(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	goodbye, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+
+	then := time.Unix(1257894000, 0)
+	fs := odb.rw.(*fileStorer)
+	require.NoError(t, os.Chtimes(fs.path(hello), then, then))
+	require.NoError(t, os.Chtimes(fs.path(goodbye), then.Add(time.Hour), then.Add(time.Hour)))
+
+	// Neither temporary files nor other directories are objects.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(filepath.Dir(fs.path(hello)), "tmp_obj_123"), nil, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "info", "packs"), nil, 0644))
+
+	ages, err := odb.LooseObjectAges()
+	require.NoError(t, err)
+
+	assert.Len(t, ages, 2)
+	assert.True(t, then.Equal(ages[hex.EncodeToString(hello)]))
+	assert.True(t, then.Add(time.Hour).Equal(ages[hex.EncodeToString(goodbye)]))
+}
+
+func TestLooseObjectAgesWithoutLooseObjects(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	ages, err := odb.LooseObjectAges()
+
+	assert.NoError(t, err)
+	assert.Empty(t, ages)
+}
+
+func TestWriteEx(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	fs, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer fs.Close()
+
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	ms, err := FromBackend(b)
+	require.NoError(t, err)
+
+	plain, err := FromBackend(&plainBackend{ms: newMemoryStorer(nil)})
+	require.NoError(t, err)
+
+	for name, odb := range map[string]*ObjectDatabase{
+		"filesystem": fs,
+		"memory":     ms,
+		"plain":      plain,
+	} {
+		for _, write := range []func() ([]byte, bool, error){
+			func() ([]byte, bool, error) {
+				return odb.WriteBlobEx(NewBlobFromBytes([]byte("Hello, world!\n")))
+			},
+			func() ([]byte, bool, error) {
+				return odb.WriteTreeEx(&Tree{})
+			},
+			func() ([]byte, bool, error) {
+				return odb.WriteCommitEx(&Commit{
+					Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+					Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+					TreeID:    []byte("aaaaaaaaaaaaaaaaaaaa"),
+					Message:   "commit",
+				})
+			},
+			func() ([]byte, bool, error) {
+				return odb.WriteTagEx(&Tag{
+					Object:     []byte("aaaaaaaaaaaaaaaaaaaa"),
+					ObjectType: CommitObjectType,
+					Name:       "v1.0.0",
+					Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+					Message:    "tag",
+				})
+			},
+		} {
+			first, created, err := write()
+			require.NoError(t, err, name)
+			assert.True(t, created, name)
+
+			second, created, err := write()
+			require.NoError(t, err, name)
+			assert.False(t, created, name)
+
+			assert.Equal(t, first, second, name)
+		}
+	}
+}
+
+func TestWriteExPackedObject(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	oid := runTestGit(t, dir, "Hello, world!\n", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, oid+"\n", "pack-objects", "-q", filepath.Join(dir, "objects", "pack", "pack"))
+	runTestGit(t, dir, "", "prune-packed")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, created, err := odb.WriteBlobEx(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	// The packed object is not written again as a loose object.
+	assert.Equal(t, oid, hex.EncodeToString(sha))
+	assert.False(t, created)
+
+	_, err = os.Stat(filepath.Join(dir, "objects", oid[:2], oid[2:]))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// plainBackend is a storage.Backend backed by a *memoryStorer, whose writable
+// storage implements only the storage.WritableStorage interface.
+type plainBackend struct {
+	ms *memoryStorer
+}
+
+func (b *plainBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return b.ms, b
+}
+
+func (b *plainBackend) Open(sha []byte) (io.ReadCloser, error)             { return b.ms.Open(sha) }
+func (b *plainBackend) Store(sha []byte, r io.Reader) (n int64, err error) { return b.ms.Store(sha, r) }
+func (b *plainBackend) Close() error                                       { return nil }
+func (b *plainBackend) IsCompressed() bool                                 { return true }
+
+// corruptTestDatabase returns a database in which the object "Hello, world!\n"
+// is stored under the ID of the blob "Goodbye, world!\n", along with that ID.
+func corruptTestDatabase(t *testing.T, options ...Option) (*ObjectDatabase, []byte) {
+	sha, _ := hex.DecodeString("c62237e82222b334d66609de729920b6d3ea9a3f")
+
+	var buf bytes.Buffer
+
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "blob 14\x00Hello, world!\n")
+	zw.Close()
+
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{
+		hex.EncodeToString(sha): &buf,
+	})
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b, options...)
+	require.NoError(t, err)
+
+	return odb, sha
+}
+
+func TestHashVerification(t *testing.T) {
+	odb, sha := corruptTestDatabase(t, SkipHashVerification(false))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	_, err = ioutil.ReadAll(blob.Contents)
+
+	assert.EqualError(t, err, "gitobj: hash mismatch for object "+
+		"c62237e82222b334d66609de729920b6d3ea9a3f: contents hash to "+
+		"af5626b4a114abcb82d63db7c8082c3c4756e51b")
+}
+
+func TestHashVerificationOfTrees(t *testing.T) {
+	odb := newTestDatabase(t, SkipHashVerification(false))
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+	}}
+
+	var buf bytes.Buffer
+	_, err = tree.Encode(&buf)
+	require.NoError(t, err)
+
+	// Store the tree under the ID of another.
+	sha := make([]byte, sha1.Size)
+	_, err = odb.rw.Store(sha, bytes.NewReader(compressObject(t, "tree", buf.Bytes())))
+	require.NoError(t, err)
+
+	_, err = odb.Tree(sha)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gitobj: hash mismatch for object 0000000000000000000000000000000000000000")
+}
+
+func TestDecodeTreeWithTrailingGarbage(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: make([]byte, sha1.Size), Filemode: 0100644},
+	}}
+
+	var contents bytes.Buffer
+	_, err := tree.Encode(&contents)
+	require.NoError(t, err)
+
+	h := sha1.New()
+	fmt.Fprintf(h, "tree %d\x00", contents.Len())
+	h.Write(contents.Bytes())
+	sha := h.Sum(nil)
+
+	for _, options := range [][]Option{
+		{},
+		{SkipHashVerification(false)},
+	} {
+		// Store the tree followed by bytes beyond its size.
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		fmt.Fprintf(zw, "tree %d\x00", contents.Len())
+		zw.Write(contents.Bytes())
+		fmt.Fprintf(zw, "garbage")
+		require.NoError(t, zw.Close())
+
+		b, err := NewMemoryBackend(map[string]io.ReadWriter{
+			hex.EncodeToString(sha): &buf,
+		})
+		require.NoError(t, err)
+
+		odb, err := FromBackend(b, options...)
+		require.NoError(t, err)
+
+		_, err = odb.Tree(sha)
+
+		assert.EqualError(t, err, fmt.Sprintf(
+			"gitobj: trailing garbage after object %x", sha))
+	}
+}
+
+func TestSkipHashVerification(t *testing.T) {
+	odb, sha := corruptTestDatabase(t, SkipHashVerification(true))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	got, err := ioutil.ReadAll(blob.Contents)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(got))
+}
+
+func TestVerify(t *testing.T) {
+	odb := newTestDatabase(t, SkipHashVerification(true))
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	assert.NoError(t, odb.Verify(sha))
+}
+
+func TestVerifyCorruptObject(t *testing.T) {
+	odb, sha := corruptTestDatabase(t, SkipHashVerification(true))
+
+	err := odb.Verify(sha)
+
+	assert.EqualError(t, err, "gitobj: hash mismatch for object "+
+		"c62237e82222b334d66609de729920b6d3ea9a3f: contents hash to "+
+		"af5626b4a114abcb82d63db7c8082c3c4756e51b")
+}
+
+func TestVerifyMissingObject(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	err := odb.Verify(make([]byte, sha1.Size))
+
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+// compressObject returns the zlib-compressed loose representation of an object
+// of the given type and contents.
+func compressObject(t *testing.T, typ string, contents []byte) []byte {
+	var buf bytes.Buffer
+
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "%s %d\x00", typ, len(contents))
+	zw.Write(contents)
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func BenchmarkReadBlobs(b *testing.B) {
+	for _, skip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("SkipHashVerification=%t", skip), func(b *testing.B) {
+			benchmarkReadBlobs(b, SkipHashVerification(skip))
+		})
+	}
+}
+
+// benchmarkReadBlobs measures reading each of a set of blobs, totalling 16 MiB,
+// from a database opened with the given options.
+func benchmarkReadBlobs(b *testing.B, options ...Option) {
+	const count, size = 256, 64 << 10
+
+	backend, err := NewMemoryBackend(nil)
+	require.NoError(b, err)
+
+	odb, err := FromBackend(backend, options...)
+	require.NoError(b, err)
+
+	shas := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		contents := bytes.Repeat([]byte{byte(i)}, size)
+
+		sha, err := odb.WriteBlob(NewBlobFromBytes(contents))
+		require.NoError(b, err)
+
+		shas = append(shas, sha)
+	}
+
+	b.SetBytes(count * size)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, sha := range shas {
+			blob, err := odb.Blob(sha)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = io.Copy(ioutil.Discard, blob.Contents); err != nil {
+				b.Fatal(err)
+			}
+			blob.Close()
+		}
+	}
+}
+
+func TestBatchDefersDirectorySyncs(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", FsyncObjects(true))
+	require.NoError(t, err)
+
+	fs := odb.rw.(*fileStorer)
+	require.True(t, fs.fsync)
+
+	odb.BeginBatch()
+	odb.BeginBatch()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]struct{}{
+		filepath.Join(root, fmt.Sprintf("%x", sha[:1])): {},
+		root: {},
+	}, fs.dirty)
+
+	require.NoError(t, odb.EndBatch())
+	assert.Len(t, fs.dirty, 2)
+
+	require.NoError(t, odb.EndBatch())
+	assert.Empty(t, fs.dirty)
+
+	_, err = odb.Blob(sha)
+	assert.NoError(t, err)
+}
+
+func TestBatchWithoutFsync(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	odb.BeginBatch()
+
+	_, err = odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	assert.Empty(t, odb.rw.(*fileStorer).dirty)
+	assert.NoError(t, odb.EndBatch())
+}
+
+func TestEndBatchWithoutBeginBatch(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	assert.EqualError(t, odb.EndBatch(), "gitobj: EndBatch called without BeginBatch")
+}
+
+// BenchmarkImportBlobs measures writing one blob per operation to a database
+// with FsyncObjects, with and without a batch around the whole import. Run it
+// with "-benchtime=100000x" to import 100,000 objects.
+func BenchmarkImportBlobs(b *testing.B) {
+	for _, batch := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batch=%t", batch), func(b *testing.B) {
+			root, err := ioutil.TempDir("", "gitobj")
+			require.NoError(b, err)
+			defer os.RemoveAll(root)
+
+			odb, err := FromFilesystem(root, "", FsyncObjects(true))
+			require.NoError(b, err)
+
+			b.ResetTimer()
+
+			if batch {
+				odb.BeginBatch()
+			}
+			for i := 0; i < b.N; i++ {
+				blob := NewBlobFromBytes([]byte(fmt.Sprintf("object %d\n", i)))
+				if _, err := odb.WriteBlob(blob); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if batch {
+				if err := odb.EndBatch(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExistsPackedAndLoose(t *testing.T) {
+	dir := newTestGitDir(t)
+	defer os.RemoveAll(dir)
+
+	packed := runTestGit(t, dir, "packed\n", "hash-object", "-w", "--stdin")
+	runTestGit(t, dir, "", "repack", "-a", "-d", "-q")
+	loose := runTestGit(t, dir, "loose\n", "hash-object", "-w", "--stdin")
+	missing := runTestGit(t, dir, "missing\n", "hash-object", "--stdin")
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	for _, test := range []struct {
+		sha    string
+		exists bool
+	}{
+		{packed, true},
+		{loose, true},
+		{missing, false},
+	} {
+		sha, err := hex.DecodeString(test.sha)
+		require.NoError(t, err)
+
+		assert.Equal(t, test.exists, odb.Exists(sha), test.sha)
+	}
+}
+
+func TestExistsInMemory(t *testing.T) {
+	odb := newTestDatabase(t, ImplicitEmptyObjects(false))
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+
+	assert.True(t, odb.Exists(blob))
+	assert.False(t, odb.Exists(make([]byte, 20)))
+
+	empty, err := odb.HashBlob(strings.NewReader(""), 0)
+	require.NoError(t, err)
+	assert.False(t, odb.Exists(empty))
+}
+
+func TestExistsImplicitEmptyObjects(t *testing.T) {
+	odb := newTestDatabase(t, ImplicitEmptyObjects(true))
+
+	empty, err := odb.HashBlob(strings.NewReader(""), 0)
+	require.NoError(t, err)
+	assert.True(t, odb.Exists(empty))
+}
+
+func TestExistsDoesNotRescan(t *testing.T) {
+	b := &rescanBackend{ms: newMemoryStorer(nil)}
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	assert.False(t, odb.Exists(make([]byte, 20)))
+	assert.Equal(t, 0, b.rescans)
+}
+
+func TestOpenImplicitEmptyObjectDoesNotRescan(t *testing.T) {
+	b := &rescanBackend{ms: newMemoryStorer(nil)}
+
+	odb, err := FromBackend(b, ImplicitEmptyObjects(true))
+	require.NoError(t, err)
+
+	treeSha, _ := hex.DecodeString("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	_, err = odb.Tree(treeSha)
+	require.NoError(t, err)
+	assert.Equal(t, 0, b.rescans)
+
+	_, err = odb.Blob(make([]byte, 20))
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Equal(t, 1, b.rescans)
+}
+
+// rescanBackend is a storage.Backend backed by a *memoryStorer, which counts
+// the number of times it is rescanned.
+type rescanBackend struct {
+	ms      *memoryStorer
+	rescans int
+}
+
+func (b *rescanBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return storage.MultiStorage(b, b.ms), b.ms
+}
+
+func (b *rescanBackend) Open(sha []byte) (io.ReadCloser, error) { return b.ms.Open(sha) }
+func (b *rescanBackend) Close() error                           { return nil }
+func (b *rescanBackend) IsCompressed() bool                     { return true }
+
+func (b *rescanBackend) Rescan() (bool, error) {
+	b.rescans++
+	return false, nil
+}
+
+func TestHashAlgoAndLen(t *testing.T) {
+	for desc, c := range map[string]struct {
+		options []Option
+		algo    string
+		len     int
+	}{
+		"default": {nil, "sha1", 20},
+		"sha1":    {[]Option{ObjectFormat(ObjectFormatSHA1)}, "sha1", 20},
+		"sha256":  {[]Option{ObjectFormat(ObjectFormatSHA256)}, "sha256", 32},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			odb := newTestDatabase(t, c.options...)
+			defer odb.Close()
+
+			assert.Equal(t, c.algo, odb.HashAlgo())
+			assert.Equal(t, c.len, odb.HashLen())
+
+			sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+			require.NoError(t, err)
+			assert.Len(t, sha, odb.HashLen())
+		})
+	}
+}
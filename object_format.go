@@ -0,0 +1,77 @@
+package gitobj
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// detectObjectFormat returns the object format of the repository whose object
+// directory is "root", as given by the "extensions.objectFormat" setting of its
+// configuration, which is the "config" file alongside that directory. If the
+// repository has no configuration, or does not set an object format, it uses
+// SHA-1.
+//
+// If the configuration could not be read, or names an object format which is
+// not supported, an error is returned.
+func detectObjectFormat(root string) (ObjectFormatAlgorithm, error) {
+	f, err := os.Open(filepath.Join(filepath.Dir(root), "config"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ObjectFormatSHA1, nil
+		}
+		return "", err
+	}
+	defer f.Close()
+
+	format := ObjectFormatSHA1
+
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return "", fmt.Errorf("gitobj: malformed section header in repository config: %q", line)
+			}
+			section = strings.ToLower(strings.TrimSpace(line[1:end]))
+			line = strings.TrimSpace(line[end+1:])
+			if len(line) == 0 {
+				continue
+			}
+		}
+		if section != "extensions" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), "objectformat") {
+			continue
+		}
+
+		value := parts[1]
+		if i := strings.IndexAny(value, "#;"); i >= 0 {
+			value = value[:i]
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch algo := ObjectFormatAlgorithm(strings.ToLower(value)); algo {
+		case ObjectFormatSHA1, ObjectFormatSHA256:
+			format = algo
+		default:
+			return "", fmt.Errorf("gitobj: unsupported object format %q in repository config", value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return format, nil
+}
@@ -0,0 +1,192 @@
+package gitobj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectObjectFormat(t *testing.T) {
+	for desc, c := range map[string]struct {
+		config string
+		format ObjectFormatAlgorithm
+		err    string
+	}{
+		"no config":     {"", ObjectFormatSHA1, ""},
+		"no extension":  {"[core]\n\tbare = true\n", ObjectFormatSHA1, ""},
+		"sha1":          {"[extensions]\n\tobjectformat = sha1\n", ObjectFormatSHA1, ""},
+		"sha256":        {"[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectformat = sha256\n", ObjectFormatSHA256, ""},
+		"mixed case":    {"[Extensions]\n\tobjectFormat = \"SHA256\" # comment\n", ObjectFormatSHA256, ""},
+		"same line":     {"[extensions] objectformat = sha256\n", ObjectFormatSHA256, ""},
+		"other section": {"[core]\n\tobjectformat = sha256\n", ObjectFormatSHA1, ""},
+		"unsupported":   {"[extensions]\n\tobjectformat = md5\n", "", `gitobj: unsupported object format "md5" in repository config`},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gitobj")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			if len(c.config) > 0 {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config"), []byte(c.config), 0644))
+			}
+
+			format, err := detectObjectFormat(filepath.Join(dir, "objects"))
+			if len(c.err) > 0 {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.format, format)
+		})
+	}
+}
+
+func TestFromFilesystemUnsupportedObjectFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	odb, err := FromFilesystem(dir, "", ObjectFormat("md5"))
+
+	assert.EqualError(t, err, `gitobj: unsupported object format "md5"`)
+	assert.Nil(t, odb)
+}
+
+func TestCommitDecodeRejectsShortIDsUnderSHA256(t *testing.T) {
+	from := fmt.Sprintf("tree %s\n\nmessage\n", strings.Repeat("a", 40))
+
+	_, err := new(Commit).Decode(sha256.New(), strings.NewReader(from), int64(len(from)))
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: tree ID %q has 40 hex digits, expected 64", strings.Repeat("a", 40)))
+}
+
+func TestTagDecodeRejectsShortIDsUnderSHA256(t *testing.T) {
+	from := fmt.Sprintf("object %s\ntype commit\ntag v1.0.0\n\nmessage\n", strings.Repeat("a", 40))
+
+	_, err := new(Tag).Decode(sha256.New(), strings.NewReader(from), int64(len(from)))
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: tag object ID %q has 40 hex digits, expected 64", strings.Repeat("a", 40)))
+}
+
+func TestSHA256RoundTrip(t *testing.T) {
+	dir := newTestSHA256GitDir(t)
+	defer os.RemoveAll(dir)
+
+	objects := filepath.Join(dir, "objects")
+	odb, err := FromFilesystem(objects, "")
+	require.NoError(t, err)
+	defer odb.Close()
+
+	require.Equal(t, "sha256", odb.HashAlgo())
+	require.Equal(t, 32, odb.HashLen())
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: FilemodeRegular},
+	}}
+	treeID, err := odb.WriteTree(tree)
+	require.NoError(t, err)
+
+	sig := "Jane Doe <jane@example.com> 1257894000 +0000"
+	commit := &Commit{
+		Author:    sig,
+		Committer: sig,
+		TreeID:    treeID,
+		Message:   "Initial commit\n",
+	}
+	commitID, err := odb.WriteCommit(commit)
+	require.NoError(t, err)
+
+	child := &Commit{
+		Author:    sig,
+		Committer: sig,
+		TreeID:    treeID,
+		ParentIDs: [][]byte{commitID},
+		Message:   "Second commit\n",
+	}
+	childID, err := odb.WriteCommit(child)
+	require.NoError(t, err)
+
+	tag := &Tag{
+		Object:     childID,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     sig,
+		Message:    "Version 1.0.0",
+	}
+	tagID, err := odb.WriteTag(tag)
+	require.NoError(t, err)
+
+	// Git should agree with the IDs of each of the objects written, and
+	// find them where it expects loose objects to be.
+	for _, sha := range [][]byte{blob, treeID, commitID, childID, tagID} {
+		name := hex.EncodeToString(sha)
+		require.Len(t, name, 64)
+		assert.FileExists(t, filepath.Join(objects, name[:2], name[2:]))
+	}
+	runTestGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+
+	// Read each object back, both from the loose objects just written and
+	// once they have been packed.
+	for _, packed := range []bool{false, true} {
+		if packed {
+			runTestGit(t, dir, hex.EncodeToString(tagID)+"\n", "pack-objects", "-q", "--revs", filepath.Join(objects, "pack", "pack"))
+			runTestGit(t, dir, "", "prune-packed")
+		}
+
+		db, err := FromFilesystem(objects, "")
+		require.NoError(t, err)
+
+		b, err := db.Blob(blob)
+		require.NoError(t, err)
+		contents, err := ioutil.ReadAll(b.Contents)
+		require.NoError(t, err)
+		require.NoError(t, b.Close())
+		assert.Equal(t, "Hello, world!\n", string(contents))
+
+		gotTree, err := db.Tree(treeID)
+		require.NoError(t, err)
+		assert.True(t, tree.Equal(gotTree))
+
+		gotCommit, err := db.Commit(commitID)
+		require.NoError(t, err)
+		assert.True(t, commit.Equal(gotCommit))
+
+		gotChild, err := db.Commit(childID)
+		require.NoError(t, err)
+		assert.True(t, child.Equal(gotChild))
+
+		gotTag, err := db.Tag(tagID)
+		require.NoError(t, err)
+		assert.True(t, tag.Equal(gotTag))
+
+		require.NoError(t, db.Close())
+	}
+}
+
+// newTestSHA256GitDir initializes a new, bare Git repository which uses SHA-256
+// object IDs in a temporary directory and returns its path, or skips the test
+// if Git is not installed or does not support SHA-256.
+func newTestSHA256GitDir(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+
+	if err := exec.Command("git", "--git-dir", dir, "init", "--quiet", "--bare", "--object-format=sha256").Run(); err != nil {
+		os.RemoveAll(dir)
+		t.Skip("git does not support SHA-256")
+	}
+	return dir
+}
@@ -88,7 +88,14 @@ func (w *ObjectWriter) WriteHeader(typ ObjectType, len int64) (n int, err error)
 	if !atomic.CompareAndSwapUint32(&w.wroteHeader, 0, 1) {
 		panic("gitobj: cannot write headers more than once")
 	}
-	return fmt.Fprintf(w, "%s %d\x00", typ, len)
+	return io.WriteString(w, objectHeader(typ, len))
+}
+
+// objectHeader returns the loose object header for an object of the given type
+// and uncompressed size, as in "<type> <size>\x00". This header is prepended to
+// the object's contents when computing its object ID.
+func objectHeader(typ ObjectType, size int64) string {
+	return fmt.Sprintf("%s %d\x00", typ, size)
 }
 
 // Write writes the given buffer "p" of uncompressed bytes into the underlying
@@ -0,0 +1,296 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+const (
+	// bitmapVersion is the only supported version of bitmap files.
+	bitmapVersion = 1
+
+	// bitmapOptFullDAG is the flag indicating that the bitmap file
+	// describes the full closure of the objects in the packfile. It is
+	// required to be set.
+	bitmapOptFullDAG = 0x1
+)
+
+var (
+	// bitmapHeader is the expected header that begins all valid bitmap
+	// files.
+	bitmapHeader = []byte{'B', 'I', 'T', 'M'}
+
+	// errBadBitmapHeader is a sentinel error value returned when the given
+	// bitmap header does not match the expected one.
+	errBadBitmapHeader = errors.New("gitobj/pack: bad bitmap header")
+)
+
+// Bitmap is a reachability bitmap index corresponding to a single packfile, as
+// stored in a "pack-*.bitmap" file. For each of a selection of commits in the
+// packfile, it stores a bitmap of every object reachable from that commit,
+// where bit "i" represents the "i"-th object in the packfile's offset order.
+type Bitmap struct {
+	// types holds one bitmap for each of the commit, tree, blob, and tag
+	// object types, in that order, each of which has a bit set for every
+	// object of that type in the packfile.
+	types [4]*ewahBitmap
+	// entries is the set of bitmapped commits, in the order in which they
+	// appear in the bitmap file.
+	entries []*bitmapEntry
+	// commits maps the position of a bitmapped commit in the index to its
+	// position in "entries".
+	commits map[uint32]int
+	// packSum is the checksum of the corresponding packfile.
+	packSum []byte
+}
+
+// bitmapEntry is a single bitmapped commit.
+type bitmapEntry struct {
+	// xorOffset is the number of entries before this one whose bitmap this
+	// bitmap is XOR'd against, or zero if this bitmap is stored as-is.
+	xorOffset uint8
+	// bitmap is the (possibly XOR'd) bitmap of this commit.
+	bitmap *ewahBitmap
+}
+
+// DecodeBitmap decodes a bitmap file whose underlying data is supplied by "r".
+//
+// DecodeBitmap reads the header, the type bitmaps, and the compressed bitmap
+// of each commit, but does not inflate or resolve any of them. The number of
+// bitmaps, and of the words in each, are checked against the length of the
+// file before anything is allocated for them.
+//
+// If the bitmap file is malformed, or of an unsupported version, an error is
+// returned.
+func DecodeBitmap(r io.ReaderAt, hash hash.Hash) (*Bitmap, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+
+	hashlen := hash.Size()
+	if len(data) < 12+hashlen {
+		return nil, fmt.Errorf("gitobj/pack: bitmap too short: %d bytes", len(data))
+	}
+
+	if !bytes.HasPrefix(data, bitmapHeader) {
+		return nil, errBadBitmapHeader
+	}
+
+	if version := binary.BigEndian.Uint16(data[4:]); version != bitmapVersion {
+		return nil, &UnsupportedVersionErr{uint32(version)}
+	}
+	if flags := binary.BigEndian.Uint16(data[6:]); flags&bitmapOptFullDAG == 0 {
+		return nil, fmt.Errorf("gitobj/pack: unsupported bitmap flags: %#x", flags)
+	}
+
+	count := binary.BigEndian.Uint32(data[8:])
+
+	b := &Bitmap{packSum: data[12 : 12+hashlen]}
+	data = data[12+hashlen:]
+
+	for i := range b.types {
+		bitmap, n, err := decodeEWAH(data)
+		if err != nil {
+			return nil, err
+		}
+		b.types[i] = bitmap
+		data = data[n:]
+	}
+
+	// Each entry is at least a 6-byte header and an empty EWAH bitmap.
+	if int64(count) > int64(len(data))/(6+12) {
+		return nil, fmt.Errorf("gitobj/pack: bitmap has too many entries: %d", count)
+	}
+	b.entries = make([]*bitmapEntry, 0, count)
+	b.commits = make(map[uint32]int, count)
+
+	for i := 0; i < int(count); i++ {
+		if len(data) < 6 {
+			return nil, fmt.Errorf("gitobj/pack: truncated bitmap entry")
+		}
+
+		pos := binary.BigEndian.Uint32(data[0:])
+		xorOffset := data[4]
+		if int(xorOffset) > i {
+			return nil, fmt.Errorf("gitobj/pack: invalid bitmap XOR offset: %d", xorOffset)
+		}
+
+		bitmap, n, err := decodeEWAH(data[6:])
+		if err != nil {
+			return nil, err
+		}
+		data = data[6+n:]
+
+		b.commits[pos] = len(b.entries)
+		b.entries = append(b.entries, &bitmapEntry{
+			xorOffset: xorOffset,
+			bitmap:    bitmap,
+		})
+	}
+	return b, nil
+}
+
+// fits returns whether no bitmap in "b" has more words than are needed to hold
+// a bit for each of the "objects" objects in the packfile it describes. Git
+// rounds the size of the bitmaps of commits up to a whole word.
+func (b *Bitmap) fits(objects int) bool {
+	words := (int64(objects) + 63) / 64
+	for _, t := range b.types {
+		if (int64(t.bits)+63)/64 > words {
+			return false
+		}
+	}
+	for _, entry := range b.entries {
+		if (int64(entry.bitmap.bits)+63)/64 > words {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the number of commits which have a bitmap.
+func (b *Bitmap) Count() int {
+	return len(b.entries)
+}
+
+// reachable returns the inflated bitmap of objects reachable from the commit
+// at position "pos" in the index, and whether that commit has a bitmap.
+func (b *Bitmap) reachable(pos uint32) ([]uint64, bool, error) {
+	i, ok := b.commits[pos]
+	if !ok {
+		return nil, false, nil
+	}
+
+	// A commit's bitmap is XOR'd against the (resolved) bitmap of an
+	// earlier entry, which may itself be XOR'd, and so on. Since XOR is
+	// associative, the resolved bitmap is the XOR of every bitmap in the
+	// chain.
+	var words []uint64
+	for {
+		entry := b.entries[i]
+
+		inflated, err := entry.bitmap.Inflate()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if words == nil {
+			words = inflated
+		} else {
+			for j := 0; j < len(words) && j < len(inflated); j++ {
+				words[j] ^= inflated[j]
+			}
+		}
+
+		if entry.xorOffset == 0 {
+			break
+		}
+		i -= int(entry.xorOffset)
+	}
+	return words, true, nil
+}
+
+// bitmapTypes are the object types of each type bitmap, in the order in which
+// they are stored.
+var bitmapTypes = [4]PackedObjectType{TypeCommit, TypeTree, TypeBlob, TypeTag}
+
+// typeOf returns the type of the object at position "bit" in offset order, as
+// given by the type bitmaps.
+func (b *Bitmap) typeOf(types [4][]uint64, bit int) PackedObjectType {
+	for i, words := range types {
+		if bit/64 < len(words) && words[bit/64]&(uint64(1)<<uint(bit%64)) != 0 {
+			return bitmapTypes[i]
+		}
+	}
+	return TypeNone
+}
+
+// ReachableFn is a function called for each object reachable from a bitmapped
+// commit, given its name and type.
+type ReachableFn func(name []byte, typ PackedObjectType) error
+
+// Reachable calls "fn" for each object reachable from the commit "name"
+// (including the commit itself), as given by the packfile's bitmap, and returns
+// true.
+//
+// If the packfile has no bitmap, or the commit is not bitmapped, Reachable
+// returns false without calling "fn". If "fn" returns an error, iteration is
+// stopped and that error is returned.
+func (p *Packfile) Reachable(name []byte, fn ReachableFn) (bool, error) {
+	bitmap := p.loadBitmap()
+	if bitmap == nil {
+		return false, nil
+	}
+
+	pos, err := p.idx.position(name)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	words, ok, err := bitmap.reachable(uint32(pos))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	var types [4][]uint64
+	for i, t := range bitmap.types {
+		if types[i], err = t.Inflate(); err != nil {
+			return false, err
+		}
+	}
+
+	order, err := p.idx.offsetOrder()
+	if err != nil {
+		return false, err
+	}
+
+	for i, word := range words {
+		for j := 0; word != 0 && j < 64; j++ {
+			if word&(uint64(1)<<uint(j)) == 0 {
+				continue
+			}
+			word &^= uint64(1) << uint(j)
+
+			bit := 64*i + j
+			if bit >= len(order) {
+				return false, fmt.Errorf("gitobj/pack: bitmap position out of range: %d", bit)
+			}
+
+			name, err := p.idx.version.Name(p.idx, int64(order[bit]))
+			if err != nil {
+				return false, err
+			}
+
+			if err = fn(name, bitmap.typeOf(types, bit)); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// HasBitmap returns whether the packfile has a usable reachability bitmap.
+func (p *Packfile) HasBitmap() bool {
+	return p.loadBitmap() != nil
+}
+
+// loadBitmap returns the packfile's reachability bitmap, opening it if it has
+// not yet been opened, or nil if it has none.
+func (p *Packfile) loadBitmap() *Bitmap {
+	p.bitmapOnce.Do(func() {
+		if p.bitmap == nil && p.openBitmap != nil {
+			p.bitmap = p.openBitmap()
+		}
+	})
+	return p.bitmap
+}
@@ -0,0 +1,248 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bitmapCommit  = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	bitmapTree    = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	bitmapBlob    = "cccccccccccccccccccccccccccccccccccccccc"
+	bitmapChild   = "dddddddddddddddddddddddddddddddddddddddd"
+	bitmapUnknown = "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+)
+
+func TestDecodeBitmap(t *testing.T) {
+	b, err := DecodeBitmap(bytes.NewReader(bitmapFixture(bitmapVersion, bitmapOptFullDAG)), sha1.New())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, b.Count())
+}
+
+func TestDecodeBitmapBadHeader(t *testing.T) {
+	buf := bitmapFixture(bitmapVersion, bitmapOptFullDAG)
+	copy(buf, "XXXX")
+
+	b, err := DecodeBitmap(bytes.NewReader(buf), sha1.New())
+
+	assert.Equal(t, errBadBitmapHeader, err)
+	assert.Nil(t, b)
+}
+
+func TestDecodeBitmapUnsupportedVersion(t *testing.T) {
+	b, err := DecodeBitmap(bytes.NewReader(bitmapFixture(2, bitmapOptFullDAG)), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: unsupported version: 2")
+	assert.Nil(t, b)
+}
+
+func TestDecodeBitmapUnsupportedFlags(t *testing.T) {
+	b, err := DecodeBitmap(bytes.NewReader(bitmapFixture(bitmapVersion, 0)), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: unsupported bitmap flags: 0x0")
+	assert.Nil(t, b)
+}
+
+func TestDecodeBitmapTruncated(t *testing.T) {
+	buf := bitmapFixture(bitmapVersion, bitmapOptFullDAG)
+
+	_, err := DecodeBitmap(bytes.NewReader(buf[:len(buf)-8]), sha1.New())
+
+	assert.Error(t, err)
+}
+
+func TestDecodeBitmapTooManyEntries(t *testing.T) {
+	buf := bitmapFixture(bitmapVersion, bitmapOptFullDAG)
+	binary.BigEndian.PutUint32(buf[8:], 0xffffffff)
+
+	b, err := DecodeBitmap(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: bitmap has too many entries: 4294967295")
+	assert.Nil(t, b)
+}
+
+func TestNewSetOpensBitmap(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	sum := data[len(data)-sha1.Size:]
+
+	for desc, c := range map[string]struct {
+		bitmap []byte
+		opened bool
+	}{
+		"valid":     {typesBitmapFixture(sum, 2), true},
+		"corrupt":   {typesBitmapFixture(sum, 2)[:12], false},
+		"mismatch":  {typesBitmapFixture(make([]byte, sha1.Size), 2), false},
+		"too large": {typesBitmapFixture(sum, 65), false},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gitobj-pack")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			pd := filepath.Join(dir, "pack")
+			require.NoError(t, os.Mkdir(pd, 0755))
+
+			for ext, contents := range map[string][]byte{"idx": idx, "pack": data, "bitmap": c.bitmap} {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-fixture."+ext), contents, 0644))
+			}
+
+			set, err := NewSet(dir, sha1.New())
+			require.NoError(t, err)
+			defer set.Close()
+
+			packs := set.packs
+			require.Len(t, packs, 1)
+
+			// The bitmap is not opened until it is needed.
+			assert.Nil(t, packs[0].bitmap)
+			assert.Equal(t, c.opened, packs[0].HasBitmap())
+		})
+	}
+}
+
+func TestPackfileReachable(t *testing.T) {
+	p := bitmapPackfile(t)
+
+	var names []string
+	var types []PackedObjectType
+
+	ok, err := p.Reachable(DecodeHex(t, bitmapCommit), func(name []byte, typ PackedObjectType) error {
+		names = append(names, hex.EncodeToString(name))
+		types = append(types, typ)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{bitmapTree, bitmapBlob, bitmapCommit}, names)
+	assert.Equal(t, []PackedObjectType{TypeTree, TypeBlob, TypeCommit}, types)
+}
+
+func TestPackfileReachableResolvesXOR(t *testing.T) {
+	p := bitmapPackfile(t)
+
+	var names []string
+	ok, err := p.Reachable(DecodeHex(t, bitmapChild), func(name []byte, typ PackedObjectType) error {
+		names = append(names, hex.EncodeToString(name))
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{bitmapTree, bitmapBlob, bitmapCommit, bitmapChild}, names)
+}
+
+func TestPackfileReachableUnbitmappedObject(t *testing.T) {
+	p := bitmapPackfile(t)
+
+	for _, name := range []string{bitmapTree, bitmapUnknown} {
+		ok, err := p.Reachable(DecodeHex(t, name), func(name []byte, typ PackedObjectType) error {
+			t.Fatalf("gitobj/pack: unexpected object: %x", name)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+}
+
+func TestPackfileReachableWithoutBitmap(t *testing.T) {
+	p := bitmapPackfile(t)
+	p.bitmap = nil
+
+	ok, err := p.Reachable(DecodeHex(t, bitmapCommit), func(name []byte, typ PackedObjectType) error {
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, p.HasBitmap())
+}
+
+func TestPackfileReachableStopsOnError(t *testing.T) {
+	p := bitmapPackfile(t)
+
+	var n int
+	ok, err := p.Reachable(DecodeHex(t, bitmapCommit), func(name []byte, typ PackedObjectType) error {
+		n++
+		return errBadBitmapHeader
+	})
+
+	assert.Equal(t, errBadBitmapHeader, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, n)
+}
+
+// bitmapPackfile returns a packfile whose objects are, in offset order, a tree,
+// a blob, and two commits, each of which has a bitmap.
+func bitmapPackfile(t *testing.T) *Packfile {
+	b, err := DecodeBitmap(bytes.NewReader(bitmapFixture(bitmapVersion, bitmapOptFullDAG)), sha1.New())
+	require.NoError(t, err)
+
+	return &Packfile{
+		idx: IndexWith(map[string]uint32{
+			bitmapCommit: 20,
+			bitmapTree:   0,
+			bitmapBlob:   10,
+			bitmapChild:  30,
+		}),
+		bitmap: b,
+	}
+}
+
+// typesBitmapFixture returns a bitmap file with no bitmapped commits, whose
+// type bitmaps each have "bits" bits, for the packfile whose checksum is "sum".
+func typesBitmapFixture(sum []byte, bits uint32) []byte {
+	buf := make([]byte, 12)
+	copy(buf, bitmapHeader)
+	binary.BigEndian.PutUint16(buf[4:], bitmapVersion)
+	binary.BigEndian.PutUint16(buf[6:], bitmapOptFullDAG)
+	buf = append(buf, sum...)
+
+	for range bitmapTypes {
+		buf = append(buf, encodeEWAH(bits)...)
+	}
+	return buf
+}
+
+// bitmapFixture returns a bitmap file describing the packfile returned by
+// bitmapPackfile, with the given version and flags. The second commit's
+// bitmap is stored XOR'd against the first's.
+func bitmapFixture(version, flags uint16) []byte {
+	buf := make([]byte, 12+sha1.Size)
+	copy(buf, bitmapHeader)
+	binary.BigEndian.PutUint16(buf[4:], version)
+	binary.BigEndian.PutUint16(buf[6:], flags)
+	binary.BigEndian.PutUint32(buf[8:], 2)
+
+	for _, types := range []uint64{0xc, 0x1, 0x2, 0x0} {
+		buf = append(buf, encodeEWAH(4, rlw(false, 0, 1), types)...)
+	}
+
+	for _, entry := range []struct {
+		pos       uint32
+		xorOffset uint8
+		bits      uint64
+	}{
+		{pos: 0, xorOffset: 0, bits: 0x7},
+		{pos: 3, xorOffset: 1, bits: 0x8},
+	} {
+		hdr := make([]byte, 6)
+		binary.BigEndian.PutUint32(hdr, entry.pos)
+		hdr[4] = entry.xorOffset
+
+		buf = append(buf, hdr...)
+		buf = append(buf, encodeEWAH(4, rlw(false, 0, 1), entry.bits)...)
+	}
+	return buf
+}
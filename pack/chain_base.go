@@ -36,6 +36,10 @@ func (b *ChainBase) Unpack() ([]byte, error) {
 
 	defer zr.Close()
 
+	if b.size > maxSliceLen {
+		return nil, &ObjectTooLargeErr{Size: b.size}
+	}
+
 	buf := make([]byte, b.size)
 	if _, err := io.ReadFull(zr, buf); err != nil {
 		return nil, err
@@ -18,18 +18,45 @@ type ChainDelta struct {
 //
 // If any of the delta-base instructions were invalid, an error will be
 // returned.
+//
+// The chain is resolved iteratively, beginning with the delta closest to its
+// base, so that a long chain does not lead to deep recursion.
 func (d *ChainDelta) Unpack() ([]byte, error) {
-	base, err := d.base.Unpack()
+	var deltas [][]byte
+
+	var base Chain = d
+	for {
+		delta, ok := base.(*ChainDelta)
+		if !ok {
+			break
+		}
+		deltas = append(deltas, delta.delta)
+		base = delta.base
+	}
+
+	data, err := base.Unpack()
 	if err != nil {
 		return nil, err
 	}
 
-	return patch(base, d.delta)
+	for i := len(deltas) - 1; i >= 0; i-- {
+		if data, err = patch(data, deltas[i]); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
 }
 
 // Type returns the type of the base of the delta-base chain.
 func (d *ChainDelta) Type() PackedObjectType {
-	return d.base.Type()
+	var base Chain = d
+	for {
+		delta, ok := base.(*ChainDelta)
+		if !ok {
+			return base.Type()
+		}
+		base = delta.base
+	}
 }
 
 // patch applies the delta instructions in "delta" to the base given as "base".
@@ -54,6 +81,11 @@ func patch(base, delta []byte) ([]byte, error) {
 	// moves the "pos" offset to the correct position to begin the set of
 	// delta instructions.
 	destSize, pos := patchDeltaHeader(delta, pos)
+	if destSize < 0 {
+		return nil, fmt.Errorf("gitobj/pack: invalid delta data")
+	} else if destSize > maxSliceLen {
+		return nil, &ObjectTooLargeErr{Size: destSize}
+	}
 
 	dest := make([]byte, 0, destSize)
 
@@ -68,40 +100,44 @@ func patch(base, delta []byte) ([]byte, error) {
 			// for the copy offset and size instructions.
 			pos -= 1
 
-			var co, cs int
+			// The offset and size are unsigned 32- and 24-bit
+			// values, respectively, so they are decoded as 64-bit
+			// integers to avoid overflowing an int on 32-bit
+			// platforms.
+			var co, cs int64
 
 			// The lower-half of "c" (0000 1111) defines a "bitmask"
 			// for the copy offset.
 			if c&0x1 != 0 {
 				pos += 1
-				co = int(delta[pos])
+				co = int64(delta[pos])
 			}
 			if c&0x2 != 0 {
 				pos += 1
-				co |= (int(delta[pos]) << 8)
+				co |= (int64(delta[pos]) << 8)
 			}
 			if c&0x4 != 0 {
 				pos += 1
-				co |= (int(delta[pos]) << 16)
+				co |= (int64(delta[pos]) << 16)
 			}
 			if c&0x8 != 0 {
 				pos += 1
-				co |= (int(delta[pos]) << 24)
+				co |= (int64(delta[pos]) << 24)
 			}
 
 			// The upper-half of "c" (1111 0000) defines a "bitmask"
 			// for the size of the copy instruction.
 			if c&0x10 != 0 {
 				pos += 1
-				cs = int(delta[pos])
+				cs = int64(delta[pos])
 			}
 			if c&0x20 != 0 {
 				pos += 1
-				cs |= (int(delta[pos]) << 8)
+				cs |= (int64(delta[pos]) << 8)
 			}
 			if c&0x40 != 0 {
 				pos += 1
-				cs |= (int(delta[pos]) << 16)
+				cs |= (int64(delta[pos]) << 16)
 			}
 
 			if cs == 0 {
@@ -117,6 +153,9 @@ func patch(base, delta []byte) ([]byte, error) {
 			// destination. Since we are copying from the base and
 			// not the delta, the position into the delta ("pos")
 			// need not be updated.
+			if co+cs > int64(len(base)) {
+				return nil, fmt.Errorf("gitobj/pack: invalid delta data")
+			}
 			dest = append(dest, base[co:co+cs]...)
 		} else if c != 0 {
 			// If the most significant bit (MSB) is _not_ set, we
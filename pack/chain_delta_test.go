@@ -110,3 +110,23 @@ func TestChainDeltaWithExtraInstructions(t *testing.T) {
 	assert.EqualError(t, err, "gitobj/pack: invalid delta data")
 	assert.Nil(t, data)
 }
+
+func TestChainDeltaWithCopyBeyondBase(t *testing.T) {
+	c := &ChainDelta{
+		base: &ChainSimple{
+			X: []byte{0x0, 0x1, 0x2, 0x3},
+		},
+		delta: []byte{
+			0x04, // Source size: 4.
+			0x01, // Destination size: 1.
+
+			0x80 | 0x08 | 0x10, // Copy, omask=1000, smask=0001.
+			0x80,               // Offset: 0x80000000.
+			0x1,                // Size: 1.
+		},
+	}
+
+	data, err := c.Unpack()
+	assert.EqualError(t, err, "gitobj/pack: invalid delta data")
+	assert.Nil(t, data)
+}
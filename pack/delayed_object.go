@@ -12,6 +12,10 @@ import (
 type delayedObjectReader struct {
 	obj *Object
 	mr  io.Reader
+
+	// closeFn is called by Close, if it is non-nil, to release the
+	// packfiles from which the object is read.
+	closeFn func() error
 }
 
 // Read implements the io.Reader method by instantiating a new underlying reader
@@ -37,5 +41,10 @@ func (d *delayedObjectReader) Read(b []byte) (int, error) {
 
 // Close implements the io.Closer interface.
 func (d *delayedObjectReader) Close() error {
-	return nil
+	if d.closeFn == nil {
+		return nil
+	}
+	closeFn := d.closeFn
+	d.closeFn = nil
+	return closeFn()
 }
@@ -0,0 +1,136 @@
+package pack
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChainLink describes a single element of the delta-base chain of a packed
+// object, as stored in its packfile.
+type ChainLink struct {
+	// Name is the name of the object that the element resolves to, or nil
+	// if the element is not indexed.
+	Name []byte
+	// Type is the type of the element: TypeObjectOffsetDelta or
+	// TypeObjectReferenceDelta for a delta, or the type of the object
+	// itself for the base of the chain.
+	Type PackedObjectType
+	// Offset is the offset at which the element begins in the packfile.
+	Offset int64
+	// StoredSize is the number of bytes that the element occupies in the
+	// packfile, including its header and its compressed data. Since
+	// elements are measured using the index, any unindexed elements which
+	// directly follow it are counted as well, although a well-formed
+	// packfile has none.
+	StoredSize int64
+}
+
+// DeltaChain returns the delta-base chain of the object named "name", ordered
+// from the element for the object itself down to the base of the chain, which
+// is not a delta. An object which is not stored as a delta has a chain of a
+// single element. Only the headers of the elements are read, so no delta is
+// resolved.
+//
+// If the object could not be found, (nil, errNotFound) will be returned. If the
+// chain is longer than the packfile's maximum delta depth (see:
+// SetMaxDeltaDepth), a *DeltaDepthErr is returned instead.
+func (p *Packfile) DeltaChain(name []byte) ([]*ChainLink, error) {
+	entry, err := p.idx.Entry(name)
+	if err != nil {
+		if !IsNotFound(err) {
+			err = fmt.Errorf("gitobj/pack: could not load index: %s", err)
+		}
+		return nil, err
+	}
+
+	var chain []*ChainLink
+	for offset := int64(entry.PackOffset); ; {
+		typ, _, dataOffset, err := p.readHeader(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		link, err := p.link(offset)
+		if err != nil {
+			return nil, err
+		}
+		link.Type = typ
+		chain = append(chain, link)
+
+		switch typ {
+		case TypeCommit, TypeTree, TypeBlob, TypeTag:
+			return chain, nil
+		case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
+			if max := p.MaxDeltaDepth(); len(chain) > max {
+				return nil, &DeltaDepthErr{Max: max}
+			}
+
+			if offset, _, err = p.findBase(typ, dataOffset, offset); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errUnrecognizedObjectType
+		}
+	}
+}
+
+// link returns a *ChainLink giving the name and stored size of the element
+// beginning at "offset", found by searching the index for the elements at and
+// after that offset. The last element ends at the trailing checksum.
+func (p *Packfile) link(offset int64) (*ChainLink, error) {
+	order, err := p.idx.offsetOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	offsetOf := func(k int) (int64, error) {
+		entry, err := p.idx.version.Entry(p.idx, int64(order[k]))
+		if err != nil {
+			return 0, err
+		}
+		return int64(entry.PackOffset), nil
+	}
+
+	// Find the first element beginning after "offset".
+	var searchErr error
+	k := sort.Search(len(order), func(k int) bool {
+		at, err := offsetOf(k)
+		if err != nil && searchErr == nil {
+			searchErr = err
+		}
+		return at > offset
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	link := &ChainLink{Offset: offset}
+
+	var next int64
+	if k < len(order) {
+		if next, err = offsetOf(k); err != nil {
+			return nil, err
+		}
+	} else {
+		size, err := p.size()
+		if err != nil {
+			return nil, err
+		}
+		next = size - int64(p.hash.Size())
+	}
+	link.StoredSize = next - offset
+
+	if k > 0 {
+		at, err := offsetOf(k - 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if at == offset {
+			if link.Name, err = p.idx.version.Name(p.idx, int64(order[k-1])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return link, nil
+}
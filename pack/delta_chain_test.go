@@ -0,0 +1,53 @@
+package pack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackfileDeltaChain(t *testing.T) {
+	p, name := deltaChainPackfile(t, 3)
+	p.r = withTrailer(t, p.r)
+
+	chain, err := p.DeltaChain(DecodeHex(t, name))
+	require.NoError(t, err)
+	require.Len(t, chain, 4)
+
+	// Only the last delta is indexed, so only it is named.
+	assert.Equal(t, DecodeHex(t, name), chain[0].Name)
+	for i, link := range chain {
+		if i > 0 {
+			assert.Nil(t, link.Name)
+			assert.True(t, link.Offset < chain[i-1].Offset)
+		}
+		assert.True(t, link.StoredSize > 0)
+	}
+
+	for _, link := range chain[:3] {
+		assert.Equal(t, TypeObjectOffsetDelta, link.Type)
+	}
+	assert.Equal(t, TypeBlob, chain[3].Type)
+	assert.EqualValues(t, 0, chain[3].Offset)
+}
+
+func TestPackfileDeltaChainRejectsDeltaChainBeyondMaxDepth(t *testing.T) {
+	p, name := deltaChainPackfile(t, 3)
+	p.r = withTrailer(t, p.r)
+	p.SetMaxDeltaDepth(2)
+
+	chain, err := p.DeltaChain(DecodeHex(t, name))
+
+	assert.Equal(t, &DeltaDepthErr{Max: 2}, err)
+	assert.Nil(t, chain)
+}
+
+func TestPackfileDeltaChainMissing(t *testing.T) {
+	p, _ := deltaChainPackfile(t, 1)
+
+	chain, err := p.DeltaChain(DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+
+	assert.True(t, IsNotFound(err))
+	assert.Nil(t, chain)
+}
@@ -0,0 +1,105 @@
+package pack
+
+import (
+	"bufio"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// ForEach calls "fn" with the offset, type, and (inflated) size of each entry
+// in the packfile, in the order in which they are stored, stopping at the first
+// error.
+//
+// Unlike EachSize, ForEach reads the packfile alone, and does not use its index,
+// so it can be used when the index is missing or cannot be trusted, such as to
+// rebuild it. The number of entries is that given by the packfile's header
+// (see: Objects), and each is found by inflating the one before it. Deltas are
+// not resolved: their type is that of the delta (TypeObjectOffsetDelta or
+// TypeObjectReferenceDelta), and their size is that of its instructions.
+//
+// If an entry is malformed, or its data does not inflate to the size given by
+// its header, an error is returned.
+func (p *Packfile) ForEach(fn func(off int64, typ PackedObjectType, size int64) error) error {
+	hashlen := int64(p.hash.Size())
+
+	var br *bufio.Reader
+
+	offset := int64(packHeaderWidth)
+	for n := uint32(0); n < p.Objects; n++ {
+		typ, size, dataOffset, err := p.readHeader(offset)
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not read entry at offset %d: %s", offset, err)
+		}
+
+		switch typ {
+		case TypeCommit, TypeTree, TypeBlob, TypeTag:
+		case TypeObjectOffsetDelta:
+			// The offset of the base precedes the instructions. Its
+			// base cannot be found using the index, so it is only
+			// checked to be within the packfile.
+			if _, dataOffset, err = p.findBase(typ, dataOffset, offset); err != nil {
+				return err
+			}
+		case TypeObjectReferenceDelta:
+			// As does the name of the base.
+			dataOffset += hashlen
+		default:
+			return fmt.Errorf("gitobj/pack: unrecognized object type %d at offset %d", typ, offset)
+		}
+
+		// The length of the compressed data is not stored, so it is
+		// found from the amount of data consumed by inflating it.
+		data := io.NewSectionReader(p.r, dataOffset, math.MaxInt64)
+		if br == nil {
+			br = bufio.NewReader(data)
+		} else {
+			br.Reset(data)
+		}
+		counted := &countingByteReader{r: br}
+
+		zr, err := zlib.NewReader(counted)
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not inflate entry at offset %d: %s", offset, err)
+		}
+
+		inflated, err := io.Copy(ioutil.Discard, zr)
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not inflate entry at offset %d: %s", offset, err)
+		}
+		if inflated != int64(size) {
+			return fmt.Errorf("gitobj/pack: entry at offset %d inflates to %d bytes, expected %d",
+				offset, inflated, size)
+		}
+
+		if err = fn(offset, typ, int64(size)); err != nil {
+			return err
+		}
+		offset = dataOffset + counted.n
+	}
+	return nil
+}
+
+// countingByteReader is an io.Reader and io.ByteReader (so that a zlib reader
+// does not read beyond the end of its data) which counts the number of bytes
+// read from the buffered reader "r".
+type countingByteReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingByteReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -0,0 +1,127 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entry is an entry reported by Packfile.ForEach.
+type entry struct {
+	off  int64
+	typ  PackedObjectType
+	size int64
+}
+
+func TestPackfileForEach(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+
+	i, err := DecodeIndex(bytes.NewReader(idx), sha1.New())
+	require.NoError(t, err)
+	order, err := i.offsetOrder()
+	require.NoError(t, err)
+
+	p, err := DecodePackfile(bytes.NewReader(data), sha1.New())
+	require.NoError(t, err)
+
+	entries := packfileEntries(t, p)
+	require.Len(t, entries, 2)
+
+	// The entries are found at the offsets given by the index, which is
+	// otherwise unused.
+	for k, e := range entries {
+		ie, err := i.version.Entry(i, int64(order[k]))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, ie.PackOffset, e.off)
+		assert.Equal(t, TypeBlob, e.typ)
+	}
+	assert.EqualValues(t, len("Hello, world!\n"), entries[0].size)
+	assert.EqualValues(t, len("Goodbye, world!\n"), entries[1].size)
+}
+
+func TestPackfileForEachReportsDeltas(t *testing.T) {
+	chain, _ := mixedDeltaChainPackfile(t, 3)
+
+	var data bytes.Buffer
+	data.Write(packHeader)
+	binary.Write(&data, binary.BigEndian, uint32(2))
+	binary.Write(&data, binary.BigEndian, uint32(4))
+	_, err := chain.r.(*bytes.Reader).WriteTo(&data)
+	require.NoError(t, err)
+
+	p, err := DecodePackfile(bytes.NewReader(data.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	entries := packfileEntries(t, p)
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, []PackedObjectType{
+		TypeBlob, TypeObjectOffsetDelta, TypeObjectReferenceDelta, TypeObjectOffsetDelta,
+	}, []PackedObjectType{entries[0].typ, entries[1].typ, entries[2].typ, entries[3].typ})
+
+	assert.EqualValues(t, packHeaderWidth, entries[0].off)
+	assert.EqualValues(t, 1, entries[0].size)
+	for size := 1; size <= 3; size++ {
+		assert.EqualValues(t, len(appendX(size)), entries[size].size)
+	}
+}
+
+func TestPackfileForEachTruncated(t *testing.T) {
+	_, data := verifyFixture(t, nil)
+
+	p, err := DecodePackfile(bytes.NewReader(data[:packHeaderWidth+8]), sha1.New())
+	require.NoError(t, err)
+
+	err = p.ForEach(func(off int64, typ PackedObjectType, size int64) error {
+		return nil
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gitobj/pack: could not inflate entry at offset 12")
+}
+
+func TestPackfileForEachUnrecognizedType(t *testing.T) {
+	_, data := verifyFixture(t, nil)
+	data[packHeaderWidth] = 0x50 | data[packHeaderWidth]&0x8f
+
+	p, err := DecodePackfile(bytes.NewReader(data), sha1.New())
+	require.NoError(t, err)
+
+	err = p.ForEach(func(off int64, typ PackedObjectType, size int64) error {
+		return nil
+	})
+
+	assert.EqualError(t, err, "gitobj/pack: unrecognized object type 5 at offset 12")
+}
+
+func TestPackfileForEachPropagatesErrors(t *testing.T) {
+	_, data := verifyFixture(t, nil)
+
+	p, err := DecodePackfile(bytes.NewReader(data), sha1.New())
+	require.NoError(t, err)
+
+	var calls int
+	err = p.ForEach(func(off int64, typ PackedObjectType, size int64) error {
+		calls++
+		return errors.New("stop")
+	})
+
+	assert.EqualError(t, err, "stop")
+	assert.Equal(t, 1, calls)
+}
+
+// packfileEntries returns every entry reported by p.ForEach.
+func packfileEntries(t *testing.T, p *Packfile) []entry {
+	var entries []entry
+	require.NoError(t, p.ForEach(func(off int64, typ PackedObjectType, size int64) error {
+		entries = append(entries, entry{off: off, typ: typ, size: size})
+		return nil
+	}))
+	return entries
+}
@@ -13,3 +13,33 @@ type UnsupportedVersionErr struct {
 func (u *UnsupportedVersionErr) Error() string {
 	return fmt.Sprintf("gitobj/pack: unsupported version: %d", u.Got)
 }
+
+// DeltaDepthErr is a type implementing 'error' which indicates that an object
+// could not be resolved because its delta-base chain is longer than the maximum
+// allowed depth.
+type DeltaDepthErr struct {
+	// Max is the maximum depth that was exceeded.
+	Max int
+}
+
+// Error implements 'error.Error()'.
+func (d *DeltaDepthErr) Error() string {
+	return fmt.Sprintf("gitobj/pack: delta chain exceeds maximum depth of %d", d.Max)
+}
+
+// ObjectTooLargeErr is a type implementing 'error' which indicates that an
+// object could not be unpacked, because it is too large to be held in memory on
+// this platform.
+type ObjectTooLargeErr struct {
+	// Size is the uncompressed size of the object, in bytes.
+	Size int64
+}
+
+// Error implements 'error.Error()'.
+func (o *ObjectTooLargeErr) Error() string {
+	return fmt.Sprintf("gitobj/pack: object too large to unpack: %d bytes", o.Size)
+}
+
+// maxSliceLen is the greatest length of a byte slice on this platform, which is
+// less than the greatest size of an object on 32-bit platforms.
+const maxSliceLen = int64(^uint(0) >> 1)
@@ -0,0 +1,91 @@
+package pack
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// errTruncatedEWAH is returned when a serialized EWAH bitmap is longer than the
+// data holding it.
+var errTruncatedEWAH = fmt.Errorf("gitobj/pack: truncated EWAH bitmap")
+
+// ewahBitmap is a bitmap compressed using the "Enhanced Word-Aligned Hybrid"
+// (EWAH) encoding, as used by Git's reachability bitmap (".bitmap") files.
+//
+// See: https://github.com/git/git/blob/v2.39.0/Documentation/technical/bitmap-format.txt
+type ewahBitmap struct {
+	// bits is the number of bits in the uncompressed bitmap.
+	bits uint32
+	// words is the compressed sequence of run-length and literal words.
+	words []uint64
+}
+
+// decodeEWAH decodes a single serialized EWAH bitmap from the start of "data",
+// and returns it along with the number of bytes it occupies.
+//
+// The serialized form is a 4-byte uncompressed bit count, a 4-byte word count,
+// that many 8-byte compressed words, and a 4-byte position of the last
+// run-length word. All values are stored in network byte order.
+//
+// If "data" is too short to hold the bitmap, an error is returned without
+// reading any of its words.
+func decodeEWAH(data []byte) (*ewahBitmap, int, error) {
+	if len(data) < 8 {
+		return nil, 0, errTruncatedEWAH
+	}
+
+	bits := binary.BigEndian.Uint32(data[0:])
+	count := binary.BigEndian.Uint32(data[4:])
+
+	if int64(count) > (int64(len(data))-12)/8 {
+		return nil, 0, errTruncatedEWAH
+	}
+
+	words := make([]uint64, count)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint64(data[8+8*i:])
+	}
+
+	return &ewahBitmap{bits: bits, words: words}, 8 + 8*int(count) + 4, nil
+}
+
+// Inflate returns the uncompressed words of the bitmap, where bit "i" of word
+// "n" represents position 64*n + i.
+//
+// If the compressed words are malformed, an error is returned.
+func (e *ewahBitmap) Inflate() ([]uint64, error) {
+	size := (int(e.bits) + 63) / 64
+	out := make([]uint64, 0, size)
+
+	for pos := 0; pos < len(e.words); {
+		// Each run-length word (RLW) encodes, from its least
+		// significant bit:
+		//   - 1 bit: the value of the bits in the run,
+		//   - 32 bits: the number of words in the run, and
+		//   - 31 bits: the number of literal words following the RLW.
+		rlw := e.words[pos]
+		pos++
+
+		var fill uint64
+		if rlw&1 != 0 {
+			fill = ^uint64(0)
+		}
+		run := int((rlw >> 1) & 0xffffffff)
+		literals := int(rlw >> 33)
+
+		if len(out)+run+literals > size || pos+literals > len(e.words) {
+			return nil, fmt.Errorf("gitobj/pack: malformed EWAH bitmap")
+		}
+
+		for i := 0; i < run; i++ {
+			out = append(out, fill)
+		}
+		out = append(out, e.words[pos:pos+literals]...)
+		pos += literals
+	}
+
+	for len(out) < size {
+		out = append(out, 0)
+	}
+	return out, nil
+}
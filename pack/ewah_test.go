@@ -0,0 +1,106 @@
+package pack
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeEWAH(t *testing.T) {
+	buf := encodeEWAH(128, rlw(false, 0, 2), 0x5, 0x1)
+	e, n, err := decodeEWAH(append(buf, 0xff))
+
+	require.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.EqualValues(t, 128, e.bits)
+	assert.Equal(t, []uint64{rlw(false, 0, 2), 0x5, 0x1}, e.words)
+}
+
+func TestDecodeEWAHTruncated(t *testing.T) {
+	buf := encodeEWAH(128, rlw(false, 0, 2), 0x5, 0x1)
+
+	_, _, err := decodeEWAH(buf[:len(buf)-5])
+
+	assert.Equal(t, errTruncatedEWAH, err)
+}
+
+func TestDecodeEWAHWordCountBeyondData(t *testing.T) {
+	buf := encodeEWAH(128)
+	binary.BigEndian.PutUint32(buf[4:], 0xffffffff)
+
+	_, _, err := decodeEWAH(buf)
+
+	assert.Equal(t, errTruncatedEWAH, err)
+}
+
+func TestEWAHInflateLiterals(t *testing.T) {
+	e := &ewahBitmap{bits: 128, words: []uint64{rlw(false, 0, 2), 0x5, 0x1}}
+
+	words, err := e.Inflate()
+
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0x5, 0x1}, words)
+}
+
+func TestEWAHInflateRuns(t *testing.T) {
+	e := &ewahBitmap{bits: 256, words: []uint64{
+		rlw(true, 2, 1), 0x3,
+		rlw(false, 1, 0),
+	}}
+
+	words, err := e.Inflate()
+
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{^uint64(0), ^uint64(0), 0x3, 0x0}, words)
+}
+
+func TestEWAHInflatePadsToSize(t *testing.T) {
+	e := &ewahBitmap{bits: 130, words: []uint64{rlw(false, 0, 1), 0x1}}
+
+	words, err := e.Inflate()
+
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0x1, 0x0, 0x0}, words)
+}
+
+func TestEWAHInflateMalformed(t *testing.T) {
+	e := &ewahBitmap{bits: 256, words: []uint64{rlw(false, 0, 3), 0x1}}
+
+	words, err := e.Inflate()
+
+	assert.EqualError(t, err, "gitobj/pack: malformed EWAH bitmap")
+	assert.Nil(t, words)
+}
+
+func TestEWAHInflateOverflow(t *testing.T) {
+	e := &ewahBitmap{bits: 64, words: []uint64{rlw(true, 2, 0)}}
+
+	_, err := e.Inflate()
+
+	assert.EqualError(t, err, "gitobj/pack: malformed EWAH bitmap")
+}
+
+// rlw returns a run-length word with the given fill bit, run length, and
+// literal count.
+func rlw(fill bool, run, literals uint32) uint64 {
+	w := uint64(run)<<1 | uint64(literals)<<33
+	if fill {
+		w |= 1
+	}
+	return w
+}
+
+// encodeEWAH serializes an EWAH bitmap of "bits" bits with the given
+// compressed words.
+func encodeEWAH(bits uint32, words ...uint64) []byte {
+	buf := make([]byte, 8+8*len(words)+4)
+
+	binary.BigEndian.PutUint32(buf[0:], bits)
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(words)))
+	for i, w := range words {
+		binary.BigEndian.PutUint64(buf[8+8*i:], w)
+	}
+	return buf
+}
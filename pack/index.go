@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
 )
 
 const MaxHashSize = sha256.Size
@@ -14,7 +16,7 @@ const MaxHashSize = sha256.Size
 type Index struct {
 	// version is the encoding version used by this index.
 	//
-	// Currently, versions 1 and 2 are supported.
+	// Currently, versions 1, 2, and 3 are supported.
 	version IndexVersion
 	// fanout is the L1 fanout table stored in this index. For a given index
 	// "i" into the array, the value stored at that index specifies the
@@ -26,6 +28,23 @@ type Index struct {
 
 	// r is the underlying set of encoded data comprising this index file.
 	r io.ReaderAt
+
+	// reverse is the corresponding "pack-*.rev" file giving the order of
+	// the objects in the packfile, or nil if there is none.
+	reverse *ReverseIndex
+	// openReverse, if non-nil, opens the reverse index, or returns nil if
+	// there is no usable one. It is called when the order of the objects
+	// is first needed, rather than when the index is opened, since the
+	// whole of the reverse index is read and checked.
+	openReverse func() *ReverseIndex
+
+	// order is the position of each object in this index, ordered by the
+	// offset of each object in the corresponding packfile. It is computed
+	// lazily, guarded by orderOnce, and orderErr holds any error
+	// encountered while doing so.
+	order     []uint32
+	orderErr  error
+	orderOnce sync.Once
 }
 
 // Count returns the number of objects in the packfile.
@@ -33,6 +52,21 @@ func (i *Index) Count() int {
 	return int(i.fanout[255])
 }
 
+// ForEach calls "fn" with the name of each object in the index, in ascending
+// order. If "fn" returns an error, iteration stops and that error is returned.
+func (i *Index) ForEach(fn func(name []byte) error) error {
+	for at, count := 0, i.Count(); at < count; at++ {
+		name, err := i.version.Name(i, int64(at))
+		if err != nil {
+			return err
+		}
+		if err = fn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close closes the packfile index if the underlying data stream is closeable.
 // If so, it returns any error involved in closing.
 func (i *Index) Close() error {
@@ -65,6 +99,16 @@ func IsNotFound(err error) bool {
 //
 // Otherwise, (entry, nil) will be returned.
 func (i *Index) Entry(name []byte) (*IndexEntry, error) {
+	at, err := i.position(name)
+	if err != nil {
+		return nil, err
+	}
+	return i.version.Entry(i, at)
+}
+
+// position returns the position of the given SHA1 "name" in the sorted list of
+// names in this index, or errNotFound if it is not present.
+func (i *Index) position(name []byte) (int64, error) {
 	var last *bounds
 	bounds := i.bounds(name)
 
@@ -76,7 +120,7 @@ func (i *Index) Entry(name []byte) (*IndexEntry, error) {
 			//
 			// Either way, we won't be able to find the object.
 			// Return immediately to prevent infinite looping.
-			return nil, errNotFound
+			return 0, errNotFound
 		}
 		last = bounds
 
@@ -85,14 +129,14 @@ func (i *Index) Entry(name []byte) (*IndexEntry, error) {
 
 		got, err := i.version.Name(i, mid)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 
 		if cmp := bytes.Compare(name, got); cmp == 0 {
 			// If "cmp" is zero, that means the object at that index
 			// "at" had a SHA equal to the one given by name, and we
 			// are done.
-			return i.version.Entry(i, mid)
+			return mid, nil
 		} else if cmp < 0 {
 			// If the comparison is less than 0, we searched past
 			// the desired object, so limit the upper bound of the
@@ -107,7 +151,74 @@ func (i *Index) Entry(name []byte) (*IndexEntry, error) {
 
 	}
 
-	return nil, errNotFound
+	return 0, errNotFound
+}
+
+// offsetOrder returns the position in this index of each object, ordered by
+// each object's offset in the corresponding packfile. This is given by the
+// reverse index, if there is one, and is otherwise computed by sorting.
+func (i *Index) offsetOrder() ([]uint32, error) {
+	i.orderOnce.Do(func() {
+		if i.reverse == nil && i.openReverse != nil {
+			i.reverse = i.openReverse()
+		}
+		if i.reverse != nil {
+			i.order = i.reverse.positions
+			return
+		}
+
+		count := i.Count()
+
+		offsets := make([]uint64, count)
+		order := make([]uint32, count)
+		for at := 0; at < count; at++ {
+			entry, err := i.version.Entry(i, int64(at))
+			if err != nil {
+				i.orderErr = err
+				return
+			}
+
+			offsets[at] = entry.PackOffset
+			order[at] = uint32(at)
+		}
+
+		sort.Slice(order, func(a, b int) bool {
+			return offsets[order[a]] < offsets[order[b]]
+		})
+		i.order = order
+	})
+	return i.order, i.orderErr
+}
+
+// after returns the position in this index of the first object whose name is
+// greater than "name", or the number of objects if there is none. Only the
+// objects which share the first byte of "name" are searched, as given by the
+// fanout table.
+func (i *Index) after(name []byte) (int, error) {
+	if len(name) == 0 {
+		return 0, nil
+	}
+
+	var lo int
+	if name[0] > 0 {
+		lo = int(i.fanout[name[0]-1])
+	}
+	hi := int(i.fanout[name[0]])
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		got, err := i.version.Name(i, int64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Compare(got, name) <= 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
 }
 
 // readAt is a convenience method that allow reading into the underlying data
@@ -58,7 +58,8 @@ var (
 // DecodeIndex decodes an index whose underlying data is supplied by "r".
 //
 // DecodeIndex reads only the header and fanout table, and does not eagerly
-// parse index entries.
+// parse index entries. Version 3 indexes have no fanout table, so one is
+// computed from the first byte of each of their names instead.
 //
 // If there was an error parsing, it will be returned immediately.
 func DecodeIndex(r io.ReaderAt, hash hash.Hash) (*Index, error) {
@@ -67,7 +68,12 @@ func DecodeIndex(r io.ReaderAt, hash hash.Hash) (*Index, error) {
 		return nil, err
 	}
 
-	fanout, err := decodeIndexFanout(r, version.Width())
+	var fanout []uint32
+	if v3, ok := version.(*V3); ok {
+		fanout, err = v3.decodeFanout(r)
+	} else {
+		fanout, err = decodeIndexFanout(r, version.Width())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -99,6 +105,8 @@ func decodeIndexHeader(r io.ReaderAt, hash hash.Hash) (IndexVersion, error) {
 			return &V1{hash: hash}, nil
 		case 2:
 			return &V2{hash: hash}, nil
+		case 3:
+			return decodeIndexV3Header(r, hash)
 		}
 		return nil, &UnsupportedVersionErr{uint32(version)}
 	}
@@ -57,11 +57,11 @@ func TestDecodeIndexV1InvalidFanout(t *testing.T) {
 func TestDecodeIndexUnsupportedVersion(t *testing.T) {
 	buf := make([]byte, 0, 4+4)
 	buf = append(buf, 0xff, 0x74, 0x4f, 0x63)
-	buf = append(buf, 0x0, 0x0, 0x0, 0x3)
+	buf = append(buf, 0x0, 0x0, 0x0, 0x4)
 
 	idx, err := DecodeIndex(bytes.NewReader(buf), sha1.New())
 
-	assert.EqualError(t, err, "gitobj/pack: unsupported version: 3")
+	assert.EqualError(t, err, "gitobj/pack: unsupported version: 4")
 	assert.Nil(t, idx)
 }
 
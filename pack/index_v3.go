@@ -0,0 +1,201 @@
+package pack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const (
+	// indexV3FixedWidth is the width of the fixed part of the header in
+	// V3: the magic header and version, followed by the length of the
+	// header, the number of objects, and the number of object formats.
+	indexV3FixedWidth = indexV2Width + 12
+	// indexV3FormatWidth is the width of the description of each object
+	// format in the V3 header: its identifier, the length of its shortened
+	// names, and the offset of its tables.
+	indexV3FormatWidth = 12
+
+	// indexObjectPositionWidth is the width of the position in the
+	// packfile of each object in V3.
+	indexObjectPositionWidth = 4
+)
+
+// V3 implements IndexVersion for v3 packfiles, which may name their objects in
+// more than one object format. Only the first of them, which must be that of
+// the hash in use, is read.
+//
+// See: https://github.com/git/git/blob/v2.30.0/Documentation/technical/hash-function-transition.txt
+type V3 struct {
+	hash hash.Hash
+
+	// width is the length of the header.
+	width int64
+	// count is the number of objects in the index.
+	count int64
+	// short is the length of the shortened names in the sorted names
+	// table.
+	short int64
+	// tables is the offset of the tables of the first object format.
+	tables int64
+	// trailer is the offset of the trailing checksums.
+	trailer int64
+}
+
+// decodeIndexV3Header decodes the header of the v3 index given by "r", whose
+// first object format must be that of "hash".
+func decodeIndexV3Header(r io.ReaderAt, hash hash.Hash) (*V3, error) {
+	hdr := make([]byte, indexV3FixedWidth)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+
+	width := int64(binary.BigEndian.Uint32(hdr[8:]))
+	count := int64(binary.BigEndian.Uint32(hdr[12:]))
+	formats := int64(binary.BigEndian.Uint32(hdr[16:]))
+	if formats == 0 || width < indexV3FixedWidth+formats*indexV3FormatWidth+4 {
+		return nil, fmt.Errorf("gitobj/pack: malformed v3 index header")
+	}
+
+	rest := make([]byte, formats*indexV3FormatWidth+4)
+	if _, err := r.ReadAt(rest, indexV3FixedWidth); err != nil {
+		return nil, err
+	}
+
+	want := formatID(hash)
+	for n := int64(0); n < formats; n++ {
+		format := rest[n*indexV3FormatWidth:]
+		if id := string(format[:4]); id != want {
+			continue
+		} else if n > 0 {
+			return nil, fmt.Errorf("gitobj/pack: v3 index has %q names only as a secondary object format", id)
+		}
+
+		v := &V3{
+			hash:    hash,
+			width:   width,
+			count:   count,
+			short:   int64(binary.BigEndian.Uint32(format[4:])),
+			tables:  int64(binary.BigEndian.Uint32(format[8:])),
+			trailer: int64(binary.BigEndian.Uint32(rest[formats*indexV3FormatWidth:])),
+		}
+		if v.short < 1 || v.short > int64(hash.Size()) || v.tables < width {
+			return nil, fmt.Errorf("gitobj/pack: malformed v3 index header")
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("gitobj/pack: v3 index has no %q names", want)
+}
+
+// formatID returns the identifier which v3 indexes give to the object format
+// using "hash".
+func formatID(hash hash.Hash) string {
+	if hash.Size() == 32 {
+		return "s256"
+	}
+	return "sha1"
+}
+
+// decodeFanout computes the fanout table of the v3 index given by "r", which
+// does not store one, from the first byte of each of its sorted names.
+func (v *V3) decodeFanout(r io.ReaderAt) ([]uint32, error) {
+	names := make([]byte, v.count*v.short)
+	if _, err := r.ReadAt(names, v.tables); err != nil {
+		if err == io.EOF {
+			return nil, ErrShortFanout
+		}
+		return nil, err
+	}
+
+	fanout := make([]uint32, indexFanoutEntries)
+	for at := int64(0); at < v.count; at++ {
+		fanout[names[at*v.short]]++
+	}
+	for i := 1; i < len(fanout); i++ {
+		fanout[i] += fanout[i-1]
+	}
+	return fanout, nil
+}
+
+// Name implements IndexVersion.Name by returning the full object name for the
+// given entry at offset "at" in the v3 index file "idx", which is found by way
+// of its position in the packfile.
+func (v *V3) Name(idx *Index, at int64) ([]byte, error) {
+	var pos [indexObjectPositionWidth]byte
+	if _, err := idx.readAt(pos[:], v.positionOffset(at)); err != nil {
+		return nil, err
+	}
+
+	var sha [MaxHashSize]byte
+
+	hashlen := int64(v.hash.Size())
+	off := v.tables + v.short*v.count + hashlen*int64(binary.BigEndian.Uint32(pos[:]))
+
+	if _, err := idx.readAt(sha[:hashlen], off); err != nil {
+		return nil, err
+	}
+	return sha[:hashlen], nil
+}
+
+// Entry implements IndexVersion.Entry for v3 packfiles by parsing and returning
+// the IndexEntry specified at the offset "at" in the given index file.
+func (v *V3) Entry(idx *Index, at int64) (*IndexEntry, error) {
+	var offs [4]byte
+	if _, err := idx.readAt(offs[:], v.smallOffsetOffset(at)); err != nil {
+		return nil, err
+	}
+
+	loc := uint64(binary.BigEndian.Uint32(offs[:]))
+	if loc&0x80000000 > 0 {
+		// As in V2, the most significant bit of the offset marks
+		// it as the index of an 8-byte pack offset.
+		var offs [8]byte
+		if _, err := idx.readAt(offs[:], v.largeOffsetOffset(int64(loc&0x7fffffff))); err != nil {
+			return nil, err
+		}
+
+		loc = binary.BigEndian.Uint64(offs[:])
+	}
+	return &IndexEntry{PackOffset: loc}, nil
+}
+
+// Width implements IndexVersion.Width() by returning the number of bytes that
+// v3 packfile index header occupy.
+func (v *V3) Width() int64 {
+	return v.width
+}
+
+// positionOffset returns the offset of the position in the packfile of the
+// object given at "at" in the V3 index file.
+func (v *V3) positionOffset(at int64) int64 {
+	return v.tables +
+		// Skip the shortened and full name tables.
+		((v.short + int64(v.hash.Size())) * v.count) +
+		// Skip until the desired position.
+		(indexObjectPositionWidth * at)
+}
+
+// crcOffset returns the offset of the CRC of the object at position "pos" in
+// the packfile.
+func (v *V3) crcOffset(pos int64) int64 {
+	return v.positionOffset(v.count) +
+		// Skip until the desired CRC, which is in packfile order.
+		(indexObjectCRCWidth * pos)
+}
+
+// smallOffsetOffset returns the offset of an object's small (4-byte) offset
+// given by "at".
+func (v *V3) smallOffsetOffset(at int64) int64 {
+	return v.crcOffset(v.count) +
+		// Skip until the desired index in the small offsets table.
+		(indexObjectSmallOffsetWidth * at)
+}
+
+// largeOffsetOffset returns the offset of an object's large (8-byte) offset,
+// given by the index "at".
+func (v *V3) largeOffsetOffset(at int64) int64 {
+	return v.smallOffsetOffset(v.count) +
+		// Seek to the large offset within the large offset(s) table.
+		(indexObjectLargeOffsetWidth * at)
+}
@@ -0,0 +1,281 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexVersionsAgree(t *testing.T) {
+	for _, algo := range []func() hash.Hash{sha1.New, sha256.New} {
+		objects := indexedObjects(algo, 1, 1<<31-1)
+
+		for _, version := range []uint32{1, 2, 3} {
+			t.Run(fmt.Sprintf("v%d/%d", version, algo().Size()), func(t *testing.T) {
+				idx, err := DecodeIndex(bytes.NewReader(encodeIndex(algo, version, objects, nil)), algo())
+				require.NoError(t, err)
+				require.Equal(t, len(objects), idx.Count())
+
+				for _, o := range objects {
+					e, err := idx.Entry(o.name)
+					require.NoError(t, err)
+					assert.Equal(t, o.offset, e.PackOffset)
+				}
+
+				missing := make([]byte, algo().Size())
+				_, err = idx.Entry(missing)
+				assert.True(t, IsNotFound(err))
+			})
+		}
+	}
+}
+
+func TestIndexV3LargeOffsets(t *testing.T) {
+	objects := indexedObjects(sha1.New, 12, 1<<33, 1<<31)
+
+	for _, version := range []uint32{2, 3} {
+		idx, err := DecodeIndex(bytes.NewReader(encodeIndex(sha1.New, version, objects, nil)), sha1.New())
+		require.NoError(t, err)
+
+		for _, o := range objects {
+			e, err := idx.Entry(o.name)
+			require.NoError(t, err)
+			assert.Equal(t, o.offset, e.PackOffset, "version %d", version)
+		}
+	}
+}
+
+func TestIndexV3ForEach(t *testing.T) {
+	objects := indexedObjects(sha1.New, 12, 34, 56, 78)
+
+	idx, err := DecodeIndex(bytes.NewReader(encodeIndex(sha1.New, 3, objects, nil)), sha1.New())
+	require.NoError(t, err)
+
+	var names [][]byte
+	require.NoError(t, idx.ForEach(func(name []byte) error {
+		names = append(names, append([]byte(nil), name...))
+		return nil
+	}))
+
+	require.Len(t, names, len(objects))
+	for i, o := range objects {
+		assert.Equal(t, o.name, names[i])
+	}
+}
+
+func TestIndexV3OtherObjectFormat(t *testing.T) {
+	buf := encodeIndex(sha1.New, 3, indexedObjects(sha1.New, 12), nil)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf), sha256.New())
+
+	assert.EqualError(t, err, `gitobj/pack: v3 index has no "s256" names`)
+	assert.Nil(t, idx)
+}
+
+func TestIndexV3SecondaryObjectFormat(t *testing.T) {
+	buf := encodeIndex(sha1.New, 3, indexedObjects(sha1.New, 12), nil)
+	// Describe a second object format, whose tables would follow those of
+	// the first.
+	end := indexV3FixedWidth + indexV3FormatWidth
+	buf = append(append(append([]byte(nil), buf[:end]...), "s256\x00\x00\x00\x04\x00\x00\x00\x00"...), buf[end:]...)
+	binary.BigEndian.PutUint32(buf[16:], 2)
+	binary.BigEndian.PutUint32(buf[8:], binary.BigEndian.Uint32(buf[8:])+indexV3FormatWidth)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf), sha256.New())
+
+	assert.EqualError(t, err, `gitobj/pack: v3 index has "s256" names only as a secondary object format`)
+	assert.Nil(t, idx)
+}
+
+func TestIndexV3MalformedHeader(t *testing.T) {
+	buf := encodeIndex(sha1.New, 3, indexedObjects(sha1.New, 12), nil)
+	binary.BigEndian.PutUint32(buf[16:], 0)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: malformed v3 index header")
+	assert.Nil(t, idx)
+}
+
+func TestVerifyPackV3Index(t *testing.T) {
+	v2, data := verifyFixture(t, nil)
+
+	idx, err := DecodeIndex(bytes.NewReader(v2), sha1.New())
+	require.NoError(t, err)
+
+	var objects []*indexedObject
+	require.NoError(t, idx.ForEach(func(name []byte) error {
+		e, err := idx.Entry(name)
+		if err != nil {
+			return err
+		}
+
+		at, err := idx.position(name)
+		if err != nil {
+			return err
+		}
+		crc := v2[indexOffsetV2Start+int64(sha1.Size*idx.Count())+indexObjectCRCWidth*at:]
+
+		objects = append(objects, &indexedObject{
+			name:   append([]byte(nil), name...),
+			offset: e.PackOffset,
+			crc:    binary.BigEndian.Uint32(crc),
+		})
+		return nil
+	}))
+
+	v3 := encodeIndex(sha1.New, 3, objects, data[len(data)-sha1.Size:])
+	assert.NoError(t, VerifyPack(bytes.NewReader(v3), bytes.NewReader(data), sha1.New))
+
+	// Corrupting a CRC should be noticed, as with version 2.
+	v3 = encodeIndex(sha1.New, 3, objects, data[len(data)-sha1.Size:])
+	trailer := len(v3) - 2*sha1.Size
+	v3[trailer-len(objects)*indexObjectSmallOffsetWidth-1] ^= 0xff
+	copy(v3[trailer+sha1.Size:], checksum(sha1.New(), v3[:trailer+sha1.Size]))
+
+	err = VerifyPack(bytes.NewReader(v3), bytes.NewReader(data), sha1.New)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gitobj/pack: CRC mismatch for object")
+}
+
+// indexedObject is an object described by an index fixture.
+type indexedObject struct {
+	name   []byte
+	offset uint64
+	crc    uint32
+}
+
+// indexedObjects returns objects at each of the given offsets, with names
+// computed using "hash", in order of their names.
+func indexedObjects(hash func() hash.Hash, offsets ...uint64) []*indexedObject {
+	var objects []*indexedObject
+	for i, offset := range offsets {
+		objects = append(objects, &indexedObject{
+			name:   checksum(hash(), []byte(fmt.Sprintf("object %d", i))),
+			offset: offset,
+			crc:    uint32(i),
+		})
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return bytes.Compare(objects[i].name, objects[j].name) < 0
+	})
+	return objects
+}
+
+// encodeIndex returns an index of the given version describing "objects", which
+// must be sorted by name, whose names are computed using "hash". The index ends
+// with the packfile checksum "sum" (or zeros if it is nil), followed by its own.
+func encodeIndex(hash func() hash.Hash, version uint32, objects []*indexedObject, sum []byte) []byte {
+	hashlen := hash().Size()
+	if sum == nil {
+		sum = make([]byte, hashlen)
+	}
+
+	// Objects are stored in the packfile in order of their offsets.
+	packed := append([]*indexedObject(nil), objects...)
+	sort.Slice(packed, func(i, j int) bool {
+		return packed[i].offset < packed[j].offset
+	})
+	pos := make(map[*indexedObject]uint32)
+	for i, o := range packed {
+		pos[o] = uint32(i)
+	}
+
+	var buf []byte
+	var large []uint64
+	offsets := func() {
+		for _, o := range objects {
+			if o.offset < 0x80000000 {
+				buf = appendUint32(buf, uint32(o.offset))
+				continue
+			}
+			buf = appendUint32(buf, 0x80000000|uint32(len(large)))
+			large = append(large, o.offset)
+		}
+		for _, offset := range large {
+			buf = appendUint32(buf, uint32(offset>>32))
+			buf = appendUint32(buf, uint32(offset))
+		}
+	}
+
+	switch version {
+	case 1, 2:
+		if version == 2 {
+			buf = append(buf, indexHeader...)
+			buf = appendUint32(buf, 2)
+		}
+		for i := 0; i < indexFanoutEntries; i++ {
+			var n uint32
+			for _, o := range objects {
+				if int(o.name[0]) <= i {
+					n++
+				}
+			}
+			buf = appendUint32(buf, n)
+		}
+
+		if version == 1 {
+			for _, o := range objects {
+				buf = appendUint32(buf, uint32(o.offset))
+				buf = append(buf, o.name...)
+			}
+			break
+		}
+
+		for _, o := range objects {
+			buf = append(buf, o.name...)
+		}
+		for _, o := range objects {
+			buf = appendUint32(buf, o.crc)
+		}
+		offsets()
+	case 3:
+		const short = 4
+		width := indexV3FixedWidth + indexV3FormatWidth + 4
+		count := len(objects)
+		tables := 4*count*(3+short/4) + hashlen*count
+
+		buf = append(buf, indexHeader...)
+		buf = appendUint32(buf, 3)
+		buf = appendUint32(buf, uint32(width))
+		buf = appendUint32(buf, uint32(count))
+		buf = appendUint32(buf, 1)
+		buf = append(buf, formatID(hash())...)
+		buf = appendUint32(buf, short)
+		buf = appendUint32(buf, uint32(width))
+
+		var nlarge int
+		for _, o := range objects {
+			if o.offset >= 0x80000000 {
+				nlarge++
+			}
+		}
+		buf = appendUint32(buf, uint32(width+tables+8*nlarge))
+
+		for _, o := range objects {
+			buf = append(buf, o.name[:short]...)
+		}
+		for _, o := range packed {
+			buf = append(buf, o.name...)
+		}
+		for _, o := range objects {
+			buf = appendUint32(buf, pos[o])
+		}
+		for _, o := range packed {
+			buf = appendUint32(buf, o.crc)
+		}
+		offsets()
+	}
+
+	buf = append(buf, sum...)
+	return append(buf, checksum(hash(), buf)...)
+}
@@ -0,0 +1,262 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// midxVersion is the only supported version of multi-pack-index
+	// files.
+	midxVersion = 1
+	// midxHeaderWidth is the width of the header of a multi-pack-index:
+	// its magic, version, hash function identifier, number of chunks,
+	// number of base files, and number of packfiles.
+	midxHeaderWidth = 12
+	// midxChunkWidth is the width of each entry in the chunk lookup
+	// table: a 4-byte identifier and an 8-byte offset.
+	midxChunkWidth = 12
+	// midxObjectOffsetWidth is the width of each entry in the object
+	// offsets chunk: the position of the object's packfile, and its
+	// offset within it.
+	midxObjectOffsetWidth = 8
+)
+
+var (
+	// midxHeader is the expected header that begins all valid
+	// multi-pack-index files.
+	midxHeader = []byte{'M', 'I', 'D', 'X'}
+
+	// errBadMultiPackIndexHeader is a sentinel error value returned when
+	// the given multi-pack-index header does not match the expected one.
+	errBadMultiPackIndexHeader = errors.New("gitobj/pack: bad multi-pack-index header")
+)
+
+// MultiPackIndex is an index of the objects in several packfiles at once, as
+// stored in the "multi-pack-index" file of an object database's "pack"
+// directory, which lets an object be found without searching the index of
+// every packfile. Objects stored in more than one of the packfiles are only
+// indexed in one of them.
+type MultiPackIndex struct {
+	// packs is the name (without its extension) of each packfile indexed.
+	packs []string
+	// fanout is the fanout table of the indexed objects, as in Index.
+	fanout []uint32
+	// hashlen is the length of the names of the indexed objects.
+	hashlen int64
+
+	// names, offsets, and large are the offsets of the OIDL, OOFF, and
+	// LOFF chunks, respectively. If there is no LOFF chunk, large is zero.
+	names, offsets, large int64
+	// largeEnd is the offset of the end of the LOFF chunk.
+	largeEnd int64
+
+	// r is the underlying set of encoded data comprising this file.
+	r io.ReaderAt
+}
+
+// MultiPackIndexEntry is the location of an object given by a MultiPackIndex.
+type MultiPackIndexEntry struct {
+	// PackName is the name (without its extension) of the packfile holding
+	// the object, such as "pack-<hash>".
+	PackName string
+	// PackOffset is the offset of the object within that packfile.
+	PackOffset uint64
+}
+
+// DecodeMultiPackIndex decodes a multi-pack-index whose underlying data is
+// supplied by "r", and which names objects using "hash".
+//
+// DecodeMultiPackIndex reads the header, the chunk lookup table, the names of
+// the packfiles, and the fanout table, but does not eagerly read the names or
+// offsets of the objects.
+//
+// If the file is malformed, of an unsupported version, uses another hash
+// function, or is missing a required chunk, an error is returned.
+func DecodeMultiPackIndex(r io.ReaderAt, hash hash.Hash) (*MultiPackIndex, error) {
+	header := make([]byte, midxHeaderWidth)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(header, midxHeader) {
+		return nil, errBadMultiPackIndexHeader
+	}
+	if version := header[4]; version != midxVersion {
+		return nil, &UnsupportedVersionErr{uint32(version)}
+	}
+
+	// Hash functions are identified as in the packfile's other files,
+	// with 1 for SHA-1 and 2 for SHA-256.
+	want := byte(1)
+	if hash.Size() == 32 {
+		want = 2
+	}
+	if id := header[5]; id != want {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index has unexpected hash function %d", id)
+	}
+	if bases := header[7]; bases != 0 {
+		return nil, fmt.Errorf("gitobj/pack: unsupported multi-pack-index with %d base files", bases)
+	}
+
+	chunks := int(header[6])
+	packs := int(binary.BigEndian.Uint32(header[8:]))
+
+	lookup := make([]byte, (chunks+1)*midxChunkWidth)
+	if _, err := r.ReadAt(lookup, midxHeaderWidth); err != nil {
+		return nil, err
+	}
+
+	// Each chunk extends to the beginning of the next, and the last to
+	// the offset given by the terminating entry.
+	bounds := make(map[string][2]int64, chunks)
+	for i := 0; i < chunks; i++ {
+		entry := lookup[i*midxChunkWidth:]
+		start := int64(binary.BigEndian.Uint64(entry[4:]))
+		end := int64(binary.BigEndian.Uint64(entry[midxChunkWidth+4:]))
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("gitobj/pack: multi-pack-index has malformed chunk %q", entry[:4])
+		}
+		bounds[string(entry[:4])] = [2]int64{start, end}
+	}
+
+	for _, id := range []string{"PNAM", "OIDF", "OIDL", "OOFF"} {
+		if _, ok := bounds[id]; !ok {
+			return nil, fmt.Errorf("gitobj/pack: multi-pack-index is missing chunk %q", id)
+		}
+	}
+
+	m := &MultiPackIndex{
+		hashlen: int64(hash.Size()),
+		names:   bounds["OIDL"][0],
+		offsets: bounds["OOFF"][0],
+		r:       r,
+	}
+	if loff, ok := bounds["LOFF"]; ok {
+		m.large, m.largeEnd = loff[0], loff[1]
+	}
+
+	pnam := bounds["PNAM"]
+	names := make([]byte, pnam[1]-pnam[0])
+	if _, err := r.ReadAt(names, pnam[0]); err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(names), "\x00") {
+		if len(name) > 0 {
+			m.packs = append(m.packs, strings.TrimSuffix(name, ".idx"))
+		}
+	}
+	if len(m.packs) != packs {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index names %d packfiles, expected %d", len(m.packs), packs)
+	}
+
+	fanout, err := decodeIndexFanout(r, bounds["OIDF"][0])
+	if err != nil {
+		return nil, err
+	}
+	m.fanout = fanout
+
+	if count := int64(m.Count()); bounds["OIDL"][1]-m.names != count*m.hashlen ||
+		bounds["OOFF"][1]-m.offsets != count*midxObjectOffsetWidth {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index has malformed chunks")
+	}
+	return m, nil
+}
+
+// Count returns the number of objects in the multi-pack-index.
+func (m *MultiPackIndex) Count() int {
+	return int(m.fanout[255])
+}
+
+// PackNames returns the name (without its extension) of each packfile indexed
+// by the multi-pack-index, in sorted order.
+func (m *MultiPackIndex) PackNames() []string {
+	return m.packs
+}
+
+// Entry returns the location of the object "name".
+//
+// If the object is not indexed, (nil, ErrNotFound) will be returned. If there
+// was an error searching for or parsing its entry, it will be returned as (nil,
+// err).
+func (m *MultiPackIndex) Entry(name []byte) (*MultiPackIndexEntry, error) {
+	if int64(len(name)) != m.hashlen {
+		return nil, errNotFound
+	}
+
+	var lo int
+	if name[0] > 0 {
+		lo = int(m.fanout[name[0]-1])
+	}
+	hi := int(m.fanout[name[0]])
+	if lo > hi || hi > m.Count() {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index fanout is inconsistent at %x", name)
+	}
+
+	var searchErr error
+	got := make([]byte, m.hashlen)
+	at := lo + sort.Search(hi-lo, func(k int) bool {
+		if _, err := m.r.ReadAt(got, m.names+int64(lo+k)*m.hashlen); err != nil {
+			searchErr = err
+			return true
+		}
+		return bytes.Compare(got, name) >= 0
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	if at >= hi {
+		return nil, errNotFound
+	}
+	if _, err := m.r.ReadAt(got, m.names+int64(at)*m.hashlen); err != nil {
+		return nil, err
+	} else if !bytes.Equal(got, name) {
+		return nil, errNotFound
+	}
+
+	var entry [midxObjectOffsetWidth]byte
+	if _, err := m.r.ReadAt(entry[:], m.offsets+int64(at)*midxObjectOffsetWidth); err != nil {
+		return nil, err
+	}
+
+	pack := binary.BigEndian.Uint32(entry[:])
+	if int(pack) >= len(m.packs) {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index has invalid packfile %d for %x", pack, name)
+	}
+
+	offset := uint64(binary.BigEndian.Uint32(entry[4:]))
+	if offset&0x80000000 > 0 {
+		// As in a version 2 index, the most significant bit of the
+		// offset marks it as the index of an 8-byte offset.
+		lo := m.large + int64(offset&0x7fffffff)*indexObjectLargeOffsetWidth
+		if m.large == 0 || lo+indexObjectLargeOffsetWidth > m.largeEnd {
+			return nil, fmt.Errorf("gitobj/pack: multi-pack-index has invalid large offset for %x", name)
+		}
+
+		var large [indexObjectLargeOffsetWidth]byte
+		if _, err := m.r.ReadAt(large[:], lo); err != nil {
+			return nil, err
+		}
+		offset = binary.BigEndian.Uint64(large[:])
+	}
+
+	return &MultiPackIndexEntry{
+		PackName:   m.packs[pack],
+		PackOffset: offset,
+	}, nil
+}
+
+// Close closes the multi-pack-index if the underlying data stream is closeable.
+// If so, it returns any error involved in closing.
+func (m *MultiPackIndex) Close() error {
+	if close, ok := m.r.(io.Closer); ok {
+		return close.Close()
+	}
+	return nil
+}
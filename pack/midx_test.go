@@ -0,0 +1,285 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeMultiPackIndex(t *testing.T) {
+	objects := indexedObjects(sha1.New, 12, 34, 1<<33, 56)
+	packs := []uint32{0, 1, 1, 0}
+
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(encodeMultiPackIndex(sha1.New, []string{"pack-a", "pack-b"}, objects, packs)), sha1.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, midx.Count())
+	assert.Equal(t, []string{"pack-a", "pack-b"}, midx.PackNames())
+
+	for i, o := range objects {
+		e, err := midx.Entry(o.name)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pack-a", "pack-b"}[packs[i]], e.PackName)
+		assert.Equal(t, o.offset, e.PackOffset)
+	}
+
+	e, err := midx.Entry(make([]byte, sha1.Size))
+	assert.True(t, IsNotFound(err))
+	assert.Nil(t, e)
+}
+
+func TestDecodeMultiPackIndexBadHeader(t *testing.T) {
+	buf := encodeMultiPackIndex(sha1.New, []string{"pack-a"}, indexedObjects(sha1.New, 12), nil)
+	copy(buf, "XDIM")
+
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.Equal(t, errBadMultiPackIndexHeader, err)
+	assert.Nil(t, midx)
+}
+
+func TestDecodeMultiPackIndexUnsupportedVersion(t *testing.T) {
+	buf := encodeMultiPackIndex(sha1.New, []string{"pack-a"}, indexedObjects(sha1.New, 12), nil)
+	buf[4] = 2
+
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: unsupported version: 2")
+	assert.Nil(t, midx)
+}
+
+func TestDecodeMultiPackIndexOtherHash(t *testing.T) {
+	buf := encodeMultiPackIndex(sha256.New, []string{"pack-a"}, indexedObjects(sha256.New, 12), nil)
+
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: multi-pack-index has unexpected hash function 2")
+	assert.Nil(t, midx)
+}
+
+func TestDecodeMultiPackIndexMissingChunk(t *testing.T) {
+	buf := encodeMultiPackIndex(sha1.New, []string{"pack-a"}, indexedObjects(sha1.New, 12), nil)
+	// Rename the OOFF chunk in the lookup table.
+	copy(buf[midxHeaderWidth+3*midxChunkWidth:], "XXXX")
+
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, `gitobj/pack: multi-pack-index is missing chunk "OOFF"`)
+	assert.Nil(t, midx)
+}
+
+func TestNewSetOpensMultiPackIndex(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	objects := objectsForIndex(t, idx)
+
+	// The same objects are stored in two packfiles, and the
+	// multi-pack-index names the second for each of them.
+	fixture := map[string][]byte{
+		"pack-a.idx":       idx,
+		"pack-a.pack":      data,
+		"pack-b.idx":       idx,
+		"pack-b.pack":      data,
+		"multi-pack-index": encodeMultiPackIndex(sha1.New, []string{"pack-a.idx", "pack-b.idx"}, objects, []uint32{1, 1}),
+	}
+
+	for desc, c := range map[string]struct {
+		mutate func(midx []byte) []byte
+		opened bool
+	}{
+		"valid":   {func(midx []byte) []byte { return midx }, true},
+		"corrupt": {func(midx []byte) []byte { return midx[:midxHeaderWidth] }, false},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gitobj-pack")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			pd := filepath.Join(dir, "pack")
+			require.NoError(t, os.Mkdir(pd, 0755))
+
+			for name, contents := range fixture {
+				if name == "multi-pack-index" {
+					contents = c.mutate(contents)
+				}
+				require.NoError(t, ioutil.WriteFile(filepath.Join(pd, name), contents, 0644))
+			}
+
+			set, err := NewSet(dir, sha1.New())
+			require.NoError(t, err)
+			defer set.Close()
+
+			assert.Equal(t, c.opened, set.midx != nil)
+
+			// Objects found by way of the multi-pack-index are the
+			// same as those found using the index of each packfile.
+			for _, o := range objects {
+				got, err := set.Object(o.name)
+				require.NoError(t, err)
+				gotData, err := got.Unpack()
+				require.NoError(t, err)
+
+				for _, p := range set.packs {
+					want, err := p.Object(o.name)
+					require.NoError(t, err)
+					wantData, err := want.Unpack()
+					require.NoError(t, err)
+
+					assert.Equal(t, wantData, gotData)
+				}
+			}
+
+			_, err = set.Object(make([]byte, sha1.Size))
+			assert.True(t, errors.IsNoSuchObject(err))
+		})
+	}
+}
+
+func TestSetObjectConsultsMultiPackIndex(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	objects := objectsForIndex(t, idx)
+
+	dir, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	// Swap the offsets of the two objects, so that it is visible which
+	// index was used to find them.
+	swapped := []*indexedObject{
+		{name: objects[0].name, offset: objects[1].offset},
+		{name: objects[1].name, offset: objects[0].offset},
+	}
+	for name, contents := range map[string][]byte{
+		"pack-a.idx":       idx,
+		"pack-a.pack":      data,
+		"multi-pack-index": encodeMultiPackIndex(sha1.New, []string{"pack-a.idx"}, swapped, nil),
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pd, name), contents, 0644))
+	}
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	got, err := set.Object(objects[0].name)
+	require.NoError(t, err)
+	gotData, err := got.Unpack()
+	require.NoError(t, err)
+
+	want, err := set.packs[0].Object(objects[1].name)
+	require.NoError(t, err)
+	wantData, err := want.Unpack()
+	require.NoError(t, err)
+
+	assert.Equal(t, wantData, gotData)
+}
+
+// objectsForIndex returns the objects described by the version 2 index "idx",
+// in order of their names.
+func objectsForIndex(t *testing.T, idx []byte) []*indexedObject {
+	i, err := DecodeIndex(bytes.NewReader(idx), sha1.New())
+	require.NoError(t, err)
+
+	var objects []*indexedObject
+	require.NoError(t, i.ForEach(func(name []byte) error {
+		e, err := i.Entry(name)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, &indexedObject{name: append([]byte(nil), name...), offset: e.PackOffset})
+		return nil
+	}))
+	return objects
+}
+
+// encodeMultiPackIndex returns a multi-pack-index of the packfiles "packs",
+// describing "objects", which must be sorted by name, whose names are computed
+// using "hash". Each object is stored in the packfile given by the
+// corresponding entry of "in", or the first if "in" is nil.
+func encodeMultiPackIndex(hash func() hash.Hash, packs []string, objects []*indexedObject, in []uint32) []byte {
+	var pnam []byte
+	for _, name := range packs {
+		pnam = append(append(pnam, name...), 0)
+	}
+	for len(pnam)%4 != 0 {
+		pnam = append(pnam, 0)
+	}
+
+	var oidf, oidl, ooff, loff []byte
+	for i := 0; i < indexFanoutEntries; i++ {
+		var n uint32
+		for _, o := range objects {
+			if int(o.name[0]) <= i {
+				n++
+			}
+		}
+		oidf = appendUint32(oidf, n)
+	}
+	for i, o := range objects {
+		oidl = append(oidl, o.name...)
+
+		var pack uint32
+		if in != nil {
+			pack = in[i]
+		}
+		ooff = appendUint32(ooff, pack)
+		if o.offset < 0x80000000 {
+			ooff = appendUint32(ooff, uint32(o.offset))
+			continue
+		}
+		ooff = appendUint32(ooff, 0x80000000|uint32(len(loff)/indexObjectLargeOffsetWidth))
+		loff = appendUint32(loff, uint32(o.offset>>32))
+		loff = appendUint32(loff, uint32(o.offset))
+	}
+
+	chunks := []struct {
+		id   string
+		data []byte
+	}{{"PNAM", pnam}, {"OIDF", oidf}, {"OIDL", oidl}, {"OOFF", ooff}}
+	if len(loff) > 0 {
+		chunks = append(chunks, struct {
+			id   string
+			data []byte
+		}{"LOFF", loff})
+	}
+
+	buf := append([]byte(nil), midxHeader...)
+	buf = append(buf, midxVersion, 1, byte(len(chunks)), 0)
+	if hash().Size() == sha256.Size {
+		buf[5] = 2
+	}
+	buf = appendUint32(buf, uint32(len(packs)))
+
+	at := uint64(midxHeaderWidth + (len(chunks)+1)*midxChunkWidth)
+	var lookup, body []byte
+	for _, c := range chunks {
+		lookup = append(lookup, c.id...)
+		lookup = appendUint64(lookup, at)
+		body = append(body, c.data...)
+		at += uint64(len(c.data))
+	}
+	lookup = append(lookup, 0, 0, 0, 0)
+	lookup = appendUint64(lookup, at)
+
+	buf = append(append(buf, lookup...), body...)
+	return append(buf, checksum(hash(), buf)...)
+}
+
+// appendUint64 appends the big-endian encoding of "n" to "buf".
+func appendUint64(buf []byte, n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return append(buf, b[:]...)
+}
@@ -6,6 +6,7 @@ import (
 	"hash"
 	"io"
 	"io/ioutil"
+	"sync"
 )
 
 // Packfile encapsulates the behavior of accessing an unpacked representation of
@@ -18,14 +19,54 @@ type Packfile struct {
 	// idx is the corresponding "pack-*.idx" file giving the positions of
 	// objects in this packfile.
 	idx *Index
+	// bitmap is the corresponding "pack-*.bitmap" file giving the objects
+	// reachable from a selection of commits in this packfile, or nil if
+	// there is none.
+	bitmap *Bitmap
+	// openBitmap, if non-nil, opens the bitmap, or returns nil if there is
+	// no usable one. It is called when the bitmap is first needed, rather
+	// than when the packfile is opened, since the whole of the bitmap is
+	// read and checked. It is called at most once, guarded by bitmapOnce.
+	openBitmap func() *Bitmap
+	bitmapOnce sync.Once
 
 	// hash is the hash algorithm used in this pack.
 	hash hash.Hash
 
+	// maxDeltaDepth is the maximum number of deltas followed when
+	// resolving an object, or zero to use DefaultMaxDeltaDepth.
+	maxDeltaDepth int
+
 	// r is an io.ReaderAt that allows read access to the packfile itself.
 	r io.ReaderAt
 }
 
+// DefaultMaxDeltaDepth is the maximum number of deltas followed when resolving
+// a packed object, unless otherwise configured. It matches the greatest depth
+// of the delta chains which Git will write, so that any packfile written by
+// Git can be read, including those written with a depth well beyond Git's
+// default of 50 (such as by "git gc --aggressive").
+const DefaultMaxDeltaDepth = 4095
+
+// MaxDeltaDepth returns the maximum number of deltas followed when resolving an
+// object in this packfile.
+func (p *Packfile) MaxDeltaDepth() int {
+	if p.maxDeltaDepth <= 0 {
+		return DefaultMaxDeltaDepth
+	}
+	return p.maxDeltaDepth
+}
+
+// SetMaxDeltaDepth sets the maximum number of deltas followed when resolving an
+// object in this packfile, beyond which the object is treated as corrupt. A
+// depth of zero (or less) restores the default, DefaultMaxDeltaDepth.
+//
+// Limiting the depth protects against packfiles crafted with pathologically
+// deep (or cyclic) delta chains.
+func (p *Packfile) SetMaxDeltaDepth(depth int) {
+	p.maxDeltaDepth = depth
+}
+
 // Close closes the packfile if the underlying data stream is closeable. If so,
 // it returns any error involved in closing.
 func (p *Packfile) Close() error {
@@ -66,7 +107,13 @@ func (p *Packfile) Object(name []byte) (*Object, error) {
 	}
 
 	// If all goes well, then unpack the object at that given offset.
-	r, err := p.find(int64(entry.PackOffset))
+	return p.objectAt(int64(entry.PackOffset))
+}
+
+// objectAt returns a reference to the object which begins at "offset" in the
+// receiving *Packfile, without unpacking it.
+func (p *Packfile) objectAt(offset int64) (*Object, error) {
+	r, err := p.find(offset)
 	if err != nil {
 		return nil, err
 	}
@@ -82,63 +129,71 @@ func (p *Packfile) Object(name []byte) (*Object, error) {
 //
 // If find returns a ChainBase, it loads that data into memory, but does not
 // zlib-flate it. Otherwise, if find returns a ChainDelta, it loads all of the
-// leading elements in the chain recursively, but does not apply one delta to
-// another.
+// leading elements in the chain, but does not apply one delta to another.
+//
+// The chain is followed iteratively, and if it contains more deltas than the
+// packfile's maximum delta depth (see: SetMaxDeltaDepth), a *DeltaDepthErr is
+// returned instead.
 func (p *Packfile) find(offset int64) (Chain, error) {
-	// Read the first byte in the chain element.
-	buf := make([]byte, 1)
-	if _, err := p.r.ReadAt(buf, offset); err != nil {
-		return nil, err
-	}
+	// deltas holds the offset of the (compressed) instructions of each
+	// delta in the chain, beginning with the last.
+	var deltas []int64
 
-	// Store the original offset; this will be compared to when loading
-	// chain elements of type OBJ_OFS_DELTA.
-	objectOffset := offset
+	var base Chain
+	for base == nil {
+		// Store the original offset; this will be compared to when
+		// loading chain elements of type OBJ_OFS_DELTA.
+		objectOffset := offset
 
-	// Of the first byte, (0123 4567):
-	//   - Bit 0 is the M.S.B., and indicates whether there is more data
-	//     encoded in the length.
-	//   - Bits 1-3 ((buf[0] >> 4) & 0x7) are the object type.
-	//   - Bits 4-7 (buf[0] & 0xf) are the first 4 bits of the variable
-	//     length size of the encoded delta or base.
-	typ := PackedObjectType((buf[0] >> 4) & 0x7)
-	size := uint64(buf[0] & 0xf)
-	shift := uint(4)
-	offset += 1
-
-	for buf[0]&0x80 != 0 {
-		// If there is more data to be read, read it.
-		if _, err := p.r.ReadAt(buf, offset); err != nil {
+		typ, size, dataOffset, err := p.readHeader(offset)
+		if err != nil {
 			return nil, err
 		}
 
-		// And update the size, bitshift, and offset accordingly.
-		size |= (uint64(buf[0]&0x7f) << shift)
-		shift += 7
-		offset += 1
-	}
+		switch typ {
+		case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
+			// If the type of delta-base element is a delta,
+			// (either OBJ_OFS_DELTA, or OBJ_REFS_DELTA), we must
+			// load the base, which itself could be either of the
+			// two above, or a OBJ_COMMIT, OBJ_BLOB, etc.
+			if max := p.MaxDeltaDepth(); len(deltas) >= max {
+				return nil, &DeltaDepthErr{Max: max}
+			}
 
-	switch typ {
-	case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
-		// If the type of delta-base element is a delta, (either
-		// OBJ_OFS_DELTA, or OBJ_REFS_DELTA), we must load the base,
-		// which itself could be either of the two above, or a
-		// OBJ_COMMIT, OBJ_BLOB, etc.
-		//
-		// Recursively load the base, and keep track of the updated
-		// offset.
-		base, offset, err := p.findBase(typ, offset, objectOffset)
-		if err != nil {
-			return nil, err
+			baseOffset, deltaOffset, err := p.findBase(typ, dataOffset, objectOffset)
+			if err != nil {
+				return nil, err
+			}
+
+			deltas = append(deltas, deltaOffset)
+			offset = baseOffset
+		case TypeCommit, TypeTree, TypeBlob, TypeTag:
+			// Otherwise, the object's contents are given to be the
+			// following zlib-compressed data.
+			//
+			// The length of the compressed data itself is not
+			// known, rather, "size" determines the length of the
+			// data after inflation.
+			base = &ChainBase{
+				offset: dataOffset,
+				size:   int64(size),
+				typ:    typ,
+
+				r: p.r,
+			}
+		default:
+			// Otherwise, we received an invalid object type.
+			return nil, errUnrecognizedObjectType
 		}
+	}
 
-		// Now load the delta to apply to the base, given at the offset
-		// "offset" and for length "size".
-		//
+	// Now load each delta to apply, beginning with the one closest to the
+	// base.
+	for i := len(deltas) - 1; i >= 0; i-- {
 		// NB: The delta instructions are zlib compressed, so ensure
 		// that we uncompress the instructions first.
 		zr, err := zlib.NewReader(&OffsetReaderAt{
-			o: offset,
+			o: deltas[i],
 			r: p.r,
 		})
 		if err != nil {
@@ -150,39 +205,60 @@ func (p *Packfile) find(offset int64) (Chain, error) {
 			return nil, err
 		}
 
-		// Then compose the two and return it as a *ChainDelta.
-		return &ChainDelta{
+		// Then compose the two as a *ChainDelta.
+		base = &ChainDelta{
 			base:  base,
 			delta: delta,
-		}, nil
-	case TypeCommit, TypeTree, TypeBlob, TypeTag:
-		// Otherwise, the object's contents are given to be the
-		// following zlib-compressed data.
-		//
-		// The length of the compressed data itself is not known,
-		// rather, "size" determines the length of the data after
-		// inflation.
-		return &ChainBase{
-			offset: offset,
-			size:   int64(size),
-			typ:    typ,
+		}
+	}
+	return base, nil
+}
 
-			r: p.r,
-		}, nil
+// readHeader reads the header of the chain element at the given offset, and
+// returns its type, its (inflated) size, and the offset of the data following
+// the header.
+func (p *Packfile) readHeader(offset int64) (PackedObjectType, uint64, int64, error) {
+	// Read the first byte in the chain element.
+	buf := make([]byte, 1)
+	if _, err := p.r.ReadAt(buf, offset); err != nil {
+		return TypeNone, 0, offset, err
 	}
-	// Otherwise, we received an invalid object type.
-	return nil, errUnrecognizedObjectType
+
+	// Of the first byte, (0123 4567):
+	//   - Bit 0 is the M.S.B., and indicates whether there is more data
+	//     encoded in the length.
+	//   - Bits 1-3 ((buf[0] >> 4) & 0x7) are the object type.
+	//   - Bits 4-7 (buf[0] & 0xf) are the first 4 bits of the variable
+	//     length size of the encoded delta or base.
+	typ := PackedObjectType((buf[0] >> 4) & 0x7)
+	size := uint64(buf[0] & 0xf)
+	shift := uint(4)
+	offset += 1
+
+	for buf[0]&0x80 != 0 {
+		// If there is more data to be read, read it.
+		if _, err := p.r.ReadAt(buf, offset); err != nil {
+			return TypeNone, 0, offset, err
+		}
+
+		// And update the size, bitshift, and offset accordingly.
+		size |= (uint64(buf[0]&0x7f) << shift)
+		shift += 7
+		offset += 1
+	}
+	return typ, size, offset, nil
 }
 
 // findBase finds the base (an object, or another delta) for a given
 // OBJ_OFS_DELTA or OBJ_REFS_DELTA at the given offset.
 //
-// It returns the preceding Chain, as well as an updated read offset into the
-// underlying packfile data.
+// It returns the offset of the preceding chain element, as well as an updated
+// read offset into the underlying packfile data, at which the delta
+// instructions begin.
 //
 // If any of the above could not be completed successfully, findBase returns an
 // error.
-func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chain, int64, error) {
+func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (int64, int64, error) {
 	var baseOffset int64
 
 	hashlen := p.hash.Size()
@@ -192,7 +268,7 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 	// length of the base offset encoded in an OBJ_OFS_DELTA).
 	var sha [MaxHashSize]byte
 	if _, err := p.r.ReadAt(sha[:hashlen], offset); err != nil {
-		return nil, baseOffset, err
+		return baseOffset, offset, err
 	}
 
 	switch typ {
@@ -206,6 +282,10 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 
 		for c&0x80 != 0 {
 			i += 1
+			if i >= hashlen {
+				return baseOffset, offset, fmt.Errorf(
+					"gitobj/pack: delta base offset too long")
+			}
 			c = int64(sha[i])
 
 			baseOffset += 1
@@ -213,6 +293,14 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 			baseOffset |= c & 0x7f
 		}
 
+		// The base of an OBJ_OFS_DELTA always precedes it in the
+		// packfile. Refuse anything else, which could otherwise form a
+		// cycle.
+		if baseOffset <= 0 || baseOffset > objOffset {
+			return baseOffset, offset, fmt.Errorf(
+				"gitobj/pack: delta base offset out of bounds: %d", baseOffset)
+		}
+
 		baseOffset = objOffset - baseOffset
 		offset += int64(i) + 1
 	case TypeObjectReferenceDelta:
@@ -221,7 +309,7 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 		// corresponding pack index file.
 		e, err := p.idx.Entry(sha[:hashlen])
 		if err != nil {
-			return nil, baseOffset, err
+			return baseOffset, offset, err
 		}
 
 		baseOffset = int64(e.PackOffset)
@@ -229,12 +317,8 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 	default:
 		// If we did not receive an OBJ_OFS_DELTA, or OBJ_REF_DELTA, the
 		// type given is not a delta-fied type. Return an error.
-		return nil, baseOffset, fmt.Errorf(
+		return baseOffset, offset, fmt.Errorf(
 			"gitobj/pack: type %s is not deltafied", typ)
 	}
-
-	// Once we have determined the base offset of the object's chain base,
-	// read the delta-base chain beginning at that offset.
-	r, err := p.find(baseOffset)
-	return r, offset, err
+	return baseOffset, offset, nil
 }
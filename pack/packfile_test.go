@@ -108,8 +108,8 @@ func TestPackObjectReturnsObjectWithDeltaBaseOffset(t *testing.T) {
 
 			0x35, // (0011 0101) (msb=0, type=blob, size=5)
 		}, compressed...), append([]byte{
-			0x6e, // (0110 1010) (msb=0, type=obj_ofs_delta, size=10)
-			0x12, // (0001 0001) (ofs_delta=-17, len(compressed))
+			0x6e,                      // (0110 1010) (msb=0, type=obj_ofs_delta, size=10)
+			byte(1 + len(compressed)), // (ofs_delta=-(1+len(compressed)))
 		}, delta...)...)),
 		hash: sha1.New(),
 	}
@@ -147,7 +147,7 @@ func TestPackfileObjectReturnsObjectWithDeltaBaseReference(t *testing.T) {
 	p := &Packfile{
 		idx: IndexWith(map[string]uint32{
 			"cccccccccccccccccccccccccccccccccccccccc": 32,
-			"dddddddddddddddddddddddddddddddddddddddd": 52,
+			"dddddddddddddddddddddddddddddddddddddddd": uint32(32 + 1 + len(compressed)),
 		}),
 		r: bytes.NewReader(append(append([]byte{
 			0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
@@ -278,3 +278,300 @@ func DecodeHex(t *testing.T, str string) []byte {
 
 	return b
 }
+
+func TestPackfileObjectRejectsDeltaBaseOffsetOutOfBounds(t *testing.T) {
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"cccccccccccccccccccccccccccccccccccccccc": 1,
+		}),
+		r: bytes.NewReader([]byte{
+			0x0,
+			0x6e, // (0110 1010) (msb=0, type=obj_ofs_delta, size=10)
+			0x02, // (0000 0010) (ofs_delta=-2, before the packfile)
+
+			0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+		}),
+		hash: sha1.New(),
+	}
+
+	o, err := p.Object(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"))
+
+	assert.EqualError(t, err, "gitobj/pack: delta base offset out of bounds: 2")
+	assert.Nil(t, o)
+}
+
+func TestPackfileObjectResolvesDeltaChainAtMaxDepth(t *testing.T) {
+	p, name := deltaChainPackfile(t, DefaultMaxDeltaDepth)
+
+	o, err := p.Object(DecodeHex(t, name))
+	assert.NoError(t, err)
+
+	assert.Equal(t, TypeBlob, o.Type())
+
+	unpacked, err := o.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, "a"+strings.Repeat("x", DefaultMaxDeltaDepth), string(unpacked))
+}
+
+func TestPackfileObjectRejectsDeltaChainBeyondMaxDepth(t *testing.T) {
+	p, name := deltaChainPackfile(t, DefaultMaxDeltaDepth+1)
+
+	o, err := p.Object(DecodeHex(t, name))
+
+	assert.Equal(t, &DeltaDepthErr{Max: DefaultMaxDeltaDepth}, err)
+	assert.EqualError(t, err, "gitobj/pack: delta chain exceeds maximum depth of 4095")
+	assert.Nil(t, o)
+}
+
+func TestPackfileObjectResolvesDeepDeltaChainWithHigherMaxDepth(t *testing.T) {
+	p, name := deltaChainPackfile(t, 5000)
+	p.SetMaxDeltaDepth(5000)
+
+	o, err := p.Object(DecodeHex(t, name))
+	assert.NoError(t, err)
+
+	unpacked, err := o.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, "a"+strings.Repeat("x", 5000), string(unpacked))
+}
+
+func TestPackfileObjectRejectsDeltaChainBeyondLowerMaxDepth(t *testing.T) {
+	p, name := deltaChainPackfile(t, 51)
+	p.SetMaxDeltaDepth(50)
+
+	o, err := p.Object(DecodeHex(t, name))
+
+	assert.EqualError(t, err, "gitobj/pack: delta chain exceeds maximum depth of 50")
+	assert.Nil(t, o)
+}
+
+func TestPackfileObjectRejectsDeltaCycles(t *testing.T) {
+	const sha = "cccccccccccccccccccccccccccccccccccccccc"
+
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			sha: 0,
+		}),
+		r: bytes.NewReader([]byte{
+			0x70, // (0111 0000) (msb=0, type=obj_ref_delta, size=0)
+
+			// SHA-1 "cccccccccccccccccccccccccccccccccccccccc", which
+			// is this object itself.
+			0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc,
+			0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc,
+		}),
+		hash: sha1.New(),
+	}
+	p.SetMaxDeltaDepth(10)
+
+	o, err := p.Object(DecodeHex(t, sha))
+
+	assert.Equal(t, &DeltaDepthErr{Max: 10}, err)
+	assert.Nil(t, o)
+}
+
+func TestPackfileObjectResolvesMixedDeltaChain(t *testing.T) {
+	p, name := mixedDeltaChainPackfile(t, 7)
+
+	o, err := p.Object(DecodeHex(t, name))
+	assert.NoError(t, err)
+
+	assert.Equal(t, TypeBlob, o.Type())
+
+	unpacked, err := o.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, "a"+strings.Repeat("x", 7), string(unpacked))
+
+	// The size of the resolved object is known without resolving it.
+	e, err := p.idx.Entry(DecodeHex(t, name))
+	assert.NoError(t, err)
+
+	typ, size, err := p.sizeAt(int64(e.PackOffset))
+	assert.NoError(t, err)
+	assert.Equal(t, TypeBlob, typ)
+	assert.EqualValues(t, len(unpacked), size)
+}
+
+func TestPackfileObjectRejectsMixedDeltaChainBeyondMaxDepth(t *testing.T) {
+	p, name := mixedDeltaChainPackfile(t, 7)
+	p.SetMaxDeltaDepth(6)
+
+	o, err := p.Object(DecodeHex(t, name))
+
+	assert.Equal(t, &DeltaDepthErr{Max: 6}, err)
+	assert.Nil(t, o)
+}
+
+func TestPackfileObjectRejectsReferenceDeltaCycles(t *testing.T) {
+	const (
+		a = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		b = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+
+	// Each object is an OBJ_REF_DELTA (with size=0) against the other.
+	data := append([]byte{0x70}, DecodeHex(t, b)...)
+	data = append(data, 0x70)
+	data = append(data, DecodeHex(t, a)...)
+
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			a: 0,
+			b: uint32(1 + sha1.Size),
+		}),
+		r:    bytes.NewReader(data),
+		hash: sha1.New(),
+	}
+	p.SetMaxDeltaDepth(10)
+
+	o, err := p.Object(DecodeHex(t, a))
+
+	assert.Equal(t, &DeltaDepthErr{Max: 10}, err)
+	assert.Nil(t, o)
+}
+
+func TestPackfileMaxDeltaDepthDefault(t *testing.T) {
+	p := new(Packfile)
+	assert.Equal(t, DefaultMaxDeltaDepth, p.MaxDeltaDepth())
+
+	p.SetMaxDeltaDepth(10)
+	assert.Equal(t, 10, p.MaxDeltaDepth())
+
+	p.SetMaxDeltaDepth(0)
+	assert.Equal(t, DefaultMaxDeltaDepth, p.MaxDeltaDepth())
+}
+
+// deltaChainPackfile returns a packfile containing the blob "a", followed by a
+// chain of "depth" OBJ_OFS_DELTAs, each of which appends an "x" to its base,
+// along with the name under which the last is indexed.
+func deltaChainPackfile(t *testing.T, depth int) (*Packfile, string) {
+	const name = "dddddddddddddddddddddddddddddddddddddddd"
+
+	base, err := compress("a")
+	if err != nil {
+		t.Fatalf("gitobj/pack: unexpected compress error: %s", err)
+	}
+
+	data := append([]byte{0x31}, base...) // (msb=0, type=blob, size=1)
+
+	var offset int
+	for size := 1; size <= depth; size++ {
+		instructions := appendX(size)
+
+		delta, err := compress(string(instructions))
+		if err != nil {
+			t.Fatalf("gitobj/pack: unexpected compress error: %s", err)
+		}
+
+		next := len(data)
+		data = append(data, objectHeader(TypeObjectOffsetDelta, len(instructions))...)
+		data = append(data, deltaOffset(next-offset)...)
+		data = append(data, delta...)
+
+		offset = next
+	}
+
+	return &Packfile{
+		idx: IndexWith(map[string]uint32{
+			name: uint32(offset),
+		}),
+		r:    bytes.NewReader(data),
+		hash: sha1.New(),
+	}, name
+}
+
+// mixedDeltaChainPackfile returns a packfile like that of deltaChainPackfile,
+// except that every other delta in the chain is an OBJ_REF_DELTA, and each
+// element of the chain is indexed. The name under which the last is indexed is
+// returned.
+func mixedDeltaChainPackfile(t *testing.T, depth int) (*Packfile, string) {
+	base, err := compress("a")
+	if err != nil {
+		t.Fatalf("gitobj/pack: unexpected compress error: %s", err)
+	}
+
+	names := map[string]uint32{}
+	name := func(size int) string { return fmt.Sprintf("%040x", size) }
+
+	data := append([]byte{0x31}, base...) // (msb=0, type=blob, size=1)
+	names[name(0)] = 0
+
+	for size := 1; size <= depth; size++ {
+		instructions := appendX(size)
+
+		delta, err := compress(string(instructions))
+		if err != nil {
+			t.Fatalf("gitobj/pack: unexpected compress error: %s", err)
+		}
+
+		next := len(data)
+		if size%2 == 0 {
+			data = append(data, objectHeader(TypeObjectReferenceDelta, len(instructions))...)
+			data = append(data, DecodeHex(t, name(size-1))...)
+		} else {
+			data = append(data, objectHeader(TypeObjectOffsetDelta, len(instructions))...)
+			data = append(data, deltaOffset(next-int(names[name(size-1)]))...)
+		}
+		data = append(data, delta...)
+
+		names[name(size)] = uint32(next)
+	}
+
+	return &Packfile{
+		idx:  IndexWith(names),
+		r:    bytes.NewReader(data),
+		hash: sha1.New(),
+	}, name(depth)
+}
+
+// appendX returns delta instructions which append an "x" to a base of the
+// given size.
+func appendX(size int) []byte {
+	var instructions []byte
+	instructions = append(instructions, deltaSize(size)...)
+	instructions = append(instructions, deltaSize(size+1)...)
+	for copied := 0; copied < size; copied += 0xff {
+		n := size - copied
+		if n > 0xff {
+			n = 0xff
+		}
+		// (copy, smask=0001, omask=0011)
+		instructions = append(instructions,
+			0x93, byte(copied), byte(copied>>8), byte(n))
+	}
+	return append(instructions, 0x1, 'x') // (add, length=1)
+}
+
+// objectHeader encodes the header of a packed object of the given type and
+// size.
+func objectHeader(typ PackedObjectType, size int) []byte {
+	hdr := []byte{byte(typ)<<4 | byte(size&0xf)}
+	for size >>= 4; size > 0; size >>= 7 {
+		hdr[len(hdr)-1] |= 0x80
+		hdr = append(hdr, byte(size&0x7f))
+	}
+	return hdr
+}
+
+// deltaSize encodes a source or destination size in delta instructions.
+func deltaSize(size int) []byte {
+	var buf []byte
+	for {
+		b := byte(size & 0x7f)
+		if size >>= 7; size > 0 {
+			buf = append(buf, b|0x80)
+			continue
+		}
+		return append(buf, b)
+	}
+}
+
+// deltaOffset encodes the distance to the base of an OBJ_OFS_DELTA.
+func deltaOffset(distance int) []byte {
+	buf := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		buf = append([]byte{0x80 | byte(distance&0x7f)}, buf...)
+	}
+	return buf
+}
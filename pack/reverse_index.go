@@ -0,0 +1,147 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+const (
+	// reverseIndexVersion is the only supported version of reverse index
+	// files.
+	reverseIndexVersion = 1
+	// reverseIndexHeaderWidth is the width of the header of a reverse
+	// index: its magic, version, and hash function identifier.
+	reverseIndexHeaderWidth = 12
+)
+
+var (
+	// reverseIndexHeader is the expected header that begins all valid
+	// reverse index files.
+	reverseIndexHeader = []byte{'R', 'I', 'D', 'X'}
+
+	// errBadReverseIndexHeader is a sentinel error value returned when the
+	// given reverse index header does not match the expected one.
+	errBadReverseIndexHeader = errors.New("gitobj/pack: bad reverse index header")
+)
+
+// ReverseIndex is a reverse index corresponding to a single packfile, as stored
+// in a "pack-*.rev" file. It lists the position in the packfile's index of each
+// object, in the order in which the objects appear in the packfile, so that an
+// object can be found by its offset without sorting the index.
+type ReverseIndex struct {
+	// positions is the position in the index of each object, ordered by
+	// its offset in the packfile.
+	positions []uint32
+	// packSum is the checksum of the corresponding packfile.
+	packSum []byte
+}
+
+// DecodeReverseIndex decodes a reverse index whose underlying data is supplied
+// by "r", and which refers to objects named using "hash".
+//
+// DecodeReverseIndex reads the entire file, and checks its trailing checksum,
+// for which "hash" is reset before and after use.
+//
+// If the file is malformed, of an unsupported version, uses another hash
+// function, or does not match its checksum, an error is returned.
+func DecodeReverseIndex(r io.ReaderAt, hash hash.Hash) (*ReverseIndex, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+
+	hashlen := hash.Size()
+	if len(data) < reverseIndexHeaderWidth+2*hashlen {
+		return nil, fmt.Errorf("gitobj/pack: reverse index too short: %d bytes", len(data))
+	}
+	if !bytes.HasPrefix(data, reverseIndexHeader) {
+		return nil, errBadReverseIndexHeader
+	}
+	if version := binary.BigEndian.Uint32(data[4:]); version != reverseIndexVersion {
+		return nil, &UnsupportedVersionErr{version}
+	}
+
+	// Hash functions are identified as in the packfile's other files,
+	// with 1 for SHA-1 and 2 for SHA-256.
+	want := uint32(1)
+	if hashlen == 32 {
+		want = 2
+	}
+	if id := binary.BigEndian.Uint32(data[8:]); id != want {
+		return nil, fmt.Errorf("gitobj/pack: reverse index has unexpected hash function %d", id)
+	}
+
+	trailer := len(data) - 2*hashlen
+
+	hash.Reset()
+	hash.Write(data[:trailer+hashlen])
+	sum := hash.Sum(nil)
+	hash.Reset()
+
+	if !bytes.Equal(sum, data[trailer+hashlen:]) {
+		return nil, fmt.Errorf("gitobj/pack: reverse index checksum mismatch")
+	}
+
+	table := data[reverseIndexHeaderWidth:trailer]
+	if len(table)%4 != 0 {
+		return nil, fmt.Errorf("gitobj/pack: reverse index has malformed table")
+	}
+
+	rev := &ReverseIndex{
+		positions: make([]uint32, len(table)/4),
+		packSum:   data[trailer : trailer+hashlen],
+	}
+	for i := range rev.positions {
+		rev.positions[i] = binary.BigEndian.Uint32(table[4*i:])
+	}
+	return rev, nil
+}
+
+// Count returns the number of objects in the reverse index.
+func (r *ReverseIndex) Count() int {
+	return len(r.positions)
+}
+
+// OidAtOffset returns the name of the object which begins at the offset "off"
+// in the packfile. Objects are found using the packfile's reverse index if it
+// has one, and otherwise by ordering its index by offset, which is computed
+// once.
+//
+// If no object begins at that offset, an error is returned.
+func (p *Packfile) OidAtOffset(off int64) ([]byte, error) {
+	order, err := p.idx.offsetOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var searchErr error
+	k := sort.Search(len(order), func(k int) bool {
+		entry, err := p.idx.version.Entry(p.idx, int64(order[k]))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return int64(entry.PackOffset) >= off
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	if k < len(order) {
+		entry, err := p.idx.version.Entry(p.idx, int64(order[k]))
+		if err != nil {
+			return nil, err
+		}
+		if int64(entry.PackOffset) == off {
+			return p.idx.version.Name(p.idx, int64(order[k]))
+		}
+	}
+	return nil, fmt.Errorf("gitobj/pack: no object at offset %d", off)
+}
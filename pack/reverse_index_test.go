@@ -0,0 +1,182 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeReverseIndex(t *testing.T) {
+	objects := indexedObjects(sha1.New, 300, 12, 200)
+
+	rev, err := DecodeReverseIndex(bytes.NewReader(encodeReverseIndex(sha1.New, objects, nil)), sha1.New())
+	require.NoError(t, err)
+
+	require.Equal(t, 3, rev.Count())
+	for k, pos := range rev.positions {
+		if k > 0 {
+			assert.True(t, objects[rev.positions[k-1]].offset < objects[pos].offset)
+		}
+	}
+}
+
+func TestDecodeReverseIndexBadHeader(t *testing.T) {
+	buf := encodeReverseIndex(sha1.New, indexedObjects(sha1.New, 12), nil)
+	copy(buf, "XDIR")
+
+	rev, err := DecodeReverseIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.Equal(t, errBadReverseIndexHeader, err)
+	assert.Nil(t, rev)
+}
+
+func TestDecodeReverseIndexUnsupportedVersion(t *testing.T) {
+	buf := encodeReverseIndex(sha1.New, indexedObjects(sha1.New, 12), nil)
+	binary.BigEndian.PutUint32(buf[4:], 2)
+
+	rev, err := DecodeReverseIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: unsupported version: 2")
+	assert.Nil(t, rev)
+}
+
+func TestDecodeReverseIndexOtherHash(t *testing.T) {
+	buf := encodeReverseIndex(sha256.New, indexedObjects(sha256.New, 12), nil)
+
+	rev, err := DecodeReverseIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: reverse index has unexpected hash function 2")
+	assert.Nil(t, rev)
+}
+
+func TestDecodeReverseIndexChecksumMismatch(t *testing.T) {
+	buf := encodeReverseIndex(sha1.New, indexedObjects(sha1.New, 12, 34), nil)
+	buf[reverseIndexHeaderWidth+3] ^= 0x1
+
+	rev, err := DecodeReverseIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: reverse index checksum mismatch")
+	assert.Nil(t, rev)
+}
+
+func TestPackfileOidAtOffset(t *testing.T) {
+	objects := indexedObjects(sha1.New, 300, 12, 200, 1<<33)
+
+	for desc, reverse := range map[string]bool{"computed": false, "reverse index": true} {
+		t.Run(desc, func(t *testing.T) {
+			idx, err := DecodeIndex(bytes.NewReader(encodeIndex(sha1.New, 2, objects, nil)), sha1.New())
+			require.NoError(t, err)
+
+			if reverse {
+				idx.reverse, err = DecodeReverseIndex(bytes.NewReader(encodeReverseIndex(sha1.New, objects, nil)), sha1.New())
+				require.NoError(t, err)
+			}
+
+			p := &Packfile{idx: idx, hash: sha1.New()}
+			for _, o := range objects {
+				name, err := p.OidAtOffset(int64(o.offset))
+				require.NoError(t, err)
+				assert.Equal(t, o.name, name)
+			}
+
+			for _, off := range []int64{0, 13, 1 << 34} {
+				name, err := p.OidAtOffset(off)
+				assert.EqualError(t, err, fmt.Sprintf("gitobj/pack: no object at offset %d", off))
+				assert.Nil(t, name)
+			}
+		})
+	}
+}
+
+func TestNewSetOpensReverseIndex(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+
+	for desc, c := range map[string]struct {
+		mutate func(rev []byte) []byte
+		opened bool
+	}{
+		"valid":    {func(rev []byte) []byte { return rev }, true},
+		"corrupt":  {func(rev []byte) []byte { return rev[:len(rev)-1] }, false},
+		"mismatch": {func(rev []byte) []byte { return reverseIndexForIndex(t, idx, make([]byte, sha1.Size)) }, false},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gitobj-pack")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			pd := filepath.Join(dir, "pack")
+			require.NoError(t, os.Mkdir(pd, 0755))
+
+			rev := c.mutate(reverseIndexForIndex(t, idx, data[len(data)-sha1.Size:]))
+			for ext, contents := range map[string][]byte{"idx": idx, "pack": data, "rev": rev} {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-fixture."+ext), contents, 0644))
+			}
+
+			set, err := NewSet(dir, sha1.New())
+			require.NoError(t, err)
+			defer set.Close()
+
+			packs := set.packs
+			require.Len(t, packs, 1)
+
+			// The reverse index is not opened until it is needed.
+			assert.Nil(t, packs[0].idx.reverse)
+			_, err = packs[0].idx.offsetOrder()
+			require.NoError(t, err)
+			assert.Equal(t, c.opened, packs[0].idx.reverse != nil)
+
+			// Offsets are resolved correctly either way.
+			name, err := packs[0].OidAtOffset(packHeaderWidth)
+			require.NoError(t, err)
+			assert.Len(t, name, sha1.Size)
+		})
+	}
+}
+
+// reverseIndexForIndex returns a reverse index for the version 2 index "idx",
+// ending with the packfile checksum "sum".
+func reverseIndexForIndex(t *testing.T, idx, sum []byte) []byte {
+	return encodeReverseIndex(sha1.New, objectsForIndex(t, idx), sum)
+}
+
+// encodeReverseIndex returns a reverse index of "objects", which must be sorted
+// by name, whose names are computed using "hash". The reverse index ends with
+// the packfile checksum "sum" (or zeros if it is nil), followed by its own.
+func encodeReverseIndex(hash func() hash.Hash, objects []*indexedObject, sum []byte) []byte {
+	if sum == nil {
+		sum = make([]byte, hash().Size())
+	}
+
+	order := make([]uint32, len(objects))
+	for i := range order {
+		order[i] = uint32(i)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return objects[order[a]].offset < objects[order[b]].offset
+	})
+
+	buf := append([]byte(nil), reverseIndexHeader...)
+	buf = appendUint32(buf, reverseIndexVersion)
+	if hash().Size() == sha256.Size {
+		buf = appendUint32(buf, 2)
+	} else {
+		buf = appendUint32(buf, 1)
+	}
+	for _, pos := range order {
+		buf = appendUint32(buf, pos)
+	}
+
+	buf = append(buf, sum...)
+	return append(buf, checksum(hash(), buf)...)
+}
@@ -1,6 +1,9 @@
 package pack
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 	"hash"
 	"os"
@@ -18,6 +21,16 @@ type Set struct {
 	// that might contain that object, in order of which packfile is most
 	// likely to contain that object.
 	m map[byte][]*Packfile
+	// packs is every packfile in the set.
+	packs []*Packfile
+	// midx is the multi-pack-index of the packfiles in the set, or nil if
+	// there is none, and named maps the name of each packfile that it
+	// indexes to that packfile.
+	midx  *MultiPackIndex
+	named map[string]*Packfile
+	// skipped describes each packfile which was left out of the set
+	// because it could not be trusted (see: Skipped).
+	skipped []error
 
 	// closeFn is a function that is run by Close(), designated to free
 	// resources held by the *Set, like open packfiles.
@@ -39,24 +52,32 @@ var (
 // containing them. If there was an error parsing the packfiles in that
 // directory, or the directory was otherwise unable to be observed, NewSet
 // returns that error.
+//
+// The trailing checksum of each packfile is checked against the one stored in
+// its index, and a packfile for which they differ is left out of the set, so
+// that the objects in the others may still be read. A warning describing each
+// packfile left out is given by Skipped. The packfiles are not otherwise
+// verified (see: Packfile.Verify).
 func NewSet(db string, algo hash.Hash) (*Set, error) {
 	pd := filepath.Join(db, "pack")
 
-	paths, err := filepath.Glob(filepath.Join(escapeGlobPattern(pd), "*.pack"))
+	names, err := packNames(db)
 	if err != nil {
 		return nil, err
 	}
 
-	packs := make([]*Packfile, 0, len(paths))
-
-	for _, path := range paths {
-		submatch := nameRe.FindStringSubmatch(filepath.Base(path))
-		if len(submatch) != 2 {
-			continue
-		}
+	packs := make([]*Packfile, 0, len(names))
+	named := make(map[string]*Packfile, len(names))
+	var skipped []error
 
-		name := submatch[1]
+	// Reverse indexes and bitmaps are opened lazily, perhaps while other
+	// packfiles are being read, so they are not given the shared "algo".
+	newHash := sha1.New
+	if algo.Size() == sha256.Size {
+		newHash = sha256.New
+	}
 
+	for _, name := range names {
 		idxf, err := os.Open(filepath.Join(pd, fmt.Sprintf("%s.idx", name)))
 		if err != nil {
 			// We have a pack (since it matched the regex), but the
@@ -87,10 +108,148 @@ func NewSet(db string, algo hash.Hash) (*Set, error) {
 		}
 
 		pack.idx = idx
+		if err = pack.verifyTrailer(); err != nil {
+			pack.Close()
+			skipped = append(skipped, fmt.Errorf("gitobj/pack: skipped %s: %s", name, err))
+			continue
+		}
+
+		bitmapPath := filepath.Join(pd, fmt.Sprintf("%s.bitmap", name))
+		pack.openBitmap = func() *Bitmap {
+			return openBitmap(bitmapPath, newHash(), idx)
+		}
+		revPath := filepath.Join(pd, fmt.Sprintf("%s.rev", name))
+		idx.openReverse = func() *ReverseIndex {
+			return openReverseIndex(revPath, newHash(), idx)
+		}
 
 		packs = append(packs, pack)
+		named[name] = pack
+	}
+
+	set := NewSetPacks(packs...)
+	set.skipped = skipped
+	if midx := openMultiPackIndex(filepath.Join(pd, "multi-pack-index"), algo); midx != nil {
+		set.midx = midx
+		set.named = named
+
+		closePacks := set.closeFn
+		set.closeFn = func() error {
+			if err := closePacks(); err != nil {
+				midx.Close()
+				return err
+			}
+			return midx.Close()
+		}
+	}
+	return set, nil
+}
+
+// packNames returns the name (without its extension) of each packfile in the
+// "pack" subdirectory of the object database root "db", in sorted order.
+func packNames(db string) ([]string, error) {
+	pd := filepath.Join(db, "pack")
+
+	paths, err := filepath.Glob(filepath.Join(escapeGlobPattern(pd), "*.pack"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		submatch := nameRe.FindStringSubmatch(filepath.Base(path))
+		if len(submatch) != 2 {
+			continue
+		}
+		names = append(names, submatch[1])
+	}
+	return names, nil
+}
+
+// openBitmap opens and decodes the bitmap file at "path" for the index "idx",
+// or returns nil if there is no usable bitmap at that path. As with Git, a
+// missing or corrupt bitmap is not an error, since objects can still be found
+// by walking the object graph instead, and neither is one which does not match
+// the packfile.
+func openBitmap(path string, algo hash.Hash, idx *Index) *Bitmap {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	bitmap, err := DecodeBitmap(f, algo)
+	if err != nil || !bitmap.fits(idx.Count()) {
+		return nil
+	}
+
+	sum, err := packChecksum(idx, int64(algo.Size()))
+	if err != nil || !bytes.Equal(sum, bitmap.packSum) {
+		return nil
+	}
+	return bitmap
+}
+
+// openReverseIndex opens and decodes the reverse index file at "path" for the
+// index "idx", or returns nil if there is no usable reverse index at that path.
+// As with bitmaps, a missing or corrupt reverse index is not an error, since
+// the order of the objects can still be computed from the index, and neither
+// is one which does not match the index.
+func openReverseIndex(path string, algo hash.Hash, idx *Index) *ReverseIndex {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	rev, err := DecodeReverseIndex(f, algo)
+	if err != nil || rev.Count() != idx.Count() {
+		return nil
+	}
+	for _, pos := range rev.positions {
+		if int(pos) >= idx.Count() {
+			return nil
+		}
+	}
+
+	sum, err := packChecksum(idx, int64(algo.Size()))
+	if err != nil || !bytes.Equal(sum, rev.packSum) {
+		return nil
+	}
+	return rev
+}
+
+// packChecksum returns the checksum of the packfile described by the index
+// "idx", as recorded in the index's trailer.
+func packChecksum(idx *Index, hashlen int64) ([]byte, error) {
+	trailer, err := indexTrailer(idx, hashlen)
+	if err != nil {
+		return nil, err
+	}
+	sum := make([]byte, hashlen)
+	if _, err = idx.readAt(sum, trailer); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// openMultiPackIndex opens and decodes the multi-pack-index file at "path", or
+// returns nil if there is no usable multi-pack-index at that path. The file is
+// kept open, since its entries are read as they are needed. As with bitmaps, a
+// missing or corrupt multi-pack-index is not an error, since objects can still
+// be found using the index of each packfile.
+func openMultiPackIndex(path string, algo hash.Hash) *MultiPackIndex {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
 	}
-	return NewSetPacks(packs...), nil
+
+	midx, err := DecodeMultiPackIndex(f, algo)
+	if err != nil {
+		f.Close()
+		return nil
+	}
+	return midx
 }
 
 // globEscapes uses these escapes because filepath.Glob does not understand
@@ -139,7 +298,8 @@ func NewSetPacks(packs ...*Packfile) *Set {
 	}
 
 	return &Set{
-		m:    m,
+		m:     m,
+		packs: packs,
 		closeFn: func() error {
 			for _, pack := range packs {
 				if err := pack.Close(); err != nil {
@@ -159,12 +319,19 @@ func (s *Set) Close() error {
 	return s.closeFn()
 }
 
+// Skipped returns a warning describing each packfile which was found by NewSet,
+// but left out of the set because its index did not match it.
+func (s *Set) Skipped() []error {
+	return s.skipped
+}
+
 // Object opens (but does not unpack, or, apply the delta-base chain) a given
 // object in the first packfile that matches it.
 //
-// Object searches packfiles contained in the set in order of how many objects
-// they have that begin with the first by of the given SHA-1 "name", in
-// descending order.
+// If the set has a multi-pack-index which indexes the object, the object is
+// opened from the packfile that it names. Otherwise, Object searches packfiles
+// contained in the set in order of how many objects they have that begin with
+// the first by of the given SHA-1 "name", in descending order.
 //
 // If the object was unable to be found in any of the packfiles, (nil,
 // ErrNotFound) will be returned.
@@ -174,11 +341,192 @@ func (s *Set) Close() error {
 //
 // Otherwise, the object will be returned without error.
 func (s *Set) Object(name []byte) (*Object, error) {
+	if s.midx != nil {
+		entry, err := s.midx.Entry(name)
+		if err == nil {
+			if pack, ok := s.named[entry.PackName]; ok {
+				return pack.objectAt(int64(entry.PackOffset))
+			}
+		} else if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+
 	return s.each(name, func(p *Packfile) (*Object, error) {
 		return p.Object(name)
 	})
 }
 
+// Has returns whether any packfile in the set holds the object "name". Only the
+// indexes of the packfiles are searched, so nothing is read from the packfiles
+// themselves.
+//
+// If there was an error searching an index, it will be returned, and no other
+// packfiles will be searched.
+func (s *Set) Has(name []byte) (bool, error) {
+	var key byte
+	if len(name) > 0 {
+		key = name[0]
+	}
+
+	for _, pack := range s.m[key] {
+		if _, err := pack.idx.position(name); err == nil {
+			return true, nil
+		} else if !IsNotFound(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// HasBitmap returns whether any packfile in the set has a reachability bitmap.
+func (s *Set) HasBitmap() bool {
+	for _, pack := range s.packs {
+		if pack.HasBitmap() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxDeltaDepth sets the maximum number of deltas followed when resolving an
+// object in any packfile in the set (see: Packfile.SetMaxDeltaDepth).
+func (s *Set) SetMaxDeltaDepth(depth int) {
+	for _, pack := range s.packs {
+		pack.SetMaxDeltaDepth(depth)
+	}
+}
+
+// Reachable calls "fn" for each object reachable from the commit "name", as
+// given by the bitmap of the first packfile which has a bitmap for that commit,
+// and returns true.
+//
+// If no packfile has a bitmap for that commit, Reachable returns false without
+// calling "fn".
+func (s *Set) Reachable(name []byte, fn ReachableFn) (bool, error) {
+	var key byte
+	if len(name) > 0 {
+		key = name[0]
+	}
+
+	for _, pack := range s.m[key] {
+		ok, err := pack.Reachable(name, fn)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// ForEach calls "fn" with the name of each object in any packfile in the set,
+// in ascending order. An object stored in more than one packfile is only given
+// once. If "fn" returns an error, iteration stops and that error is returned.
+func (s *Set) ForEach(fn func(name []byte) error) error {
+	return s.ForEachAfter(nil, fn)
+}
+
+// ForEachAfter is as ForEach, but only gives the names greater than "after".
+// The objects before it are skipped by searching each index, rather than
+// listing them.
+func (s *Set) ForEachAfter(after []byte, fn func(name []byte) error) error {
+	// Each index is already sorted, so merge them by keeping the position
+	// of, and name at, the next object to be given from each.
+	type cursor struct {
+		idx  *Index
+		at   int
+		name []byte
+	}
+
+	advance := func(c *cursor) error {
+		c.name = nil
+		if c.at < c.idx.Count() {
+			name, err := c.idx.version.Name(c.idx, int64(c.at))
+			if err != nil {
+				return err
+			}
+			c.name = name
+			c.at++
+		}
+		return nil
+	}
+
+	cursors := make([]*cursor, 0, len(s.packs))
+	for _, pack := range s.packs {
+		at, err := pack.idx.after(after)
+		if err != nil {
+			return err
+		}
+
+		c := &cursor{idx: pack.idx, at: at}
+		if err := advance(c); err != nil {
+			return err
+		}
+		cursors = append(cursors, c)
+	}
+
+	for {
+		var next []byte
+		for _, c := range cursors {
+			if c.name != nil && (next == nil || bytes.Compare(c.name, next) < 0) {
+				next = c.name
+			}
+		}
+		if next == nil {
+			return nil
+		}
+
+		if err := fn(next); err != nil {
+			return err
+		}
+
+		for _, c := range cursors {
+			if c.name != nil && bytes.Equal(c.name, next) {
+				if err := advance(c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// EachSize calls "fn" with the name, type, and sizes of each object in each
+// packfile in the set (see: Packfile.EachSize), stopping at the first error.
+// Unlike ForEach, the objects are not given in any particular order, and an
+// object stored in more than one packfile is given once for each.
+func (s *Set) EachSize(fn SizeFn) error {
+	for _, pack := range s.packs {
+		if err := pack.EachSize(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeltaChain returns the delta-base chain of the object named "name" (see:
+// Packfile.DeltaChain) in the first packfile that contains it, searching the
+// packfiles in the same order as Object.
+//
+// If the object was unable to be found in any of the packfiles,
+// errors.NoSuchObject is returned.
+func (s *Set) DeltaChain(name []byte) ([]*ChainLink, error) {
+	var key byte
+	if len(name) > 0 {
+		key = name[0]
+	}
+
+	for _, pack := range s.m[key] {
+		chain, err := pack.DeltaChain(name)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		return chain, nil
+	}
+	return nil, errors.NoSuchObject(name)
+}
+
 // iterFn is a function that takes a given packfile and opens an object from it.
 type iterFn func(p *Packfile) (o *Object, err error)
 
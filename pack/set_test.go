@@ -2,6 +2,9 @@ package pack
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -76,3 +79,134 @@ func TestSetOpenOpensPackedObjectsInPackOrder(t *testing.T) {
 	assert.EqualValues(t, visited[1].Objects, 2)
 	assert.EqualValues(t, visited[2].Objects, 1)
 }
+
+func TestSetForEach(t *testing.T) {
+	p1 := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"aa00000000000000000000000000000000000000": 1,
+			"cc00000000000000000000000000000000000000": 2,
+		}),
+		r: bytes.NewReader(nil),
+	}
+	p2 := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"bb00000000000000000000000000000000000000": 1,
+			"cc00000000000000000000000000000000000000": 2,
+			"dd00000000000000000000000000000000000000": 3,
+		}),
+		r: bytes.NewReader(nil),
+	}
+
+	set := NewSetPacks(p1, p2)
+
+	var names []string
+	err := set.ForEach(func(name []byte) error {
+		names = append(names, fmt.Sprintf("%x", name))
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"aa00000000000000000000000000000000000000",
+		"bb00000000000000000000000000000000000000",
+		"cc00000000000000000000000000000000000000",
+		"dd00000000000000000000000000000000000000",
+	}, names)
+}
+
+func TestSetForEachAfter(t *testing.T) {
+	set := NewSetPacks(&Packfile{
+		idx: IndexWith(map[string]uint32{
+			"aa00000000000000000000000000000000000000": 1,
+			"cc00000000000000000000000000000000000000": 2,
+			"cc00000000000000000000000000000000000001": 3,
+		}),
+		r: bytes.NewReader(nil),
+	}, &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"bb00000000000000000000000000000000000000": 1,
+			"dd00000000000000000000000000000000000000": 2,
+		}),
+		r: bytes.NewReader(nil),
+	})
+
+	for after, want := range map[string][]string{
+		"aa00000000000000000000000000000000000000": {
+			"bb00000000000000000000000000000000000000",
+			"cc00000000000000000000000000000000000000",
+			"cc00000000000000000000000000000000000001",
+			"dd00000000000000000000000000000000000000",
+		},
+		"cc00000000000000000000000000000000000000": {
+			"cc00000000000000000000000000000000000001",
+			"dd00000000000000000000000000000000000000",
+		},
+		// A name which is not in any index falls between those which are.
+		"bc00000000000000000000000000000000000000": {
+			"cc00000000000000000000000000000000000000",
+			"cc00000000000000000000000000000000000001",
+			"dd00000000000000000000000000000000000000",
+		},
+		"ff00000000000000000000000000000000000000": nil,
+	} {
+		name, err := hex.DecodeString(after)
+		require.NoError(t, err)
+
+		var names []string
+		err = set.ForEachAfter(name, func(name []byte) error {
+			names = append(names, fmt.Sprintf("%x", name))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, names, after)
+	}
+}
+
+func TestSetForEachStopsOnError(t *testing.T) {
+	set := NewSetPacks(&Packfile{
+		idx: IndexWith(map[string]uint32{
+			"aa00000000000000000000000000000000000000": 1,
+			"bb00000000000000000000000000000000000000": 2,
+		}),
+		r: bytes.NewReader(nil),
+	})
+
+	var calls int
+	err := set.ForEach(func(name []byte) error {
+		calls++
+		return errors.New("stop")
+	})
+
+	assert.EqualError(t, err, "stop")
+	assert.Equal(t, 1, calls)
+}
+
+func TestSetHas(t *testing.T) {
+	set := NewSetPacks(&Packfile{
+		idx: IndexWith(map[string]uint32{
+			"aa00000000000000000000000000000000000000": 1,
+		}),
+		r: bytes.NewReader(nil),
+	}, &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"bb00000000000000000000000000000000000000": 1,
+		}),
+		r: bytes.NewReader(nil),
+	})
+
+	for _, test := range []struct {
+		sha string
+		has bool
+	}{
+		{"aa00000000000000000000000000000000000000", true},
+		{"bb00000000000000000000000000000000000000", true},
+		{"aa11111111111111111111111111111111111111", false},
+		{"cc00000000000000000000000000000000000000", false},
+	} {
+		has, err := set.Has(DecodeHex(t, test.sha))
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.has, has, test.sha)
+	}
+}
@@ -0,0 +1,190 @@
+package pack
+
+import (
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// maxDeltaSizeWidth is the maximum width of one of the variable-length sizes
+// which begin the instructions of a delta.
+const maxDeltaSizeWidth = 10
+
+// SizeFn is a function called with the name, type, and inflated size of a
+// packed object, along with the number of bytes that it occupies in its
+// packfile.
+type SizeFn func(name []byte, typ PackedObjectType, size, stored int64) error
+
+// EachSize calls "fn" with the name, type, and sizes of each object in the
+// packfile, in the order in which they are stored, stopping at the first error.
+//
+// The type and size given for a deltified object are those of the object it
+// resolves to, rather than of the delta. They are found by reading only the
+// headers of the elements in its delta-base chain and the start of its own
+// delta instructions, so no chain is resolved. The stored size is that of the
+// object's own element, so it excludes the elements of its bases.
+func (p *Packfile) EachSize(fn SizeFn) error {
+	order, err := p.idx.offsetOrder()
+	if err != nil {
+		return err
+	}
+
+	size, err := p.size()
+	if err != nil {
+		return err
+	}
+	end := size - int64(p.hash.Size())
+
+	for k, at := range order {
+		name, err := p.idx.version.Name(p.idx, int64(at))
+		if err != nil {
+			return err
+		}
+
+		entry, err := p.idx.version.Entry(p.idx, int64(at))
+		if err != nil {
+			return err
+		}
+		start := int64(entry.PackOffset)
+
+		// Objects are laid out back-to-back, so each ends where the
+		// next begins, and the last ends at the trailing checksum.
+		next := end
+		if k+1 < len(order) {
+			e, err := p.idx.version.Entry(p.idx, int64(order[k+1]))
+			if err != nil {
+				return err
+			}
+			next = int64(e.PackOffset)
+		}
+		if next <= start {
+			return fmt.Errorf("gitobj/pack: invalid offset %d for object %x", start, name)
+		}
+
+		typ, inflated, err := p.sizeAt(start)
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not read object %x: %s", name, err)
+		}
+
+		if err = fn(name, typ, inflated, next-start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sizeAt returns the type and inflated size of the object whose element begins
+// at "offset", following its delta-base chain (if any) only as far as is
+// needed to find its type.
+func (p *Packfile) sizeAt(offset int64) (PackedObjectType, int64, error) {
+	typ, size, dataOffset, err := p.readHeader(offset)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+
+	switch typ {
+	case TypeCommit, TypeTree, TypeBlob, TypeTag:
+		return typ, int64(size), nil
+	case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
+	default:
+		return TypeNone, 0, errUnrecognizedObjectType
+	}
+
+	base, deltaOffset, err := p.findBase(typ, dataOffset, offset)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+
+	resolved, err := p.deltaSize(deltaOffset)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+
+	for depth := 1; ; depth++ {
+		objOffset := base
+
+		typ, _, dataOffset, err = p.readHeader(base)
+		if err != nil {
+			return TypeNone, 0, err
+		}
+
+		switch typ {
+		case TypeCommit, TypeTree, TypeBlob, TypeTag:
+			return typ, resolved, nil
+		case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
+			if max := p.MaxDeltaDepth(); depth >= max {
+				return TypeNone, 0, &DeltaDepthErr{Max: max}
+			}
+
+			if base, _, err = p.findBase(typ, dataOffset, objOffset); err != nil {
+				return TypeNone, 0, err
+			}
+		default:
+			return TypeNone, 0, errUnrecognizedObjectType
+		}
+	}
+}
+
+// deltaSize returns the size of the object produced by the (compressed) delta
+// instructions at "offset", which is the second of the two sizes which begin
+// them.
+func (p *Packfile) deltaSize(offset int64) (int64, error) {
+	zr, err := zlib.NewReader(&OffsetReaderAt{
+		o: offset,
+		r: p.r,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := ioutil.ReadAll(io.LimitReader(zr, 2*maxDeltaSizeWidth))
+	if err != nil {
+		return 0, err
+	}
+
+	var sizes [2]int64
+	var pos int
+	for i := range sizes {
+		var shift uint
+		for {
+			if pos >= len(header) || shift >= 7*maxDeltaSizeWidth {
+				return 0, fmt.Errorf("gitobj/pack: invalid delta header")
+			}
+
+			c := header[pos]
+			pos++
+
+			sizes[i] |= int64(c&0x7f) << shift
+			shift += 7
+
+			if c&0x80 == 0 {
+				break
+			}
+		}
+	}
+	return sizes[1], nil
+}
+
+// size returns the length of the packfile in bytes, if its underlying data
+// stream is able to report it.
+func (p *Packfile) size() (int64, error) {
+	return readerSize(p.r, "packfile")
+}
+
+// readerSize returns the length in bytes of the data given by "r", if it is
+// able to report it, or an error naming the data as "what" otherwise.
+func readerSize(r io.ReaderAt, what string) (int64, error) {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size(), nil
+	case interface{ Stat() (os.FileInfo, error) }:
+		fi, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	return 0, fmt.Errorf("gitobj/pack: cannot determine size of %s", what)
+}
@@ -0,0 +1,77 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type packedSize struct {
+	Name   []byte
+	Type   PackedObjectType
+	Size   int64
+	Stored int64
+}
+
+func TestPackfileEachSize(t *testing.T) {
+	p, name := deltaChainPackfile(t, 3)
+	p.r = withTrailer(t, p.r)
+
+	var sizes []packedSize
+	err := p.EachSize(func(name []byte, typ PackedObjectType, size, stored int64) error {
+		sizes = append(sizes, packedSize{name, typ, size, stored})
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, sizes, 1)
+	assert.Equal(t, DecodeHex(t, name), sizes[0].Name)
+	assert.Equal(t, TypeBlob, sizes[0].Type)
+	assert.EqualValues(t, len("axxx"), sizes[0].Size)
+
+	data := p.r.(*bytes.Reader)
+	entry, err := p.idx.Entry(DecodeHex(t, name))
+	require.NoError(t, err)
+	assert.Equal(t, data.Size()-sha1.Size-int64(entry.PackOffset), sizes[0].Stored)
+}
+
+func TestPackfileEachSizeRejectsDeltaChainBeyondMaxDepth(t *testing.T) {
+	p, _ := deltaChainPackfile(t, 3)
+	p.r = withTrailer(t, p.r)
+	p.SetMaxDeltaDepth(2)
+
+	err := p.EachSize(func(name []byte, typ PackedObjectType, size, stored int64) error {
+		return nil
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gitobj/pack: could not read object")
+	assert.Contains(t, err.Error(), (&DeltaDepthErr{Max: 2}).Error())
+}
+
+func TestPackfileEachSizeRequiresPackfileSize(t *testing.T) {
+	p, _ := deltaChainPackfile(t, 1)
+	p.r = &ReaderAtCloser{}
+
+	err := p.EachSize(func(name []byte, typ PackedObjectType, size, stored int64) error {
+		return nil
+	})
+
+	assert.EqualError(t, err, "gitobj/pack: cannot determine size of packfile")
+}
+
+// withTrailer returns the packfile data given by "r" followed by a (zeroed)
+// trailing checksum.
+func withTrailer(t *testing.T, r io.ReaderAt) *bytes.Reader {
+	data := r.(*bytes.Reader)
+
+	buf := make([]byte, data.Size(), data.Size()+sha1.Size)
+	_, err := data.ReadAt(buf, 0)
+	require.NoError(t, err)
+
+	return bytes.NewReader(append(buf, make([]byte, sha1.Size)...))
+}
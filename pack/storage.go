@@ -3,33 +3,210 @@ package pack
 import (
 	"hash"
 	"io"
+	"os"
+	"path/filepath"
+	"sync"
 )
 
 // Storage implements the storage.Storage interface.
 type Storage struct {
+	// root is the object database root containing the packfiles.
+	root string
+	// algo is the hash algorithm used by the packfiles.
+	algo hash.Hash
+
+	// mu guards the fields below, which are replaced by Rescan.
+	mu *sync.RWMutex
+	// packs is the set of packfiles found by the most recent scan.
 	packs *Set
+	// names is the name of each indexed packfile found by the most recent
+	// scan (see: indexedPackNames).
+	names []string
+	// readers counts the objects opened from, and other reads in progress
+	// of, each set of packfiles which has any. A set replaced by Rescan is
+	// closed once it has none, since until then it may still be read.
+	readers map[*Set]int
+	// maxDeltaDepth is the maximum delta depth given to SetMaxDeltaDepth,
+	// which is applied to each new set of packfiles.
+	maxDeltaDepth int
 }
 
 // NewStorage returns a new storage object based on a pack set.
 func NewStorage(root string, algo hash.Hash) (*Storage, error) {
+	names, err := indexedPackNames(root)
+	if err != nil {
+		return nil, err
+	}
+
 	packs, err := NewSet(root, algo)
 	if err != nil {
 		return nil, err
 	}
-	return &Storage{packs: packs}, nil
+	return &Storage{
+		root:  root,
+		algo:  algo,
+		mu:    new(sync.RWMutex),
+		packs: packs,
+		names: names,
+	}, nil
 }
 
 // Open implements the storage.Storage.Open interface.
 func (f *Storage) Open(oid []byte) (r io.ReadCloser, err error) {
-	obj, err := f.packs.Object(oid)
+	packs := f.acquire()
+
+	obj, err := packs.Object(oid)
 	if err != nil {
+		f.release(packs)
 		return nil, err
 	}
-	return &delayedObjectReader{obj: obj}, nil
+	return &delayedObjectReader{obj: obj, closeFn: func() error {
+		return f.release(packs)
+	}}, nil
+}
+
+// Has returns whether any packfile in this storage holds the object "oid",
+// searching only their indexes (see: Set.Has).
+func (f *Storage) Has(oid []byte) (bool, error) {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.Has(oid)
+}
+
+// Rescan looks for packfiles added to or removed from the storage's directory
+// since it was last scanned, as happens when objects are repacked by another
+// process, and returns whether any were. If so, objects are subsequently read
+// from the packfiles now in the directory.
+//
+// Packfiles which have been removed remain open until every object already
+// opened from them has been closed, so that those objects can still be read.
+func (f *Storage) Rescan() (bool, error) {
+	names, err := indexedPackNames(f.root)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if equalNames(names, f.names) {
+		return false, nil
+	}
+
+	packs, err := NewSet(f.root, f.algo)
+	if err != nil {
+		return false, err
+	}
+	packs.SetMaxDeltaDepth(f.maxDeltaDepth)
+
+	old := f.packs
+	f.packs = packs
+	f.names = names
+
+	if f.readers[old] == 0 {
+		return true, old.Close()
+	}
+	return true, nil
+}
+
+// ObjectType returns the type of the object "oid" in this storage, as named by
+// PackedObjectType.String. Only the headers of the object's delta-base chain are
+// read, so it is not unpacked.
+func (f *Storage) ObjectType(oid []byte) (string, error) {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	obj, err := packs.Object(oid)
+	if err != nil {
+		return "", err
+	}
+	return obj.Type().String(), nil
+}
+
+// HasBitmap returns whether any packfile in this storage has a reachability
+// bitmap.
+func (f *Storage) HasBitmap() bool {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.HasBitmap()
+}
+
+// Reachable calls "fn" for each object reachable from the commit "oid" according
+// to the packfile bitmaps in this storage, and returns true. If no bitmap
+// describes that commit, it returns false without calling "fn".
+func (f *Storage) Reachable(oid []byte, fn ReachableFn) (bool, error) {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.Reachable(oid, fn)
+}
+
+// ForEach calls "fn" with the ID of each object in this storage, in ascending
+// order (see: Set.ForEach).
+func (f *Storage) ForEach(fn func(oid []byte) error) error {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.ForEach(fn)
+}
+
+// ForEachAfter is as ForEach, but only gives the IDs greater than "after" (see:
+// Set.ForEachAfter).
+func (f *Storage) ForEachAfter(after []byte, fn func(oid []byte) error) error {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.ForEachAfter(after, fn)
+}
+
+// EachSize calls "fn" with the ID, type, and inflated size of each object in
+// this storage, along with the number of bytes it occupies in its packfile
+// (see: Set.EachSize).
+func (f *Storage) EachSize(fn func(oid []byte, typ string, size, stored int64) error) error {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.EachSize(func(name []byte, typ PackedObjectType, size, stored int64) error {
+		return fn(name, typ.String(), size, stored)
+	})
+}
+
+// DeltaChain returns the delta-base chain of the object "oid" in this storage
+// (see: Set.DeltaChain).
+func (f *Storage) DeltaChain(oid []byte) ([]*ChainLink, error) {
+	packs := f.acquire()
+	defer f.release(packs)
+
+	return packs.DeltaChain(oid)
+}
+
+// SetMaxDeltaDepth sets the maximum number of deltas followed when resolving an
+// object in this storage (see: Packfile.SetMaxDeltaDepth).
+func (f *Storage) SetMaxDeltaDepth(depth int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.maxDeltaDepth = depth
+	f.packs.SetMaxDeltaDepth(depth)
 }
 
 // Open implements the storage.Storage.Open interface.
 func (f *Storage) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for packs := range f.readers {
+		if packs == f.packs {
+			continue
+		}
+		if err := packs.Close(); err != nil {
+			return err
+		}
+	}
+	f.readers = nil
+
 	return f.packs.Close()
 }
 
@@ -37,3 +214,72 @@ func (f *Storage) Close() error {
 func (f *Storage) IsCompressed() bool {
 	return false
 }
+
+// acquire returns the set of packfiles found by the most recent scan, which is
+// not closed by Rescan until it has been released (see: release).
+func (f *Storage) acquire() *Set {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.readers == nil {
+		f.readers = make(map[*Set]int)
+	}
+	f.readers[f.packs]++
+	return f.packs
+}
+
+// release releases the set of packfiles "packs" given by acquire. If it has
+// since been replaced by Rescan, and this was its last reader, it is closed,
+// and any error in doing so is returned.
+func (f *Storage) release(packs *Set) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, ok := f.readers[packs]
+	if !ok {
+		// The storage has since been closed.
+		return nil
+	}
+	if n > 1 {
+		f.readers[packs] = n - 1
+		return nil
+	}
+
+	delete(f.readers, packs)
+	if packs == f.packs {
+		return nil
+	}
+	return packs.Close()
+}
+
+// indexedPackNames returns the name of each packfile beneath the object
+// database root "db" which has an index, in sorted order. A packfile without
+// an index cannot be read, but may yet be given one by the process writing it.
+func indexedPackNames(db string) ([]string, error) {
+	names, err := packNames(db)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := names[:0]
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(db, "pack", name+".idx")); err == nil {
+			indexed = append(indexed, name)
+		}
+	}
+	return indexed, nil
+}
+
+// equalNames returns whether "a" and "b" hold the same names in the same
+// order.
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageRescanClosesStaleSetOnceRead(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	name := objectsForIndex(t, idx)[0].name
+
+	dir, pd := storageFixtureDir(t)
+	defer os.RemoveAll(dir)
+	writeStorageFixture(t, pd, "pack-a", idx, data)
+
+	s, err := NewStorage(dir, sha1.New())
+	require.NoError(t, err)
+	defer s.Close()
+
+	r, err := s.Open(name)
+	require.NoError(t, err)
+
+	stale := s.packs
+	writeStorageFixture(t, pd, "pack-b", idx, data)
+
+	changed, err := s.Rescan()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// The object opened before the rescan can still be read, and the set
+	// from which it was read is closed once it has been.
+	contents, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "blob 14\x00Hello, world!\n", string(contents))
+	assert.Contains(t, s.readers, stale)
+
+	require.NoError(t, r.Close())
+	assert.NotContains(t, s.readers, stale)
+	assert.Error(t, stale.packs[0].r.(*os.File).Close())
+
+	// Closing the reader again does not release the new set.
+	require.NoError(t, r.Close())
+	assert.NoError(t, s.packs.packs[0].r.(*os.File).Close())
+}
+
+func TestStorageRescanClosesUnreadStaleSet(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+
+	dir, pd := storageFixtureDir(t)
+	defer os.RemoveAll(dir)
+	writeStorageFixture(t, pd, "pack-a", idx, data)
+
+	s, err := NewStorage(dir, sha1.New())
+	require.NoError(t, err)
+	defer s.Close()
+
+	stale := s.packs
+	writeStorageFixture(t, pd, "pack-b", idx, data)
+
+	changed, err := s.Rescan()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	assert.Empty(t, s.readers)
+	assert.Error(t, stale.packs[0].r.(*os.File).Close())
+}
+
+// storageFixtureDir returns a new temporary object database root, and the
+// "pack" directory beneath it.
+func storageFixtureDir(t *testing.T) (dir, pd string) {
+	dir, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+
+	pd = filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+	return dir, pd
+}
+
+// writeStorageFixture writes the index "idx" and packfile "data" named "name"
+// into the pack directory "pd".
+func writeStorageFixture(t *testing.T, pd, name string, idx, data []byte) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, name+".idx"), idx, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, name+".pack"), data, 0644))
+}
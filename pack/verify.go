@@ -0,0 +1,352 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// packHeaderWidth is the width of the header which begins every packfile: the
+// "PACK" magic, a 4-byte version, and a 4-byte object count.
+const packHeaderWidth = 12
+
+// VerifyPack checks that the packfile given by "data" and its index given by
+// "idx" are well-formed and consistent with one another, using the hash
+// algorithm returned by "hash". Specifically, it checks that:
+//
+//   - the packfile has a valid header of a supported version, and ends with
+//     a checksum of its contents,
+//   - the index is sorted, its fanout table agrees with its names, and it
+//     ends with the packfile's checksum followed by its own,
+//   - every object named in the index can be resolved (including any delta
+//     chains), and hashes to the name under which it is indexed, and
+//   - the indexed objects are laid out back-to-back in the packfile with no
+//     gaps, and (for version 2 and 3 indexes) the CRC-32 of each matches the
+//     index.
+//
+// It is the equivalent of "git index-pack --strict" for an already indexed
+// packfile, and should be used before trusting a packfile received from an
+// untrusted source.
+//
+// If any check fails, a descriptive error is returned.
+func VerifyPack(idx, data io.ReaderAt, hash func() hash.Hash) error {
+	p, err := DecodePackfile(data, hash())
+	if err != nil {
+		return err
+	}
+	if p.Version != 2 && p.Version != 3 {
+		return &UnsupportedVersionErr{Got: p.Version}
+	}
+
+	size, sum, err := verifyChecksum(data, hash())
+	if err != nil {
+		return err
+	}
+
+	p.idx, err = DecodeIndex(idx, hash())
+	if err != nil {
+		return err
+	}
+	return p.verify(size, sum, hash)
+}
+
+// Verify checks that the packfile and its index are well-formed and consistent
+// with one another, as VerifyPack does. Since this reads and resolves every
+// object in the packfile, it is not done when a packfile is opened, which only
+// checks that the index belongs to the packfile (by comparing the packfile's
+// trailing checksum with that stored in the index).
+//
+// If any check fails, a descriptive error is returned.
+func (p *Packfile) Verify() error {
+	if p.Version != 2 && p.Version != 3 {
+		return &UnsupportedVersionErr{Got: p.Version}
+	}
+
+	// The packfile's own hash is not used, since it may be shared with
+	// other packfiles.
+	hash := sha1.New
+	if p.hash.Size() == sha256.Size {
+		hash = sha256.New
+	}
+
+	size, sum, err := verifyChecksum(p.r, hash())
+	if err != nil {
+		return err
+	}
+	return p.verify(size, sum, hash)
+}
+
+// verify checks the index of the packfile "p", of the given size and ending
+// with the checksum "sum", and each of its objects, using the hash algorithm
+// returned by "hash".
+func (p *Packfile) verify(size int64, sum []byte, hash func() hash.Hash) error {
+	if count := p.idx.Count(); count != int(p.Objects) {
+		return fmt.Errorf("gitobj/pack: index has %d objects, packfile has %d",
+			count, p.Objects)
+	}
+
+	if err := verifyIndex(p.idx, sum, hash()); err != nil {
+		return err
+	}
+	return verifyObjects(p, size-int64(len(sum)), hash())
+}
+
+// verifyTrailer checks that the checksum which ends the packfile is the one
+// stored in its index. Unlike Verify, it reads only those two checksums, so it
+// is cheap enough to do whenever a packfile is opened.
+func (p *Packfile) verifyTrailer() error {
+	hashlen := int64(p.hash.Size())
+
+	size, err := p.size()
+	if err != nil {
+		return err
+	}
+	isize, err := readerSize(p.idx.r, "index")
+	if err != nil {
+		return err
+	}
+
+	if size < packHeaderWidth+hashlen {
+		return fmt.Errorf("gitobj/pack: packfile too short: %d bytes", size)
+	}
+	if isize < 2*hashlen {
+		return fmt.Errorf("gitobj/pack: index too short: %d bytes", isize)
+	}
+
+	got := make([]byte, hashlen)
+	if _, err = p.r.ReadAt(got, size-hashlen); err != nil {
+		return err
+	}
+
+	want := make([]byte, hashlen)
+	if _, err = p.idx.readAt(want, isize-2*hashlen); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("gitobj/pack: index does not match packfile checksum")
+	}
+	return nil
+}
+
+// verifyChecksum reads all of "r", and checks that its last h.Size() bytes are
+// the checksum of the data preceding them. It returns the total size of "r"
+// and that checksum.
+func verifyChecksum(r io.ReaderAt, h hash.Hash) (int64, []byte, error) {
+	hashlen := h.Size()
+
+	br := bufio.NewReader(io.NewSectionReader(r, 0, math.MaxInt64))
+	buf := make([]byte, 32*1024)
+
+	// tail holds the last "hashlen" bytes read so far, which are only
+	// hashed once it is known that they are not part of the trailer.
+	var tail []byte
+	var size int64
+
+	for {
+		n, err := br.Read(buf)
+		if n > 0 {
+			size += int64(n)
+
+			tail = append(tail, buf[:n]...)
+			if over := len(tail) - hashlen; over > 0 {
+				h.Write(tail[:over])
+				tail = append(tail[:0], tail[over:]...)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if size < packHeaderWidth+int64(hashlen) {
+		return 0, nil, fmt.Errorf("gitobj/pack: packfile too short: %d bytes", size)
+	}
+	if !bytes.Equal(h.Sum(nil), tail) {
+		return 0, nil, fmt.Errorf("gitobj/pack: packfile checksum mismatch")
+	}
+	return size, tail, nil
+}
+
+// verifyIndex checks that the names in the index "i" are strictly sorted and
+// agree with its fanout table, and that the index ends with the packfile
+// checksum "sum" followed by a checksum (computed with "h") of its own.
+func verifyIndex(i *Index, sum []byte, h hash.Hash) error {
+	hashlen := int64(h.Size())
+	count := i.Count()
+
+	var prev []byte
+	for at := 0; at < count; at++ {
+		name, err := i.version.Name(i, int64(at))
+		if err != nil {
+			return err
+		}
+
+		if prev != nil && bytes.Compare(prev, name) >= 0 {
+			return fmt.Errorf("gitobj/pack: index is not sorted at %x", name)
+		}
+		prev = append(prev[:0], name...)
+
+		// Every object is counted by the fanout entries of its first
+		// byte and above, and no entry below.
+		if at >= int(i.fanout[name[0]]) || (name[0] > 0 && at < int(i.fanout[name[0]-1])) {
+			return fmt.Errorf("gitobj/pack: index fanout is inconsistent at %x", name)
+		}
+	}
+	for b := 1; b < len(i.fanout); b++ {
+		if i.fanout[b] < i.fanout[b-1] {
+			return fmt.Errorf("gitobj/pack: index fanout is not monotonic at %d", b)
+		}
+	}
+
+	trailer, err := indexTrailer(i, hashlen)
+	if err != nil {
+		return err
+	}
+
+	checksums := make([]byte, 2*hashlen)
+	if _, err = i.readAt(checksums, trailer); err != nil {
+		return fmt.Errorf("gitobj/pack: could not read index checksums: %s", err)
+	}
+	if _, err = i.readAt(make([]byte, 1), trailer+2*hashlen); err != io.EOF {
+		return fmt.Errorf("gitobj/pack: unexpected data after index checksums")
+	}
+
+	if !bytes.Equal(checksums[:hashlen], sum) {
+		return fmt.Errorf("gitobj/pack: index does not match packfile checksum")
+	}
+
+	if _, err = io.Copy(h, io.NewSectionReader(i.r, 0, trailer+hashlen)); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), checksums[hashlen:]) {
+		return fmt.Errorf("gitobj/pack: index checksum mismatch")
+	}
+	return nil
+}
+
+// indexTrailer returns the offset of the checksums which end the index "i".
+func indexTrailer(i *Index, hashlen int64) (int64, error) {
+	count := int64(i.Count())
+
+	switch v := i.version.(type) {
+	case *V1:
+		return indexOffsetV1Start + count*(indexObjectSmallOffsetWidth+hashlen), nil
+	case *V2:
+		// The large offset table holds one entry for each small offset
+		// with its most significant bit set.
+		offs := make([]byte, indexObjectSmallOffsetWidth*count)
+		if _, err := i.readAt(offs, v2SmallOffsetOffset(0, count, hashlen)); err != nil {
+			return 0, err
+		}
+
+		var large int64
+		for at := int64(0); at < count; at++ {
+			if binary.BigEndian.Uint32(offs[at*indexObjectSmallOffsetWidth:])&0x80000000 != 0 {
+				large++
+			}
+		}
+		return v2LargeOffsetOffset(large, count, hashlen), nil
+	case *V3:
+		return v.trailer, nil
+	}
+	return 0, fmt.Errorf("gitobj/pack: unknown index version: %T", i.version)
+}
+
+// crcOffset returns the offset in the index "i" of the CRC-32 of the object at
+// "at" in its sorted names, which is the "pos"th object in the packfile, or
+// false if the index does not store CRCs.
+func crcOffset(i *Index, at, pos, count, hashlen int64) (int64, bool) {
+	switch v := i.version.(type) {
+	case *V2:
+		return indexOffsetV2Start + hashlen*count + indexObjectCRCWidth*at, true
+	case *V3:
+		return v.crcOffset(pos), true
+	}
+	return 0, false
+}
+
+// verifyObjects checks that the objects in "p" occupy the packfile from the end
+// of its header up to "end" without any gaps, match their CRC-32 (if the index
+// has them), and hash (using "h") to the names under which they are indexed.
+func verifyObjects(p *Packfile, end int64, h hash.Hash) error {
+	order, err := p.idx.offsetOrder()
+	if err != nil {
+		return err
+	}
+
+	count := int64(p.idx.Count())
+	hashlen := int64(h.Size())
+
+	for k, at := range order {
+		name, err := p.idx.version.Name(p.idx, int64(at))
+		if err != nil {
+			return err
+		}
+
+		entry, err := p.idx.version.Entry(p.idx, int64(at))
+		if err != nil {
+			return err
+		}
+		start := int64(entry.PackOffset)
+
+		// Objects are laid out back-to-back, so each ends where the
+		// next begins, and the last ends at the trailing checksum.
+		next := end
+		if k+1 < len(order) {
+			e, err := p.idx.version.Entry(p.idx, int64(order[k+1]))
+			if err != nil {
+				return err
+			}
+			next = int64(e.PackOffset)
+		}
+
+		if (k == 0 && start != packHeaderWidth) || next <= start || next > end {
+			return fmt.Errorf("gitobj/pack: invalid offset %d for object %x", start, name)
+		}
+
+		if crc, ok := crcOffset(p.idx, int64(at), int64(k), count, hashlen); ok {
+			var want [indexObjectCRCWidth]byte
+			if _, err = p.idx.readAt(want[:], crc); err != nil {
+				return err
+			}
+
+			got := crc32.NewIEEE()
+			if _, err = io.Copy(got, io.NewSectionReader(p.r, start, next-start)); err != nil {
+				return err
+			}
+			if got.Sum32() != binary.BigEndian.Uint32(want[:]) {
+				return fmt.Errorf("gitobj/pack: CRC mismatch for object %x", name)
+			}
+		}
+
+		chain, err := p.find(start)
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not read object %x: %s", name, err)
+		}
+
+		contents, err := chain.Unpack()
+		if err != nil {
+			return fmt.Errorf("gitobj/pack: could not unpack object %x: %s", name, err)
+		}
+
+		h.Reset()
+		fmt.Fprintf(h, "%s %d\x00", chain.Type(), len(contents))
+		h.Write(contents)
+
+		if sum := h.Sum(nil); !bytes.Equal(sum, name) {
+			return fmt.Errorf("gitobj/pack: object %x hashes to %x", name, sum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPack(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+
+	assert.NoError(t, VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New))
+}
+
+func TestVerifyPackBadHeader(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	copy(data, "KCAP")
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.Equal(t, errBadPackHeader, err)
+}
+
+func TestVerifyPackUnsupportedVersion(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	binary.BigEndian.PutUint32(data[4:], 4)
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.EqualError(t, err, "gitobj/pack: unsupported version: 4")
+}
+
+func TestVerifyPackChecksumMismatch(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	data[len(data)-1] ^= 0xff
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.EqualError(t, err, "gitobj/pack: packfile checksum mismatch")
+}
+
+func TestVerifyPackIndexChecksumMismatch(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	idx[len(idx)-1] ^= 0xff
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.EqualError(t, err, "gitobj/pack: index checksum mismatch")
+}
+
+func TestVerifyPackIndexForOtherPackfile(t *testing.T) {
+	idx, _ := verifyFixture(t, nil)
+	_, data := verifyFixture(t, func(data []byte) {
+		// Change the version, which changes the trailing checksum.
+		binary.BigEndian.PutUint32(data[4:], 3)
+	})
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.EqualError(t, err, "gitobj/pack: index does not match packfile checksum")
+}
+
+func TestVerifyPackCountMismatch(t *testing.T) {
+	idx, data := verifyFixture(t, func(data []byte) {
+		binary.BigEndian.PutUint32(data[8:], 3)
+	})
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	assert.EqualError(t, err, "gitobj/pack: index has 2 objects, packfile has 3")
+}
+
+func TestVerifyPackCorruptObject(t *testing.T) {
+	idx, data := verifyFixture(t, func(data []byte) {
+		// Flip a bit within the compressed contents of the first
+		// object, leaving its header intact.
+		data[packHeaderWidth+4] ^= 0x1
+	})
+
+	err := VerifyPack(bytes.NewReader(idx), bytes.NewReader(data), sha1.New)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gitobj/pack: CRC mismatch for object")
+}
+
+func TestPackfileVerify(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+
+	p := verifyFixturePackfile(t, idx, data)
+	assert.NoError(t, p.Verify())
+
+	// Flipping any byte of the packfile is noticed.
+	data[packHeaderWidth+1] ^= 0xff
+	assert.EqualError(t, p.Verify(), "gitobj/pack: packfile checksum mismatch")
+}
+
+func TestPackfileVerifyIndexChecksumMismatch(t *testing.T) {
+	idx, data := verifyFixture(t, nil)
+	// Flip a byte of the first CRC, which is otherwise checked later.
+	idx[indexOffsetV2Start+2*sha1.Size] ^= 0xff
+
+	p := verifyFixturePackfile(t, idx, data)
+
+	assert.EqualError(t, p.Verify(), "gitobj/pack: index checksum mismatch")
+}
+
+func TestNewSetSkipsIndexForOtherPackfile(t *testing.T) {
+	good, goodData := verifyFixture(t, nil)
+	idx, _ := verifyFixture(t, nil)
+	_, data := verifyFixture(t, func(data []byte) {
+		binary.BigEndian.PutUint32(data[4:], 3)
+	})
+
+	dir, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-fixture.idx"), idx, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-fixture.pack"), data, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-good.idx"), good, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, "pack-good.pack"), goodData, 0644))
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	// The mismatched packfile is left out, but the objects in the other
+	// can still be read.
+	require.Len(t, set.packs, 1)
+	require.Len(t, set.Skipped(), 1)
+	assert.EqualError(t, set.Skipped()[0], "gitobj/pack: skipped pack-fixture: "+
+		"gitobj/pack: index does not match packfile checksum")
+
+	for _, o := range objectsForIndex(t, good) {
+		_, err := set.Object(o.name)
+		assert.NoError(t, err)
+	}
+}
+
+// verifyFixturePackfile returns the packfile given by "data", indexed by "idx".
+func verifyFixturePackfile(t *testing.T, idx, data []byte) *Packfile {
+	p, err := DecodePackfile(bytes.NewReader(data), sha1.New())
+	require.NoError(t, err)
+
+	p.idx, err = DecodeIndex(bytes.NewReader(idx), sha1.New())
+	require.NoError(t, err)
+	return p
+}
+
+// verifyFixture returns a version 2 index and packfile containing two blobs,
+// with correct checksums and CRCs. If "mutate" is non-nil, it is called with the
+// packfile data before the packfile checksum is computed.
+func verifyFixture(t *testing.T, mutate func(data []byte)) (idx, data []byte) {
+	type object struct {
+		name   []byte
+		offset uint32
+		crc    uint32
+	}
+
+	data = make([]byte, packHeaderWidth)
+	copy(data, packHeader)
+	binary.BigEndian.PutUint32(data[4:], 2)
+	binary.BigEndian.PutUint32(data[8:], 2)
+
+	var objects []*object
+	for _, contents := range []string{"Hello, world!\n", "Goodbye, world!\n"} {
+		compressed, err := compress(contents)
+		require.NoError(t, err)
+
+		raw := append([]byte{0x30 | byte(len(contents)&0xf), byte(len(contents) >> 4)}, compressed...)
+		raw[0] |= 0x80
+
+		h := sha1.New()
+		fmt.Fprintf(h, "blob %d\x00%s", len(contents), contents)
+
+		objects = append(objects, &object{
+			name:   h.Sum(nil),
+			offset: uint32(len(data)),
+			crc:    crc32.ChecksumIEEE(raw),
+		})
+		data = append(data, raw...)
+	}
+
+	if mutate != nil {
+		mutate(data)
+	}
+	data = append(data, checksum(sha1.New(), data)...)
+
+	sort.Slice(objects, func(i, j int) bool {
+		return bytes.Compare(objects[i].name, objects[j].name) < 0
+	})
+
+	idx = append([]byte{}, indexHeader...)
+	idx = append(idx, 0x0, 0x0, 0x0, 0x2)
+	for i := 0; i < indexFanoutEntries; i++ {
+		var n uint32
+		for _, o := range objects {
+			if int(o.name[0]) <= i {
+				n++
+			}
+		}
+		idx = appendUint32(idx, n)
+	}
+	for _, o := range objects {
+		idx = append(idx, o.name...)
+	}
+	for _, o := range objects {
+		idx = appendUint32(idx, o.crc)
+	}
+	for _, o := range objects {
+		idx = appendUint32(idx, o.offset)
+	}
+	idx = append(idx, data[len(data)-sha1.Size:]...)
+	idx = append(idx, checksum(sha1.New(), idx)...)
+
+	return idx, data
+}
+
+// checksum returns the hash of "data" using "h".
+func checksum(h hash.Hash, data []byte) []byte {
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// appendUint32 appends "n" to "buf" in network byte order.
+func appendUint32(buf []byte, n uint32) []byte {
+	var x [4]byte
+	binary.BigEndian.PutUint32(x[:], n)
+	return append(buf, x[:]...)
+}
@@ -0,0 +1,214 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// patchContext is the number of lines of unchanged context written
+	// around each change in a patch.
+	patchContext = 3
+
+	// patchDateFormat is the format of the "Date" header of a patch, as
+	// given by RFC 2822.
+	patchDateFormat = "Mon, 2 Jan 2006 15:04:05 -0700"
+
+	// binarySniffLength is the number of leading bytes of a blob which are
+	// inspected to determine whether it is binary.
+	binarySniffLength = 8000
+)
+
+// FormatPatch writes the commit named "commit" to "w" as a patch in the style
+// of "git format-patch": a mailbox header giving the commit's author, date, and
+// subject, followed by the rest of its message and a unified diff of the
+// changes it makes to its first parent (or to the empty tree, if it has
+// none).
+//
+// Changes to binary files are noted as such, rather than written out in full.
+//
+// If the commit, its first parent, or any tree or blob they refer to could not
+// be read, an error is returned.
+func (o *ObjectDatabase) FormatPatch(w io.Writer, commit []byte) error {
+	c, err := o.Commit(commit)
+	if err != nil {
+		return err
+	}
+
+	author, err := ParseSignature(c.Author)
+	if err != nil {
+		return err
+	}
+
+	first, ok, err := o.firstParent(commit, c)
+	if err != nil {
+		return err
+	}
+
+	var parent []byte
+	if ok {
+		p, err := o.Commit(first)
+		if err != nil {
+			return err
+		}
+		parent = p.TreeID
+	}
+
+	diffs, err := o.DiffTrees(parent, c.TreeID)
+	if err != nil {
+		return err
+	}
+
+	subject, body := splitMessage(c.Message)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %x Mon Sep 17 00:00:00 2001\n", commit)
+	fmt.Fprintf(&buf, "From: %s <%s>\n", author.Name, author.Email)
+	fmt.Fprintf(&buf, "Date: %s\n", author.When.Format(patchDateFormat))
+	fmt.Fprintf(&buf, "Subject: [PATCH] %s\n\n", subject)
+	if len(body) > 0 {
+		fmt.Fprintf(&buf, "%s\n", body)
+	}
+	buf.WriteString("---\n")
+
+	if _, err = buf.WriteTo(w); err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		if err = o.writeFilePatch(w, diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFilePatch writes the "diff --git" section describing a single change to
+// "w".
+func (o *ObjectDatabase) writeFilePatch(w io.Writer, diff *TreeDiff) error {
+	oldPath, newPath := "a/"+diff.Path, "b/"+diff.Path
+	oldOid, newOid := strings.Repeat("0", abbrevLength), strings.Repeat("0", abbrevLength)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "diff --git %s %s\n", oldPath, newPath)
+
+	switch diff.Status {
+	case DiffAdded:
+		fmt.Fprintf(&buf, "new file mode %06o\n", diff.New.Filemode)
+		oldPath = "/dev/null"
+	case DiffDeleted:
+		fmt.Fprintf(&buf, "deleted file mode %06o\n", diff.Old.Filemode)
+		newPath = "/dev/null"
+	case DiffModified:
+		if diff.Old.Filemode != diff.New.Filemode {
+			fmt.Fprintf(&buf, "old mode %06o\n", diff.Old.Filemode)
+			fmt.Fprintf(&buf, "new mode %06o\n", diff.New.Filemode)
+		}
+	}
+
+	if diff.Old != nil {
+		oldOid = abbrev(diff.Old.Oid)
+	}
+	if diff.New != nil {
+		newOid = abbrev(diff.New.Oid)
+	}
+
+	if diff.Status == DiffModified && bytes.Equal(diff.Old.Oid, diff.New.Oid) {
+		// Only the filemode changed, so there is no content to show.
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	fmt.Fprintf(&buf, "index %s..%s", oldOid, newOid)
+	if diff.Status == DiffModified && diff.Old.Filemode == diff.New.Filemode {
+		fmt.Fprintf(&buf, " %06o", diff.New.Filemode)
+	}
+	buf.WriteString("\n")
+
+	a, err := o.patchContents(diff.Old)
+	if err != nil {
+		return err
+	}
+	b, err := o.patchContents(diff.New)
+	if err != nil {
+		return err
+	}
+
+	if isBinary(a) || isBinary(b) {
+		fmt.Fprintf(&buf, "Binary files %s and %s differ\n", oldPath, newPath)
+
+		_, err = buf.WriteTo(w)
+		return err
+	}
+
+	fmt.Fprintf(&buf, "--- %s\n", oldPath)
+	fmt.Fprintf(&buf, "+++ %s\n", newPath)
+	if _, err = buf.WriteTo(w); err != nil {
+		return err
+	}
+
+	return writeUnifiedDiff(w, splitLines(a), splitLines(b), patchContext)
+}
+
+// patchContents returns the contents of the given entry as shown in a patch:
+// the contents of blobs (and symbolic links), and the recorded commit of
+// gitlinks. It returns nil if the entry is nil.
+func (o *ObjectDatabase) patchContents(entry *TreeEntry) ([]byte, error) {
+	if entry == nil {
+		return nil, nil
+	}
+
+	if entry.Type() == CommitObjectType {
+		return []byte(fmt.Sprintf("Subproject commit %x\n", entry.Oid)), nil
+	}
+
+	blob, err := o.Blob(entry.Oid)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	return ioutil.ReadAll(blob.Contents)
+}
+
+// isBinary returns whether "data" appears to be binary rather than text, which
+// is the case if its leading bytes contain a NUL byte.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLength {
+		data = data[:binarySniffLength]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines splits "data" into lines, each of which includes its trailing
+// newline (if any).
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, data[:i])
+		data = data[i:]
+	}
+	return lines
+}
+
+// splitMessage splits a commit message into its subject, which is its first
+// paragraph joined onto a single line, and its body, which is the remainder of
+// the message with surrounding blank lines removed.
+func splitMessage(message string) (subject, body string) {
+	lines := strings.Split(strings.TrimLeft(message, "\n"), "\n")
+
+	var para []string
+	for len(lines) > 0 && len(strings.TrimSpace(lines[0])) > 0 {
+		para = append(para, strings.TrimSpace(lines[0]))
+		lines = lines[1:]
+	}
+
+	return strings.Join(para, " "), strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,146 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPatch(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	v1, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	v2, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, there!\n")))
+	require.NoError(t, err)
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: v1, Filemode: 0100644},
+		{Name: "removed.txt", Oid: v1, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: v2, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	parent := writeTestCommit(t, odb, a, "parent\n")
+	commit := writeTestCommit(t, odb, b, "Greet someone specific\n"+
+		"instead of everyone\n\nThis is the body.\n", parent)
+
+	var buf bytes.Buffer
+	require.NoError(t, odb.FormatPatch(&buf, commit))
+
+	assert.Equal(t, fmt.Sprintf("From %x Mon Sep 17 00:00:00 2001\n", commit)+
+		"From: Jane Doe <jane@example.com>\n"+
+		"Date: Tue, 10 Nov 2009 23:00:00 +0000\n"+
+		"Subject: [PATCH] Greet someone specific instead of everyone\n"+
+		"\n"+
+		"This is the body.\n"+
+		"---\n"+
+		"diff --git a/hello.txt b/hello.txt\n"+
+		fmt.Sprintf("index %s..%s 100644\n", abbrev(v1), abbrev(v2))+
+		"--- a/hello.txt\n"+
+		"+++ b/hello.txt\n"+
+		"@@ -1 +1 @@\n"+
+		"-Hello, world!\n"+
+		"+Hello, there!\n"+
+		"diff --git a/removed.txt b/removed.txt\n"+
+		"deleted file mode 100644\n"+
+		fmt.Sprintf("index %s..0000000\n", abbrev(v1))+
+		"--- a/removed.txt\n"+
+		"+++ /dev/null\n"+
+		"@@ -1 +0,0 @@\n"+
+		"-Hello, world!\n", buf.String())
+}
+
+func TestFormatPatchRootCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	commit := writeTestCommit(t, odb, tree, "Initial commit\n")
+
+	var buf bytes.Buffer
+	require.NoError(t, odb.FormatPatch(&buf, commit))
+
+	assert.Contains(t, buf.String(), "Subject: [PATCH] Initial commit\n\n---\n"+
+		"diff --git a/hello.txt b/hello.txt\n"+
+		"new file mode 100644\n"+
+		fmt.Sprintf("index 0000000..%s\n", abbrev(blob))+
+		"--- /dev/null\n"+
+		"+++ b/hello.txt\n"+
+		"@@ -0,0 +1 @@\n"+
+		"+Hello, world!\n")
+}
+
+func TestFormatPatchBinaryAndModeChanges(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	bin, err := odb.WriteBlob(NewBlobFromBytes([]byte("\x00\x01\x02")))
+	require.NoError(t, err)
+	script, err := odb.WriteBlob(NewBlobFromBytes([]byte("#!/bin/sh\n")))
+	require.NoError(t, err)
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "script.sh", Oid: script, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "data.bin", Oid: bin, Filemode: 0100644},
+		{Name: "script.sh", Oid: script, Filemode: 0100755},
+	}})
+	require.NoError(t, err)
+
+	parent := writeTestCommit(t, odb, a, "parent\n")
+	commit := writeTestCommit(t, odb, b, "child\n", parent)
+
+	var buf bytes.Buffer
+	require.NoError(t, odb.FormatPatch(&buf, commit))
+
+	assert.Contains(t, buf.String(), "---\n"+
+		"diff --git a/data.bin b/data.bin\n"+
+		"new file mode 100644\n"+
+		fmt.Sprintf("index 0000000..%s\n", abbrev(bin))+
+		"Binary files /dev/null and b/data.bin differ\n"+
+		"diff --git a/script.sh b/script.sh\n"+
+		"old mode 100644\n"+
+		"new mode 100755\n")
+	assert.NotContains(t, buf.String(), "+++")
+}
+
+func TestFormatPatchMissingCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	var buf bytes.Buffer
+	err := odb.FormatPatch(&buf, []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestSplitMessage(t *testing.T) {
+	for desc, c := range map[string]struct {
+		Message, Subject, Body string
+	}{
+		"subject only":     {"subject\n", "subject", ""},
+		"subject and body": {"subject\n\nbody\n\nmore\n", "subject", "body\n\nmore"},
+		"long subject":     {"first\nsecond\n\nbody\n", "first second", "body"},
+		"leading newlines": {"\n\nsubject\n", "subject", ""},
+		"empty":            {"", "", ""},
+	} {
+		subject, body := splitMessage(c.Message)
+
+		assert.Equal(t, c.Subject, subject, desc)
+		assert.Equal(t, c.Body, body, desc)
+	}
+}
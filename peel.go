@@ -0,0 +1,41 @@
+package gitobj
+
+import (
+	"fmt"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// maxPeelDepth is the greatest number of tags which Peel follows before giving
+// up, so that a chain of tags which refer to one another cannot be followed
+// forever.
+const maxPeelDepth = 64
+
+// Peel follows the chain of tags beginning with the object named "oid", each of
+// which refers to the next, until it reaches an object which is not a tag, and
+// returns that object's type and ID, as "git rev-parse <oid>^{}" would. If the
+// object is not a tag, its own type and ID are returned.
+//
+// The type of each object is read from the object itself, rather than from the
+// tag which refers to it. If any object in the chain does not exist, or could
+// not be read, or if more than 64 tags are followed, an error is returned.
+func (o *ObjectDatabase) Peel(oid []byte) (ObjectType, []byte, error) {
+	sha := oid
+	for depth := 0; depth <= maxPeelDepth; depth++ {
+		typ, err := o.objectType(sha)
+		if err != nil {
+			return UnknownObjectType, nil, err
+		} else if typ == UnknownObjectType {
+			return UnknownObjectType, nil, errors.NoSuchObject(sha)
+		} else if typ != TagObjectType {
+			return typ, sha, nil
+		}
+
+		tag, err := o.Tag(sha)
+		if err != nil {
+			return UnknownObjectType, nil, err
+		}
+		sha = tag.Object
+	}
+	return UnknownObjectType, nil, fmt.Errorf("gitobj: cannot peel %x: more than %d nested tags", oid, maxPeelDepth)
+}
@@ -0,0 +1,63 @@
+package gitobj
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeel(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := writeDatedCommit(t, odb, 1, nil)
+	inner := writeDatedTag(t, odb, 1, "inner", commit, CommitObjectType)
+	outer := writeDatedTag(t, odb, 2, "outer", inner, TagObjectType)
+
+	for _, oid := range [][]byte{commit, inner, outer} {
+		typ, sha, err := odb.Peel(oid)
+		require.NoError(t, err)
+
+		assert.Equal(t, CommitObjectType, typ)
+		assert.Equal(t, commit, sha)
+	}
+}
+
+func TestPeelTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	typ, sha, err := odb.Peel(writeDatedTag(t, odb, 1, "tree", tree, TreeObjectType))
+	require.NoError(t, err)
+
+	assert.Equal(t, TreeObjectType, typ)
+	assert.Equal(t, tree, sha)
+}
+
+func TestPeelMissingObject(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	missing := make([]byte, 20)
+	tag := writeDatedTag(t, odb, 1, "missing", missing, CommitObjectType)
+
+	_, _, err := odb.Peel(tag)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestPeelTooDeep(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := writeDatedCommit(t, odb, 1, nil)
+
+	sha := writeDatedTag(t, odb, 1, "tag", commit, CommitObjectType)
+	for i := 0; i < maxPeelDepth; i++ {
+		sha = writeDatedTag(t, odb, 1, "tag", sha, TagObjectType)
+	}
+
+	_, _, err := odb.Peel(sha)
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: cannot peel %x: more than 64 nested tags", sha))
+}
@@ -0,0 +1,150 @@
+package gitobj
+
+import (
+	"fmt"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// bitmapStorage is implemented by storage backends which can answer
+// reachability queries using reachability bitmaps.
+type bitmapStorage interface {
+	// HasBitmap returns whether the storage has any bitmaps.
+	HasBitmap() bool
+	// Reachable calls "fn" for each object reachable from the commit
+	// "oid", and returns true, if that commit is bitmapped. Otherwise, it
+	// returns false without calling "fn".
+	Reachable(oid []byte, fn pack.ReachableFn) (bool, error)
+}
+
+// reachableFn is a function called by walkReachable for each reachable object.
+type reachableFn func(sha []byte, typ ObjectType) error
+
+// walkReachable calls "fn" once for each object reachable from the given tips,
+// including the tips themselves: the targets of tags, the trees and parents of
+// commits, and the entries of trees (excluding gitlinks, whose commits are not
+// expected to be present in this database).
+//
+// Blobs are reported based on the mode of the tree entry that refers to them,
+// and are not read from the database. If a commit is described by a
+// reachability bitmap, the objects reachable from it are reported from that
+// bitmap instead of being walked.
+//
+// If any object could not be read, or "fn" returns an error, the walk is
+// stopped and that error is returned.
+func (o *ObjectDatabase) walkReachable(tips [][]byte, fn reachableFn) error {
+	type item struct {
+		sha []byte
+		typ ObjectType
+	}
+
+	seen := make(map[string]struct{})
+
+	var bitmaps []bitmapStorage
+	for _, s := range o.storages() {
+		if b, ok := s.(bitmapStorage); ok && b.HasBitmap() {
+			bitmaps = append(bitmaps, b)
+		}
+	}
+
+	var stack []item
+	for _, tip := range tips {
+		stack = append(stack, item{sha: tip, typ: UnknownObjectType})
+	}
+
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, ok := seen[string(next.sha)]; ok {
+			continue
+		}
+
+		if len(bitmaps) > 0 && (next.typ == CommitObjectType || next.typ == UnknownObjectType) {
+			ok, err := reachableFromBitmaps(bitmaps, next.sha, seen, fn)
+			if err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
+		seen[string(next.sha)] = struct{}{}
+
+		if next.typ == BlobObjectType {
+			if err := fn(next.sha, BlobObjectType); err != nil {
+				return err
+			}
+			continue
+		}
+
+		obj, err := o.Object(next.sha)
+		if err != nil {
+			return err
+		}
+
+		if next.typ != UnknownObjectType && obj.Type() != next.typ {
+			if blob, ok := obj.(*Blob); ok {
+				blob.Close()
+			}
+			return &UnexpectedObjectType{Got: obj.Type(), Wanted: next.typ}
+		}
+
+		if err = fn(next.sha, obj.Type()); err != nil {
+			if blob, ok := obj.(*Blob); ok {
+				blob.Close()
+			}
+			return err
+		}
+
+		switch obj := obj.(type) {
+		case *Blob:
+			if err = obj.Close(); err != nil {
+				return err
+			}
+		case *Tree:
+			for i := len(obj.Entries) - 1; i >= 0; i-- {
+				entry := obj.Entries[i]
+
+				typ := entry.Type()
+				if typ == CommitObjectType {
+					continue
+				}
+				stack = append(stack, item{sha: entry.Oid, typ: typ})
+			}
+		case *Commit:
+			parents, err := o.parents(next.sha, obj)
+			if err != nil {
+				return err
+			}
+			for i := len(parents) - 1; i >= 0; i-- {
+				stack = append(stack, item{sha: parents[i], typ: CommitObjectType})
+			}
+			stack = append(stack, item{sha: obj.TreeID, typ: TreeObjectType})
+		case *Tag:
+			stack = append(stack, item{sha: obj.Object, typ: obj.ObjectType})
+		default:
+			return fmt.Errorf("gitobj: unknown object type: %s", obj.Type())
+		}
+	}
+	return nil
+}
+
+// reachableFromBitmaps calls "fn" for each object reachable from the commit
+// "sha" which is not already in "seen" (adding it), as given by the first of the
+// given bitmaps to describe that commit. It returns whether any did.
+func reachableFromBitmaps(bitmaps []bitmapStorage, sha []byte, seen map[string]struct{}, fn reachableFn) (bool, error) {
+	for _, b := range bitmaps {
+		ok, err := b.Reachable(sha, func(name []byte, typ pack.PackedObjectType) error {
+			if _, ok := seen[string(name)]; ok {
+				return nil
+			}
+			seen[string(name)] = struct{}{}
+
+			return fn(name, ObjectTypeFromString(typ.String()))
+		})
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
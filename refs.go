@@ -0,0 +1,211 @@
+package gitobj
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymrefDepth is the number of symbolic references followed when resolving a
+// reference before giving up, as in Git.
+const maxSymrefDepth = 5
+
+// TagKind is the kind of a tag reference.
+type TagKind uint8
+
+const (
+	// LightweightTag indicates that a tag reference points directly at
+	// the tagged object.
+	LightweightTag TagKind = iota
+	// AnnotatedTag indicates that a tag reference points at a tag object,
+	// which in turn names the tagged object.
+	AnnotatedTag
+)
+
+// String implements fmt.Stringer and returns a human-readable name for the
+// kind.
+func (k TagKind) String() string {
+	switch k {
+	case LightweightTag:
+		return "lightweight"
+	case AnnotatedTag:
+		return "annotated"
+	}
+	return fmt.Sprintf("<unknown tag kind %d>", uint8(k))
+}
+
+// ResolveTagRef reads the tag reference "name", which is either a full
+// reference name, such as "refs/tags/v1.0.0", or a name relative to
+// "refs/tags", and returns whether it is lightweight or annotated, along with
+// the object ID that it points at. If it is annotated, that object is a tag, and
+// it is returned decoded; otherwise, the returned *Tag is nil.
+//
+// The reference is read from the repository containing the object directory
+// (see: Root), from either its loose or packed references.
+//
+// If the database has no object directory, the reference does not exist, or it
+// or the object it points at could not be read, an error is returned instead.
+func (o *ObjectDatabase) ResolveTagRef(name string) (kind TagKind, target []byte, tag *Tag, err error) {
+	if !strings.HasPrefix(name, "refs/") {
+		name = "refs/tags/" + name
+	}
+
+	target, err = o.resolveRef(name)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	typ, err := o.objectType(target)
+	if err != nil {
+		return 0, nil, nil, err
+	} else if typ == UnknownObjectType {
+		return 0, nil, nil, fmt.Errorf("gitobj: reference %s points at missing object %x", name, target)
+	} else if typ != TagObjectType {
+		return LightweightTag, target, nil, nil
+	}
+
+	if tag, err = o.Tag(target); err != nil {
+		return 0, nil, nil, err
+	}
+	return AnnotatedTag, target, tag, nil
+}
+
+// HeadRef returns the name of the reference that HEAD points at, such as
+// "refs/heads/main", without resolving it to an object ID. The reference need
+// not exist, as is the case for the default branch of an empty repository.
+//
+// HEAD is read from the repository containing the object directory (see: Root).
+//
+// If HEAD is detached, a *DetachedHeadError giving the object ID that it points
+// at is returned. If the database has no object directory, or HEAD could not be
+// read or is malformed, an error is returned instead.
+func (o *ObjectDatabase) HeadRef() (string, error) {
+	dir, err := o.gitDir()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := readRef(dir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(value, "ref: ") {
+		name := strings.TrimSpace(strings.TrimPrefix(value, "ref: "))
+		if !validRefName(name) || name == "HEAD" {
+			return "", fmt.Errorf("gitobj: malformed reference HEAD: %q", value)
+		}
+		return name, nil
+	}
+
+	sha, err := hex.DecodeString(value)
+	if err != nil || len(sha) != o.HashLen() {
+		return "", fmt.Errorf("gitobj: malformed reference HEAD: %q", value)
+	}
+	return "", &DetachedHeadError{Oid: sha}
+}
+
+// resolveRef returns the object ID named by the reference "name", following
+// any symbolic references. Loose references take precedence over packed ones,
+// as in Git.
+func (o *ObjectDatabase) resolveRef(name string) ([]byte, error) {
+	dir, err := o.gitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		if !validRefName(name) {
+			return nil, fmt.Errorf("gitobj: invalid reference name: %q", name)
+		}
+
+		value, err := readRef(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(value, "ref: ") {
+			name = strings.TrimSpace(strings.TrimPrefix(value, "ref: "))
+			continue
+		}
+
+		sha, err := hex.DecodeString(value)
+		if err != nil || len(sha) != o.HashLen() {
+			return nil, fmt.Errorf("gitobj: malformed reference %s: %q", name, value)
+		}
+		return sha, nil
+	}
+	return nil, fmt.Errorf("gitobj: too many levels of symbolic references: %s", name)
+}
+
+// gitDir returns the repository directory containing the database's object
+// directory, or an error if it has none.
+func (o *ObjectDatabase) gitDir() (string, error) {
+	root, ok := o.Root()
+	if !ok {
+		return "", fmt.Errorf("gitobj: cannot read references without an object directory")
+	}
+	return filepath.Dir(root), nil
+}
+
+// readRef returns the value of the reference "name" in the repository "dir",
+// which is either an object ID in hex, or "ref: " followed by the name of
+// another reference.
+func readRef(dir, name string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("gitobj: no such reference: %s", name)
+		}
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			// Skip the header, and the peeled values of
+			// annotated tags.
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 && fields[1] == name {
+			return fields[0], nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("gitobj: no such reference: %s", name)
+}
+
+// validRefName returns whether "name" may safely be read as a reference: it is
+// either "HEAD", or beneath "refs/", and has no empty, "." or ".." components.
+func validRefName(name string) bool {
+	if name == "HEAD" {
+		return true
+	}
+	if !strings.HasPrefix(name, "refs/") {
+		return false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
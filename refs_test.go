@@ -0,0 +1,163 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTagRefLightweight(t *testing.T) {
+	dir, odb, commit, _ := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	kind, target, tag, err := odb.ResolveTagRef("lightweight")
+
+	assert.NoError(t, err)
+	assert.Equal(t, LightweightTag, kind)
+	assert.Equal(t, commit, target)
+	assert.Nil(t, tag)
+}
+
+func TestResolveTagRefAnnotated(t *testing.T) {
+	dir, odb, commit, annotated := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	kind, target, tag, err := odb.ResolveTagRef("refs/tags/v1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, AnnotatedTag, kind)
+	assert.Equal(t, annotated, target)
+	require.NotNil(t, tag)
+	assert.Equal(t, commit, tag.Object)
+	assert.Equal(t, "v1.0.0", tag.Name)
+}
+
+func TestResolveTagRefPrefersLooseReferences(t *testing.T) {
+	dir, odb, commit, _ := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	writeTestRef(t, dir, "refs/tags/v1.0.0", fmt.Sprintf("%x\n", commit))
+
+	kind, target, _, err := odb.ResolveTagRef("v1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, LightweightTag, kind)
+	assert.Equal(t, commit, target)
+}
+
+func TestResolveTagRefMissing(t *testing.T) {
+	dir, odb, _, _ := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	_, _, _, err := odb.ResolveTagRef("missing")
+
+	assert.EqualError(t, err, "gitobj: no such reference: refs/tags/missing")
+}
+
+func TestResolveTagRefInvalidName(t *testing.T) {
+	dir, odb, _, _ := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	_, _, _, err := odb.ResolveTagRef("../../config")
+
+	assert.EqualError(t, err, `gitobj: invalid reference name: "refs/tags/../../config"`)
+}
+
+func TestResolveTagRefWithoutObjectDirectory(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	_, _, _, err := odb.ResolveTagRef("v1.0.0")
+
+	assert.EqualError(t, err, "gitobj: cannot read references without an object directory")
+}
+
+func TestHeadRef(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	writeTestRef(t, dir, "HEAD", "ref: refs/heads/main\n")
+
+	name, err := odb.HeadRef()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/heads/main", name)
+}
+
+func TestHeadRefDetached(t *testing.T) {
+	dir, odb, commit, _ := newTestTagRepository(t)
+	defer os.RemoveAll(dir)
+
+	writeTestRef(t, dir, "HEAD", fmt.Sprintf("%x\n", commit))
+
+	name, err := odb.HeadRef()
+
+	assert.Empty(t, name)
+	require.IsType(t, &DetachedHeadError{}, err)
+	assert.Equal(t, commit, err.(*DetachedHeadError).Oid)
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: HEAD is detached at %x", commit))
+}
+
+func TestHeadRefMalformed(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	for _, contents := range []string{"ref: ../config\n", "ref: HEAD\n", "not an object\n"} {
+		writeTestRef(t, dir, "HEAD", contents)
+
+		_, err := odb.HeadRef()
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "gitobj: malformed reference HEAD")
+	}
+}
+
+func TestHeadRefMissing(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	_, err := odb.HeadRef()
+
+	assert.EqualError(t, err, "gitobj: no such reference: HEAD")
+}
+
+// newTestTagRepository returns a repository directory and its database,
+// holding a commit, a loose lightweight tag "lightweight" of it, and a packed
+// annotated tag "v1.0.0" of it, along with the IDs of the commit and the tag
+// object. The directory should be removed by the caller.
+func newTestTagRepository(t *testing.T) (dir string, odb *ObjectDatabase, commit, tag []byte) {
+	dir, odb = newTestRepository(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+	commit = writeTestCommit(t, odb, tree, "initial commit")
+
+	tag, err = odb.WriteTag(&Tag{
+		Object:     commit,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1234567890 +0000",
+		Message:    "Version 1.0.0\n",
+	})
+	require.NoError(t, err)
+
+	writeTestRef(t, dir, "refs/tags/lightweight", fmt.Sprintf("%x\n", commit))
+	writeTestRef(t, dir, "packed-refs", fmt.Sprintf(
+		"# pack-refs with: peeled fully-peeled sorted \n%x refs/tags/v1.0.0\n^%x\n",
+		tag, commit))
+
+	return dir, odb, commit, tag
+}
+
+// writeTestRef writes "contents" to the file "name" beneath the repository
+// directory "dir".
+func writeTestRef(t *testing.T, dir, name, contents string) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+}
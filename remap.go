@@ -0,0 +1,69 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// RemapTreeBlobs rewrites the tree named "root" so that each blob entry
+// (including symbolic links, but not gitlinks) whose ID is a key of "mapping",
+// hex-encoded, instead refers to the ID it maps to, and returns the ID of the
+// rewritten tree. This is the core of converting files to or from Git LFS
+// pointers throughout a tree.
+//
+// Rewritten subtrees are written bottom-up. A subtree containing no mapped
+// blobs is left as it is, and a subtree which appears at more than one path is
+// only rewritten once. If no blobs are mapped, "root" itself is returned.
+//
+// If the tree or any of its subtrees could not be read, or a rewritten tree
+// could not be written, an error is returned instead.
+func (o *ObjectDatabase) RemapTreeBlobs(root []byte, mapping map[string][]byte) (newRoot []byte, err error) {
+	return o.remapTreeBlobs(root, mapping, make(map[string][]byte))
+}
+
+// remapTreeBlobs returns the ID of the tree named "tree" with its blobs
+// remapped by "mapping". The result for each subtree is recorded in "seen",
+// so that it is not rewritten again.
+func (o *ObjectDatabase) remapTreeBlobs(tree []byte, mapping map[string][]byte, seen map[string][]byte) ([]byte, error) {
+	if sha, ok := seen[string(tree)]; ok {
+		return sha, nil
+	}
+
+	t, err := o.Tree(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed bool
+	entries := make([]*TreeEntry, 0, len(t.Entries))
+	for _, entry := range t.Entries {
+		oid := entry.Oid
+
+		switch entry.Type() {
+		case BlobObjectType:
+			if to, ok := mapping[hex.EncodeToString(oid)]; ok {
+				oid = to
+			}
+		case TreeObjectType:
+			if oid, err = o.remapTreeBlobs(oid, mapping, seen); err != nil {
+				return nil, err
+			}
+		}
+
+		if !bytes.Equal(oid, entry.Oid) {
+			changed = true
+			entry = &TreeEntry{Name: entry.Name, Oid: oid, Filemode: entry.Filemode}
+		}
+		entries = append(entries, entry)
+	}
+
+	sha := tree
+	if changed {
+		if sha, err = o.WriteTree(&Tree{Entries: entries}); err != nil {
+			return nil, err
+		}
+	}
+
+	seen[string(tree)] = sha
+	return sha, nil
+}
@@ -0,0 +1,84 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemapTreeBlobs(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	other, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+	pointer, err := odb.WriteBlob(NewBlobFromBytes([]byte("pointer\n")))
+	require.NoError(t, err)
+
+	changed, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	unchanged, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "other.txt", Oid: other, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a", Oid: changed, Filemode: 040000},
+		{Name: "b", Oid: unchanged, Filemode: 040000},
+		{Name: "hello.txt", Oid: hello, Filemode: 0100755},
+		{Name: "submodule", Oid: hello, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	newRoot, err := odb.RemapTreeBlobs(root, map[string][]byte{
+		hex.EncodeToString(hello): pointer,
+	})
+	require.NoError(t, err)
+
+	tree, err := odb.Tree(newRoot)
+	require.NoError(t, err)
+	require.Len(t, tree.Entries, 4)
+
+	assert.Equal(t, unchanged, tree.Entries[1].Oid)
+	assert.Equal(t, &TreeEntry{Name: "hello.txt", Oid: pointer, Filemode: 0100755}, tree.Entries[2])
+	assert.Equal(t, &TreeEntry{Name: "submodule", Oid: hello, Filemode: 0160000}, tree.Entries[3])
+
+	sub, err := odb.Tree(tree.Entries[0].Oid)
+	require.NoError(t, err)
+	assert.Equal(t, []*TreeEntry{
+		{Name: "hello.txt", Oid: pointer, Filemode: 0100644},
+	}, sub.Entries)
+}
+
+func TestRemapTreeBlobsNoMatches(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	newRoot, err := odb.RemapTreeBlobs(root, map[string][]byte{
+		"0000000000000000000000000000000000000000": hello,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, root, newRoot)
+}
+
+func TestRemapTreeBlobsMissingTree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	newRoot, err := odb.RemapTreeBlobs([]byte("aaaaaaaaaaaaaaaaaaaa"), nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, newRoot)
+}
@@ -0,0 +1,194 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"path"
+)
+
+// maxRenameContents is the greatest number of bytes of the contents of deleted
+// blobs kept in memory while looking for similar renames.
+const maxRenameContents = 32 << 20
+
+// detectRenames returns "diffs" with each pair of a deletion and an addition
+// which are a rename replaced by a single rename, at the position of the
+// addition. Exact renames are found first, and then, if "similarity" is
+// positive, renames whose contents are at least that similar.
+func (o *ObjectDatabase) detectRenames(diffs []*TreeDiff, similarity int) ([]*TreeDiff, error) {
+	var deleted, added []*TreeDiff
+	for _, diff := range diffs {
+		switch diff.Status {
+		case DiffDeleted:
+			if renamable(diff.Old) {
+				deleted = append(deleted, diff)
+			}
+		case DiffAdded:
+			if renamable(diff.New) {
+				added = append(added, diff)
+			}
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return diffs, nil
+	}
+
+	// renames maps each addition which is part of a rename to that
+	// rename, and paired records the deletions which are.
+	renames := make(map[*TreeDiff]*TreeDiff)
+	paired := make(map[*TreeDiff]bool)
+
+	pair := func(from, to *TreeDiff, score int) {
+		renames[to] = &TreeDiff{
+			Path:       to.Path,
+			OldPath:    from.Path,
+			Status:     DiffRenamed,
+			Similarity: score,
+			Old:        from.Old,
+			New:        to.New,
+		}
+		paired[from] = true
+	}
+
+	// Exact renames need only a comparison of object IDs, preferring a
+	// deletion with the same file name where there is more than one.
+	byOid := make(map[string][]*TreeDiff)
+	for _, from := range deleted {
+		byOid[string(from.Old.Oid)] = append(byOid[string(from.Old.Oid)], from)
+	}
+	for _, to := range added {
+		var best *TreeDiff
+		for _, from := range byOid[string(to.New.Oid)] {
+			if paired[from] || !sameKind(from.Old, to.New) {
+				continue
+			}
+			if best == nil || (path.Base(from.Path) == path.Base(to.Path) && path.Base(best.Path) != path.Base(to.Path)) {
+				best = from
+			}
+		}
+		if best != nil {
+			pair(best, to, 100)
+		}
+	}
+
+	if similarity > 0 {
+		// Each addition is read once, and scored against every unpaired
+		// deletion. The contents of deletions are kept so that they are
+		// not read again for each addition, up to a total of
+		// maxRenameContents bytes, and dropped once they are paired.
+		contents := make(map[*TreeDiff][]byte)
+		var kept int
+		read := func(from *TreeDiff) ([]byte, error) {
+			if data, ok := contents[from]; ok {
+				return data, nil
+			}
+
+			data, err := o.blobContents(from.Old.Oid)
+			if err != nil {
+				return nil, err
+			}
+			if kept+len(data) <= maxRenameContents {
+				contents[from] = data
+				kept += len(data)
+			}
+			return data, nil
+		}
+
+		for _, to := range added {
+			if _, ok := renames[to]; ok {
+				continue
+			}
+
+			b, err := o.blobContents(to.New.Oid)
+			if err != nil {
+				return nil, err
+			}
+
+			var best *TreeDiff
+			var bestScore int
+			for _, from := range deleted {
+				if paired[from] || !sameKind(from.Old, to.New) {
+					continue
+				}
+
+				a, err := read(from)
+				if err != nil {
+					return nil, err
+				}
+
+				if score := similarityScore(a, b); score >= similarity && score > bestScore {
+					best, bestScore = from, score
+				}
+			}
+			if best != nil {
+				pair(best, to, bestScore)
+
+				kept -= len(contents[best])
+				delete(contents, best)
+			}
+		}
+	}
+
+	if len(renames) == 0 {
+		return diffs, nil
+	}
+
+	result := make([]*TreeDiff, 0, len(diffs)-len(renames))
+	for _, diff := range diffs {
+		if paired[diff] {
+			continue
+		}
+		if rename, ok := renames[diff]; ok {
+			diff = rename
+		}
+		result = append(result, diff)
+	}
+	return result, nil
+}
+
+// blobContents returns the contents of the blob named "sha".
+func (o *ObjectDatabase) blobContents(sha []byte) ([]byte, error) {
+	blob, err := o.Blob(sha)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	return ioutil.ReadAll(blob.Contents)
+}
+
+// renamable returns whether the given entry may be part of a rename, which is
+// the case for blobs and symbolic links, but not gitlinks.
+func renamable(entry *TreeEntry) bool {
+	return entry.Type() == BlobObjectType
+}
+
+// sameKind returns whether the two entries are either both symbolic links, or
+// both not, since one may not be renamed into the other.
+func sameKind(a, b *TreeEntry) bool {
+	return a.IsSymlink() == b.IsSymlink()
+}
+
+// similarityScore estimates the percentage of "a" and "b" which is the same as
+// the lengths of their common prefix and suffix relative to the size of the
+// larger of the two.
+func similarityScore(a, b []byte) int {
+	max := len(a)
+	if len(b) > max {
+		max = len(b)
+	}
+	if max == 0 {
+		return 100
+	}
+
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	return (prefix + suffix) * 100 / max
+}
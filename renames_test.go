@@ -0,0 +1,146 @@
+package gitobj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffTreesDetectsExactRenames(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello := writeTestBlob(t, odb, "Hello, world!\n")
+	goodbye := writeTestBlob(t, odb, "Goodbye, world!\n")
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "old.txt", Oid: hello, Filemode: 0100644},
+		{Name: "same.txt", Oid: goodbye, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "new.txt", Oid: hello, Filemode: 0100755},
+		{Name: "same.txt", Oid: goodbye, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTreesWithOptions(a, b, &DiffOptions{DetectRenames: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*TreeDiff{
+		{
+			Path:       "new.txt",
+			OldPath:    "old.txt",
+			Status:     DiffRenamed,
+			Similarity: 100,
+			Old:        &TreeEntry{Name: "old.txt", Oid: hello, Filemode: 0100644},
+			New:        &TreeEntry{Name: "new.txt", Oid: hello, Filemode: 0100755},
+		},
+	}, diffs)
+
+	diffs, err = odb.DiffTrees(a, b)
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 2)
+	assert.Equal(t, DiffAdded, diffs[0].Status)
+	assert.Equal(t, DiffDeleted, diffs[1].Status)
+}
+
+func TestDiffTreesPrefersRenamesWithTheSameName(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello := writeTestBlob(t, odb, "Hello, world!\n")
+
+	subtree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "b.txt", Oid: hello, Filemode: 0100644},
+		{Name: "old", Oid: subtree, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "new", Oid: subtree, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTreesWithOptions(a, b, &DiffOptions{DetectRenames: true})
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "b.txt", diffs[0].Path)
+	assert.Equal(t, DiffDeleted, diffs[0].Status)
+	assert.Equal(t, "new/a.txt", diffs[1].Path)
+	assert.Equal(t, "old/a.txt", diffs[1].OldPath)
+	assert.Equal(t, DiffRenamed, diffs[1].Status)
+}
+
+func TestDiffTreesDetectsSimilarRenames(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	body := strings.Repeat("unchanged\n", 9)
+	before := writeTestBlob(t, odb, body+"before\n")
+	after := writeTestBlob(t, odb, body+"after!\n")
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "old.txt", Oid: before, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "new.txt", Oid: after, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTreesWithOptions(a, b, &DiffOptions{
+		DetectRenames:    true,
+		RenameSimilarity: 50,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 1)
+	assert.Equal(t, DiffRenamed, diffs[0].Status)
+	assert.Equal(t, "old.txt", diffs[0].OldPath)
+	assert.Equal(t, "new.txt", diffs[0].Path)
+	assert.Equal(t, 93, diffs[0].Similarity)
+
+	for _, similarity := range []int{0, 95} {
+		diffs, err = odb.DiffTreesWithOptions(a, b, &DiffOptions{
+			DetectRenames:    true,
+			RenameSimilarity: similarity,
+		})
+		require.NoError(t, err)
+
+		assert.Len(t, diffs, 2, "similarity %d", similarity)
+	}
+}
+
+func TestDiffTreesDoesNotRenameSymlinksToFiles(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	hello := writeTestBlob(t, odb, "Hello, world!\n")
+
+	a, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "link", Oid: hello, Filemode: 0120000},
+	}})
+	require.NoError(t, err)
+	b, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "file", Oid: hello, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	diffs, err := odb.DiffTreesWithOptions(a, b, &DiffOptions{DetectRenames: true})
+	require.NoError(t, err)
+
+	assert.Len(t, diffs, 2)
+}
+
+func TestSimilarityScore(t *testing.T) {
+	assert.Equal(t, 100, similarityScore(nil, nil))
+	assert.Equal(t, 100, similarityScore([]byte("abcd"), []byte("abcd")))
+	assert.Equal(t, 75, similarityScore([]byte("abcd"), []byte("abxd")))
+	assert.Equal(t, 50, similarityScore([]byte("ab"), []byte("abcd")))
+	assert.Equal(t, 0, similarityScore([]byte("abcd"), []byte("wxyz")))
+}
@@ -0,0 +1,88 @@
+package gitobj
+
+import "container/heap"
+
+// RevListOptions controls the behavior of RevList.
+type RevListOptions struct {
+	// MaxDepth is the number of generations of history to list, where the
+	// tips are the first generation, their parents the second, and so on,
+	// as with "git clone --depth". Commits in the last generation are
+	// listed, but their parents are not followed. A commit reachable along
+	// several paths belongs to the generation of the shortest. A value of
+	// zero or less lists the whole history.
+	MaxDepth int
+}
+
+// RevList returns the commits reachable from the given tips, including the
+// tips themselves, as "git rev-list" does: newest-first by commit date, with
+// each commit listed once. If "opts" is nil, the default options are used.
+//
+// If any commit could not be read, or the committer of any commit could not be
+// parsed, an error is returned instead.
+func (o *ObjectDatabase) RevList(tips [][]byte, opts *RevListOptions) ([][]byte, error) {
+	if opts == nil {
+		opts = new(RevListOptions)
+	}
+
+	queued := make(map[string]*queuedCommit)
+	parents := make(map[string][][]byte)
+	queue := new(commitQueue)
+
+	push := func(sha []byte, depth int) error {
+		if q, ok := queued[string(sha)]; ok {
+			// A commit which has not yet been visited may be
+			// found to be shallower than first thought.
+			if depth < q.depth {
+				q.depth = depth
+			}
+			return nil
+		}
+
+		c, err := o.Commit(sha)
+		if err != nil {
+			return err
+		}
+		committer, err := ParseSignature(c.Committer)
+		if err != nil {
+			return err
+		}
+
+		ps, err := o.parents(sha, c)
+		if err != nil {
+			return err
+		}
+
+		q := &queuedCommit{sha: sha, when: committer.When, seq: queue.seq, depth: depth}
+		queue.seq++
+
+		queued[string(sha)] = q
+		parents[string(sha)] = ps
+		heap.Push(queue, q)
+		return nil
+	}
+
+	for _, tip := range tips {
+		if err := push(tip, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits [][]byte
+	for queue.Len() > 0 {
+		q := heap.Pop(queue).(*queuedCommit)
+		commits = append(commits, q.sha)
+
+		ps := parents[string(q.sha)]
+		delete(parents, string(q.sha))
+
+		if opts.MaxDepth > 0 && q.depth >= opts.MaxDepth {
+			continue
+		}
+		for _, parent := range ps {
+			if err := push(parent, q.depth+1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return commits, nil
+}
@@ -0,0 +1,82 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevList(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil, a)
+	c := writeDatedCommit(t, odb, 3, nil, a)
+	d := writeDatedCommit(t, odb, 4, nil, b, c)
+
+	commits, err := odb.RevList([][]byte{d}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{d, c, b, a}, commits)
+}
+
+func TestRevListMultipleTips(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil, a)
+	c := writeDatedCommit(t, odb, 3, nil, a)
+
+	commits, err := odb.RevList([][]byte{b, c, b}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{c, b, a}, commits)
+}
+
+func TestRevListMaxDepth(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	a := writeDatedCommit(t, odb, 1, nil)
+	b := writeDatedCommit(t, odb, 2, nil, a)
+	c := writeDatedCommit(t, odb, 3, nil, b)
+	d := writeDatedCommit(t, odb, 4, nil, c)
+
+	commits, err := odb.RevList([][]byte{d}, &RevListOptions{MaxDepth: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{d, c}, commits)
+}
+
+func TestRevListMaxDepthUsesShortestPath(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	// "a" is first reached through "c" and "b", three generations from
+	// "e", but is only two generations away through the older "d". Its
+	// parent "x" is therefore within the fourth generation.
+	x := writeDatedCommit(t, odb, 1, nil)
+	a := writeDatedCommit(t, odb, 2, nil, x)
+	b := writeDatedCommit(t, odb, 8, nil, a)
+	c := writeDatedCommit(t, odb, 9, nil, b)
+	d := writeDatedCommit(t, odb, 3, nil, a)
+	e := writeDatedCommit(t, odb, 10, nil, c, d)
+
+	commits, err := odb.RevList([][]byte{e}, &RevListOptions{MaxDepth: 4})
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{e, c, b, d, a, x}, commits)
+}
+
+func TestRevListMissingParent(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tip := writeDatedCommit(t, odb, 1, nil, []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	_, err := odb.RevList([][]byte{tip}, nil)
+	require.Error(t, err)
+
+	commits, err := odb.RevList([][]byte{tip}, &RevListOptions{MaxDepth: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{tip}, commits)
+}
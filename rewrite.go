@@ -0,0 +1,43 @@
+package gitobj
+
+import "fmt"
+
+// RewriteCommit reads the commit named "sha", passes a copy of it to
+// "transform", and writes the commit which is returned, giving its object ID.
+// The transform may either modify and return the commit it is given, or return
+// another altogether.
+//
+// If the transform changes any part of the commit which its signature covers,
+// such as its tree, parents, author, committer, or message, any signature the
+// commit has would no longer match, and so is dropped, unless the
+// KeepSignatures option is given. A commit which is returned unchanged is
+// written with its signature intact.
+//
+// The given WriteOptions are otherwise applied as in WriteCommit.
+//
+// If the commit could not be read or written, or the transform returns an
+// error, that error is returned instead. So is an error if the transform
+// returns no commit, or a commit without a tree.
+func (o *ObjectDatabase) RewriteCommit(sha []byte, transform func(*Commit) (*Commit, error), setters ...WriteOption) ([]byte, error) {
+	original, err := o.Commit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten, err := transform(original.clone())
+	if err != nil {
+		return nil, err
+	}
+	if rewritten == nil {
+		return nil, fmt.Errorf("gitobj: transform of commit %x returned no commit", sha)
+	}
+	if len(rewritten.TreeID) == 0 {
+		return nil, fmt.Errorf("gitobj: transform of commit %x returned a commit without a tree", sha)
+	}
+
+	args := newWriteOptions(setters...)
+	if !args.keepSignatures && !rewritten.withoutSignatures().Equal(original.withoutSignatures()) {
+		rewritten = rewritten.withoutSignatures()
+	}
+	return o.WriteCommit(rewritten, setters...)
+}
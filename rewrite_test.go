@@ -0,0 +1,139 @@
+package gitobj
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteCommitDropsSignatureWhenChanged(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		c.Author = "John Doe <john@example.com> 1257894000 +0000"
+		return c, nil
+	})
+	require.NoError(t, err)
+
+	commit, err := odb.Commit(sha)
+	require.NoError(t, err)
+
+	assert.Equal(t, "John Doe <john@example.com> 1257894000 +0000", commit.Author)
+	assert.Equal(t, []*ExtraHeader{{K: "encoding", V: "UTF-8"}}, commit.ExtraHeaders)
+}
+
+func TestRewriteCommitKeepsSignatureWhenUnchanged(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		return c, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, signed, sha)
+}
+
+func TestRewriteCommitKeepSignatures(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		c.Message = "reworded"
+		return c, nil
+	}, KeepSignatures(true))
+	require.NoError(t, err)
+
+	commit, err := odb.Commit(sha)
+	require.NoError(t, err)
+
+	assert.Equal(t, "reworded", commit.Message)
+	assert.Len(t, commit.ExtraHeaders, 3)
+}
+
+func TestRewriteCommitReturnsTransformErrors(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		return nil, errors.New("gitobj: transform failed")
+	})
+
+	assert.EqualError(t, err, "gitobj: transform failed")
+	assert.Nil(t, sha)
+}
+
+func TestRewriteCommitNilCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		return nil, nil
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: transform of commit %x returned no commit", signed))
+	assert.Nil(t, sha)
+}
+
+func TestRewriteCommitNilTree(t *testing.T) {
+	odb := newTestDatabase(t)
+	signed := writeSignedTestCommit(t, odb)
+
+	sha, err := odb.RewriteCommit(signed, func(c *Commit) (*Commit, error) {
+		c.TreeID = nil
+		return c, nil
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: transform of commit %x returned a commit without a tree", signed))
+	assert.Nil(t, sha)
+}
+
+func TestWriteCommitDeduplicatingParentsDropsSignature(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+	parent := writeTestCommit(t, odb, tree, "parent")
+
+	sha, err := odb.WriteCommit(&Commit{
+		Author:       "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		ParentIDs:    [][]byte{parent, parent},
+		TreeID:       tree,
+		ExtraHeaders: []*ExtraHeader{{K: "gpgsig", V: "signature"}},
+		Message:      "merge",
+	}, DeduplicateParents(DedupAll))
+	require.NoError(t, err)
+
+	commit, err := odb.Commit(sha)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]byte{parent}, commit.ParentIDs)
+	assert.Empty(t, commit.ExtraHeaders)
+}
+
+// writeSignedTestCommit writes a commit with both SHA-1 and SHA-256 signatures,
+// as well as an unrelated extra header, and returns its object ID.
+func writeSignedTestCommit(t *testing.T, odb *ObjectDatabase) []byte {
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	sha, err := odb.WriteCommit(&Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    tree,
+		ExtraHeaders: []*ExtraHeader{
+			{K: "encoding", V: "UTF-8"},
+			{K: "gpgsig", V: "-----BEGIN PGP SIGNATURE-----\n\nsignature\n-----END PGP SIGNATURE-----"},
+			{K: "gpgsig-sha256", V: "-----BEGIN PGP SIGNATURE-----\n\nsignature\n-----END PGP SIGNATURE-----"},
+		},
+		Message: "signed",
+	})
+	require.NoError(t, err)
+
+	return sha
+}
@@ -0,0 +1,95 @@
+package gitobj
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ShallowBoundary returns the commits at the boundary of a shallow clone, as
+// listed in the "shallow" file of the repository containing the object
+// directory (see: Root), in the order listed. If the repository is not shallow,
+// or the database has no object directory, no commits are returned.
+//
+// Walks of history treat commits at the boundary as having no parents, as Git
+// does, since their parents are not expected to be present, so that they stop
+// there rather than failing. The commits themselves are decoded unchanged. The
+// file is read once, when first needed by a walk.
+//
+// If the file could not be read, or is malformed, an error is returned
+// instead.
+func (o *ObjectDatabase) ShallowBoundary() ([][]byte, error) {
+	dir, err := o.gitDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, "shallow"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var commits [][]byte
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		sha, err := hex.DecodeString(line)
+		if err != nil || len(sha) != o.HashLen() {
+			return nil, fmt.Errorf("gitobj: malformed shallow commit: %q", line)
+		}
+		commits = append(commits, sha)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// parents returns the parents of the commit "c", named "sha", as followed by
+// walks of history. A commit at the boundary of a shallow clone has none (see:
+// ShallowBoundary). The commit itself is left as it was decoded, so that it
+// still encodes to "sha".
+func (o *ObjectDatabase) parents(sha []byte, c *Commit) ([][]byte, error) {
+	o.shallowOnce.Do(func() {
+		commits, err := o.ShallowBoundary()
+		if err != nil {
+			o.shallowErr = err
+			return
+		}
+
+		o.shallow = make(map[string]struct{}, len(commits))
+		for _, commit := range commits {
+			o.shallow[string(commit)] = struct{}{}
+		}
+	})
+	if o.shallowErr != nil {
+		return nil, o.shallowErr
+	}
+
+	if _, ok := o.shallow[string(sha)]; ok {
+		return nil, nil
+	}
+	return c.ParentIDs, nil
+}
+
+// firstParent returns the first parent of the commit "c", named "sha", as
+// followed by walks of history (see: parents), and whether it has one.
+func (o *ObjectDatabase) firstParent(sha []byte, c *Commit) ([]byte, bool, error) {
+	parents, err := o.parents(sha, c)
+	if err != nil || len(parents) == 0 {
+		return nil, false, err
+	}
+	return parents[0], true, nil
+}
@@ -0,0 +1,110 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShallowBoundary(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	missing := []byte("aaaaaaaaaaaaaaaaaaaa")
+	boundary := writeTestCommit(t, odb, tree, "boundary", missing)
+	tip := writeTestCommit(t, odb, tree, "tip", boundary)
+
+	writeTestRef(t, dir, "shallow", fmt.Sprintf("%x\n", boundary))
+
+	commits, err := odb.ShallowBoundary()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{boundary}, commits)
+
+	// The boundary commit is decoded unchanged, so that it is written
+	// back under the same ID.
+	c, err := odb.Commit(boundary)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{missing}, c.ParentIDs)
+
+	rewritten, err := odb.WriteCommit(c)
+	require.NoError(t, err)
+	assert.Equal(t, boundary, rewritten)
+
+	c, err = odb.Commit(tip)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{boundary}, c.ParentIDs)
+
+	// Walks of history stop at the boundary, rather than failing to read
+	// its missing parent.
+	history, err := odb.RevList([][]byte{tip}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{tip, boundary}, history)
+
+	count, err := odb.CommitCount(tip, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	paths, err := odb.PathHistory(tip, []string{""})
+	assert.NoError(t, err)
+	assert.Empty(t, paths[""])
+
+	broken, err := odb.ConnectivityCheck([][]byte{boundary})
+	assert.NoError(t, err)
+	assert.Empty(t, broken)
+}
+
+func TestShallowBoundaryNotShallow(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	commits, err := odb.ShallowBoundary()
+
+	assert.NoError(t, err)
+	assert.Empty(t, commits)
+}
+
+func TestShallowBoundaryWithoutObjectDirectory(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commits, err := odb.ShallowBoundary()
+
+	assert.NoError(t, err)
+	assert.Empty(t, commits)
+}
+
+func TestShallowBoundaryMalformed(t *testing.T) {
+	dir, odb := newTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+	commit := writeTestCommit(t, odb, tree, "commit")
+
+	writeTestRef(t, dir, "shallow", "not-a-commit\n")
+
+	_, err = odb.ShallowBoundary()
+	assert.EqualError(t, err, `gitobj: malformed shallow commit: "not-a-commit"`)
+
+	_, err = odb.RevList([][]byte{commit}, nil)
+	assert.EqualError(t, err, `gitobj: malformed shallow commit: "not-a-commit"`)
+}
+
+// newTestRepository returns a new, empty repository directory, and a database
+// of its object directory. The directory should be removed by the caller.
+func newTestRepository(t *testing.T) (string, *ObjectDatabase) {
+	dir, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+
+	odb, err := FromFilesystem(filepath.Join(dir, "objects"), "")
+	require.NoError(t, err)
+
+	return dir, odb
+}
@@ -0,0 +1,108 @@
+package gitobj
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// objectFileMode is the mode of loose object files to which the
+	// permissions of a shared repository are added. Objects are never
+	// modified once written, so they are read-only.
+	objectFileMode os.FileMode = 0444
+	// objectDirMode is the mode of object directories to which the
+	// permissions of a shared repository are added.
+	objectDirMode os.FileMode = 0755
+)
+
+// SharedMode describes the permissions given to the objects and directories
+// written to a repository which is shared between several users, as
+// configured by Git's "core.sharedRepository" setting.
+type SharedMode struct {
+	// perm is the permission bits given by the setting.
+	perm os.FileMode
+	// exact indicates whether "perm" replaces the default permissions
+	// altogether, rather than adding to them.
+	exact bool
+}
+
+var (
+	// SharedUmask leaves objects and directories with their default
+	// permissions, subject to the process's umask, as when a repository is
+	// not shared.
+	SharedUmask = SharedMode{}
+	// SharedGroup makes objects and directories readable (and directories
+	// writable) by the group which owns them.
+	SharedGroup = SharedMode{perm: 0660}
+	// SharedAll is as SharedGroup, but also makes objects and directories
+	// readable by everyone.
+	SharedAll = SharedMode{perm: 0664}
+)
+
+// SharedPerm returns a SharedMode which gives objects and directories exactly
+// the permission bits "perm", less any write permissions for objects, and with
+// execute permission added to directories wherever they are readable.
+func SharedPerm(perm os.FileMode) SharedMode {
+	return SharedMode{perm: perm & os.ModePerm, exact: true}
+}
+
+// ParseSharedRepository parses the value of Git's "core.sharedRepository"
+// setting, which is one of "umask" (or "false"), "group" (or "true"), "all"
+// (or "world" or "everybody"), or an octal permission mode, and returns the
+// SharedMode it describes.
+func ParseSharedRepository(value string) (SharedMode, error) {
+	switch strings.ToLower(value) {
+	case "", "umask", "false", "no", "off", "0":
+		return SharedUmask, nil
+	case "group", "true", "yes", "on", "1":
+		return SharedGroup, nil
+	case "all", "world", "everybody", "2":
+		return SharedAll, nil
+	}
+
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || perm&^uint64(os.ModePerm) != 0 {
+		return SharedUmask, fmt.Errorf("gitobj: invalid core.sharedRepository value: %q", value)
+	}
+	if perm&0600 != 0600 {
+		return SharedUmask, fmt.Errorf("gitobj: core.sharedRepository mode %#o must give the owner read and write permission", perm)
+	}
+	return SharedPerm(os.FileMode(perm)), nil
+}
+
+// FileMode returns the mode given to loose object files.
+func (m SharedMode) FileMode() os.FileMode {
+	return m.apply(objectFileMode)
+}
+
+// DirMode returns the mode given to object directories. The directories of a
+// shared repository also have the setgid bit set, so that the objects
+// written within them belong to the same group.
+func (m SharedMode) DirMode() os.FileMode {
+	mode := m.apply(objectDirMode)
+	if m != SharedUmask {
+		mode |= os.ModeSetgid
+	}
+	return mode
+}
+
+// apply returns the default mode "mode" adjusted by the shared permissions, in
+// the same way as Git does.
+func (m SharedMode) apply(mode os.FileMode) os.FileMode {
+	tweak := m.perm
+	if mode&0200 == 0 {
+		// Read-only files remain read-only.
+		tweak &^= 0222
+	}
+	if mode&0100 != 0 {
+		// Directories are searchable wherever they are readable.
+		tweak |= (tweak & 0444) >> 2
+	}
+
+	if m.exact {
+		return mode&^os.ModePerm | tweak
+	}
+	return mode | tweak
+}
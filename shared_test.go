@@ -0,0 +1,129 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSharedRepository(t *testing.T) {
+	for value, expected := range map[string]SharedMode{
+		"":          SharedUmask,
+		"umask":     SharedUmask,
+		"false":     SharedUmask,
+		"group":     SharedGroup,
+		"true":      SharedGroup,
+		"all":       SharedAll,
+		"world":     SharedAll,
+		"everybody": SharedAll,
+		"0640":      SharedPerm(0640),
+		"600":       SharedPerm(0600),
+	} {
+		mode, err := ParseSharedRepository(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, mode, value)
+	}
+}
+
+func TestParseSharedRepositoryRejectsInvalidValues(t *testing.T) {
+	for _, value := range []string{"sometimes", "0999", "01777"} {
+		_, err := ParseSharedRepository(value)
+
+		assert.EqualError(t, err, "gitobj: invalid core.sharedRepository value: \""+value+"\"")
+	}
+}
+
+func TestParseSharedRepositoryRejectsModesWithoutOwnerAccess(t *testing.T) {
+	_, err := ParseSharedRepository("0440")
+
+	assert.EqualError(t, err, "gitobj: core.sharedRepository mode 0440 must give the owner read and write permission")
+}
+
+func TestSharedModePermissions(t *testing.T) {
+	for _, test := range []struct {
+		mode SharedMode
+		file os.FileMode
+		dir  os.FileMode
+	}{
+		{SharedUmask, 0444, 0755},
+		{SharedGroup, 0444, os.ModeSetgid | 0775},
+		{SharedAll, 0444, os.ModeSetgid | 0775},
+		{SharedPerm(0640), 0440, os.ModeSetgid | 0750},
+		{SharedPerm(0600), 0400, os.ModeSetgid | 0700},
+	} {
+		assert.Equal(t, test.file, test.mode.FileMode(), "%+v", test.mode)
+		assert.Equal(t, test.dir, test.mode.DirMode(), "%+v", test.mode)
+	}
+}
+
+func TestWriteBlobPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	for _, test := range []struct {
+		opts []Option
+		file os.FileMode
+		dir  os.FileMode
+	}{
+		{[]Option{SharedRepository(SharedGroup)}, 0444, os.ModeDir | os.ModeSetgid | 0775},
+		{[]Option{SharedRepository(SharedPerm(0640))}, 0440, os.ModeDir | os.ModeSetgid | 0750},
+	} {
+		root, err := ioutil.TempDir("", "gitobj")
+		require.NoError(t, err)
+		defer os.RemoveAll(root)
+
+		odb, err := FromFilesystem(root, "", test.opts...)
+		require.NoError(t, err)
+
+		sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+		require.NoError(t, err)
+		require.NoError(t, odb.Close())
+
+		path := odb.rw.(*fileStorer).path(sha)
+
+		file, err := os.Stat(path)
+		require.NoError(t, err)
+		dir, err := os.Stat(filepath.Dir(path))
+		require.NoError(t, err)
+
+		assert.Equal(t, test.file, file.Mode())
+		assert.Equal(t, test.dir, dir.Mode())
+	}
+}
+
+func TestWriteBlobPermissionsUnderUmask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	root, err := ioutil.TempDir("", "gitobj")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, odb.Close())
+
+	path := odb.rw.(*fileStorer).path(sha)
+
+	file, err := os.Stat(path)
+	require.NoError(t, err)
+	dir, err := os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+
+	// Objects are read-only, with no more permissions than Git's 0444,
+	// and directories are not made group-owned.
+	assert.Equal(t, os.FileMode(0400), file.Mode().Perm()&0400)
+	assert.Equal(t, os.FileMode(0), file.Mode().Perm()&^0444)
+	assert.Equal(t, os.FileMode(0), dir.Mode()&os.ModeSetgid)
+}
@@ -35,6 +35,12 @@ func (m *multiStorage) Open(oid []byte) (f io.ReadCloser, err error) {
 	return nil, errors.NoSuchObject(oid)
 }
 
+// Storages returns the set of storage backends from which this storage reads,
+// in the order in which they are searched.
+func (m *multiStorage) Storages() []Storage {
+	return m.impls
+}
+
 // Close closes the filesystem, after which no more operations are
 // allowed.
 func (m *multiStorage) Close() error {
@@ -0,0 +1,92 @@
+package gitobj
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// WriteResult is the outcome of writing a single object given to WriteStream.
+type WriteResult struct {
+	// Oid is the ID of the object, or nil if it could not be written.
+	Oid []byte
+	// Created indicates whether the object was newly created, rather than
+	// already present.
+	Created bool
+	// Err is the error encountered in writing the object, if any.
+	Err error
+}
+
+// WriteStream writes each object received from "objs", and returns a channel
+// yielding the result of each write, in the order the objects were received.
+// Commits are written without any WriteOption.
+//
+// Objects are written one at a time and the results channel is unbuffered, so
+// a producer is held back until the consumer has received the result of the
+// previous object. An error writing one object is reported in its result, and
+// does not stop the rest from being written. Once "objs" is closed and the
+// final result received, the results channel is closed.
+//
+// The consumer must receive from the results channel until it is closed, or
+// the goroutine writing the objects is never released. To stop early, use
+// WriteStreamContext instead.
+//
+// If the database is closed, an error is returned instead, and "objs" is not
+// read from.
+func (o *ObjectDatabase) WriteStream(objs <-chan Object) (<-chan WriteResult, error) {
+	return o.WriteStreamContext(context.Background(), objs)
+}
+
+// WriteStreamContext is as WriteStream, but stops once "ctx" is done: no more
+// objects are read from "objs", any result not yet received is discarded, and
+// the results channel is closed, so that a consumer may stop early without
+// leaking the writing goroutine.
+func (o *ObjectDatabase) WriteStreamContext(ctx context.Context, objs <-chan Object) (<-chan WriteResult, error) {
+	if atomic.LoadUint32(&o.closed) == 1 {
+		return nil, fmt.Errorf("gitobj: cannot write to closed *ObjectDatabase")
+	}
+
+	results := make(chan WriteResult)
+	go func() {
+		defer close(results)
+
+		for {
+			var obj Object
+			select {
+			case next, ok := <-objs:
+				if !ok {
+					return
+				}
+				obj = next
+			case <-ctx.Done():
+				return
+			}
+
+			var res WriteResult
+			res.Oid, res.Created, res.Err = o.writeObject(obj)
+
+			select {
+			case results <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// writeObject writes the given object with the Write*Ex function for its
+// concrete type.
+func (o *ObjectDatabase) writeObject(obj Object) ([]byte, bool, error) {
+	switch obj := obj.(type) {
+	case *Blob:
+		return o.WriteBlobEx(obj)
+	case *Tree:
+		return o.WriteTreeEx(obj)
+	case *Commit:
+		return o.WriteCommitEx(obj)
+	case *Tag:
+		return o.WriteTagEx(obj)
+	}
+	return nil, false, fmt.Errorf("gitobj: cannot write object of type %T", obj)
+}
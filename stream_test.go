@@ -0,0 +1,79 @@
+package gitobj
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStream(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	objs := make(chan Object)
+	results, err := odb.WriteStream(objs)
+	require.NoError(t, err)
+
+	go func() {
+		objs <- NewBlobFromBytes([]byte("Hello, world!\n"))
+		objs <- NewBlobFromBytes([]byte("Hello, world!\n"))
+		objs <- &Tree{}
+		objs <- nil
+		close(objs)
+	}()
+
+	var got []WriteResult
+	for res := range results {
+		got = append(got, res)
+	}
+
+	require.Len(t, got, 4)
+
+	assert.NoError(t, got[0].Err)
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(got[0].Oid))
+	assert.True(t, got[0].Created)
+
+	assert.NoError(t, got[1].Err)
+	assert.Equal(t, got[0].Oid, got[1].Oid)
+	assert.False(t, got[1].Created)
+
+	assert.NoError(t, got[2].Err)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", hex.EncodeToString(got[2].Oid))
+
+	assert.EqualError(t, got[3].Err, "gitobj: cannot write object of type <nil>")
+	assert.Nil(t, got[3].Oid)
+}
+
+func TestWriteStreamClosedDatabase(t *testing.T) {
+	odb := newTestDatabase(t)
+	require.NoError(t, odb.Close())
+
+	results, err := odb.WriteStream(make(chan Object))
+
+	assert.EqualError(t, err, "gitobj: cannot write to closed *ObjectDatabase")
+	assert.Nil(t, results)
+}
+
+func TestWriteStreamContextCanceled(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	objs := make(chan Object, 2)
+	objs <- NewBlobFromBytes([]byte("Hello, world!\n"))
+	objs <- NewBlobFromBytes([]byte("Goodbye, world!\n"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := odb.WriteStreamContext(ctx, objs)
+	require.NoError(t, err)
+
+	res := <-results
+	assert.NoError(t, res.Err)
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(res.Oid))
+
+	// Stop without receiving the second result, or closing "objs".
+	cancel()
+
+	for range results {
+	}
+}
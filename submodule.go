@@ -0,0 +1,170 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// gitmodulesName is the name of the file in the root of a tree that describes
+// the submodules it contains.
+const gitmodulesName = ".gitmodules"
+
+// Submodule describes a single submodule as configured in the ".gitmodules"
+// file of a tree.
+type Submodule struct {
+	// Name is the name of the submodule, as given in its section header.
+	Name string
+	// Path is the path of the submodule relative to the root of the tree.
+	Path string
+	// URL is the URL from which the submodule is cloned.
+	URL string
+	// Branch is the branch tracked by the submodule, if any.
+	Branch string
+	// Oid is the object ID of the commit recorded by the submodule's
+	// gitlink entry in the tree, or nil if the tree has no gitlink entry
+	// at Path.
+	Oid []byte
+}
+
+// Submodules parses the ".gitmodules" file at the root of the tree named
+// "root" and returns the submodules it describes, in the order in which they
+// are configured. Each submodule is correlated with the gitlink entry at its
+// path, if any, to determine the commit it records.
+//
+// If the tree has no ".gitmodules" file, an empty slice is returned. If the
+// tree or any of its subtrees could not be read, or the ".gitmodules" file is
+// malformed, an error is returned instead.
+func (o *ObjectDatabase) Submodules(root []byte) ([]*Submodule, error) {
+	tree, err := o.Tree(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var gitmodules *TreeEntry
+	for _, entry := range tree.Entries {
+		if entry.Name == gitmodulesName && entry.Type() == BlobObjectType {
+			gitmodules = entry
+			break
+		}
+	}
+	if gitmodules == nil {
+		return []*Submodule{}, nil
+	}
+
+	lines, err := o.BlobLines(gitmodules.Oid)
+	if err != nil {
+		return nil, err
+	}
+
+	submodules, err := parseSubmodules(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, submodule := range submodules {
+		entry, _, err := o.walkPath(tree, strings.Trim(submodule.Path, "/"))
+		if err != nil {
+			return nil, err
+		}
+
+		if entry != nil && entry.Type() == CommitObjectType {
+			submodule.Oid = entry.Oid
+		}
+	}
+	return submodules, nil
+}
+
+// parseSubmodules parses the lines of a ".gitmodules" file, and returns the
+// submodules it describes. Sections other than "submodule" sections, and
+// unknown keys within them, are ignored.
+func parseSubmodules(lines [][]byte) ([]*Submodule, error) {
+	var submodules []*Submodule
+	var current *Submodule
+
+	for i, line := range lines {
+		text := strings.TrimSpace(string(line))
+		if len(text) == 0 || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			end := strings.IndexByte(text, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("gitobj: malformed %s section on line %d: %q",
+					gitmodulesName, i+1, text)
+			}
+
+			current = nil
+
+			section := strings.TrimSpace(text[1:end])
+			fields := strings.SplitN(section, " ", 2)
+			if len(fields) != 2 || !strings.EqualFold(fields[0], "submodule") {
+				continue
+			}
+
+			current = &Submodule{
+				Name: strings.Trim(strings.TrimSpace(fields[1]), `"`),
+			}
+			submodules = append(submodules, current)
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(text[:eq]))
+		value := configValue(text[eq+1:])
+
+		switch key {
+		case "path":
+			current.Path = value
+		case "url":
+			current.URL = value
+		case "branch":
+			current.Branch = value
+		}
+	}
+
+	if submodules == nil {
+		submodules = []*Submodule{}
+	}
+	return submodules, nil
+}
+
+// configValue returns the value of a Git configuration entry with surrounding
+// whitespace, enclosing quotes, and any trailing comment removed.
+func configValue(raw string) string {
+	var buf bytes.Buffer
+	var quoted bool
+
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			default:
+				buf.WriteByte(raw[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(buf.String())
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
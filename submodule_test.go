@@ -0,0 +1,96 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmodules(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	gitmodules, err := odb.WriteBlob(NewBlobFromBytes([]byte(`# Submodules
+[submodule "lib"]
+	path = lib
+	url = https://example.com/lib.git
+[core]
+	path = ignored
+[submodule "vendor/dep"]
+	path = vendor/dep
+	url = "https://example.com/dep.git" ; a comment
+	branch = stable
+[submodule "missing"]
+	path = missing
+	url = https://example.com/missing.git
+`)))
+	require.NoError(t, err)
+
+	lib := []byte("aaaaaaaaaaaaaaaaaaaa")
+	dep := []byte("bbbbbbbbbbbbbbbbbbbb")
+
+	vendor, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "dep", Oid: dep, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: ".gitmodules", Oid: gitmodules, Filemode: 0100644},
+		{Name: "lib", Oid: lib, Filemode: 0160000},
+		{Name: "vendor", Oid: vendor, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	submodules, err := odb.Submodules(root)
+	require.NoError(t, err)
+	require.Len(t, submodules, 3)
+
+	assert.Equal(t, &Submodule{
+		Name: "lib",
+		Path: "lib",
+		URL:  "https://example.com/lib.git",
+		Oid:  lib,
+	}, submodules[0])
+	assert.Equal(t, &Submodule{
+		Name:   "vendor/dep",
+		Path:   "vendor/dep",
+		URL:    "https://example.com/dep.git",
+		Branch: "stable",
+		Oid:    dep,
+	}, submodules[1])
+	assert.Equal(t, &Submodule{
+		Name: "missing",
+		Path: "missing",
+		URL:  "https://example.com/missing.git",
+	}, submodules[2])
+}
+
+func TestSubmodulesWithoutGitmodules(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "lib", Oid: []byte("aaaaaaaaaaaaaaaaaaaa"), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	submodules, err := odb.Submodules(root)
+	require.NoError(t, err)
+	assert.NotNil(t, submodules)
+	assert.Empty(t, submodules)
+}
+
+func TestSubmodulesWithMalformedGitmodules(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	gitmodules, err := odb.WriteBlob(NewBlobFromBytes([]byte("[submodule \"lib\"\n")))
+	require.NoError(t, err)
+
+	root, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: ".gitmodules", Oid: gitmodules, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	submodules, err := odb.Submodules(root)
+	assert.Error(t, err)
+	assert.Nil(t, submodules)
+}
@@ -0,0 +1,101 @@
+package gitobj
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoadSubtree returns the tree at the slash-separated path "prefix" beneath the
+// tree named "root", along with its object ID. Only the trees along the path are
+// read, so none of their siblings are loaded. An empty prefix names the root
+// tree itself.
+//
+// If there is no entry at the path, or it is not a tree, a *NoSuchPathError is
+// returned. If any tree along the path could not be read, its error is returned
+// instead.
+func (o *ObjectDatabase) LoadSubtree(root []byte, prefix string) (*Tree, []byte, error) {
+	tree, err := o.Tree(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if prefix = strings.Trim(prefix, "/"); len(prefix) == 0 {
+		return tree, root, nil
+	}
+
+	entry, _, err := o.walkPath(tree, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil || entry.Type() != TreeObjectType {
+		return nil, nil, &NoSuchPathError{Path: prefix}
+	}
+
+	if tree, err = o.Tree(entry.Oid); err != nil {
+		return nil, nil, err
+	}
+	return tree, entry.Oid, nil
+}
+
+// TreeEntryAtPath returns the entry at the slash-separated path "path" beneath
+// the tree named "treeID". Only the trees along the path are read, one path
+// component at a time.
+//
+// If any component of the path is missing, or the path is empty, a
+// *NoSuchPathError is returned. If a component other than the last names an
+// entry which is not a tree, such as a blob or submodule, an error saying so is
+// returned instead. If any tree along the path could not be read, its error is
+// returned.
+func (o *ObjectDatabase) TreeEntryAtPath(treeID []byte, path string) (*TreeEntry, error) {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return nil, &NoSuchPathError{Path: path}
+	}
+
+	tree, err := o.Tree(treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, blocked, err := o.walkPath(tree, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocked) > 0 {
+		return nil, fmt.Errorf("gitobj: cannot find %q: %q is not a tree", path, blocked)
+	}
+	if entry == nil {
+		return nil, &NoSuchPathError{Path: path}
+	}
+	return entry, nil
+}
+
+// walkPath returns the entry at the slash-separated path "path" beneath "tree",
+// reading only the trees along the path, one component at a time. The path must
+// not begin or end with a slash.
+//
+// If any component of the path is missing, a nil entry is returned. If a
+// component other than the last names an entry which is not a tree, a nil entry
+// is returned along with the path of that entry, "blocked". If any tree along
+// the path could not be read, its error is returned.
+func (o *ObjectDatabase) walkPath(tree *Tree, path string) (entry *TreeEntry, blocked string, err error) {
+	parts := strings.Split(path, "/")
+
+	for i, part := range parts {
+		found, ok := tree.Lookup(part)
+		if !ok {
+			return nil, "", nil
+		}
+		if i == len(parts)-1 {
+			return found, "", nil
+		}
+
+		if found.Type() != TreeObjectType {
+			return nil, strings.Join(parts[:i+1], "/"), nil
+		}
+		if tree, err = o.Tree(found.Oid); err != nil {
+			return nil, "", err
+		}
+	}
+	return nil, "", nil
+}
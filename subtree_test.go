@@ -0,0 +1,113 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSubtree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt":         {Oid: blob, Filemode: 0100644},
+		"dir/b.txt":     {Oid: blob, Filemode: 0100644},
+		"dir/sub/c.txt": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	for _, prefix := range []string{"dir/sub", "/dir/sub/"} {
+		tree, sha, err := odb.LoadSubtree(root, prefix)
+		require.NoError(t, err)
+
+		require.Len(t, tree.Entries, 1)
+		assert.Equal(t, "c.txt", tree.Entries[0].Name)
+
+		got, err := odb.Tree(sha)
+		require.NoError(t, err)
+		assert.True(t, tree.Equal(got))
+	}
+}
+
+func TestLoadSubtreeRoot(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	tree, sha, err := odb.LoadSubtree(root, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, root, sha)
+	assert.Len(t, tree.Entries, 1)
+}
+
+func TestLoadSubtreeNotFound(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"dir/a.txt": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	for _, prefix := range []string{"missing", "dir/a.txt", "dir/a.txt/b"} {
+		_, _, err := odb.LoadSubtree(root, prefix)
+
+		assert.Equal(t, &NoSuchPathError{Path: prefix}, err)
+	}
+}
+
+func TestTreeEntryAtPath(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"src/main.go":      {Oid: blob, Filemode: 0100644},
+		"src/cmd/tool.go":  {Oid: blob, Filemode: 0100755},
+		"src/cmd.go":       {Oid: blob, Filemode: 0100644},
+		"vendor/submodule": {Oid: blob, Filemode: 0160000},
+	})
+	require.NoError(t, err)
+
+	entry, err := odb.TreeEntryAtPath(root, "src/cmd/tool.go")
+	require.NoError(t, err)
+	assert.Equal(t, &TreeEntry{Name: "tool.go", Oid: blob, Filemode: 0100755}, entry)
+
+	entry, err = odb.TreeEntryAtPath(root, "src/cmd")
+	require.NoError(t, err)
+	assert.True(t, entry.IsDir())
+}
+
+func TestTreeEntryAtPathNotFound(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"src/main.go": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	for _, path := range []string{"", "missing", "src/missing", "missing/main.go"} {
+		_, err := odb.TreeEntryAtPath(root, path)
+		assert.Equal(t, &NoSuchPathError{Path: path}, err)
+	}
+}
+
+func TestTreeEntryAtPathThroughBlob(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"src/main.go": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	_, err = odb.TreeEntryAtPath(root, "src/main.go/x")
+	assert.EqualError(t, err, `gitobj: cannot find "src/main.go/x": "src/main.go" is not a tree`)
+}
@@ -25,7 +25,7 @@ type Tag struct {
 //
 // If any error was encountered along the way it will be returned, and the
 // receiving *Tag is considered invalid.
-func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int, error) {
+func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int64, error) {
 	scanner := bufio.NewScanner(io.LimitReader(r, size))
 
 	var (
@@ -52,6 +52,8 @@ func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int, error) {
 				sha, err := hex.DecodeString(parts[1])
 				if err != nil {
 					return 0, fmt.Errorf("gitobj: unable to decode SHA-1: %s", err)
+				} else if len(sha) != hash.Size() {
+					return 0, fmt.Errorf("gitobj: tag object ID %q has %d hex digits, expected %d", parts[1], len(parts[1]), 2*hash.Size())
 				}
 
 				t.Object = sha
@@ -73,14 +75,14 @@ func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int, error) {
 
 	t.Message = strings.Join(message, "\n")
 
-	return int(size), nil
+	return size, nil
 }
 
 // Encode encodes the Tag's contents to the given io.Writer, "w". If there was
 // any error copying the Tag's contents, that error will be returned.
 //
 // Otherwise, the number of bytes written will be returned.
-func (t *Tag) Encode(w io.Writer) (int, error) {
+func (t *Tag) Encode(w io.Writer) (int64, error) {
 	headers := []string{
 		fmt.Sprintf("object %s", hex.EncodeToString(t.Object)),
 		fmt.Sprintf("type %s", t.ObjectType),
@@ -88,7 +90,59 @@ func (t *Tag) Encode(w io.Writer) (int, error) {
 		fmt.Sprintf("tagger %s", t.Tagger),
 	}
 
-	return fmt.Fprintf(w, "%s\n\n%s", strings.Join(headers, "\n"), t.Message)
+	n, err := fmt.Fprintf(w, "%s\n\n%s", strings.Join(headers, "\n"), t.Message)
+	return int64(n), err
+}
+
+// TaggerSig parses the tag's tagger as a *Signature (see: ParseSignature),
+// including the time and timezone at which it was tagged. If the tagger is
+// malformed, or missing, as it is in some very old tags, an error is returned
+// instead.
+func (t *Tag) TaggerSig() (*Signature, error) {
+	return ParseSignature(t.Tagger)
+}
+
+// tagSignatureStarts are the lines which begin the signatures that Git appends
+// to the messages of signed tags.
+var tagSignatureStarts = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+}
+
+// Signature returns the signature appended to the tag's message, ending in a
+// newline as Git extracts it, along with the payload which it signs: the tag
+// encoded with its message cut short before the signature, as given to gpg by
+// "git verify-tag". The signature begins at the last line of the message which
+// starts a PGP, X.509, or SSH signature. If there is no such line, ok is false.
+func (t *Tag) Signature() (sig string, signed []byte, ok bool) {
+	start := -1
+	for i := 0; i < len(t.Message); {
+		line := t.Message[i:]
+		if nl := strings.IndexByte(line, '\n'); nl >= 0 {
+			line = line[:nl]
+		}
+
+		for _, prefix := range tagSignatureStarts {
+			if strings.HasPrefix(line, prefix) {
+				start = i
+			}
+		}
+		i += len(line) + 1
+	}
+	if start < 0 {
+		return "", nil, false
+	}
+
+	unsigned := *t
+	unsigned.Message = t.Message[:start]
+
+	var buf bytes.Buffer
+	if _, err := unsigned.Encode(&buf); err != nil {
+		return "", nil, false
+	}
+	return strings.TrimSuffix(t.Message[start:], "\n") + "\n", buf.Bytes(), true
 }
 
 // Equal returns whether the receiving and given Tags are equal, or in other
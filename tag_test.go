@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTagTypeReturnsCorrectObjectType(t *testing.T) {
@@ -56,7 +58,7 @@ func TestTagDecode(t *testing.T) {
 	n, err := tag.Decode(sha1.New(), from, int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, n, flen)
+	assert.Equal(t, int64(flen), n)
 
 	assert.Equal(t, []byte("aaaaaaaaaaaaaaaaaaaa"), tag.Object)
 	assert.Equal(t, CommitObjectType, tag.ObjectType)
@@ -64,3 +66,131 @@ func TestTagDecode(t *testing.T) {
 	assert.Equal(t, "A U Thor <author@example.com>", tag.Tagger)
 	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", tag.Message)
 }
+
+func TestTagSignature(t *testing.T) {
+	unsigned := "object 1e8a52e18cfb381bc9cc1f0b720540364d2a6edd\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger J. Roe <jroe@example.ca> 1337889148 -0600\n" +
+		"\n" +
+		"Release v1.0.0\n" +
+		"\n" +
+		"-----BEGIN SSH SIGNATURE----- starts a line, but not the last one.\n"
+	sig := "-----BEGIN PGP SIGNATURE-----\n" +
+		"\n" +
+		"Not a real signature\n" +
+		"-----END PGP SIGNATURE-----\n"
+	raw := unsigned + sig
+
+	tag := new(Tag)
+	_, err := tag.Decode(sha1.New(), strings.NewReader(raw), int64(len(raw)))
+	require.NoError(t, err)
+
+	got, signed, ok := tag.Signature()
+
+	assert.True(t, ok)
+	assert.Equal(t, sig, got)
+	assert.Equal(t, unsigned, string(signed))
+}
+
+func TestTagSignatureUnsigned(t *testing.T) {
+	tag := &Tag{Message: "Release v1.0.0\n"}
+
+	sig, signed, ok := tag.Signature()
+
+	assert.False(t, ok)
+	assert.Empty(t, sig)
+	assert.Nil(t, signed)
+}
+
+func TestTagTaggerSig(t *testing.T) {
+	tag := &Tag{Tagger: "Jane Doe <jane@example.com> 1503956287 -0400"}
+
+	tagger, err := tag.TaggerSig()
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", tagger.Name)
+	assert.Equal(t, "jane@example.com", tagger.Email)
+	assert.Equal(t, int64(1503956287), tagger.When.Unix())
+	_, offset := tagger.When.Zone()
+	assert.Equal(t, -4*60*60, offset)
+}
+
+func TestTagTaggerSigMissing(t *testing.T) {
+	_, err := new(Tag).TaggerSig()
+	assert.Error(t, err)
+}
+
+func TestTagEqualReturnsTrueWithIdenticalTags(t *testing.T) {
+	t1 := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1503956287 -0400",
+		Message:    "Release v1.0.0",
+	}
+	t2 := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1503956287 -0400",
+		Message:    "Release v1.0.0",
+	}
+
+	assert.True(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWithDifferentObjects(t *testing.T) {
+	t1 := &Tag{Object: make([]byte, 20)}
+	t2 := &Tag{Object: make([]byte, 20)}
+
+	t1.Object[1] = 0x1
+
+	assert.False(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWithDifferentObjectTypes(t *testing.T) {
+	t1 := &Tag{ObjectType: CommitObjectType}
+	t2 := &Tag{ObjectType: BlobObjectType}
+
+	assert.False(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWithDifferentNames(t *testing.T) {
+	t1 := &Tag{Name: "v1.0.0"}
+	t2 := &Tag{Name: "v2.0.0"}
+
+	assert.False(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWithDifferentTaggers(t *testing.T) {
+	t1 := &Tag{Tagger: "Jane Doe <jane@example.com> 1503956287 -0400"}
+	t2 := &Tag{Tagger: "John Doe <john@example.com> 1503956287 -0400"}
+
+	assert.False(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWithDifferentMessages(t *testing.T) {
+	t1 := &Tag{Message: "Release v1.0.0"}
+	t2 := &Tag{Message: "Release v2.0.0"}
+
+	assert.False(t, t1.Equal(t2))
+}
+
+func TestTagEqualReturnsFalseWhenOneTagIsNil(t *testing.T) {
+	t1 := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+	}
+	t2 := (*Tag)(nil)
+
+	assert.False(t, t1.Equal(t2))
+	assert.False(t, t2.Equal(t1))
+}
+
+func TestTagEqualReturnsTrueWhenBothTagsAreNil(t *testing.T) {
+	t1 := (*Tag)(nil)
+	t2 := (*Tag)(nil)
+
+	assert.True(t, t1.Equal(t2))
+}
@@ -24,9 +24,32 @@ const (
 	sIFGITLINK = int32(0160000)
 )
 
+// These are the filemodes which Git permits a tree entry to have (see:
+// TreeEntry.Validate).
+const (
+	// FilemodeDir is the filemode of a subtree.
+	FilemodeDir = int32(040000)
+	// FilemodeRegular is the filemode of a regular, non-executable file.
+	FilemodeRegular = int32(0100644)
+	// FilemodeExecutable is the filemode of an executable file.
+	FilemodeExecutable = int32(0100755)
+	// FilemodeSymlink is the filemode of a symbolic link, whose blob holds
+	// the path that it points to.
+	FilemodeSymlink = int32(0120000)
+	// FilemodeSubmodule is the filemode of a submodule, whose object ID is
+	// that of a commit in another repository.
+	FilemodeSubmodule = int32(0160000)
+)
+
 // Tree encapsulates a Git tree object.
 type Tree struct {
 	// Entries is the list of entries held by this tree.
+	//
+	// Entries are kept in the order in which they were decoded (or
+	// appended), which for any tree read from the object database is
+	// "subtree" order (see: SubtreeOrder). This order is authoritative
+	// when encoding, and changing it changes the tree's object ID. For a
+	// plain alphabetical listing, see EntriesByName.
 	Entries []*TreeEntry
 }
 
@@ -38,22 +61,29 @@ func (t *Tree) Type() ObjectType { return TreeObjectType }
 // read. It returns the number of uncompressed bytes being consumed off of the
 // stream, which should be strictly equal to the size given.
 //
+// Nothing beyond the given size is read. If anything but whitespace follows
+// the last complete entry, an error is returned.
+//
 // If any error was encountered along the way, that will be returned, along with
 // the number of bytes read up to that point.
-func (t *Tree) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err error) {
+func (t *Tree) Decode(hash hash.Hash, from io.Reader, size int64) (n int64, err error) {
 	hashlen := hash.Size()
-	buf := bufio.NewReader(from)
+	buf := bufio.NewReader(io.LimitReader(from, size))
 
 	var entries []*TreeEntry
 	for {
 		modes, err := buf.ReadString(' ')
 		if err != nil {
 			if err == io.EOF {
+				if len(strings.TrimSpace(modes)) > 0 {
+					return n, fmt.Errorf("gitobj: trailing garbage in tree: %q", modes)
+				}
+				n += int64(len(modes))
 				break
 			}
 			return n, err
 		}
-		n += len(modes)
+		n += int64(len(modes))
 		modes = strings.TrimSuffix(modes, " ")
 
 		mode, _ := strconv.ParseInt(modes, 8, 32)
@@ -62,14 +92,14 @@ func (t *Tree) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err er
 		if err != nil {
 			return n, err
 		}
-		n += len(fname)
+		n += int64(len(fname))
 		fname = strings.TrimSuffix(fname, "\x00")
 
 		var sha [pack.MaxHashSize]byte
 		if _, err = io.ReadFull(buf, sha[:hashlen]); err != nil {
 			return n, err
 		}
-		n += hashlen
+		n += int64(hashlen)
 
 		entries = append(entries, &TreeEntry{
 			Name:     fname,
@@ -86,8 +116,13 @@ func (t *Tree) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err er
 // Encode encodes the tree's contents to the given io.Writer, "w". If there was
 // any error copying the tree's contents, that error will be returned.
 //
+// Entries are written in the order in which they appear in t.Entries. Git
+// requires them to be in subtree order (see: SubtreeOrder), so a tree whose
+// entries were added out of order should be sorted with Sort before it is
+// encoded, or it will not have the object ID that Git would give it.
+//
 // Otherwise, the number of bytes written will be returned.
-func (t *Tree) Encode(to io.Writer) (n int, err error) {
+func (t *Tree) Encode(to io.Writer) (n int64, err error) {
 	const entryTmpl = "%s %s\x00%s"
 
 	for _, entry := range t.Entries {
@@ -102,18 +137,104 @@ func (t *Tree) Encode(to io.Writer) (n int, err error) {
 			return n, err
 		}
 
-		n = n + ne
+		n = n + int64(ne)
 	}
 	return
 }
 
+// Sort sorts the tree's entries in place into subtree order, the order in which
+// Git requires them to be encoded (see: SubtreeOrder). Entries with the same
+// name keep their relative order.
+func (t *Tree) Sort() {
+	sort.Stable(SubtreeOrder(t.Entries))
+}
+
+// EntriesByName returns a copy of the tree's entries sorted in plain
+// lexicographic byte-order by name, without applying Git's rule that
+// subtrees sort as if their names ended in a "/".
+//
+// This ordering is meant for display purposes only. It may differ from the
+// order of t.Entries, and a tree encoded with entries in this order is not
+// guaranteed to be valid, or to have the same object ID as the original.
+func (t *Tree) EntriesByName() []*TreeEntry {
+	entries := make([]*TreeEntry, len(t.Entries))
+	copy(entries, t.Entries)
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
+
+// Lookup returns the entry of the tree with the given name, and true, or false
+// if there is none.
+//
+// Since Git sorts a subtree as if its name ended in a "/" (see: SubtreeOrder),
+// an entry is searched for in both of the places where it could be, so that,
+// for instance, a subtree "foo" is found even though a blob "foo.txt" sorts
+// between it and where a blob "foo" would be.
+//
+// Entries are found by binary search, in O(log n) time, if they are in subtree
+// order, as they are in every tree read from or written to the object database.
+// If no entry is found that way, the entries are scanned in case they are not
+// in order.
+func (t *Tree) Lookup(name string) (*TreeEntry, bool) {
+	for _, key := range []string{name + "\x00", name + "/"} {
+		i := sort.Search(len(t.Entries), func(i int) bool {
+			return lookupKey(t.Entries[i]) >= key
+		})
+		if i < len(t.Entries) && t.Entries[i].Name == name {
+			return t.Entries[i], true
+		}
+	}
+
+	for _, entry := range t.Entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// lookupKey returns the name of the entry as it is sorted in subtree order,
+// like SubtreeOrder.Name, but without panicking on entries of an unknown type.
+func lookupKey(entry *TreeEntry) string {
+	if entry.Filemode&sIFMT == sIFDIR {
+		return entry.Name + "/"
+	}
+	return entry.Name + "\x00"
+}
+
+// Filter returns a new tree holding only those of the tree's entries for which
+// "keep" returns true, in the same order. The new tree shares its entries with
+// the receiving tree, but not its slice of them, so neither tree is changed by
+// adding or removing entries of the other.
+func (t *Tree) Filter(keep func(*TreeEntry) bool) *Tree {
+	entries := make([]*TreeEntry, 0, len(t.Entries))
+	for _, entry := range t.Entries {
+		if keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return &Tree{Entries: entries}
+}
+
 // Merge performs a merge operation against the given set of `*TreeEntry`'s by
 // either replacing existing tree entries of the same name, or appending new
 // entries in sub-tree order.
 //
+// Entries are matched by name alone, so the given entries always win: one
+// replaces an existing entry of the same name even if one is a subtree and the
+// other is not. If several of the given entries have the same name, the last of
+// them is used, and if the tree itself has several entries with the same name,
+// only the first is kept. The merged tree therefore never has two entries with
+// the same name.
+//
 // It returns a copy of the tree, and performs the merge in O(n*log(n)) time.
 func (t *Tree) Merge(others ...*TreeEntry) *Tree {
 	unseen := make(map[string]*TreeEntry)
+	seen := make(map[string]struct{}, len(t.Entries))
 
 	// Build a cache of name to *TreeEntry.
 	for _, other := range others {
@@ -124,6 +245,11 @@ func (t *Tree) Merge(others ...*TreeEntry) *Tree {
 	// copying an existing entry, or replacing it with a new one.
 	entries := make([]*TreeEntry, 0, len(t.Entries))
 	for _, entry := range t.Entries {
+		if _, ok := seen[entry.Name]; ok {
+			continue
+		}
+		seen[entry.Name] = struct{}{}
+
 		if other, ok := unseen[entry.Name]; ok {
 			entries = append(entries, other)
 			delete(unseen, entry.Name)
@@ -160,6 +286,17 @@ func (t *Tree) Merge(others ...*TreeEntry) *Tree {
 	return &Tree{Entries: entries}
 }
 
+// Validate checks each of the tree's entries (see: TreeEntry.Validate), and
+// returns the error for the first which is invalid, or nil if all are valid.
+func (t *Tree) Validate() error {
+	for _, entry := range t.Entries {
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Equal returns whether the receiving and given trees are equal, or in other
 // words, whether they are represented by the same SHA-1 when saved to the
 // object database.
@@ -212,6 +349,30 @@ func (e *TreeEntry) Equal(other *TreeEntry) bool {
 	return true
 }
 
+// validFilemodes are the filemodes which Git permits a tree entry to have.
+var validFilemodes = map[int32]struct{}{
+	FilemodeDir:        {},
+	FilemodeRegular:    {},
+	FilemodeExecutable: {},
+	FilemodeSymlink:    {},
+	FilemodeSubmodule:  {},
+}
+
+// Validate checks that the entry has one of the filemodes which Git permits:
+// 040000 for a subtree, 0100644 or 0100755 for a regular or executable file,
+// 0120000 for a symbolic link, or 0160000 for a submodule. If it does not, an
+// error naming the entry and its filemode is returned.
+//
+// Encode does not check filemodes, so that trees written by older versions of
+// Git with other modes, such as 0100664, can still be read and rewritten
+// unchanged.
+func (e *TreeEntry) Validate() error {
+	if _, ok := validFilemodes[e.Filemode]; !ok {
+		return fmt.Errorf("gitobj: invalid filemode %06o for tree entry %q", e.Filemode, e.Name)
+	}
+	return nil
+}
+
 // Type is the type of entry (either blob: BlobObjectType, or a sub-tree:
 // TreeObjectType).
 func (e *TreeEntry) Type() ObjectType {
@@ -236,6 +397,30 @@ func (e *TreeEntry) IsLink() bool {
 	return e.Filemode & sIFMT == sIFLNK
 }
 
+// IsSymlink returns true if the given TreeEntry is a blob which represents a
+// symbolic link, as IsLink does.
+func (e *TreeEntry) IsSymlink() bool {
+	return e.IsLink()
+}
+
+// IsDir returns true if the given TreeEntry is a subtree.
+func (e *TreeEntry) IsDir() bool {
+	return e.Filemode&sIFMT == sIFDIR
+}
+
+// IsSubmodule returns true if the given TreeEntry is a submodule, whose object
+// ID is that of a commit.
+func (e *TreeEntry) IsSubmodule() bool {
+	return e.Filemode&sIFMT == sIFGITLINK
+}
+
+// IsExecutable returns true if the given TreeEntry is a regular file which is
+// executable by its owner, as Git considers a file executable. For files written
+// by Git, this is a filemode of 0100755.
+func (e *TreeEntry) IsExecutable() bool {
+	return e.Filemode&sIFMT == sIFREG && e.Filemode&0100 != 0
+}
+
 // SubtreeOrder is an implementation of sort.Interface that sorts a set of
 // `*TreeEntry`'s according to "subtree" order. This ordering is required to
 // write trees in a correct, readable format to the Git object database.
@@ -72,7 +72,7 @@ func TestTreeDecoding(t *testing.T) {
 	n, err := tree.Decode(sha1.New(), from, int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	require.Equal(t, 4, len(tree.Entries))
 	assert.Equal(t, &TreeEntry{
@@ -110,7 +110,7 @@ func TestTreeDecodingShaBoundary(t *testing.T) {
 	n, err := tree.Decode(sha1.New(), bufio.NewReaderSize(&from, flen-2), int64(flen))
 
 	assert.Nil(t, err)
-	assert.Equal(t, flen, n)
+	assert.Equal(t, int64(flen), n)
 
 	require.Len(t, tree.Entries, 1)
 	assert.Equal(t, &TreeEntry{
@@ -120,6 +120,60 @@ func TestTreeDecodingShaBoundary(t *testing.T) {
 	}, tree.Entries[0])
 }
 
+func TestTreeDecodingTrailingGarbage(t *testing.T) {
+	var from bytes.Buffer
+
+	fmt.Fprintf(&from, "%s %s\x00%s",
+		strconv.FormatInt(int64(0100644), 8),
+		"a.dat", []byte("aaaaaaaaaaaaaaaaaaaa"))
+	fmt.Fprintf(&from, "garbage")
+
+	tree := new(Tree)
+	_, err := tree.Decode(sha1.New(), &from, int64(from.Len()))
+
+	assert.EqualError(t, err, `gitobj: trailing garbage in tree: "garbage"`)
+}
+
+func TestTreeDecodingTrailingWhitespace(t *testing.T) {
+	var from bytes.Buffer
+
+	fmt.Fprintf(&from, "%s %s\x00%s",
+		strconv.FormatInt(int64(0100644), 8),
+		"a.dat", []byte("aaaaaaaaaaaaaaaaaaaa"))
+	fmt.Fprintf(&from, "\n")
+
+	flen := from.Len()
+
+	tree := new(Tree)
+	n, err := tree.Decode(sha1.New(), &from, int64(flen))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(flen), n)
+	assert.Len(t, tree.Entries, 1)
+}
+
+func TestTreeDecodingStopsAtSize(t *testing.T) {
+	var from bytes.Buffer
+
+	fmt.Fprintf(&from, "%s %s\x00%s",
+		strconv.FormatInt(int64(0100644), 8),
+		"a.dat", []byte("aaaaaaaaaaaaaaaaaaaa"))
+
+	flen := from.Len()
+
+	fmt.Fprintf(&from, "%s %s\x00%s",
+		strconv.FormatInt(int64(0100644), 8),
+		"b.dat", []byte("bbbbbbbbbbbbbbbbbbbb"))
+
+	tree := new(Tree)
+	n, err := tree.Decode(sha1.New(), &from, int64(flen))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(flen), n)
+	require.Len(t, tree.Entries, 1)
+	assert.Equal(t, "a.dat", tree.Entries[0].Name)
+}
+
 func TestTreeMergeReplaceElements(t *testing.T) {
 	e1 := &TreeEntry{Name: "a", Filemode: 0100644, Oid: []byte{0x1}}
 	e2 := &TreeEntry{Name: "b", Filemode: 0100644, Oid: []byte{0x2}}
@@ -164,6 +218,45 @@ func TestMergeInsertElementsInSubtreeOrder(t *testing.T) {
 	assert.True(t, bytes.Equal(t2.Entries[3].Oid, []byte{0x3}))
 }
 
+func TestTreeMergeCollidingBlobs(t *testing.T) {
+	e1 := &TreeEntry{Name: "README.md", Filemode: 0100644, Oid: []byte{0x1}}
+	e2 := &TreeEntry{Name: "README.md", Filemode: 0100644, Oid: []byte{0x2}}
+	e3 := &TreeEntry{Name: "README.md", Filemode: 0100755, Oid: []byte{0x3}}
+
+	t1 := &Tree{Entries: []*TreeEntry{e1}}
+	t2 := t1.Merge(e2, e3)
+
+	require.Len(t, t2.Entries, 1)
+	assert.Equal(t, e3, t2.Entries[0])
+}
+
+func TestTreeMergeCollidingBlobAndSubtree(t *testing.T) {
+	e1 := &TreeEntry{Name: "a", Filemode: 0100644, Oid: []byte{0x1}}
+	e2 := &TreeEntry{Name: "a.txt", Filemode: 0100644, Oid: []byte{0x2}}
+	e3 := &TreeEntry{Name: "a", Filemode: 040000, Oid: []byte{0x3}}
+
+	t1 := &Tree{Entries: []*TreeEntry{e1, e2}}
+	t2 := t1.Merge(e3)
+
+	require.Len(t, t2.Entries, 2)
+	assert.Equal(t, e2, t2.Entries[0])
+	assert.Equal(t, e3, t2.Entries[1])
+	assert.True(t, sort.IsSorted(SubtreeOrder(t2.Entries)))
+}
+
+func TestTreeMergeDuplicateEntries(t *testing.T) {
+	e1 := &TreeEntry{Name: "a", Filemode: 0100644, Oid: []byte{0x1}}
+	e2 := &TreeEntry{Name: "a", Filemode: 0100644, Oid: []byte{0x2}}
+	e3 := &TreeEntry{Name: "b", Filemode: 0100644, Oid: []byte{0x3}}
+
+	t1 := &Tree{Entries: []*TreeEntry{e1, e2}}
+	t2 := t1.Merge(e3)
+
+	require.Len(t, t2.Entries, 2)
+	assert.True(t, bytes.Equal(t2.Entries[0].Oid, []byte{0x1}))
+	assert.Equal(t, e3, t2.Entries[1])
+}
+
 type TreeEntryTypeTestCase struct {
 	Filemode int32
 	Expected ObjectType
@@ -355,3 +448,135 @@ func assertTreeEntry(t *testing.T, buf *bytes.Buffer,
 	assert.Nil(t, err)
 	assert.Equal(t, oid, sha[:])
 }
+
+func TestTreeEntriesByNameSortsAlphabetically(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 0100644, Name: "a-"}
+	e2 := &TreeEntry{Filemode: 040000, Name: "a"}
+	e3 := &TreeEntry{Filemode: 0100644, Name: "a="}
+
+	tree := &Tree{Entries: []*TreeEntry{e1, e2, e3}}
+
+	entries := tree.EntriesByName()
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, "a", entries[0].Name)
+	assert.Equal(t, "a-", entries[1].Name)
+	assert.Equal(t, "a=", entries[2].Name)
+}
+
+func TestTreeEntriesByNameDoesNotModifyEntries(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 0100644, Name: "a-"}
+	e2 := &TreeEntry{Filemode: 040000, Name: "a"}
+
+	tree := &Tree{Entries: []*TreeEntry{e1, e2}}
+	tree.EntriesByName()
+
+	require.Len(t, tree.Entries, 2)
+	assert.Equal(t, "a-", tree.Entries[0].Name)
+	assert.Equal(t, "a", tree.Entries[1].Name)
+}
+
+func TestTreeLookupDistinguishesSubtreesFromBlobs(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 0100644, Name: "foo.txt"}
+	e2 := &TreeEntry{Filemode: 040000, Name: "foo"}
+	e3 := &TreeEntry{Filemode: 0100644, Name: "foo0"}
+
+	tree := &Tree{Entries: []*TreeEntry{e1, e2, e3}}
+	require.True(t, sort.IsSorted(SubtreeOrder(tree.Entries)))
+
+	for _, e := range tree.Entries {
+		found, ok := tree.Lookup(e.Name)
+		assert.True(t, ok)
+		assert.Equal(t, e, found)
+	}
+
+	found, ok := tree.Lookup("fo")
+	assert.False(t, ok)
+	assert.Nil(t, found)
+}
+
+func TestTreeLookupUnsortedEntries(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 0100644, Name: "b"}
+	e2 := &TreeEntry{Filemode: 0100644, Name: "a"}
+
+	tree := &Tree{Entries: []*TreeEntry{e1, e2}}
+
+	found, ok := tree.Lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, e2, found)
+
+	_, ok = tree.Lookup("c")
+	assert.False(t, ok)
+}
+
+func TestTreeSortUsesSubtreeOrder(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 040000, Name: "a"}
+	e2 := &TreeEntry{Filemode: 0100644, Name: "a.txt"}
+	e3 := &TreeEntry{Filemode: 0100644, Name: "a0"}
+
+	tree := &Tree{Entries: []*TreeEntry{e3, e1, e2}}
+	tree.Sort()
+
+	assert.Equal(t, []*TreeEntry{e2, e1, e3}, tree.Entries)
+}
+
+func TestTreeEntryValidate(t *testing.T) {
+	for _, mode := range []int32{040000, 0100644, 0100755, 0120000, 0160000} {
+		e := &TreeEntry{Name: "a", Filemode: mode}
+		assert.NoError(t, e.Validate(), "mode %o", mode)
+	}
+
+	for _, mode := range []int32{0, 0644, 040755, 0100664, 0100600} {
+		e := &TreeEntry{Name: "a", Filemode: mode}
+		assert.Error(t, e.Validate(), "mode %o", mode)
+	}
+}
+
+func TestTreeValidateReportsInvalidEntry(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Filemode: 0100644},
+		{Name: "dir", Filemode: 0644},
+	}}
+
+	assert.EqualError(t, tree.Validate(), `gitobj: invalid filemode 000644 for tree entry "dir"`)
+}
+
+func TestTreeEntryFilemodePredicates(t *testing.T) {
+	for _, test := range []struct {
+		Mode                                int32
+		Dir, Symlink, Submodule, Executable bool
+	}{
+		{Mode: FilemodeDir, Dir: true},
+		{Mode: FilemodeRegular},
+		{Mode: FilemodeExecutable, Executable: true},
+		{Mode: FilemodeSymlink, Symlink: true},
+		{Mode: FilemodeSubmodule, Submodule: true},
+		{Mode: 0100664},
+	} {
+		e := &TreeEntry{Name: "a", Filemode: test.Mode}
+
+		assert.Equal(t, test.Dir, e.IsDir(), "mode %o", test.Mode)
+		assert.Equal(t, test.Symlink, e.IsSymlink(), "mode %o", test.Mode)
+		assert.Equal(t, test.Submodule, e.IsSubmodule(), "mode %o", test.Mode)
+		assert.Equal(t, test.Executable, e.IsExecutable(), "mode %o", test.Mode)
+	}
+}
+
+func TestTreeFilter(t *testing.T) {
+	e1 := &TreeEntry{Filemode: 0100644, Name: "a.txt"}
+	e2 := &TreeEntry{Filemode: 0160000, Name: "module"}
+	e3 := &TreeEntry{Filemode: 0100644, Name: "z.txt"}
+
+	tree := &Tree{Entries: []*TreeEntry{e1, e2, e3}}
+
+	filtered := tree.Filter(func(e *TreeEntry) bool {
+		return !e.IsSubmodule()
+	})
+
+	require.Len(t, filtered.Entries, 2)
+	assert.True(t, e1 == filtered.Entries[0])
+	assert.True(t, e3 == filtered.Entries[1])
+
+	filtered.Entries[0] = e2
+	assert.Equal(t, []*TreeEntry{e1, e2, e3}, tree.Entries)
+}
@@ -0,0 +1,57 @@
+package gitobj
+
+import "fmt"
+
+// SkipDir may be returned by the function passed to Tree.Walk to skip the
+// subtree which it was called with, as with filepath.SkipDir. It is not
+// returned as an error by the walk.
+//
+// If it is returned for an entry which is not a subtree, the remaining entries
+// of the tree containing that entry are skipped instead.
+var SkipDir = fmt.Errorf("gitobj: skip this directory")
+
+// Walk calls "fn" for each entry of the tree, and for each entry of every tree
+// beneath it, in tree order, with the entry's slash-separated path relative to
+// the tree. A subtree is passed to "fn" before any of its entries. The entries
+// of submodules are not walked.
+//
+// Each subtree is only read from the database "odb" when the walk reaches it,
+// and is not kept once the walk has left it, so the whole tree is never held in
+// memory at once.
+//
+// If "fn" returns SkipDir for a subtree, the walk continues without reading
+// it (see: SkipDir). If "fn" returns any other error, the walk stops and that
+// error is returned. If any subtree could not be read, its error is returned
+// instead.
+func (t *Tree) Walk(odb *ObjectDatabase, fn func(path string, entry *TreeEntry) error) error {
+	return t.walk(odb, "", fn)
+}
+
+// walk walks the tree as Walk does, giving each path beneath the tree the prefix
+// "prefix".
+func (t *Tree) walk(odb *ObjectDatabase, prefix string, fn func(path string, entry *TreeEntry) error) error {
+	for _, entry := range t.Entries {
+		path := prefix + entry.Name
+		isTree := entry.Type() == TreeObjectType
+
+		if err := fn(path, entry); err == SkipDir {
+			if isTree {
+				continue
+			}
+			return nil
+		} else if err != nil {
+			return err
+		} else if !isTree {
+			continue
+		}
+
+		subtree, err := odb.Tree(entry.Oid)
+		if err != nil {
+			return err
+		}
+		if err = subtree.walk(odb, path+"/", fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
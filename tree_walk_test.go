@@ -0,0 +1,144 @@
+package gitobj
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeWalk(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt":         {Oid: blob, Filemode: 0100644},
+		"dir/b.txt":     {Oid: blob, Filemode: 0100644},
+		"dir/sub/c.txt": {Oid: blob, Filemode: 0100755},
+		"dir.txt":       {Oid: blob, Filemode: 0100644},
+		"module":        {Oid: blob, Filemode: 0160000},
+	})
+	require.NoError(t, err)
+
+	tree, err := odb.Tree(root)
+	require.NoError(t, err)
+
+	var paths []string
+	err = tree.Walk(odb, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"a.txt",
+		"dir.txt",
+		"dir",
+		"dir/b.txt",
+		"dir/sub",
+		"dir/sub/c.txt",
+		"module",
+	}, paths)
+}
+
+func TestTreeWalkStopsOnError(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a/b.txt": {Oid: blob, Filemode: 0100644},
+		"a/c.txt": {Oid: blob, Filemode: 0100644},
+		"d.txt":   {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	tree, err := odb.Tree(root)
+	require.NoError(t, err)
+
+	stop := errors.New("stop")
+
+	var paths []string
+	err = tree.Walk(odb, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "a/b.txt" {
+			return stop
+		}
+		return nil
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []string{"a", "a/b.txt"}, paths)
+}
+
+func TestTreeWalkMissingSubtree(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "missing", Oid: make([]byte, 20), Filemode: 040000},
+	}}
+
+	err := tree.Walk(odb, func(path string, entry *TreeEntry) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestTreeWalkSkipDir(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a.txt":   {Oid: blob, Filemode: 0100644},
+		"b/c.txt": {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	tree, err := odb.Tree(root)
+	require.NoError(t, err)
+
+	// The subtree "missing" is not in the database, so the walk would
+	// fail if it were read.
+	tree.Entries = append([]*TreeEntry{
+		{Name: "missing", Oid: make([]byte, 20), Filemode: 040000},
+	}, tree.Entries...)
+
+	var paths []string
+	err = tree.Walk(odb, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "missing" {
+			return SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"missing", "a.txt", "b", "b/c.txt"}, paths)
+}
+
+func TestTreeWalkSkipDirOnBlobSkipsSiblings(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+	root, err := odb.writeFiles(map[string]*TreeEntry{
+		"a/b.txt": {Oid: blob, Filemode: 0100644},
+		"a/c.txt": {Oid: blob, Filemode: 0100644},
+		"d.txt":   {Oid: blob, Filemode: 0100644},
+	})
+	require.NoError(t, err)
+
+	tree, err := odb.Tree(root)
+	require.NoError(t, err)
+
+	var paths []string
+	err = tree.Walk(odb, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "a/b.txt" {
+			return SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "a/b.txt", "d.txt"}, paths)
+}
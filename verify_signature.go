@@ -0,0 +1,49 @@
+package gitobj
+
+import "fmt"
+
+// Verifier verifies detached signatures, such as those of signed commits and
+// tags, using whatever keys and cryptography it is configured with, so that
+// none need be built into this package.
+type Verifier interface {
+	// Verify checks that "sig" is a valid signature of "payload", and
+	// returns a description of the signer, such as their name and email
+	// address, or the fingerprint of their key. If the signature is not
+	// valid, or could not be checked, an error is returned instead.
+	Verify(payload []byte, sig string) (signer string, err error)
+}
+
+// VerifySignature verifies the signature of the commit or tag named "sha" with
+// the given Verifier, and returns the signer that it identifies. The signature
+// and signed payload of a commit are given by Commit.Signature, and those of a
+// tag by Tag.Signature.
+//
+// If the object could not be read, is not a commit or tag, or is not signed, or
+// the Verifier rejects its signature, an error is returned instead.
+func (o *ObjectDatabase) VerifySignature(sha []byte, v Verifier) (string, error) {
+	obj, err := o.Object(sha)
+	if err != nil {
+		return "", err
+	}
+
+	var sig string
+	var payload []byte
+	var ok bool
+
+	switch obj := obj.(type) {
+	case *Commit:
+		sig, payload, ok = obj.Signature()
+	case *Tag:
+		sig, payload, ok = obj.Signature()
+	case *Blob:
+		obj.Close()
+		return "", fmt.Errorf("gitobj: cannot verify signature of %s %x", obj.Type(), sha)
+	default:
+		return "", fmt.Errorf("gitobj: cannot verify signature of %s %x", obj.Type(), sha)
+	}
+
+	if !ok {
+		return "", fmt.Errorf("gitobj: %s %x is not signed", obj.Type(), sha)
+	}
+	return v.Verify(payload, sig)
+}
@@ -0,0 +1,107 @@
+package gitobj
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testVerifier is a Verifier which records what it was given, and accepts any
+// signature but "bad".
+type testVerifier struct {
+	payload []byte
+	sig     string
+}
+
+func (v *testVerifier) Verify(payload []byte, sig string) (string, error) {
+	v.payload, v.sig = payload, sig
+	if sig == "bad\n" {
+		return "", errors.New("bad signature")
+	}
+	return "Jane Doe <jane@example.com>", nil
+}
+
+func TestVerifySignatureOfCommit(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    make([]byte, 20),
+		Message:   "Signed commit",
+	}
+	commit.AddSignature("-----BEGIN PGP SIGNATURE-----\n\nsig\n-----END PGP SIGNATURE-----\n")
+
+	sha, err := odb.WriteCommit(commit)
+	require.NoError(t, err)
+
+	v := new(testVerifier)
+	signer, err := odb.VerifySignature(sha, v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Jane Doe <jane@example.com>", signer)
+	assert.Equal(t, "-----BEGIN PGP SIGNATURE-----\n\nsig\n-----END PGP SIGNATURE-----\n", v.sig)
+	assert.Equal(t, "tree 0000000000000000000000000000000000000000\n"+
+		"author Jane Doe <jane@example.com> 1257894000 +0000\n"+
+		"committer Jane Doe <jane@example.com> 1257894000 +0000\n"+
+		"\n"+
+		"Signed commit\n", string(v.payload))
+}
+
+func TestVerifySignatureOfTag(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	sha, err := odb.WriteTag(&Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "Release\n-----BEGIN SSH SIGNATURE-----\nsig\n-----END SSH SIGNATURE-----\n",
+	})
+	require.NoError(t, err)
+
+	v := new(testVerifier)
+	_, err = odb.VerifySignature(sha, v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "-----BEGIN SSH SIGNATURE-----\nsig\n-----END SSH SIGNATURE-----\n", v.sig)
+	assert.Equal(t, "object 0000000000000000000000000000000000000000\n"+
+		"type commit\n"+
+		"tag v1.0.0\n"+
+		"tagger Jane Doe <jane@example.com> 1257894000 +0000\n"+
+		"\n"+
+		"Release\n", string(v.payload))
+}
+
+func TestVerifySignatureRejected(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    make([]byte, 20),
+	}
+	commit.AddSignature("bad")
+
+	sha, err := odb.WriteCommit(commit)
+	require.NoError(t, err)
+
+	_, err = odb.VerifySignature(sha, new(testVerifier))
+	assert.EqualError(t, err, "bad signature")
+}
+
+func TestVerifySignatureUnsigned(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := writeTestCommit(t, odb, make([]byte, 20), "Unsigned commit")
+	blob := writeTestBlob(t, odb, "Hello, world!\n")
+
+	_, err := odb.VerifySignature(commit, new(testVerifier))
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: commit %x is not signed", commit))
+
+	_, err = odb.VerifySignature(blob, new(testVerifier))
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: cannot verify signature of blob %x", blob))
+}
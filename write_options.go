@@ -0,0 +1,114 @@
+package gitobj
+
+import "bytes"
+
+// WriteOption is an option which modifies how an object is written to the
+// object database. Options which do not apply to the type of object being
+// written are ignored.
+type WriteOption func(*writeOptions)
+
+// writeOptions holds the set of options given to a write.
+type writeOptions struct {
+	dedupParents   ParentDedup
+	keepSignatures bool
+	validate       bool
+	validateTag    bool
+}
+
+// newWriteOptions returns the writeOptions resulting from applying all of the
+// given setters in order.
+func newWriteOptions(setters ...WriteOption) *writeOptions {
+	args := new(writeOptions)
+	for _, setter := range setters {
+		setter(args)
+	}
+	return args
+}
+
+// ParentDedup specifies which duplicate parents, if any, are removed from a
+// commit before it is written.
+type ParentDedup uint8
+
+const (
+	// DedupNone leaves a commit's parents unchanged. It is the default,
+	// and preserves a byte-for-byte round trip of existing commits.
+	DedupNone ParentDedup = iota
+	// DedupConsecutive removes parents which are identical to the parent
+	// immediately preceding them.
+	DedupConsecutive
+	// DedupAll removes all but the first occurrence of each parent.
+	DedupAll
+)
+
+// DeduplicateParents is a WriteOption which removes duplicate parents from a
+// commit before it is written, as specified by "mode". Since Git normally
+// rejects commits with duplicate parents, this is useful for repairing commits
+// produced by tools which list a parent more than once.
+//
+// Removing parents changes the object ID of the commit. The *Commit given to
+// WriteCommit is not modified.
+func DeduplicateParents(mode ParentDedup) WriteOption {
+	return func(args *writeOptions) {
+		args.dedupParents = mode
+	}
+}
+
+// KeepSignatures is a WriteOption which specifies whether the signatures of a
+// commit (its "gpgsig" and "gpgsig-sha256" headers) are kept when the commit is
+// changed before it is written, for instance by DeduplicateParents or by the
+// transform given to RewriteCommit.
+//
+// Since a signature covers the whole of the commit, it no longer matches once
+// the commit is changed, and so by default it is dropped. Commits which are
+// written unchanged always keep their signatures.
+func KeepSignatures(keep bool) WriteOption {
+	return func(args *writeOptions) {
+		args.keepSignatures = keep
+	}
+}
+
+// ValidateCommit is a WriteOption which specifies whether a commit is checked
+// with Commit.Validate before it is written, in which case object IDs must also
+// be of the length used by the database. If the commit is invalid, it is not
+// written, and the *InvalidCommitError is returned.
+func ValidateCommit(validate bool) WriteOption {
+	return func(args *writeOptions) {
+		args.validate = validate
+	}
+}
+
+// ValidateTagTarget is a WriteOption which specifies whether the object named
+// by a tag is read before the tag is written, to check that the tag's
+// ObjectType is the object's actual type. If the object does not exist, or is
+// of another type, the tag is not written, and an error is returned.
+//
+// By default, the object is not read, so that a tag may be written before the
+// object which it names.
+func ValidateTagTarget(validate bool) WriteOption {
+	return func(args *writeOptions) {
+		args.validateTag = validate
+	}
+}
+
+// dedupParents returns a new set of parents with duplicates removed as
+// specified by "mode".
+func dedupParents(parents [][]byte, mode ParentDedup) [][]byte {
+	deduped := make([][]byte, 0, len(parents))
+	seen := make(map[string]struct{}, len(parents))
+
+	for i, parent := range parents {
+		switch mode {
+		case DedupConsecutive:
+			if i > 0 && bytes.Equal(parent, parents[i-1]) {
+				continue
+			}
+		case DedupAll:
+			if _, ok := seen[string(parent)]; ok {
+				continue
+			}
+			seen[string(parent)] = struct{}{}
+		}
+		deduped = append(deduped, parent)
+	}
+	return deduped
+}
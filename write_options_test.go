@@ -0,0 +1,125 @@
+package gitobj
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupParents(t *testing.T) {
+	p1 := []byte("aaaaaaaaaaaaaaaaaaaa")
+	p2 := []byte("bbbbbbbbbbbbbbbbbbbb")
+
+	parents := [][]byte{p1, p1, p2, p1}
+
+	assert.Equal(t, parents, dedupParents(parents, DedupNone))
+	assert.Equal(t, [][]byte{p1, p2, p1}, dedupParents(parents, DedupConsecutive))
+	assert.Equal(t, [][]byte{p1, p2}, dedupParents(parents, DedupAll))
+}
+
+func TestWriteCommitDeduplicatesParents(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	p1 := writeTestCommit(t, odb, tree, "first")
+	p2 := writeTestCommit(t, odb, tree, "second")
+
+	duplicated := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		ParentIDs: [][]byte{p1, p1, p2},
+		TreeID:    tree,
+		Message:   "merge",
+	}
+	deduplicated := duplicated.withParents([][]byte{p1, p2})
+
+	unchanged, err := odb.WriteCommit(duplicated)
+	require.NoError(t, err)
+
+	expected, err := odb.WriteCommit(deduplicated)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, unchanged)
+
+	sha, err := odb.WriteCommit(duplicated, DeduplicateParents(DedupAll))
+	require.NoError(t, err)
+	assert.Equal(t, expected, sha)
+
+	assert.Len(t, duplicated.ParentIDs, 3)
+
+	commit, err := odb.Commit(unchanged)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{p1, p1, p2}, commit.ParentIDs)
+}
+
+func TestWriteCommitValidates(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	commit := &Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 +0000",
+		Committer: "Jane Doe <jane@example.com> 1257894000 +0000",
+		TreeID:    make([]byte, 32),
+		Message:   "commit",
+	}
+
+	sha, err := odb.WriteCommit(commit, ValidateCommit(true))
+
+	assert.EqualError(t, err, "gitobj: invalid commit: tree ID has invalid length 32")
+	assert.Nil(t, sha)
+
+	commit.TreeID = make([]byte, 20)
+
+	sha, err = odb.WriteCommit(commit, ValidateCommit(true))
+
+	assert.NoError(t, err)
+	assert.NotNil(t, sha)
+}
+
+func TestWriteTagValidatesTarget(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tree, err := odb.WriteTree(&Tree{})
+	require.NoError(t, err)
+	commit := writeTestCommit(t, odb, tree, "first")
+
+	tag := &Tag{
+		Object:     commit,
+		ObjectType: BlobObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "Release v1.0.0",
+	}
+
+	sha, err := odb.WriteTag(tag, ValidateTagTarget(true))
+	assert.EqualError(t, err, fmt.Sprintf(
+		`gitobj: tag "v1.0.0" has object type blob, but names commit %x`, commit))
+	assert.Nil(t, sha)
+
+	tag.ObjectType = CommitObjectType
+
+	sha, err = odb.WriteTag(tag, ValidateTagTarget(true))
+	assert.NoError(t, err)
+	assert.NotNil(t, sha)
+}
+
+func TestWriteTagValidatesMissingTarget(t *testing.T) {
+	odb := newTestDatabase(t)
+
+	tag := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1257894000 +0000",
+		Message:    "Release v1.0.0",
+	}
+
+	_, err := odb.WriteTag(tag, ValidateTagTarget(true))
+	assert.Error(t, err)
+
+	sha, err := odb.WriteTag(tag)
+	assert.NoError(t, err)
+	assert.NotNil(t, sha)
+}